@@ -21,6 +21,13 @@ type binaryMatcher struct {
 	failures  []error
 }
 
+type conditionalMatcher struct {
+	condition types.GomegaMatcher
+	thenNode  types.GomegaMatcher
+	elseNode  types.GomegaMatcher
+	failures  []error
+}
+
 type assignMatcher struct {
 	left     types.GomegaMatcher
 	right    types.GomegaMatcher
@@ -33,6 +40,12 @@ type unaryMatcher struct {
 	failures  []error
 }
 
+type postfixMatcher struct {
+	operation ast.Operation
+	prev      types.GomegaMatcher
+	failures  []error
+}
+
 type numericMatcher struct {
 	value    interface{}
 	failures []error
@@ -49,6 +62,18 @@ type functionMatcher struct {
 	failures []error
 }
 
+type blockMatcher struct {
+	statements []types.GomegaMatcher
+	failures   []error
+}
+
+type functionDefMatcher struct {
+	name     interface{}
+	params   []string
+	body     types.GomegaMatcher
+	failures []error
+}
+
 func MatchBinaryNode(operation ast.Operation, left types.GomegaMatcher, right types.GomegaMatcher) types.GomegaMatcher {
 	return &binaryMatcher{
 		operation: operation,
@@ -56,6 +81,21 @@ func MatchBinaryNode(operation ast.Operation, left types.GomegaMatcher, right ty
 		right:     right,
 	}
 }
+
+// MatchImplicitMultiplication matches multiplication inserted by the parser between adjacent operands
+func MatchImplicitMultiplication(left types.GomegaMatcher, right types.GomegaMatcher) types.GomegaMatcher {
+	return gomega.And(
+		MatchBinaryNode(ast.Multiplication, left, right),
+		gomega.WithTransform(func(n *ast.BinaryNode) bool { return n.Implicit() }, gomega.BeTrue()),
+	)
+}
+func MatchConditionalNode(condition, thenNode, elseNode types.GomegaMatcher) types.GomegaMatcher {
+	return &conditionalMatcher{
+		condition: condition,
+		thenNode:  thenNode,
+		elseNode:  elseNode,
+	}
+}
 func MatchAssignNode(left types.GomegaMatcher, right types.GomegaMatcher) types.GomegaMatcher {
 	return &assignMatcher{
 		left:  left,
@@ -68,6 +108,12 @@ func MatchUnaryNode(operation ast.Operation, node types.GomegaMatcher) types.Gom
 		next:      node,
 	}
 }
+func MatchPostfixNode(operation ast.Operation, node types.GomegaMatcher) types.GomegaMatcher {
+	return &postfixMatcher{
+		operation: operation,
+		prev:      node,
+	}
+}
 
 // MatchNumericNode expects types.GomegaMatcher or passed value will be compared with gomega.Equal
 func MatchNumericNode(value interface{}) types.GomegaMatcher {
@@ -90,6 +136,68 @@ func MatchFunctionNode(name interface{}, params ...types.GomegaMatcher) types.Go
 	}
 }
 
+// MatchFunctionDefNode expects types.GomegaMatcher or passed name will be compared with gomega.Equal
+func MatchFunctionDefNode(name interface{}, params []string, body types.GomegaMatcher) types.GomegaMatcher {
+	return &functionDefMatcher{
+		name:   name,
+		params: params,
+		body:   body,
+	}
+}
+
+func MatchBlockNode(statements ...types.GomegaMatcher) types.GomegaMatcher {
+	return &blockMatcher{
+		statements: statements,
+	}
+}
+
+// MatchErrorNode matches placeholder of the part of the input which could not be parsed
+func MatchErrorNode() types.GomegaMatcher {
+	return gomega.BeAssignableToTypeOf(&ast.ErrorNode{})
+}
+
+// MatchTree matches tree with the same structure as the expected one, tokens and literals are ignored.
+// Binary nodes must keep implicit multiplication and relative percent, see ast.BinaryNode.Implicit
+func MatchTree(expected ast.Node) types.GomegaMatcher {
+	switch n := expected.(type) {
+	case *ast.NumericNode:
+		return MatchNumericNode(n.Value())
+	case *ast.VariableNode:
+		return MatchVariableNode(n.Name())
+	case *ast.UnaryNode:
+		return MatchUnaryNode(n.Operator(), MatchTree(n.Next()))
+	case *ast.PostfixNode:
+		return MatchPostfixNode(n.Operator(), MatchTree(n.Prev()))
+	case *ast.BinaryNode:
+		return gomega.And(
+			MatchBinaryNode(n.Operator(), MatchTree(n.Left()), MatchTree(n.Right())),
+			gomega.WithTransform(func(b *ast.BinaryNode) bool { return b.Implicit() }, gomega.Equal(n.Implicit())),
+			gomega.WithTransform(
+				func(b *ast.BinaryNode) bool { return b.RelativePercent() }, gomega.Equal(n.RelativePercent()),
+			),
+		)
+	case *ast.ConditionalNode:
+		return MatchConditionalNode(MatchTree(n.Condition()), MatchTree(n.Then()), MatchTree(n.Else()))
+	case *ast.AssignNode:
+		return MatchAssignNode(MatchVariableNode(n.Left().Name()), MatchTree(n.Right()))
+	case *ast.FunctionNode:
+		return MatchFunctionNode(n.Name(), matchTrees(n.Params())...)
+	case *ast.FunctionDefNode:
+		return MatchFunctionDefNode(n.Name(), n.ParamNames(), MatchTree(n.Body()))
+	case *ast.BlockNode:
+		return MatchBlockNode(matchTrees(n.Statements())...)
+	}
+	return MatchErrorNode()
+}
+
+func matchTrees(nodes []ast.Node) []types.GomegaMatcher {
+	matchers := make([]types.GomegaMatcher, 0, len(nodes))
+	for _, n := range nodes {
+		matchers = append(matchers, MatchTree(n))
+	}
+	return matchers
+}
+
 func matchNode(matcher types.GomegaMatcher, current interface{}, nestStr string, failures []error) []error {
 	m, err := matcher.Match(current)
 	if err != nil {
@@ -144,6 +252,26 @@ func (matcher *binaryMatcher) NegatedFailureMessage(actual interface{}) (message
 	return fmt.Sprintf("not to match node %s", format.Object(actual, 0))
 }
 
+func (matcher *conditionalMatcher) Match(actual interface{}) (success bool, err error) {
+	if node, ok := actual.(*ast.ConditionalNode); ok {
+		matcher.failures = matchNode(matcher.condition, node.Condition(), " -> Condition", matcher.failures)
+		matcher.failures = matchNode(matcher.thenNode, node.Then(), " -> Then", matcher.failures)
+		matcher.failures = matchNode(matcher.elseNode, node.Else(), " -> Else", matcher.failures)
+
+		return len(matcher.failures) == 0, nil
+	}
+	return false, fmt.Errorf(
+		"matcher MatchConditionalNode expects a `*ast.ConditionalNode` Got:\n%s", format.Object(actual, 1))
+}
+
+func (matcher *conditionalMatcher) FailureMessage(actual interface{}) (message string) {
+	return formatFailureMessage(matcher.failures, actual)
+}
+
+func (matcher *conditionalMatcher) NegatedFailureMessage(actual interface{}) (message string) {
+	return fmt.Sprintf("not to match node %s", format.Object(actual, 0))
+}
+
 func (matcher *assignMatcher) Match(actual interface{}) (success bool, err error) {
 	if node, ok := actual.(*ast.AssignNode); ok {
 		matcher.failures = matchNode(matcher.left, node.Left(), " -> Left", matcher.failures)
@@ -180,6 +308,24 @@ func (matcher *unaryMatcher) NegatedFailureMessage(actual interface{}) (message
 	return fmt.Sprintf("not to match node %s", format.Object(actual, 0))
 }
 
+func (matcher *postfixMatcher) Match(actual interface{}) (success bool, err error) {
+	if node, ok := actual.(*ast.PostfixNode); ok {
+		matcher.failures = matchOperation(node.Operator(), matcher.operation, matcher.failures)
+		matcher.failures = matchNode(matcher.prev, node.Prev(), " -> Prev", matcher.failures)
+
+		return len(matcher.failures) == 0, nil
+	}
+	return false, fmt.Errorf("matcher MatchPostfixNode expects a `*ast.PostfixNode` Got:\n%s", format.Object(actual, 1))
+}
+
+func (matcher *postfixMatcher) FailureMessage(actual interface{}) (message string) {
+	return formatFailureMessage(matcher.failures, actual)
+}
+
+func (matcher *postfixMatcher) NegatedFailureMessage(actual interface{}) (message string) {
+	return fmt.Sprintf("not to match node %s", format.Object(actual, 0))
+}
+
 func (matcher *numericMatcher) Match(actual interface{}) (success bool, err error) {
 	if val, ok := actual.(*ast.NumericNode); ok {
 		var valMatcher types.GomegaMatcher
@@ -267,3 +413,112 @@ func (matcher *functionMatcher) FailureMessage(actual interface{}) (message stri
 func (matcher *functionMatcher) NegatedFailureMessage(actual interface{}) (message string) {
 	return fmt.Sprintf("not to match value %s", format.Object(actual, 0))
 }
+
+func (matcher *blockMatcher) Match(actual interface{}) (success bool, err error) {
+	if node, ok := actual.(*ast.BlockNode); ok {
+		statements := node.Statements()
+		if len(matcher.statements) != len(statements) {
+			matcher.failures = append(
+				matcher.failures,
+				fmt.Errorf("block expecting %d statements, got %d", len(matcher.statements), len(statements)),
+			)
+		} else {
+			for i, m := range matcher.statements {
+				matcher.failures = matchNode(m, statements[i], fmt.Sprintf(" -> %d. Statement", i), matcher.failures)
+			}
+		}
+
+		return len(matcher.failures) == 0, nil
+	}
+	return false, fmt.Errorf(
+		"matcher MatchBlockNode expects a `*ast.BlockNode` Got:\n%s", format.Object(actual, 1))
+}
+
+func (matcher *blockMatcher) FailureMessage(actual interface{}) (message string) {
+	return formatFailureMessage(matcher.failures, actual)
+}
+
+func (matcher *blockMatcher) NegatedFailureMessage(actual interface{}) (message string) {
+	return fmt.Sprintf("not to match value %s", format.Object(actual, 0))
+}
+
+func (matcher *functionDefMatcher) Match(actual interface{}) (success bool, err error) {
+	if node, ok := actual.(*ast.FunctionDefNode); ok {
+		var valMatcher types.GomegaMatcher
+		if vm, ok := matcher.name.(types.GomegaMatcher); ok {
+			valMatcher = vm
+		} else {
+			valMatcher = gomega.Equal(matcher.name)
+		}
+		matcher.failures = matchNode(valMatcher, node.Name(), " -> Name", matcher.failures)
+		paramsMatcher := gomega.BeEmpty()
+		if len(matcher.params) > 0 {
+			paramsMatcher = gomega.Equal(matcher.params)
+		}
+		matcher.failures = matchNode(paramsMatcher, node.ParamNames(), " -> Params", matcher.failures)
+		matcher.failures = matchNode(matcher.body, node.Body(), " -> Body", matcher.failures)
+
+		return len(matcher.failures) == 0, nil
+	}
+	return false, fmt.Errorf(
+		"matcher MatchFunctionDefNode expects a `*ast.FunctionDefNode` Got:\n%s", format.Object(actual, 1))
+}
+
+func (matcher *functionDefMatcher) FailureMessage(actual interface{}) (message string) {
+	return formatFailureMessage(matcher.failures, actual)
+}
+
+func (matcher *functionDefMatcher) NegatedFailureMessage(actual interface{}) (message string) {
+	return fmt.Sprintf("not to match value %s", format.Object(actual, 0))
+}
+
+type jsonRoundTripMatcher struct {
+	encoded []byte
+	decoded []byte
+	// tree is set when the decoded tree does not match the original one, see MatchTree
+	tree        types.GomegaMatcher
+	decodedTree ast.Node
+}
+
+// SurviveJSONRoundTrip checks that the tree encoded by ast.Encode is decoded back into the same tree,
+// including tokens. Decoded tree must match the original one by MatchTree, then it is encoded again
+// and both JSONs are compared.
+func SurviveJSONRoundTrip() types.GomegaMatcher {
+	return &jsonRoundTripMatcher{}
+}
+
+func (matcher *jsonRoundTripMatcher) Match(actual interface{}) (success bool, err error) {
+	node, ok := actual.(ast.Node)
+	if !ok {
+		return false, fmt.Errorf(
+			"matcher SurviveJSONRoundTrip expects an `ast.Node` Got:\n%s", format.Object(actual, 1))
+	}
+	if matcher.encoded, err = ast.Encode(node); err != nil {
+		return false, err
+	}
+	decoded, err := ast.Decode(matcher.encoded)
+	if err != nil {
+		return false, err
+	}
+	treeMatcher := MatchTree(node)
+	if ok, err := treeMatcher.Match(decoded); err != nil || !ok {
+		matcher.tree, matcher.decodedTree = treeMatcher, decoded
+		return false, err
+	}
+	if matcher.decoded, err = ast.Encode(decoded); err != nil {
+		return false, err
+	}
+	return string(matcher.encoded) == string(matcher.decoded), nil
+}
+
+func (matcher *jsonRoundTripMatcher) FailureMessage(actual interface{}) (message string) {
+	if matcher.tree != nil {
+		return fmt.Sprintf("Expected tree decoded from\n%s\nto match the original tree\n%s",
+			matcher.encoded, matcher.tree.FailureMessage(matcher.decodedTree))
+	}
+	return fmt.Sprintf("Expected tree to be decoded from\n%s\nbut got\n%s", matcher.encoded, matcher.decoded)
+}
+
+func (matcher *jsonRoundTripMatcher) NegatedFailureMessage(actual interface{}) (message string) {
+	return fmt.Sprintf("not to survive JSON round trip %s", format.Object(actual, 0))
+}
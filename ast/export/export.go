@@ -0,0 +1,172 @@
+// Package export renders AST into formats for documents, like LaTeX or presentation MathML.
+// Parentheses follow conventional mathematical notation, not parser priorities, as the output is read by people.
+package export
+
+import (
+	"strings"
+
+	"github.com/arxeiss/go-expression-calculator/ast"
+)
+
+type operandSide uint8
+
+const (
+	leftOperand operandSide = iota
+	rightOperand
+)
+
+// Precedence levels of rendered nodes. Fractions and functions group their content, so they are atoms.
+const (
+	statementPrecedence = iota
+	orPrecedence
+	andPrecedence
+	comparisonPrecedence
+	sumPrecedence
+	productPrecedence
+	unaryPrecedence
+	powerPrecedence
+	postfixPrecedence
+	atomPrecedence
+)
+
+// namedFunctions maps functions to names used in mathematical notation
+var namedFunctions = map[string]string{
+	"sin":  "sin",
+	"cos":  "cos",
+	"tan":  "tan",
+	"asin": "arcsin",
+	"acos": "arccos",
+	"atan": "arctan",
+	"max":  "max",
+	"min":  "min",
+	"exp":  "exp",
+	"ln":   "ln",
+	"arg":  "arg",
+	"re":   "Re",
+	"im":   "Im",
+}
+
+type constant struct {
+	latex  string
+	mathML string
+}
+
+// constants are functions without arguments which are written as symbols
+var constants = map[string]constant{
+	"pi":  {latex: `\pi`, mathML: "&#x3C0;"},
+	"phi": {latex: `\phi`, mathML: "&#x3C6;"},
+	"e":   {latex: "e", mathML: "e"},
+}
+
+func precedence(node ast.Node) int {
+	switch n := node.(type) {
+	case *ast.BinaryNode:
+		switch n.Operator() {
+		case ast.Addition, ast.Substraction:
+			return sumPrecedence
+		case ast.Multiplication, ast.Modulus:
+			return productPrecedence
+		case ast.Exponent:
+			return powerPrecedence
+		case ast.Less, ast.LessOrEqual, ast.Greater, ast.GreaterOrEqual, ast.IsEqual, ast.NotEqual:
+			return comparisonPrecedence
+		case ast.And:
+			return andPrecedence
+		case ast.Or:
+			return orPrecedence
+		}
+		// Division is rendered as a fraction
+		return atomPrecedence
+	case *ast.UnaryNode:
+		return unaryPrecedence
+	case *ast.PostfixNode:
+		return postfixPrecedence
+	case *ast.NumericNode:
+		switch number := splitNumber(n); {
+		case number.negative:
+			return unaryPrecedence
+		case number.imaginary || number.exponent != "":
+			// Both 2i and 2e3 are written as a product
+			return productPrecedence
+		}
+	case *ast.AssignNode, *ast.FunctionDefNode, *ast.BlockNode:
+		return statementPrecedence
+	}
+	return atomPrecedence
+}
+
+// needsParentheses checks if operand of unary, postfix or binary node must be wrapped into parentheses
+func needsParentheses(parent, operand ast.Node, side operandSide) bool {
+	operandPrecedence := precedence(operand)
+	switch n := parent.(type) {
+	case *ast.UnaryNode:
+		return operandPrecedence <= unaryPrecedence
+	case *ast.PostfixNode:
+		// Repeated postfix operators are wrapped too, as 3!! means double factorial in mathematics
+		return operandPrecedence < atomPrecedence
+	case *ast.BinaryNode:
+		switch n.Operator() {
+		case ast.Division, ast.FloorDiv:
+			return false
+		case ast.Exponent:
+			// Exponent is grouped by superscript, but base must be a single symbol, so a/b is wrapped as well
+			if side == rightOperand {
+				return false
+			}
+			_, isFunction := operand.(*ast.FunctionNode)
+			return operandPrecedence < atomPrecedence || !isFunction && !isSimple(operand)
+		}
+		// Sign right after an operator is always wrapped, like a - (-b). Product can be on the right side
+		// of a sum without parentheses, so signed left operand of the product is wrapped too, like a - (-b) * c
+		if operandPrecedence == unaryPrecedence && (side == rightOperand || precedence(parent) == productPrecedence) {
+			return true
+		}
+		// Chained comparisons like a < b < c have different meaning in mathematics, so both sides are wrapped
+		if side == leftOperand && operandPrecedence != comparisonPrecedence {
+			return operandPrecedence < precedence(parent)
+		}
+		return operandPrecedence <= precedence(parent)
+	}
+	return false
+}
+
+func isSimple(node ast.Node) bool {
+	switch node.(type) {
+	case *ast.NumericNode, *ast.VariableNode:
+		return true
+	}
+	return false
+}
+
+// number is numeric literal split into parts, which are rendered separately.
+// Digits of non-decimal numbers are stored in mantissa without the base prefix
+type number struct {
+	negative  bool
+	mantissa  string
+	exponent  string
+	imaginary bool
+	base      int
+}
+
+func splitNumber(n *ast.NumericNode) number {
+	literal := strings.ReplaceAll(n.Literal(), "_", "")
+	result := number{imaginary: n.Imaginary(), mantissa: literal, base: n.Base()}
+	if strings.HasPrefix(literal, "-") {
+		result.negative, result.mantissa = true, literal[1:]
+	}
+	if result.base != 10 {
+		// Hexadecimal digits can contain e, so there is no exponent to split
+		result.mantissa = result.mantissa[2:]
+		return result
+	}
+	if i := strings.IndexAny(result.mantissa, "eE"); i >= 0 {
+		result.mantissa, result.exponent = result.mantissa[:i], strings.TrimPrefix(result.mantissa[i+1:], "+")
+	}
+	return result
+}
+
+// isConstant checks if node is function without arguments with given name, like e()
+func isConstant(node ast.Node, name string) bool {
+	f, ok := node.(*ast.FunctionNode)
+	return ok && len(f.Params()) == 0 && strings.EqualFold(f.Name(), name)
+}
@@ -0,0 +1,28 @@
+package export_test
+
+import (
+	"testing"
+
+	"github.com/arxeiss/go-expression-calculator/ast"
+	"github.com/arxeiss/go-expression-calculator/lexer"
+	"github.com/arxeiss/go-expression-calculator/parser"
+	"github.com/arxeiss/go-expression-calculator/parser/recursivedescent"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestExport(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Export Suite")
+}
+
+func parseExpression(expr string) ast.Node {
+	tokens, err := lexer.NewLexer(expr).Tokenize()
+	Expect(err).To(Succeed())
+	p, err := recursivedescent.NewParser(parser.DefaultTokenPriorities())
+	Expect(err).To(Succeed())
+	rootNode, err := p.Parse(tokens)
+	Expect(err).To(Succeed())
+	return rootNode
+}
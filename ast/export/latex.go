@@ -0,0 +1,233 @@
+package export
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/arxeiss/go-expression-calculator/ast"
+)
+
+// latexCommands are names of functions which have own command in LaTeX, others are written with \operatorname
+var latexCommands = map[string]bool{
+	"sin": true, "cos": true, "tan": true, "arcsin": true, "arccos": true, "arctan": true,
+	"max": true, "min": true, "exp": true, "ln": true, "log": true, "arg": true,
+}
+
+// latexEnclosing are functions of single argument written as the argument between prefix and suffix
+var latexEnclosing = map[string][2]string{
+	"sqrt":  {`\sqrt{`, "}"},
+	"abs":   {`\left|`, `\right|`},
+	"floor": {`\left\lfloor `, ` \right\rfloor`},
+	"ceil":  {`\left\lceil `, ` \right\rceil`},
+	"conj":  {`\overline{`, "}"},
+}
+
+var latexOperators = map[ast.Operation]string{
+	ast.Addition:       " + ",
+	ast.Substraction:   " - ",
+	ast.Multiplication: ` \cdot `,
+	ast.Modulus:        ` \bmod `,
+	ast.Less:           " < ",
+	ast.LessOrEqual:    ` \le `,
+	ast.Greater:        " > ",
+	ast.GreaterOrEqual: ` \ge `,
+	ast.IsEqual:        " = ",
+	ast.NotEqual:       ` \ne `,
+	ast.And:            ` \land `,
+	ast.Or:             ` \lor `,
+	ast.Factorial:      "!",
+	ast.Percent:        `\%`,
+}
+
+type latexWriter struct {
+	b strings.Builder
+}
+
+// ToLaTeX renders expression in LaTeX math mode notation, without surrounding delimiters like $
+func ToLaTeX(rootNode ast.Node) string {
+	w := &latexWriter{}
+	w.write(rootNode)
+	return w.b.String()
+}
+
+func (w *latexWriter) write(node ast.Node) {
+	switch n := node.(type) {
+	case *ast.NumericNode:
+		w.writeNumber(n)
+	case *ast.VariableNode:
+		w.b.WriteString(latexIdentifier(n.Name()))
+	case *ast.UnaryNode:
+		if n.Operator() == ast.Not {
+			w.b.WriteString(`\lnot `)
+		} else {
+			w.b.WriteString(n.Operator().String())
+		}
+		w.writeOperand(n, n.Next(), rightOperand)
+	case *ast.PostfixNode:
+		w.writeOperand(n, n.Prev(), leftOperand)
+		w.b.WriteString(latexOperators[n.Operator()])
+	case *ast.BinaryNode:
+		w.writeBinary(n)
+	case *ast.ConditionalNode:
+		w.writeConditional(n)
+	case *ast.BlockNode:
+		w.writeBlock(n)
+	case *ast.AssignNode:
+		w.b.WriteString(latexIdentifier(n.Left().Name()))
+		w.b.WriteString(" = ")
+		w.write(n.Right())
+	case *ast.FunctionNode:
+		w.writeFunction(n)
+	case *ast.FunctionDefNode:
+		w.writeFunctionName(n.Name())
+		w.b.WriteString(`\left(`)
+		for i, p := range n.ParamNames() {
+			if i > 0 {
+				w.b.WriteString(", ")
+			}
+			w.b.WriteString(latexIdentifier(p))
+		}
+		w.b.WriteString(`\right) = `)
+		w.write(n.Body())
+	}
+}
+
+// writeBlock writes statements on one line separated by semicolon and space
+func (w *latexWriter) writeBlock(n *ast.BlockNode) {
+	for i, s := range n.Statements() {
+		if i > 0 {
+			w.b.WriteString(`;\quad `)
+		}
+		w.write(s)
+	}
+}
+
+func (w *latexWriter) writeNumber(n *ast.NumericNode) {
+	number := splitNumber(n)
+	if number.negative {
+		w.b.WriteByte('-')
+	}
+	if number.base != 10 {
+		// Base is written as subscript, like FF_{16}
+		w.b.WriteString(`\mathrm{` + number.mantissa + `}_{` + strconv.Itoa(number.base) + "}")
+	} else {
+		w.b.WriteString(number.mantissa)
+	}
+	if number.exponent != "" {
+		w.b.WriteString(` \cdot 10^{` + number.exponent + "}")
+	}
+	if number.imaginary {
+		w.b.WriteByte('i')
+	}
+}
+
+func (w *latexWriter) writeBinary(n *ast.BinaryNode) {
+	switch n.Operator() {
+	case ast.Division:
+		w.b.WriteString(`\frac{`)
+		w.write(n.Left())
+		w.b.WriteString("}{")
+		w.write(n.Right())
+		w.b.WriteString("}")
+	case ast.FloorDiv:
+		w.b.WriteString(`\left\lfloor \frac{`)
+		w.write(n.Left())
+		w.b.WriteString("}{")
+		w.write(n.Right())
+		w.b.WriteString(`} \right\rfloor`)
+	case ast.Exponent:
+		w.writeOperand(n, n.Left(), leftOperand)
+		w.b.WriteString("^{")
+		w.write(n.Right())
+		w.b.WriteString("}")
+	default:
+		w.writeOperand(n, n.Left(), leftOperand)
+		w.b.WriteString(latexOperators[n.Operator()])
+		w.writeOperand(n, n.Right(), rightOperand)
+	}
+}
+
+// writeConditional writes cases, conditional in the else branch is written as another case
+func (w *latexWriter) writeConditional(n *ast.ConditionalNode) {
+	w.b.WriteString(`\begin{cases} `)
+	var node ast.Node = n
+	for {
+		conditional, ok := node.(*ast.ConditionalNode)
+		if !ok {
+			break
+		}
+		w.write(conditional.Then())
+		w.writeEnclosed(` & \text{if } `, ` \\ `, conditional.Condition())
+		node = conditional.Else()
+	}
+	w.write(node)
+	w.b.WriteString(` & \text{otherwise} \end{cases}`)
+}
+
+func (w *latexWriter) writeOperand(parent, operand ast.Node, side operandSide) {
+	if !needsParentheses(parent, operand, side) {
+		w.write(operand)
+		return
+	}
+	w.b.WriteString(`\left(`)
+	w.write(operand)
+	w.b.WriteString(`\right)`)
+}
+
+func (w *latexWriter) writeFunction(n *ast.FunctionNode) {
+	name := strings.ToLower(n.Name())
+	params := n.Params()
+	switch {
+	case len(params) == 0 && constants[name].latex != "":
+		w.b.WriteString(constants[name].latex)
+	case len(params) == 1 && latexEnclosing[name] != [2]string{}:
+		w.writeEnclosed(latexEnclosing[name][0], latexEnclosing[name][1], params[0])
+	case len(params) == 2 && name == "nth_root":
+		w.writeEnclosed(`\sqrt[`, "]", params[1])
+		w.writeEnclosed("{", "}", params[0])
+	case len(params) == 2 && name == "log" && isConstant(params[1], "e"):
+		w.writeEnclosed(`\ln\left(`, `\right)`, params[0])
+	case len(params) == 2 && name == "log":
+		w.writeEnclosed(`\log_{`, "}", params[1])
+		w.writeEnclosed(`\left(`, `\right)`, params[0])
+	default:
+		w.writeFunctionName(n.Name())
+		w.b.WriteString(`\left(`)
+		for i, p := range params {
+			if i > 0 {
+				w.b.WriteString(", ")
+			}
+			w.write(p)
+		}
+		w.b.WriteString(`\right)`)
+	}
+}
+
+func (w *latexWriter) writeEnclosed(prefix, suffix string, node ast.Node) {
+	w.b.WriteString(prefix)
+	w.write(node)
+	w.b.WriteString(suffix)
+}
+
+func (w *latexWriter) writeFunctionName(name string) {
+	if named, ok := namedFunctions[strings.ToLower(name)]; ok {
+		name = named
+	}
+	if latexCommands[name] {
+		w.b.WriteString(`\` + name)
+		return
+	}
+	w.b.WriteString(`\operatorname{` + latexEscape(name) + "}")
+}
+
+// latexIdentifier writes single letter variables directly, longer names are written in italic as one word
+func latexIdentifier(name string) string {
+	if len(name) == 1 {
+		return name
+	}
+	return `\mathit{` + latexEscape(name) + "}"
+}
+
+func latexEscape(name string) string {
+	return strings.ReplaceAll(name, "_", `\_`)
+}
@@ -0,0 +1,60 @@
+package export_test
+
+import (
+	"github.com/arxeiss/go-expression-calculator/ast"
+	"github.com/arxeiss/go-expression-calculator/ast/export"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LaTeX", func() {
+	DescribeTable("Expressions",
+		func(expr, expected string) {
+			Expect(export.ToLaTeX(parseExpression(expr))).To(Equal(expected))
+		},
+		Entry("Numbers and variables", "2.50 * x + alpha_1", `2.50 \cdot x + \mathit{alpha\_1}`),
+		Entry("Scientific and imaginary numbers", "1.5e-3 + 2i", `1.5 \cdot 10^{-3} + 2i`),
+		Entry("Numbers in other bases", "0xFE + 0b1010 * 1_000",
+			`\mathrm{FE}_{16} + \mathrm{1010}_{2} \cdot 1000`),
+		Entry("Precedence", "(a + b) * c - (d * e)", `\left(a + b\right) \cdot c - d \cdot e`),
+		Entry("Associativity", "a - (b - c) + (d + e)", `a - \left(b - c\right) + \left(d + e\right)`),
+		Entry("Fraction", "(a + 1) / (b * 2) / c", `\frac{\frac{a + 1}{b \cdot 2}}{c}`),
+		Entry("Floor division and modulus", "(a // b) % 3", `\left\lfloor \frac{a}{b} \right\rfloor \bmod 3`),
+		Entry("Power", "(a / b) ^ (c + 1) ^ 2", `\left(\frac{a}{b}\right)^{\left(c + 1\right)^{2}}`),
+		Entry("Power of function", "sin(x) ^ 2", `\sin\left(x\right)^{2}`),
+		Entry("Unary operators", "-a ^ 2 + (-a) ^ 2 - -b * -(c + d)",
+			`-a^{2} + \left(-a\right)^{2} - \left(-b\right) \cdot \left(-\left(c + d\right)\right)`),
+		Entry("Roots", "sqrt(x + 1) * nth_root(a, n)", `\sqrt{x + 1} \cdot \sqrt[n]{a}`),
+		Entry("Trigonometric functions",
+			"asin(x) + COS(x) / tan(x)", `\arcsin\left(x\right) + \frac{\cos\left(x\right)}{\tan\left(x\right)}`),
+		Entry("Logarithms", "log(x, e()) - log(x, 10)", `\ln\left(x\right) - \log_{10}\left(x\right)`),
+		Entry("Brackets", "abs(x) + floor(x) + ceil(x)",
+			`\left|x\right| + \left\lfloor x \right\rfloor + \left\lceil x \right\rceil`),
+		Entry("Constants", "2 * pi() * phi() + e()", `2 \cdot \pi \cdot \phi + e`),
+		Entry("Other functions", "max(1, x, 3) + rand_f() + deg2rad(x)",
+			`\max\left(1, x, 3\right) + \operatorname{rand\_f}\left(\right) + \operatorname{deg2rad}\left(x\right)`),
+		Entry("Assignment", "area = pi() * r ^ 2", `\mathit{area} = \pi \cdot r^{2}`),
+		Entry("Statements", "r = 2; pi() * r ^ 2", `r = 2;\quad \pi \cdot r^{2}`),
+		Entry("Function definition", "hypot(a, b) = sqrt(a^2 + b^2)",
+			`\operatorname{hypot}\left(a, b\right) = \sqrt{a^{2} + b^{2}}`),
+		Entry("Comparisons", "a + 1 <= b != (c > d) == (x >= y)",
+			`\left(\left(a + 1 \le b\right) \ne \left(c > d\right)\right) = \left(x \ge y\right)`),
+		Entry("Logical operators", "!a && (b || c < 2) || d",
+			`\lnot a \land \left(b \lor c < 2\right) \lor d`),
+		Entry("Conditional", "2 * (x < 0 ? -x : x > 0 ? x : 1)",
+			`2 \cdot \begin{cases} -x & \text{if } x < 0 \\ x & \text{if } x > 0 \\ 1 & \text{otherwise} \end{cases}`),
+		Entry("Postfix operators", "-n! + (n + 1)! / 2 ^ k! - 3!! + 100 + 10%",
+			`-n! + \frac{\left(n + 1\right)!}{2^{k!}} - \left(3!\right)! + 100 + 10\%`),
+	)
+
+	It("Negative numbers", func() {
+		node := ast.NewBinaryNode(ast.Exponent,
+			ast.NewNumericNode(-2, nil),
+			ast.NewBinaryNode(ast.Multiplication, ast.NewNumericNode(3, nil), ast.NewNumericNode(-0.5, nil), nil),
+			nil,
+		)
+		Expect(export.ToLaTeX(node)).To(Equal(`\left(-2\right)^{3 \cdot \left(-0.5\right)}`))
+	})
+})
@@ -0,0 +1,259 @@
+package export
+
+import (
+	"html"
+	"strconv"
+	"strings"
+
+	"github.com/arxeiss/go-expression-calculator/ast"
+)
+
+const (
+	mathMLMinus          = "&#x2212;"
+	mathMLApplyFunction  = "&#x2061;"
+	mathMLNamespace      = "http://www.w3.org/1998/Math/MathML"
+	mathMLMultiplication = "&#x22C5;"
+)
+
+var mathMLOperators = map[ast.Operation]string{
+	ast.Addition:       "+",
+	ast.Substraction:   mathMLMinus,
+	ast.Multiplication: mathMLMultiplication,
+	ast.Modulus:        "mod",
+	ast.Less:           "&lt;",
+	ast.LessOrEqual:    "&#x2264;",
+	ast.Greater:        "&gt;",
+	ast.GreaterOrEqual: "&#x2265;",
+	ast.IsEqual:        "=",
+	ast.NotEqual:       "&#x2260;",
+	ast.And:            "&#x2227;",
+	ast.Or:             "&#x2228;",
+	ast.Not:            "&#xAC;",
+	ast.Factorial:      "!",
+	ast.Percent:        "%",
+}
+
+// mathMLEnclosing are functions of single argument written as the argument between prefix and suffix
+var mathMLEnclosing = map[string][2]string{
+	"sqrt":  {"<msqrt>", "</msqrt>"},
+	"abs":   {"<mrow><mo>|</mo>", "<mo>|</mo></mrow>"},
+	"floor": {"<mrow><mo>&#x230A;</mo>", "<mo>&#x230B;</mo></mrow>"},
+	"ceil":  {"<mrow><mo>&#x2308;</mo>", "<mo>&#x2309;</mo></mrow>"},
+	"conj":  {"<mover>", "<mo>&#xAF;</mo></mover>"},
+}
+
+type mathMLWriter struct {
+	b strings.Builder
+}
+
+// ToMathML renders expression as presentation MathML wrapped in <math> element.
+// Every node is rendered as single element, so it can be used as argument of <mfrac>, <msup> and others.
+func ToMathML(rootNode ast.Node) string {
+	w := &mathMLWriter{}
+	w.b.WriteString(`<math xmlns="` + mathMLNamespace + `">`)
+	w.write(rootNode)
+	w.b.WriteString("</math>")
+	return w.b.String()
+}
+
+func (w *mathMLWriter) write(node ast.Node) {
+	switch n := node.(type) {
+	case *ast.NumericNode:
+		w.writeNumber(n)
+	case *ast.VariableNode:
+		w.element("mi", html.EscapeString(n.Name()))
+	case *ast.UnaryNode:
+		w.b.WriteString("<mrow>")
+		w.element("mo", mathMLOperators[n.Operator()])
+		w.writeOperand(n, n.Next(), rightOperand)
+		w.b.WriteString("</mrow>")
+	case *ast.PostfixNode:
+		w.b.WriteString("<mrow>")
+		w.writeOperand(n, n.Prev(), leftOperand)
+		w.element("mo", mathMLOperators[n.Operator()])
+		w.b.WriteString("</mrow>")
+	case *ast.BinaryNode:
+		w.writeBinary(n)
+	case *ast.ConditionalNode:
+		w.writeConditional(n)
+	case *ast.BlockNode:
+		w.writeBlock(n)
+	case *ast.AssignNode:
+		w.b.WriteString("<mrow>")
+		w.element("mi", html.EscapeString(n.Left().Name()))
+		w.element("mo", "=")
+		w.write(n.Right())
+		w.b.WriteString("</mrow>")
+	case *ast.FunctionNode:
+		w.writeFunction(n)
+	case *ast.FunctionDefNode:
+		w.b.WriteString("<mrow>")
+		w.writeFunctionName(n.Name())
+		w.element("mo", mathMLApplyFunction)
+		w.b.WriteString("<mrow><mo>(</mo>")
+		for i, p := range n.ParamNames() {
+			if i > 0 {
+				w.element("mo", ",")
+			}
+			w.element("mi", html.EscapeString(p))
+		}
+		w.b.WriteString("<mo>)</mo></mrow>")
+		w.element("mo", "=")
+		w.write(n.Body())
+		w.b.WriteString("</mrow>")
+	}
+}
+
+// writeBlock writes statements into one row separated by semicolons
+func (w *mathMLWriter) writeBlock(n *ast.BlockNode) {
+	w.b.WriteString("<mrow>")
+	for i, s := range n.Statements() {
+		if i > 0 {
+			w.b.WriteString(`<mo separator="true">;</mo>`)
+		}
+		w.write(s)
+	}
+	w.b.WriteString("</mrow>")
+}
+
+func (w *mathMLWriter) element(tag, content string) {
+	w.b.WriteString("<" + tag + ">" + content + "</" + tag + ">")
+}
+
+func (w *mathMLWriter) writeNumber(n *ast.NumericNode) {
+	number := splitNumber(n)
+	if !number.negative && !number.imaginary && number.exponent == "" {
+		w.writeMantissa(number)
+		return
+	}
+	w.b.WriteString("<mrow>")
+	if number.negative {
+		w.element("mo", mathMLMinus)
+	}
+	w.writeMantissa(number)
+	if number.exponent != "" {
+		w.element("mo", mathMLMultiplication)
+		w.b.WriteString("<msup><mn>10</mn>")
+		if strings.HasPrefix(number.exponent, "-") {
+			w.b.WriteString("<mrow><mo>" + mathMLMinus + "</mo><mn>" + number.exponent[1:] + "</mn></mrow>")
+		} else {
+			w.element("mn", number.exponent)
+		}
+		w.b.WriteString("</msup>")
+	}
+	if number.imaginary {
+		w.element("mi", "i")
+	}
+	w.b.WriteString("</mrow>")
+}
+
+// writeMantissa writes digits of the number, base of non-decimal numbers is written as subscript
+func (w *mathMLWriter) writeMantissa(number number) {
+	if number.base == 10 {
+		w.element("mn", number.mantissa)
+		return
+	}
+	w.b.WriteString("<msub>")
+	w.element("mn", number.mantissa)
+	w.element("mn", strconv.Itoa(number.base))
+	w.b.WriteString("</msub>")
+}
+
+func (w *mathMLWriter) writeBinary(n *ast.BinaryNode) {
+	switch n.Operator() {
+	case ast.Division:
+		w.writeEnclosed("<mfrac>", "</mfrac>", n.Left(), n.Right())
+	case ast.FloorDiv:
+		w.b.WriteString("<mrow><mo>&#x230A;</mo>")
+		w.writeEnclosed("<mfrac>", "</mfrac>", n.Left(), n.Right())
+		w.b.WriteString("<mo>&#x230B;</mo></mrow>")
+	case ast.Exponent:
+		w.b.WriteString("<msup>")
+		w.writeOperand(n, n.Left(), leftOperand)
+		w.write(n.Right())
+		w.b.WriteString("</msup>")
+	default:
+		w.b.WriteString("<mrow>")
+		w.writeOperand(n, n.Left(), leftOperand)
+		w.element("mo", mathMLOperators[n.Operator()])
+		w.writeOperand(n, n.Right(), rightOperand)
+		w.b.WriteString("</mrow>")
+	}
+}
+
+// writeConditional writes cases as a table, conditional in the else branch is written as another row
+func (w *mathMLWriter) writeConditional(n *ast.ConditionalNode) {
+	w.b.WriteString("<mrow><mo>{</mo><mtable>")
+	var node ast.Node = n
+	for {
+		conditional, ok := node.(*ast.ConditionalNode)
+		if !ok {
+			break
+		}
+		w.writeEnclosed("<mtr><mtd>", "</mtd>", conditional.Then())
+		w.writeEnclosed("<mtd><mtext>if&#xA0;</mtext>", "</mtd></mtr>", conditional.Condition())
+		node = conditional.Else()
+	}
+	w.writeEnclosed("<mtr><mtd>", "</mtd><mtd><mtext>otherwise</mtext></mtd></mtr>", node)
+	w.b.WriteString("</mtable></mrow>")
+}
+
+func (w *mathMLWriter) writeOperand(parent, operand ast.Node, side operandSide) {
+	if !needsParentheses(parent, operand, side) {
+		w.write(operand)
+		return
+	}
+	w.writeEnclosed("<mrow><mo>(</mo>", "<mo>)</mo></mrow>", operand)
+}
+
+func (w *mathMLWriter) writeFunction(n *ast.FunctionNode) {
+	name := strings.ToLower(n.Name())
+	params := n.Params()
+	switch {
+	case len(params) == 0 && constants[name].mathML != "":
+		w.element("mi", constants[name].mathML)
+	case len(params) == 1 && mathMLEnclosing[name] != [2]string{}:
+		w.writeEnclosed(mathMLEnclosing[name][0], mathMLEnclosing[name][1], params[0])
+	case len(params) == 2 && name == "nth_root":
+		w.writeEnclosed("<mroot>", "</mroot>", params[0], params[1])
+	case len(params) == 2 && name == "log" && isConstant(params[1], "e"):
+		w.writeApplication("<mi>ln</mi>", params[:1])
+	case len(params) == 2 && name == "log":
+		b := &mathMLWriter{}
+		b.writeEnclosed("<msub><mi>log</mi>", "</msub>", params[1])
+		w.writeApplication(b.b.String(), params[:1])
+	default:
+		b := &mathMLWriter{}
+		b.writeFunctionName(n.Name())
+		w.writeApplication(b.b.String(), params)
+	}
+}
+
+// writeApplication writes already rendered function name followed by arguments in parentheses
+func (w *mathMLWriter) writeApplication(name string, params []ast.Node) {
+	w.b.WriteString("<mrow>" + name)
+	w.element("mo", mathMLApplyFunction)
+	w.b.WriteString("<mrow><mo>(</mo>")
+	for i, p := range params {
+		if i > 0 {
+			w.element("mo", ",")
+		}
+		w.write(p)
+	}
+	w.b.WriteString("<mo>)</mo></mrow></mrow>")
+}
+
+func (w *mathMLWriter) writeEnclosed(prefix, suffix string, nodes ...ast.Node) {
+	w.b.WriteString(prefix)
+	for _, n := range nodes {
+		w.write(n)
+	}
+	w.b.WriteString(suffix)
+}
+
+func (w *mathMLWriter) writeFunctionName(name string) {
+	if named, ok := namedFunctions[strings.ToLower(name)]; ok {
+		name = named
+	}
+	w.element("mi", html.EscapeString(name))
+}
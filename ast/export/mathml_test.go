@@ -0,0 +1,87 @@
+package export_test
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+
+	"github.com/arxeiss/go-expression-calculator/ast/export"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+const mathPrefix = `<math xmlns="http://www.w3.org/1998/Math/MathML">`
+
+var _ = Describe("MathML", func() {
+	DescribeTable("Expressions",
+		func(expr, expected string) {
+			Expect(export.ToMathML(parseExpression(expr))).To(Equal(mathPrefix + expected + "</math>"))
+		},
+		Entry("Number", "2.5", "<mn>2.5</mn>"),
+		Entry("Scientific and imaginary numbers", "1.5e-3 + 2i",
+			"<mrow><mrow><mn>1.5</mn><mo>&#x22C5;</mo><msup><mn>10</mn><mrow><mo>&#x2212;</mo><mn>3</mn></mrow></msup>"+
+				"</mrow><mo>+</mo><mrow><mn>2</mn><mi>i</mi></mrow></mrow>"),
+		Entry("Numbers in other bases", "0xFE - 0o7i",
+			"<mrow><msub><mn>FE</mn><mn>16</mn></msub><mo>&#x2212;</mo><mrow><msub><mn>7</mn><mn>8</mn></msub>"+
+				"<mi>i</mi></mrow></mrow>"),
+		Entry("Precedence", "(a + b) * c",
+			"<mrow><mrow><mo>(</mo><mrow><mi>a</mi><mo>+</mo><mi>b</mi></mrow><mo>)</mo></mrow>"+
+				"<mo>&#x22C5;</mo><mi>c</mi></mrow>"),
+		Entry("Fraction", "(a - 1) / b", "<mfrac><mrow><mi>a</mi><mo>&#x2212;</mo><mn>1</mn></mrow><mi>b</mi></mfrac>"),
+		Entry("Floor division",
+			"a // b", "<mrow><mo>&#x230A;</mo><mfrac><mi>a</mi><mi>b</mi></mfrac><mo>&#x230B;</mo></mrow>"),
+		Entry("Power",
+			"(-x) ^ 2",
+			"<msup><mrow><mo>(</mo><mrow><mo>&#x2212;</mo><mi>x</mi></mrow><mo>)</mo></mrow><mn>2</mn></msup>",
+		),
+		Entry("Roots", "sqrt(x) + nth_root(a, 3)",
+			"<mrow><msqrt><mi>x</mi></msqrt><mo>+</mo><mroot><mi>a</mi><mn>3</mn></mroot></mrow>"),
+		Entry("Functions", "sin(x) % max(x, 2)",
+			"<mrow><mrow><mi>sin</mi><mo>&#x2061;</mo><mrow><mo>(</mo><mi>x</mi><mo>)</mo></mrow></mrow>"+
+				"<mo>mod</mo><mrow><mi>max</mi><mo>&#x2061;</mo>"+
+				"<mrow><mo>(</mo><mi>x</mi><mo>,</mo><mn>2</mn><mo>)</mo></mrow></mrow></mrow>"),
+		Entry("Logarithm", "log(x, 2)",
+			"<mrow><msub><mi>log</mi><mn>2</mn></msub><mo>&#x2061;</mo>"+
+				"<mrow><mo>(</mo><mi>x</mi><mo>)</mo></mrow></mrow>"),
+		Entry("Constants", "pi() * abs(x)",
+			"<mrow><mi>&#x3C0;</mi><mo>&#x22C5;</mo><mrow><mo>|</mo><mi>x</mi><mo>|</mo></mrow></mrow>"),
+		Entry("Function definition", "f(x) = x ^ 2",
+			"<mrow><mi>f</mi><mo>&#x2061;</mo><mrow><mo>(</mo><mi>x</mi><mo>)</mo></mrow><mo>=</mo>"+
+				"<msup><mi>x</mi><mn>2</mn></msup></mrow>"),
+		Entry("Comparison and logical operators", "!a || b < 1 && c >= 2",
+			"<mrow><mrow><mo>&#xAC;</mo><mi>a</mi></mrow><mo>&#x2228;</mo>"+
+				"<mrow><mrow><mi>b</mi><mo>&lt;</mo><mn>1</mn></mrow><mo>&#x2227;</mo>"+
+				"<mrow><mi>c</mi><mo>&#x2265;</mo><mn>2</mn></mrow></mrow></mrow>"),
+		Entry("Conditional", "x < 0 ? -x : x > 0 ? x : 1",
+			"<mrow><mo>{</mo><mtable>"+
+				"<mtr><mtd><mrow><mo>&#x2212;</mo><mi>x</mi></mrow></mtd>"+
+				"<mtd><mtext>if&#xA0;</mtext><mrow><mi>x</mi><mo>&lt;</mo><mn>0</mn></mrow></mtd></mtr>"+
+				"<mtr><mtd><mi>x</mi></mtd>"+
+				"<mtd><mtext>if&#xA0;</mtext><mrow><mi>x</mi><mo>&gt;</mo><mn>0</mn></mrow></mtd></mtr>"+
+				"<mtr><mtd><mn>1</mn></mtd><mtd><mtext>otherwise</mtext></mtd></mtr>"+
+				"</mtable></mrow>"),
+		Entry("Postfix operators", "(n + 1)! * 10%",
+			"<mrow><mrow><mrow><mo>(</mo><mrow><mi>n</mi><mo>+</mo><mn>1</mn></mrow><mo>)</mo></mrow><mo>!</mo></mrow>"+
+				"<mo>&#x22C5;</mo><mrow><mn>10</mn><mo>%</mo></mrow></mrow>"),
+		Entry("Statements", "r = 2; r + 1",
+			`<mrow><mrow><mi>r</mi><mo>=</mo><mn>2</mn></mrow><mo separator="true">;</mo>`+
+				"<mrow><mi>r</mi><mo>+</mo><mn>1</mn></mrow></mrow>"),
+	)
+
+	It("Produces well formed XML", func() {
+		mathML := export.ToMathML(parseExpression(
+			"y = -(a + b) ^ 2 / floor(c // 2) - ceil(log(x, e()) * 1e5) + conj(2i) % atan(pi()) < 1 && !(x ? 1 : 2)",
+		))
+		decoder := xml.NewDecoder(strings.NewReader(mathML))
+		decoder.Entity = xml.HTMLEntity
+		for {
+			_, err := decoder.Token()
+			if err == io.EOF {
+				break
+			}
+			Expect(err).To(Succeed())
+		}
+	})
+})
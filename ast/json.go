@@ -0,0 +1,343 @@
+package ast
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/arxeiss/go-expression-calculator/lexer"
+)
+
+var (
+	ErrUnknownNodeKind = errors.New("unknown node kind")
+	ErrUnknownOperator = errors.New("unknown operator")
+	ErrMissingNode     = errors.New("missing node")
+	ErrInvalidNode     = errors.New("invalid node")
+)
+
+// Kinds of nodes used in serialized tree
+const (
+	NumberKind      = "number"
+	VariableKind    = "variable"
+	UnaryKind       = "unary"
+	PostfixKind     = "postfix"
+	BinaryKind      = "binary"
+	ConditionalKind = "conditional"
+	AssignKind      = "assign"
+	FunctionKind    = "function"
+	FunctionDefKind = "functionDef"
+	BlockKind       = "block"
+	ErrorKind       = "error"
+)
+
+// jsonNode is serialized form of any node, only fields related to the kind are set
+type jsonNode struct {
+	Kind       string       `json:"kind"`
+	Value      *float64     `json:"value,omitempty"`
+	Name       string       `json:"name,omitempty"`
+	Operator   string       `json:"operator,omitempty"`
+	Operand    *jsonNode    `json:"operand,omitempty"`
+	Left       *jsonNode    `json:"left,omitempty"`
+	Right      *jsonNode    `json:"right,omitempty"`
+	Relative   bool         `json:"relative,omitempty"`
+	Condition  *jsonNode    `json:"condition,omitempty"`
+	Then       *jsonNode    `json:"then,omitempty"`
+	Else       *jsonNode    `json:"else,omitempty"`
+	Params     []*jsonNode  `json:"params,omitempty"`
+	Body       *jsonNode    `json:"body,omitempty"`
+	Statements []*jsonNode  `json:"statements,omitempty"`
+	Token      *lexer.Token `json:"token,omitempty"`
+}
+
+// Encode serializes the tree into JSON. Every node is an object with "kind" and optional "token" fields:
+//
+//	number      {"kind": "number", "value": 2.5}
+//	variable    {"kind": "variable", "name": "x"}
+//	unary       {"kind": "unary", "operator": "-", "operand": {...}}
+//	postfix     {"kind": "postfix", "operator": "!", "operand": {...}}
+//	binary      {"kind": "binary", "operator": "+", "left": {...}, "right": {...}}
+//	conditional {"kind": "conditional", "condition": {...}, "then": {...}, "else": {...}}
+//	assign      {"kind": "assign", "left": {"kind": "variable", ...}, "right": {...}}
+//	function    {"kind": "function", "name": "max", "params": [{...}, ...]}
+//	functionDef {"kind": "functionDef", "name": "f", "params": [{"kind": "variable", ...}, ...], "body": {...}}
+//	block       {"kind": "block", "statements": [{...}, ...]}
+//	error       {"kind": "error"}
+//
+// Operators are written same way as in the expression: + - * / ^ // % < <= > >= == != && || !
+// Postfix operators are factorial ! and percent %. Binary node with percent taken from the left operand,
+// like 100 + 10%, has "relative": true.
+// Token keeps the original span in the input, see lexer.Token.MarshalJSON for its format.
+func Encode(rootNode Node) ([]byte, error) {
+	encoded, err := encodeNode(rootNode)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(encoded)
+}
+
+// Decode creates the tree from JSON produced by Encode
+func Decode(data []byte) (Node, error) {
+	var decoded jsonNode
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, err
+	}
+	return decodeNode(&decoded)
+}
+
+func encodeNode(node Node) (*jsonNode, error) {
+	var err error
+	var encoded *jsonNode
+	switch n := node.(type) {
+	case *NumericNode:
+		value := n.Value()
+		encoded = &jsonNode{Kind: NumberKind, Value: &value}
+	case *VariableNode:
+		encoded = &jsonNode{Kind: VariableKind, Name: n.Name()}
+	case *UnaryNode:
+		encoded = &jsonNode{Kind: UnaryKind, Operator: n.Operator().String()}
+		encoded.Operand, err = encodeNode(n.Next())
+	case *PostfixNode:
+		encoded = &jsonNode{Kind: PostfixKind, Operator: n.Operator().String()}
+		encoded.Operand, err = encodeNode(n.Prev())
+	case *BinaryNode:
+		encoded = &jsonNode{Kind: BinaryKind, Operator: n.Operator().String(), Relative: n.RelativePercent()}
+		encoded.Left, encoded.Right, err = encodePair(n.Left(), n.Right())
+	case *ConditionalNode:
+		encoded = &jsonNode{Kind: ConditionalKind}
+		encoded.Condition, err = encodeNode(n.Condition())
+		if err == nil {
+			encoded.Then, encoded.Else, err = encodePair(n.Then(), n.Else())
+		}
+	case *AssignNode:
+		encoded = &jsonNode{Kind: AssignKind}
+		encoded.Left, encoded.Right, err = encodePair(n.Left(), n.Right())
+	case *FunctionNode:
+		encoded = &jsonNode{Kind: FunctionKind, Name: n.Name()}
+		encoded.Params, err = encodeList(n.Params())
+	case *FunctionDefNode:
+		encoded, err = encodeFunctionDef(n)
+	case *BlockNode:
+		encoded = &jsonNode{Kind: BlockKind}
+		encoded.Statements, err = encodeList(n.Statements())
+	case *ErrorNode:
+		encoded = &jsonNode{Kind: ErrorKind}
+	default:
+		return nil, fmt.Errorf("%w, cannot encode %T", ErrUnknownNodeKind, node)
+	}
+	if err != nil {
+		return nil, err
+	}
+	encoded.Token = node.GetToken()
+	return encoded, nil
+}
+
+func encodeFunctionDef(n *FunctionDefNode) (*jsonNode, error) {
+	params := make([]Node, 0, len(n.Params()))
+	for _, p := range n.Params() {
+		params = append(params, p)
+	}
+	encoded := &jsonNode{Kind: FunctionDefKind, Name: n.Name()}
+	var err error
+	if encoded.Params, err = encodeList(params); err == nil {
+		encoded.Body, err = encodeNode(n.Body())
+	}
+	return encoded, err
+}
+
+func encodePair(left, right Node) (*jsonNode, *jsonNode, error) {
+	encodedLeft, err := encodeNode(left)
+	if err != nil {
+		return nil, nil, err
+	}
+	encodedRight, err := encodeNode(right)
+	if err != nil {
+		return nil, nil, err
+	}
+	return encodedLeft, encodedRight, nil
+}
+
+func encodeList(nodes []Node) ([]*jsonNode, error) {
+	encoded := make([]*jsonNode, 0, len(nodes))
+	for _, n := range nodes {
+		e, err := encodeNode(n)
+		if err != nil {
+			return nil, err
+		}
+		encoded = append(encoded, e)
+	}
+	return encoded, nil
+}
+
+func decodeNode(n *jsonNode) (Node, error) {
+	switch n.Kind {
+	case NumberKind:
+		if n.Value == nil {
+			return nil, fmt.Errorf("%w, value of number is not set", ErrMissingNode)
+		}
+		return NewNumericNode(*n.Value, n.Token), nil
+	case VariableKind:
+		return NewVariableNode(n.Name, n.Token), nil
+	case UnaryKind:
+		return decodeUnary(n)
+	case PostfixKind:
+		return decodePostfix(n)
+	case BinaryKind:
+		return decodeBinary(n)
+	case ConditionalKind:
+		return decodeConditional(n)
+	case AssignKind:
+		return decodeAssign(n)
+	case FunctionKind:
+		return decodeFunction(n)
+	case FunctionDefKind:
+		return decodeFunctionDef(n)
+	case BlockKind:
+		return decodeBlock(n)
+	case ErrorKind:
+		return NewErrorNode(n.Token), nil
+	}
+	return nil, fmt.Errorf("%w '%s'", ErrUnknownNodeKind, n.Kind)
+}
+
+func decodeUnary(n *jsonNode) (Node, error) {
+	operator, err := decodeOperator(n.Operator, Addition, Substraction, Not)
+	if err != nil {
+		return nil, err
+	}
+	next, err := decodeChild(n.Operand, "operand")
+	if err != nil {
+		return nil, err
+	}
+	return NewUnaryNode(operator, next, n.Token), nil
+}
+
+func decodePostfix(n *jsonNode) (Node, error) {
+	operator, err := decodeOperator(n.Operator, Factorial, Percent)
+	if err != nil {
+		return nil, err
+	}
+	prev, err := decodeChild(n.Operand, "operand")
+	if err != nil {
+		return nil, err
+	}
+	return NewPostfixNode(operator, prev, n.Token), nil
+}
+
+func decodeBinary(n *jsonNode) (Node, error) {
+	operator, err := decodeOperator(n.Operator,
+		Addition, Substraction, Multiplication, Division, Exponent, FloorDiv, Modulus,
+		Less, LessOrEqual, Greater, GreaterOrEqual, IsEqual, NotEqual, And, Or)
+	if err != nil {
+		return nil, err
+	}
+	left, right, err := decodePair(n)
+	if err != nil {
+		return nil, err
+	}
+	if n.Relative {
+		return NewRelativePercentNode(operator, left, right, n.Token), nil
+	}
+	return NewBinaryNode(operator, left, right, n.Token), nil
+}
+
+func decodeConditional(n *jsonNode) (Node, error) {
+	condition, err := decodeChild(n.Condition, "condition")
+	if err != nil {
+		return nil, err
+	}
+	thenNode, err := decodeChild(n.Then, "then")
+	if err != nil {
+		return nil, err
+	}
+	elseNode, err := decodeChild(n.Else, "else")
+	if err != nil {
+		return nil, err
+	}
+	return NewConditionalNode(condition, thenNode, elseNode, n.Token), nil
+}
+
+func decodeAssign(n *jsonNode) (Node, error) {
+	left, right, err := decodePair(n)
+	if err != nil {
+		return nil, err
+	}
+	variable, ok := left.(*VariableNode)
+	if !ok {
+		return nil, fmt.Errorf("%w, left side of assign must be variable, got %T", ErrInvalidNode, left)
+	}
+	return NewAssignNode(variable, right, n.Token), nil
+}
+
+func decodeFunction(n *jsonNode) (Node, error) {
+	params := make([]Node, 0, len(n.Params))
+	for _, p := range n.Params {
+		param, err := decodeChild(p, "parameter")
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, param)
+	}
+	return NewFunctionNode(n.Name, params, n.Token), nil
+}
+
+func decodeFunctionDef(n *jsonNode) (Node, error) {
+	params := make([]*VariableNode, 0, len(n.Params))
+	for _, p := range n.Params {
+		param, err := decodeChild(p, "parameter")
+		if err != nil {
+			return nil, err
+		}
+		variable, ok := param.(*VariableNode)
+		if !ok {
+			return nil, fmt.Errorf(
+				"%w, parameter of function definition must be variable, got %T", ErrInvalidNode, param)
+		}
+		params = append(params, variable)
+	}
+	body, err := decodeChild(n.Body, "body")
+	if err != nil {
+		return nil, err
+	}
+	return NewFunctionDefNode(n.Name, params, body, n.Token), nil
+}
+
+func decodeBlock(n *jsonNode) (Node, error) {
+	statements := make([]Node, 0, len(n.Statements))
+	for _, s := range n.Statements {
+		statement, err := decodeChild(s, "statement")
+		if err != nil {
+			return nil, err
+		}
+		statements = append(statements, statement)
+	}
+	return NewBlockNode(statements, n.Token), nil
+}
+
+// decodeChild decodes nested node, missing node is reported with its name
+func decodeChild(n *jsonNode, name string) (Node, error) {
+	if n == nil {
+		return nil, fmt.Errorf("%w, %s is not set", ErrMissingNode, name)
+	}
+	return decodeNode(n)
+}
+
+func decodePair(n *jsonNode) (Node, Node, error) {
+	left, err := decodeChild(n.Left, "left")
+	if err != nil {
+		return nil, nil, err
+	}
+	right, err := decodeChild(n.Right, "right")
+	if err != nil {
+		return nil, nil, err
+	}
+	return left, right, nil
+}
+
+func decodeOperator(operator string, allowed ...Operation) (Operation, error) {
+	for _, op := range allowed {
+		if op.String() == operator {
+			return op, nil
+		}
+	}
+	return Invalid, fmt.Errorf("%w '%s'", ErrUnknownOperator, operator)
+}
@@ -0,0 +1,216 @@
+package ast_test
+
+import (
+	"github.com/arxeiss/go-expression-calculator/ast"
+	. "github.com/arxeiss/go-expression-calculator/ast/astutils"
+	"github.com/arxeiss/go-expression-calculator/lexer"
+	"github.com/arxeiss/go-expression-calculator/parser"
+	"github.com/arxeiss/go-expression-calculator/parser/pratt"
+	"github.com/arxeiss/go-expression-calculator/parser/recursivedescent"
+	"github.com/arxeiss/go-expression-calculator/parser/shuntyard"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+type parserConstructor func(priorities parser.TokenPriorities) (parser.Parser, error)
+
+var parsers = map[string]parserConstructor{
+	"shuntyard":        shuntyard.NewParser,
+	"recursivedescent": recursivedescent.NewParser,
+	"pratt":            pratt.NewParser,
+}
+
+func isFunction(name string) bool {
+	return name == "sin" || name == "max" || name == "pi" || name == "f"
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func mustEncode(node ast.Node) []byte {
+	encoded, err := ast.Encode(node)
+	Expect(err).To(Succeed())
+	return encoded
+}
+
+func parseExpression(expr string) ast.Node {
+	tokens, err := lexer.NewLexer(expr).Tokenize()
+	Expect(err).To(Succeed())
+	p, err := recursivedescent.NewParser(parser.DefaultTokenPriorities())
+	Expect(err).To(Succeed())
+	rootNode, err := p.Parse(tokens)
+	Expect(err).To(Succeed())
+	return rootNode
+}
+
+var _ = Describe("JSON", func() {
+	It("Encodes schema with tokens", func() {
+		encoded, err := ast.Encode(parseExpression("-x + 2.50i"))
+		Expect(err).To(Succeed())
+		Expect(encoded).To(MatchJSON(`{
+			"kind": "binary",
+			"operator": "+",
+			"left": {
+				"kind": "unary",
+				"operator": "-",
+				"operand": {
+					"kind": "variable",
+					"name": "x",
+					"token": {
+						"type": "Identifier", "literal": "x", "start": 1, "end": 2, "line": 1, "column": 2,
+						"identifier": "x"
+					}
+				},
+				"token": {"type": "UnarySubstraction", "literal": "-", "start": 0, "end": 1, "line": 1, "column": 1}
+			},
+			"right": {
+				"kind": "number",
+				"value": 2.5,
+				"token": {
+					"type": "Number", "literal": "2.50i", "start": 5, "end": 10, "line": 1, "column": 6,
+					"value": 2.5, "imaginary": true
+				}
+			},
+			"token": {"type": "Addition", "literal": "+", "start": 3, "end": 4, "line": 1, "column": 4}
+		}`))
+	})
+
+	It("Encodes nodes without tokens", func() {
+		node := ast.NewFunctionDefNode(
+			"f",
+			[]*ast.VariableNode{ast.NewVariableNode("a", nil)},
+			ast.NewAssignNode(ast.NewVariableNode("b", nil), ast.NewNumericNode(0, nil), nil),
+			nil,
+		)
+		encoded, err := ast.Encode(node)
+		Expect(err).To(Succeed())
+		Expect(encoded).To(MatchJSON(`{
+			"kind": "functionDef",
+			"name": "f",
+			"params": [{"kind": "variable", "name": "a"}],
+			"body": {
+				"kind": "assign",
+				"left": {"kind": "variable", "name": "b"},
+				"right": {"kind": "number", "value": 0}
+			}
+		}`))
+		Expect(node).To(SurviveJSONRoundTrip())
+	})
+
+	DescribeTable("Round trip",
+		// Expression is parsed by all parsers, unless only some of them are listed
+		func(expr string, only ...string) {
+			for name, constructor := range parsers {
+				if len(only) > 0 && !contains(only, name) {
+					continue
+				}
+				p, err := constructor(parser.DefaultTokenPriorities())
+				Expect(err).To(Succeed())
+				p.(parser.ImplicitMultiplicationParser).SetImplicitMultiplication(
+					parser.ImplicitMultiplication{Enabled: true, IsFunction: isFunction},
+				)
+				tokens, err := lexer.NewLexer(expr).Tokenize()
+				Expect(err).To(Succeed())
+				node, err := p.Parse(tokens)
+				Expect(err).To(Succeed(), name)
+				Expect(node).To(SurviveJSONRoundTrip(), name)
+
+				encoded, err := ast.Encode(node)
+				Expect(err).To(Succeed())
+				decoded, err := ast.Decode(encoded)
+				Expect(err).To(Succeed())
+				Expect(decoded).To(MatchTree(node), name)
+				Expect(decoded.GetToken().StartPosition()).To(Equal(node.GetToken().StartPosition()), name)
+			}
+		},
+		Entry("All operators", "1 + 2 - 3 * 4 / 5 // 6 % 7 ^ +8"),
+		Entry("Numbers keep literals", "1.50e3 + 3j"),
+		Entry("Functions", "max(sin(x), pi(), 2)"),
+		Entry("Comparison and logical operators", "!a || b < 1 && c >= 2 == (d != e) && f <= g > h"),
+		Entry("Conditional", "a > 0 ? b ? 1 : 2 : -c"),
+		Entry("Assignment", "x = y * 2"),
+		// Shunting yard does not support function definitions
+		Entry("Function definition", "f(a, b) = a ^ b", "recursivedescent", "pratt"),
+		Entry("Statements", "f(a) = a * 2; x = f(3)\nx ^ 2", "recursivedescent", "pratt"),
+		Entry("Postfix operators", "-3! + 100 + 10% - (a + 1)!%"),
+		Entry("Implicit multiplication", "2x + 3 sin(y) - 3(a + b)(c - 1)"),
+		Entry("Relative percent", "100 + 10% - 5% * 2 - x(20%)"),
+	)
+
+	It("Keeps implicit multiplication and relative percent", func() {
+		decoded, err := ast.Decode(mustEncode(parseExpression("100 - 10%")))
+		Expect(err).To(Succeed())
+		Expect(decoded.(*ast.BinaryNode).RelativePercent()).To(BeTrue())
+
+		tokens, err := lexer.NewLexer("2x").Tokenize()
+		Expect(err).To(Succeed())
+		p, err := pratt.NewParser(parser.DefaultTokenPriorities())
+		Expect(err).To(Succeed())
+		p.(parser.ImplicitMultiplicationParser).SetImplicitMultiplication(parser.ImplicitMultiplication{Enabled: true})
+		node, err := p.Parse(tokens)
+		Expect(err).To(Succeed())
+		decoded, err = ast.Decode(mustEncode(node))
+		Expect(err).To(Succeed())
+		Expect(decoded.(*ast.BinaryNode).Implicit()).To(BeTrue())
+	})
+
+	It("Keeps literal and imaginary flag of numbers", func() {
+		decoded, err := ast.Decode([]byte(
+			`{"kind": "number", "value": 2, "token": ` +
+				`{"type": "Number", "literal": "2.0i", "value": 2, "imaginary": true}}`,
+		))
+		Expect(err).To(Succeed())
+		number, ok := decoded.(*ast.NumericNode)
+		Expect(ok).To(BeTrue())
+		Expect(number.Imaginary()).To(BeTrue())
+		Expect(number.Literal()).To(Equal("2.0"))
+	})
+
+	DescribeTable("Decode errors",
+		func(data string, expectedErr error, errStr string) {
+			node, err := ast.Decode([]byte(data))
+			Expect(node).To(BeNil())
+			Expect(err).To(MatchError(expectedErr))
+			Expect(err).To(MatchError(errStr))
+		},
+		Entry("Unknown kind", `{"kind": "matrix"}`, ast.ErrUnknownNodeKind, "unknown node kind 'matrix'"),
+		Entry("Nested unknown kind", `{"kind": "unary", "operator": "-", "operand": {"kind": ""}}`,
+			ast.ErrUnknownNodeKind, "unknown node kind ''"),
+		Entry("Unknown operator", `{"kind": "binary", "operator": "=", "left": {}, "right": {}}`,
+			ast.ErrUnknownOperator, "unknown operator '='"),
+		Entry("Binary operator in unary node", `{"kind": "unary", "operator": "*", "operand": {}}`,
+			ast.ErrUnknownOperator, "unknown operator '*'"),
+		Entry("Unary operator in postfix node", `{"kind": "postfix", "operator": "-", "operand": {}}`,
+			ast.ErrUnknownOperator, "unknown operator '-'"),
+		Entry("Missing operand", `{"kind": "binary", "operator": "+", "left": {"kind": "variable", "name": "x"}}`,
+			ast.ErrMissingNode, "missing node, right is not set"),
+		Entry("Missing branch of conditional",
+			`{"kind": "conditional", "condition": {"kind": "variable", "name": "x"}, "then": {"kind": "variable"}}`,
+			ast.ErrMissingNode, "missing node, else is not set"),
+		Entry("Missing value", `{"kind": "number"}`, ast.ErrMissingNode, "missing node, value of number is not set"),
+		Entry("Assign to number",
+			`{"kind": "assign", "left": {"kind": "number", "value": 1}, "right": {"kind": "number", "value": 2}}`,
+			ast.ErrInvalidNode, "invalid node, left side of assign must be variable, got *ast.NumericNode"),
+		Entry("Function definition with expression as parameter",
+			`{"kind": "functionDef", "name": "f", "params": [{"kind": "number", "value": 1}], "body": {}}`,
+			ast.ErrInvalidNode,
+			"invalid node, parameter of function definition must be variable, got *ast.NumericNode",
+		),
+		Entry("Unknown token type", `{"kind": "variable", "name": "x", "token": {"type": "Bracket"}}`,
+			lexer.ErrUnknownTokenType, "unknown token type 'Bracket'"),
+	)
+
+	It("Encode error", func() {
+		_, err := ast.Encode(ast.NewUnaryNode(ast.Substraction, nil, nil))
+		Expect(err).To(MatchError(ast.ErrUnknownNodeKind))
+		Expect(err).To(MatchError("unknown node kind, cannot encode <nil>"))
+	})
+})
@@ -1,6 +1,9 @@
 package ast
 
 import (
+	"strconv"
+	"strings"
+
 	"github.com/m1gwings/treedrawer/tree"
 
 	"github.com/arxeiss/go-expression-calculator/lexer"
@@ -21,9 +24,41 @@ type Node interface {
 var _ Node = &NumericNode{}
 var _ Node = &VariableNode{}
 var _ Node = &UnaryNode{}
+var _ Node = &PostfixNode{}
 var _ Node = &BinaryNode{}
+var _ Node = &ConditionalNode{}
 var _ Node = &AssignNode{}
 var _ Node = &FunctionNode{}
+var _ Node = &FunctionDefNode{}
+var _ Node = &BlockNode{}
+var _ Node = &ErrorNode{}
+
+// Children returns direct child nodes in the order they are written in the expression
+func Children(node Node) []Node {
+	switch n := node.(type) {
+	case *UnaryNode:
+		return []Node{n.next}
+	case *PostfixNode:
+		return []Node{n.prev}
+	case *BinaryNode:
+		return []Node{n.left, n.right}
+	case *ConditionalNode:
+		return []Node{n.condition, n.thenNode, n.elseNode}
+	case *AssignNode:
+		return []Node{n.left, n.right}
+	case *FunctionNode:
+		return n.params
+	case *FunctionDefNode:
+		children := make([]Node, 0, len(n.params)+1)
+		for _, p := range n.params {
+			children = append(children, p)
+		}
+		return append(children, n.body)
+	case *BlockNode:
+		return n.statements
+	}
+	return nil
+}
 
 type NumericNode struct {
 	val   float64
@@ -38,6 +73,10 @@ func NewNumericNode(val float64, token *lexer.Token) *NumericNode {
 }
 
 func (n *NumericNode) toTreeDrawer(t *tree.Tree) {
+	if n.Imaginary() {
+		t.SetVal(tree.NodeString(strconv.FormatFloat(n.val, 'f', -1, 64) + "i"))
+		return
+	}
 	t.SetVal(tree.NodeFloat64(n.val))
 }
 func (n *NumericNode) GetToken() *lexer.Token {
@@ -47,6 +86,37 @@ func (n *NumericNode) Value() float64 {
 	return n.val
 }
 
+// Imaginary returns true when number was written with imaginary suffix, like 3i
+func (n *NumericNode) Imaginary() bool {
+	return n.token.Imaginary()
+}
+
+// Literal returns number as it was written in the input, so it can be parsed without loss of precision.
+// Imaginary suffix is not part of the literal.
+func (n *NumericNode) Literal() string {
+	if n.hasLiteral() {
+		literal := n.token.Literal()
+		if n.token.Imaginary() {
+			return literal[:len(literal)-1]
+		}
+		return literal
+	}
+	return strconv.FormatFloat(n.val, 'g', -1, 64)
+}
+
+// Base returns base in which the literal is written, like 16 for 0xFF, see Literal
+func (n *NumericNode) Base() int {
+	if n.hasLiteral() {
+		return n.token.Base()
+	}
+	return 10
+}
+
+// hasLiteral checks if the value was not changed since the number was read from the input
+func (n *NumericNode) hasLiteral() bool {
+	return n.token != nil && n.token.Type() == lexer.Number && n.token.Value() == n.val
+}
+
 type VariableNode struct {
 	name  string
 	token *lexer.Token
@@ -97,13 +167,43 @@ func (n *UnaryNode) GetToken() *lexer.Token {
 	return n.token
 }
 
-type BinaryNode struct {
+// PostfixNode is operator written after its operand, like factorial 5! or percent 10%
+type PostfixNode struct {
+	prev     Node
 	operator Operation
-	left     Node
-	right    Node
 	token    *lexer.Token
 }
 
+func NewPostfixNode(operator Operation, prev Node, token *lexer.Token) *PostfixNode {
+	return &PostfixNode{
+		operator: operator,
+		prev:     prev,
+		token:    token,
+	}
+}
+
+func (n *PostfixNode) Operator() Operation {
+	return n.operator
+}
+func (n *PostfixNode) Prev() Node {
+	return n.prev
+}
+func (n *PostfixNode) toTreeDrawer(t *tree.Tree) {
+	t.SetVal(tree.NodeString("()" + n.operator.String()))
+	n.prev.toTreeDrawer(t.AddChild(nil))
+}
+func (n *PostfixNode) GetToken() *lexer.Token {
+	return n.token
+}
+
+type BinaryNode struct {
+	operator        Operation
+	left            Node
+	right           Node
+	relativePercent bool
+	token           *lexer.Token
+}
+
 func NewBinaryNode(operator Operation, left, right Node, token *lexer.Token) *BinaryNode {
 	return &BinaryNode{
 		operator: operator,
@@ -122,6 +222,26 @@ func (n *BinaryNode) Right() Node {
 func (n *BinaryNode) Operator() Operation {
 	return n.operator
 }
+
+// Implicit returns true when the multiplication was not written, but inserted by the parser between operands, like 2x
+func (n *BinaryNode) Implicit() bool {
+	return n.operator == Multiplication && n.token.Type() == lexer.ImplicitMultiplication
+}
+
+// NewRelativePercentNode creates addition or substraction of the percent, which is taken from the left operand.
+// Parsers create it for 100 + 10%, trees built in other way keep percent as a plain fraction
+func NewRelativePercentNode(operator Operation, left, right Node, token *lexer.Token) *BinaryNode {
+	n := NewBinaryNode(operator, left, right, token)
+	n.relativePercent = operator == Addition || operator == Substraction
+	return n
+}
+
+// RelativePercent returns true when percent is added to or substracted from the left operand, like 100 + 10%.
+// As on desk calculators, the percent is taken from the left operand, so the result is 110
+func (n *BinaryNode) RelativePercent() bool {
+	return n.relativePercent
+}
+
 func (n *BinaryNode) toTreeDrawer(t *tree.Tree) {
 	t.SetVal(tree.NodeString(n.operator.String()))
 	n.left.toTreeDrawer(t.AddChild(nil))
@@ -131,6 +251,42 @@ func (n *BinaryNode) GetToken() *lexer.Token {
 	return n.token
 }
 
+// ConditionalNode evaluates only one of its branches, Then when condition is non-zero, otherwise Else
+type ConditionalNode struct {
+	condition Node
+	thenNode  Node
+	elseNode  Node
+	token     *lexer.Token
+}
+
+func NewConditionalNode(condition, thenNode, elseNode Node, token *lexer.Token) *ConditionalNode {
+	return &ConditionalNode{
+		condition: condition,
+		thenNode:  thenNode,
+		elseNode:  elseNode,
+		token:     token,
+	}
+}
+
+func (n *ConditionalNode) Condition() Node {
+	return n.condition
+}
+func (n *ConditionalNode) Then() Node {
+	return n.thenNode
+}
+func (n *ConditionalNode) Else() Node {
+	return n.elseNode
+}
+func (n *ConditionalNode) toTreeDrawer(t *tree.Tree) {
+	t.SetVal(tree.NodeString("?:"))
+	n.condition.toTreeDrawer(t.AddChild(nil))
+	n.thenNode.toTreeDrawer(t.AddChild(nil))
+	n.elseNode.toTreeDrawer(t.AddChild(nil))
+}
+func (n *ConditionalNode) GetToken() *lexer.Token {
+	return n.token
+}
+
 type AssignNode struct {
 	left  *VariableNode
 	right Node
@@ -189,3 +345,98 @@ func (n *FunctionNode) toTreeDrawer(t *tree.Tree) {
 func (n *FunctionNode) GetToken() *lexer.Token {
 	return n.token
 }
+
+type FunctionDefNode struct {
+	name   string
+	params []*VariableNode
+	body   Node
+	token  *lexer.Token
+}
+
+func NewFunctionDefNode(name string, params []*VariableNode, body Node, token *lexer.Token) *FunctionDefNode {
+	return &FunctionDefNode{
+		name:   name,
+		params: params,
+		body:   body,
+		token:  token,
+	}
+}
+
+func (n *FunctionDefNode) Name() string {
+	return n.name
+}
+func (n *FunctionDefNode) Params() []*VariableNode {
+	return n.params
+}
+
+// ParamNames returns names of all function parameters in the order of definition
+func (n *FunctionDefNode) ParamNames() []string {
+	names := make([]string, 0, len(n.params))
+	for _, v := range n.params {
+		names = append(names, v.Name())
+	}
+	return names
+}
+func (n *FunctionDefNode) Body() Node {
+	return n.body
+}
+func (n *FunctionDefNode) toTreeDrawer(t *tree.Tree) {
+	t.SetVal(tree.NodeString(n.name + "(" + strings.Join(n.ParamNames(), ", ") + ") " + Assign.String()))
+	n.body.toTreeDrawer(t.AddChild(nil))
+}
+func (n *FunctionDefNode) GetToken() *lexer.Token {
+	return n.token
+}
+
+// BlockNode holds statements of the program in the order they are written
+type BlockNode struct {
+	statements []Node
+	token      *lexer.Token
+}
+
+func NewBlockNode(statements []Node, token *lexer.Token) *BlockNode {
+	return &BlockNode{
+		statements: statements,
+		token:      token,
+	}
+}
+
+func (n *BlockNode) Statements() []Node {
+	return n.statements
+}
+func (n *BlockNode) toTreeDrawer(t *tree.Tree) {
+	t.SetVal(tree.NodeString(";"))
+	for _, v := range n.statements {
+		v.toTreeDrawer(t.AddChild(nil))
+	}
+}
+func (n *BlockNode) GetToken() *lexer.Token {
+	return n.token
+}
+
+// LastStatement returns the statement which gives the result of the program,
+// which is the last statement of the block or the node itself
+func LastStatement(rootNode Node) Node {
+	if block, ok := rootNode.(*BlockNode); ok && len(block.statements) > 0 {
+		return block.statements[len(block.statements)-1]
+	}
+	return rootNode
+}
+
+// ErrorNode is a placeholder for the part of the input which could not be parsed
+type ErrorNode struct {
+	token *lexer.Token
+}
+
+func NewErrorNode(token *lexer.Token) *ErrorNode {
+	return &ErrorNode{
+		token: token,
+	}
+}
+
+func (n *ErrorNode) toTreeDrawer(t *tree.Tree) {
+	t.SetVal(tree.NodeString("error"))
+}
+func (n *ErrorNode) GetToken() *lexer.Token {
+	return n.token
+}
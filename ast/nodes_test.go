@@ -0,0 +1,26 @@
+package ast_test
+
+import (
+	"github.com/arxeiss/go-expression-calculator/ast"
+	. "github.com/arxeiss/go-expression-calculator/ast/astutils"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Nodes", func() {
+	DescribeTable("Last statement",
+		func(expression, expected string) {
+			Expect(ast.LastStatement(parseExpression(expression))).To(MatchTree(parseExpression(expected)))
+		},
+		Entry("Single expression", "x + 1", "x + 1"),
+		Entry("Block", "x = 2; y = 3; x * y", "x * y"),
+		Entry("Function definition at the end", "x = 2; f(a) = a * x", "f(a) = a * x"),
+	)
+
+	It("Returns empty block itself", func() {
+		block := ast.NewBlockNode(nil, nil)
+		Expect(ast.LastStatement(block)).To(BeIdenticalTo(block))
+	})
+})
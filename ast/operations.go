@@ -1,7 +1,8 @@
 package ast
 
 var (
-	operationsStr = []string{"Invalid", "+", "-", "*", "/", "^", "//", "%", "="}
+	operationsStr = []string{"Invalid", "+", "-", "*", "/", "^", "//", "%", "=",
+		"<", "<=", ">", ">=", "==", "!=", "&&", "||", "!", "!", "%"}
 )
 
 type Operation uint8
@@ -18,6 +19,21 @@ const (
 	FloorDiv
 	Modulus
 	Assign
+
+	Less
+	LessOrEqual
+	Greater
+	GreaterOrEqual
+	IsEqual
+	NotEqual
+	And
+	Or
+	Not
+
+	// Postfix operations
+
+	Factorial
+	Percent
 )
 
 func (o Operation) String() string {
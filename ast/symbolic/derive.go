@@ -0,0 +1,317 @@
+package symbolic
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/arxeiss/go-expression-calculator/ast"
+)
+
+var (
+	ErrNotDifferentiable = errors.New("expression is not differentiable")
+	ErrEmptyVariable     = errors.New("variable name cannot be empty")
+)
+
+// outerDerivatives contains derivatives of single argument functions from evaluator.MathFunctions().
+// Chain rule is applied afterwards, so the result is multiplied by derivative of the argument.
+var outerDerivatives = map[string]func(u ast.Node) ast.Node{
+	"abs": func(u ast.Node) ast.Node { return div(u, function("abs", u)) },
+	"acos": func(u ast.Node) ast.Node {
+		return neg(div(number(1), function("sqrt", sub(number(1), pow(u, number(2))))))
+	},
+	"asin": func(u ast.Node) ast.Node {
+		return div(number(1), function("sqrt", sub(number(1), pow(u, number(2)))))
+	},
+	"atan":    func(u ast.Node) ast.Node { return div(number(1), add(number(1), pow(u, number(2)))) },
+	"ceil":    func(u ast.Node) ast.Node { return number(0) },
+	"floor":   func(u ast.Node) ast.Node { return number(0) },
+	"cos":     func(u ast.Node) ast.Node { return neg(function("sin", u)) },
+	"sin":     func(u ast.Node) ast.Node { return function("cos", u) },
+	"sqrt":    func(u ast.Node) ast.Node { return div(number(1), mul(number(2), function("sqrt", u))) },
+	"tan":     func(u ast.Node) ast.Node { return div(number(1), pow(function("cos", u), number(2))) },
+	"deg2rad": func(u ast.Node) ast.Node { return div(function("pi"), number(180)) },
+	"rad2deg": func(u ast.Node) ast.Node { return div(number(180), function("pi")) },
+}
+
+// constants are functions without arguments which always return the same value
+var constants = map[string]bool{"pi": true, "e": true, "phi": true}
+
+type deriver struct {
+	variable string
+}
+
+// Derive returns derivative of the expression with respect to the given variable.
+// Result is built from new nodes without tokens, but parts of the original tree can be reused.
+// Operations with 0 and 1 are folded immediately, so the result does not grow with useless nodes.
+func Derive(node ast.Node, variable string) (ast.Node, error) {
+	if variable == "" {
+		return nil, ErrEmptyVariable
+	}
+	return deriver{variable: variable}.derive(node)
+}
+
+func (d deriver) derive(node ast.Node) (ast.Node, error) {
+	switch n := node.(type) {
+	case *ast.NumericNode:
+		return number(0), nil
+	case *ast.VariableNode:
+		// Variables are case insensitive
+		if strings.EqualFold(n.Name(), d.variable) {
+			return number(1), nil
+		}
+		return number(0), nil
+	case *ast.UnaryNode:
+		next, err := d.derive(n.Next())
+		if err != nil {
+			return nil, err
+		}
+		switch n.Operator() {
+		case ast.Addition:
+			return next, nil
+		case ast.Substraction:
+			return neg(next), nil
+		case ast.Not:
+			return nil, DeriveError(n.GetToken(), fmt.Errorf(
+				"%w, operator %s has no derivative", ErrNotDifferentiable, n.Operator()))
+		}
+		return nil, DeriveError(n.GetToken(), errors.New("unary node supports only Addition and Substraction operator"))
+	case *ast.PostfixNode:
+		return d.derivePostfix(n)
+	case *ast.BinaryNode:
+		return d.deriveBinary(n)
+	case *ast.ConditionalNode:
+		return d.deriveConditional(n)
+	case *ast.FunctionNode:
+		return d.deriveFunction(n)
+	}
+	return nil, DeriveError(node.GetToken(), fmt.Errorf("%w, unsupported node type %T", ErrNotDifferentiable, node))
+}
+
+// derivePostfix derives percent as division by 100, factorial has no derivative
+func (d deriver) derivePostfix(n *ast.PostfixNode) (ast.Node, error) {
+	prev, err := d.derive(n.Prev())
+	if err != nil {
+		return nil, err
+	}
+	if n.Operator() == ast.Percent {
+		return div(prev, number(100)), nil
+	}
+	return nil, DeriveError(n.GetToken(), fmt.Errorf(
+		"%w, operator %s has no derivative", ErrNotDifferentiable, n.Operator()))
+}
+
+func (d deriver) deriveBinary(n *ast.BinaryNode) (ast.Node, error) {
+	u, v := n.Left(), n.Right()
+	du, err := d.derive(u)
+	if err != nil {
+		return nil, err
+	}
+	dv, err := d.derive(v)
+	if err != nil {
+		return nil, err
+	}
+	// Percent is taken from the left operand, u + v% is u + u * v%, so product rule is applied on the right side
+	if n.RelativePercent() {
+		dv = add(mul(du, v), mul(u, dv))
+	}
+
+	switch n.Operator() {
+	case ast.Addition:
+		return add(du, dv), nil
+	case ast.Substraction:
+		return sub(du, dv), nil
+	case ast.Multiplication:
+		// (u * v)' = u' * v + u * v'
+		return add(mul(du, v), mul(u, dv)), nil
+	case ast.Division:
+		// (u / v)' = (u' * v - u * v') / v^2
+		return div(sub(mul(du, v), mul(u, dv)), pow(v, number(2))), nil
+	case ast.Exponent:
+		return derivePower(u, v, du, dv), nil
+	}
+	return nil, DeriveError(n.GetToken(), fmt.Errorf(
+		"%w, operator %s has no derivative", ErrNotDifferentiable, n.Operator()))
+}
+
+// deriveConditional derives both branches, the condition is kept as it is.
+// Points where the condition changes are not differentiable, but they are ignored same way as abs() does.
+func (d deriver) deriveConditional(n *ast.ConditionalNode) (ast.Node, error) {
+	thenNode, err := d.derive(n.Then())
+	if err != nil {
+		return nil, err
+	}
+	elseNode, err := d.derive(n.Else())
+	if err != nil {
+		return nil, err
+	}
+	if equalNodes(thenNode, elseNode) {
+		return thenNode, nil
+	}
+	return ast.NewConditionalNode(n.Condition(), thenNode, elseNode, nil), nil
+}
+
+// derivePower handles power rule, exponential rule and their combination.
+// Derivative of constant expression is always folded into 0, so it is used to detect which rule to apply.
+func derivePower(u, v, du, dv ast.Node) ast.Node {
+	switch {
+	case isValue(dv, 0):
+		// (u^c)' = c * u^(c-1) * u'
+		return mul(mul(v, pow(u, sub(v, number(1)))), du)
+	case isValue(u, 0):
+		// (0^v)' = 0, the power is constant wherever it is defined, but ln(0) would make it NaN
+		return number(0)
+	case isValue(du, 0):
+		// (c^v)' = c^v * ln(c) * v'
+		return mul(mul(pow(u, v), ln(u)), dv)
+	}
+	// (u^v)' = u^v * (v' * ln(u) + v * u' / u)
+	return mul(pow(u, v), add(mul(dv, ln(u)), div(mul(v, du), u)))
+}
+
+func (d deriver) deriveFunction(n *ast.FunctionNode) (ast.Node, error) {
+	name := strings.ToLower(n.Name())
+	params := n.Params()
+
+	switch {
+	case constants[name] && len(params) == 0:
+		return number(0), nil
+	case outerDerivatives[name] != nil && len(params) == 1:
+		du, err := d.derive(params[0])
+		if err != nil {
+			return nil, err
+		}
+		// Chain rule f(u)' = f'(u) * u'
+		return mul(outerDerivatives[name](params[0]), du), nil
+	case name == "log" && len(params) == 2:
+		return d.deriveLog(params[0], params[1])
+	case name == "nth_root" && len(params) == 2:
+		return d.derive(pow(params[0], div(number(1), params[1])))
+	}
+	return nil, DeriveError(n.GetToken(), fmt.Errorf(
+		"%w, derivative of function '%s' with %d arguments is not known", ErrNotDifferentiable, n.Name(), len(params)))
+}
+
+// deriveLog derives logarithm with any base. Base is usually constant, then log(u, b)' = u' / (u * ln(b))
+func (d deriver) deriveLog(u, base ast.Node) (ast.Node, error) {
+	dBase, err := d.derive(base)
+	if err != nil {
+		return nil, err
+	}
+	if !isValue(dBase, 0) {
+		// log(u, b) = ln(u) / ln(b), where both are logarithms with constant base
+		return d.derive(div(ln(u), ln(base)))
+	}
+	du, err := d.derive(u)
+	if err != nil {
+		return nil, err
+	}
+	if f, ok := base.(*ast.FunctionNode); ok && strings.EqualFold(f.Name(), "e") && len(f.Params()) == 0 {
+		return div(du, u), nil
+	}
+	return div(du, mul(u, ln(base))), nil
+}
+
+func number(v float64) ast.Node {
+	return ast.NewNumericNode(v, nil)
+}
+
+func function(name string, params ...ast.Node) ast.Node {
+	return ast.NewFunctionNode(name, params, nil)
+}
+
+// ln is natural logarithm, which is not between built-in functions, so log with base e is used
+func ln(u ast.Node) ast.Node {
+	return function("log", u, function("e"))
+}
+
+// isValue checks if node is real number with given value
+func isValue(node ast.Node, v float64) bool {
+	n, ok := node.(*ast.NumericNode)
+	return ok && !n.Imaginary() && n.Value() == v
+}
+
+// numbers returns values of both nodes, if both are real numbers
+func numbers(a, b ast.Node) (float64, float64, bool) {
+	na, okA := a.(*ast.NumericNode)
+	nb, okB := b.(*ast.NumericNode)
+	if !okA || !okB || na.Imaginary() || nb.Imaginary() {
+		return 0, 0, false
+	}
+	return na.Value(), nb.Value(), true
+}
+
+func neg(a ast.Node) ast.Node {
+	if n, ok := a.(*ast.NumericNode); ok && !n.Imaginary() {
+		return number(-n.Value())
+	}
+	if n, ok := a.(*ast.UnaryNode); ok && n.Operator() == ast.Substraction {
+		return n.Next()
+	}
+	return ast.NewUnaryNode(ast.Substraction, a, nil)
+}
+
+func add(a, b ast.Node) ast.Node {
+	if x, y, ok := numbers(a, b); ok {
+		return number(x + y)
+	}
+	if isValue(a, 0) {
+		return b
+	}
+	if isValue(b, 0) {
+		return a
+	}
+	return ast.NewBinaryNode(ast.Addition, a, b, nil)
+}
+
+func sub(a, b ast.Node) ast.Node {
+	if x, y, ok := numbers(a, b); ok {
+		return number(x - y)
+	}
+	if isValue(a, 0) {
+		return neg(b)
+	}
+	if isValue(b, 0) {
+		return a
+	}
+	return ast.NewBinaryNode(ast.Substraction, a, b, nil)
+}
+
+func mul(a, b ast.Node) ast.Node {
+	switch x, y, ok := numbers(a, b); {
+	case ok:
+		return number(x * y)
+	case isValue(a, 0) || isValue(b, 0):
+		return number(0)
+	case isValue(a, 1):
+		return b
+	case isValue(b, 1):
+		return a
+	case isValue(a, -1):
+		return neg(b)
+	case isValue(b, -1):
+		return neg(a)
+	}
+	return ast.NewBinaryNode(ast.Multiplication, a, b, nil)
+}
+
+// div does not fold 2 numbers, as the fraction is more readable than the decimal number
+func div(a, b ast.Node) ast.Node {
+	if isValue(a, 0) && !isValue(b, 0) {
+		return number(0)
+	}
+	if isValue(b, 1) {
+		return a
+	}
+	return ast.NewBinaryNode(ast.Division, a, b, nil)
+}
+
+func pow(a, b ast.Node) ast.Node {
+	if isValue(b, 1) {
+		return a
+	}
+	if isValue(b, 0) {
+		return number(1)
+	}
+	return ast.NewBinaryNode(ast.Exponent, a, b, nil)
+}
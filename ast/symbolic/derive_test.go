@@ -0,0 +1,132 @@
+package symbolic_test
+
+import (
+	"math"
+
+	"github.com/arxeiss/go-expression-calculator/ast"
+	"github.com/arxeiss/go-expression-calculator/ast/symbolic"
+	"github.com/arxeiss/go-expression-calculator/evaluator"
+	"github.com/arxeiss/go-expression-calculator/lexer"
+	"github.com/arxeiss/go-expression-calculator/parser"
+	"github.com/arxeiss/go-expression-calculator/parser/recursivedescent"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+func parseExpression(expr string) ast.Node {
+	tokens, err := lexer.NewLexer(expr).Tokenize()
+	Expect(err).To(Succeed())
+	p, err := recursivedescent.NewParser(parser.DefaultTokenPriorities())
+	Expect(err).To(Succeed())
+	rootNode, err := p.Parse(tokens)
+	Expect(err).To(Succeed())
+	return rootNode
+}
+
+func format(node ast.Node) string {
+	return parser.Format(node, parser.DefaultTokenPriorities())
+}
+
+func evaluate(node ast.Node, x float64) float64 {
+	ev, err := evaluator.NewNumericEvaluator(
+		map[string]float64{"x": x, "y": 3}, evaluator.MathFunctions(), evaluator.MathFunctionsWithVarArgs(),
+	)
+	Expect(err).To(Succeed())
+	res, err := ev.Eval(node)
+	Expect(err).To(Succeed())
+	return res
+}
+
+var _ = Describe("Derive", func() {
+	DescribeTable("Derivative expression",
+		func(expr, expected string) {
+			res, err := symbolic.Derive(parseExpression(expr), "x")
+			Expect(err).To(Succeed())
+			Expect(format(res)).To(Equal(expected))
+		},
+		Entry("Constant", "42 * y", "0"),
+		Entry("Variable", "x", "1"),
+		Entry("Variables are case insensitive", "X + y", "1"),
+		Entry("Unary minus", "-x", "-1"),
+		Entry("Sum", "x + x - 5", "2"),
+		Entry("Product with constant", "y * x", "y"),
+		Entry("Product", "x * sin(x)", "sin(x) + x * cos(x)"),
+		Entry("Quotient", "1 / x", "-1 / x ^ 2"),
+		Entry("Power", "x ^ 3", "3 * x ^ 2"),
+		Entry("Square", "x ^ 2", "2 * x"),
+		Entry("Exponential", "2 ^ x", "2 ^ x * log(2, e())"),
+		Entry("Natural exponential", "e() ^ x", "e() ^ x * log(e(), e())"),
+		Entry("Zero base", "0 ^ x + x", "1"),
+		Entry("Chain rule", "sin(x ^ 2)", "cos(x ^ 2) * (2 * x)"),
+		Entry("Natural logarithm", "log(x, e())", "1 / x"),
+		Entry("Logarithm with constant base", "log(x, 10)", "1 / (x * log(10, e()))"),
+		Entry("Cosine", "cos(x)", "-sin(x)"),
+		Entry("Floor", "floor(x) + pi()", "0"),
+		Entry("Conditional", "x > 0 ? x ^ 2 : -x", "x > 0 ? 2 * x : -1"),
+		Entry("Conditional with same derivatives", "x > y ? x + 1 : x", "1"),
+		Entry("Percent", "x%", "1 / 100"),
+	)
+
+	DescribeTable("Derivative value",
+		func(expr string, points ...float64) {
+			node := parseExpression(expr)
+			res, err := symbolic.Derive(node, "x")
+			Expect(err).To(Succeed())
+			for _, x := range points {
+				// Central difference approximation of the derivative
+				h := 1e-6
+				approx := (evaluate(node, x+h) - evaluate(node, x-h)) / (2 * h)
+				Expect(evaluate(res, x)).To(BeNumerically("~", approx, 1e-5*math.Max(1, math.Abs(approx))), "x = %f", x)
+			}
+		},
+		Entry("Polynomial", "3 * x ^ 4 - 2 * x ^ 2 + x - 7", -2.0, 0.5, 3.0),
+		Entry("Quotient", "(x ^ 2 + 1) / (x - 3)", -1.0, 0.0, 2.0),
+		Entry("Power with variable base and exponent", "x ^ x", 0.5, 1.0, 2.5),
+		Entry("Power with variable base and exponent in function", "x ^ sin(x)", 0.5, 2.0),
+		Entry("Power with zero base", "0 ^ (x ^ 2 + 1) * y + x", -1.0, 0.5),
+		Entry("Logarithm with variable base", "log(y * x, x)", 0.5, 2.0, 7.0),
+		Entry("Absolute value", "abs(x ^ 3 - y)", -1.0, 0.5, 2.0),
+		Entry("Arcus functions", "acos(x / 2) + asin(x / 3) + atan(x ^ 2)", -0.5, 0.0, 0.7),
+		Entry("Tangent", "tan(2 * x)", 0.1, 0.5),
+		Entry("Square root", "sqrt(x ^ 2 + y)", -1.0, 4.0),
+		Entry("Conversion of angles", "sin(deg2rad(x)) + rad2deg(x) * y", 30.0, 90.0),
+		Entry("N-th root", "nth_root(x, 3) + nth_root(8, x)", 1.5, 4.0),
+		Entry("Nested functions", "cos(sin(x) ^ 2) / sqrt(x)", 0.3, 2.0),
+		Entry("Piecewise function", "x < 1 ? x ^ 2 : x > y ? sin(x) : 2 * x - 1", -1.0, 2.0, 4.0),
+		Entry("Percent relative to the left operand", "(x ^ 2 + 10%) - y * x%", -1.0, 0.5, 3.0),
+		Entry("Percent of variable relative to the left operand", "sin(x) - x%", 0.3, 2.0),
+		Entry("Percent in product is not relative in the derivative", "sin(x)% * x", 0.3, 2.0),
+	)
+
+	DescribeTable("Errors",
+		func(expr, errStr string) {
+			_, err := symbolic.Derive(parseExpression(expr), "x")
+			Expect(err).To(MatchError(symbolic.ErrNotDifferentiable))
+			Expect(err).To(MatchError(errStr))
+		},
+		Entry("Floor division", "x // 2",
+			"expression is not differentiable, operator // has no derivative at position 2"),
+		Entry("Modulus", "3 + x % 2",
+			"expression is not differentiable, operator % has no derivative at position 6"),
+		Entry("Comparison", "x < 2",
+			"expression is not differentiable, operator < has no derivative at position 2"),
+		Entry("Logical negation", "2 * !x",
+			"expression is not differentiable, operator ! has no derivative at position 4"),
+		Entry("Factorial", "2 * x!",
+			"expression is not differentiable, operator ! has no derivative at position 5"),
+		Entry(
+			"Unknown function", "2 * max(x, 1)",
+			"expression is not differentiable, derivative of function 'max' with 2 arguments is not known"+
+				" at position 4",
+		),
+		Entry("Assignment", "y = x",
+			"expression is not differentiable, unsupported node type *ast.AssignNode at position 2"),
+	)
+
+	It("Requires variable name", func() {
+		_, err := symbolic.Derive(ast.NewVariableNode("x", nil), "")
+		Expect(err).To(MatchError(symbolic.ErrEmptyVariable))
+	})
+})
@@ -0,0 +1,35 @@
+package symbolic
+
+import "github.com/arxeiss/go-expression-calculator/lexer"
+
+type Error struct {
+	token *lexer.Token
+	err   error
+}
+
+// Position returns 0 based index of error in original input expression
+// If position is not set, -1 is returned
+func (e *Error) Position() int {
+	return e.Location().Offset
+}
+
+// Location returns span of the token where the error happened
+func (e *Error) Location() lexer.Location {
+	return e.token.Location()
+}
+
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// Error message does not contain the type of the token, it is clear from the node
+func (e *Error) Error() string {
+	return lexer.FormatError(e.err, nil, e.Location())
+}
+
+func DeriveError(token *lexer.Token, err error) *Error {
+	return &Error{
+		token: token,
+		err:   err,
+	}
+}
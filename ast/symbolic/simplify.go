@@ -0,0 +1,457 @@
+package symbolic
+
+import (
+	"math"
+	"strings"
+
+	"github.com/arxeiss/go-expression-calculator/ast"
+	"github.com/arxeiss/go-expression-calculator/lexer"
+)
+
+// Simplify returns new tree with folded constant subtrees, applied identities like x*1, x+0, x^1, --x
+// and with collected like terms, so 2*x + y - x becomes x + y.
+// Original tree is not modified and simplified nodes keep tokens of the nodes they were created from,
+// so errors still point into the original input. Functions are expected to be pure, they are never folded,
+// but f(x) - f(x) is simplified into 0. Percent is rewritten into division by 100 and factorial of a number is folded.
+// Product with zero coefficient, like -0 * x, becomes 0, as its sign of zero depends on other factors.
+func Simplify(node ast.Node) ast.Node {
+	return simplifier{}.simplify(node)
+}
+
+// SimplifyExact simplifies the tree same as Simplify, but the result is always evaluated into the same value
+// with the same side effects. Terms are dropped, cancelled or merged only when they contain no assignment,
+// no variable, no function call and no operation which can end with NaN or infinity, like 1/0.
+// Variables can hold NaN or infinity, so x - x or 0 * x is kept as it is.
+// Product with zero coefficient keeps its factors, so the sign of zero is kept too.
+func SimplifyExact(node ast.Node) ast.Node {
+	return simplifier{exact: true}.simplify(node)
+}
+
+// simplifier keeps mode of the simplification, exact one never drops terms which are not removable, see removable
+type simplifier struct {
+	exact bool
+}
+
+func (s simplifier) simplify(node ast.Node) ast.Node {
+	switch n := node.(type) {
+	case *ast.UnaryNode:
+		return s.unary(n.Operator(), s.simplify(n.Next()), n.GetToken())
+	case *ast.PostfixNode:
+		return s.postfix(n.Operator(), s.simplify(n.Prev()), n.GetToken())
+	case *ast.BinaryNode:
+		left, right := s.simplify(n.Left()), s.simplify(n.Right())
+		// Percent is taken from the left operand, so a + b% is a + a * b/100
+		if n.RelativePercent() {
+			right = s.binary(ast.Multiplication, left, right, n.Right().GetToken())
+		}
+		return s.binary(n.Operator(), left, right, n.GetToken())
+	case *ast.ConditionalNode:
+		return s.conditional(s.simplify(n.Condition()), s.simplify(n.Then()), s.simplify(n.Else()), n.GetToken())
+	case *ast.FunctionNode:
+		var params []ast.Node
+		for _, p := range n.Params() {
+			params = append(params, s.simplify(p))
+		}
+		return ast.NewFunctionNode(n.Name(), params, n.GetToken())
+	case *ast.AssignNode:
+		return ast.NewAssignNode(n.Left(), s.simplify(n.Right()), n.GetToken())
+	case *ast.FunctionDefNode:
+		return ast.NewFunctionDefNode(n.Name(), n.Params(), s.simplify(n.Body()), n.GetToken())
+	case *ast.BlockNode:
+		statements := make([]ast.Node, 0, len(n.Statements()))
+		for _, statement := range n.Statements() {
+			statements = append(statements, s.simplify(statement))
+		}
+		return ast.NewBlockNode(statements, n.GetToken())
+	}
+	return node
+}
+
+// removable checks if the node can be dropped or cancelled without changing the result of the evaluation.
+// In the default mode everything is removable, as functions are expected to be pure
+func (s simplifier) removable(node ast.Node) bool {
+	if !s.exact {
+		return true
+	}
+	switch n := node.(type) {
+	case *ast.NumericNode:
+		return !math.IsNaN(n.Value()) && !math.IsInf(n.Value(), 0)
+	case *ast.UnaryNode:
+		return s.removable(n.Next())
+	case *ast.PostfixNode:
+		return n.Operator() == ast.Percent && s.removable(n.Prev())
+	case *ast.BinaryNode:
+		switch n.Operator() {
+		case ast.Division, ast.FloorDiv, ast.Modulus, ast.Exponent:
+			return false
+		}
+		return s.removable(n.Left()) && s.removable(n.Right())
+	case *ast.ConditionalNode:
+		return s.removable(n.Condition()) && s.removable(n.Then()) && s.removable(n.Else())
+	}
+	// Variables and functions can be NaN or infinity, assignments and definitions have side effects
+	return false
+}
+
+func (s simplifier) unary(op ast.Operation, next ast.Node, token *lexer.Token) ast.Node {
+	switch op {
+	case ast.Addition:
+		return next
+	case ast.Substraction:
+		if n, ok := next.(*ast.NumericNode); ok && !n.Imaginary() {
+			return ast.NewNumericNode(-n.Value(), token)
+		}
+		if n, ok := next.(*ast.UnaryNode); ok && n.Operator() == ast.Substraction {
+			return n.Next()
+		}
+	}
+	return ast.NewUnaryNode(op, next, token)
+}
+
+func (s simplifier) postfix(op ast.Operation, prev ast.Node, token *lexer.Token) ast.Node {
+	if op == ast.Percent {
+		return s.binary(ast.Division, prev, ast.NewNumericNode(100, token), token)
+	}
+	// Factorial is evaluated by gamma function, same as NumericEvaluator does
+	if n, ok := prev.(*ast.NumericNode); ok && !n.Imaginary() {
+		if v := math.Gamma(n.Value() + 1); !math.IsNaN(v) && !math.IsInf(v, 0) {
+			return ast.NewNumericNode(v, token)
+		}
+	}
+	return ast.NewPostfixNode(op, prev, token)
+}
+
+func (s simplifier) binary(op ast.Operation, l, r ast.Node, token *lexer.Token) ast.Node {
+	if x, y, ok := numbers(l, r); ok {
+		if v, ok := fold(op, x, y); ok {
+			return ast.NewNumericNode(v, token)
+		}
+	}
+
+	switch op {
+	case ast.Addition, ast.Substraction:
+		return s.collectTerms(ast.NewBinaryNode(op, l, r, token))
+	case ast.Multiplication:
+		return s.multiply(ast.NewBinaryNode(op, l, r, token))
+	case ast.Division:
+		if isValue(r, 1) {
+			return l
+		}
+	case ast.Exponent:
+		switch {
+		case isValue(r, 1), isValue(l, 1):
+			return l
+		case isValue(r, 0):
+			return ast.NewNumericNode(1, token)
+		}
+	}
+	return ast.NewBinaryNode(op, l, r, token)
+}
+
+// conditional picks the branch when condition is constant or both branches are the same
+func (s simplifier) conditional(condition, thenNode, elseNode ast.Node, token *lexer.Token) ast.Node {
+	if n, ok := condition.(*ast.NumericNode); ok && !n.Imaginary() {
+		if n.Value() != 0 {
+			return thenNode
+		}
+		return elseNode
+	}
+	if equalNodes(thenNode, elseNode) && s.removable(condition) {
+		return thenNode
+	}
+	return ast.NewConditionalNode(condition, thenNode, elseNode, token)
+}
+
+// fold evaluates operation same way as NumericEvaluator does.
+// Operations which would end with division by zero, NaN or infinity are not folded, so evaluator can handle them.
+func fold(op ast.Operation, x, y float64) (float64, bool) {
+	var v float64
+	switch op {
+	case ast.Addition:
+		v = x + y
+	case ast.Substraction:
+		v = x - y
+	case ast.Multiplication:
+		v = x * y
+	case ast.Division:
+		v = x / y
+	case ast.FloorDiv:
+		v = math.Floor(x / y)
+	case ast.Modulus:
+		v = math.Mod(x, y)
+	case ast.Exponent:
+		v = math.Pow(x, y)
+	default:
+		return 0, false
+	}
+	return v, !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+// product is multiplication split into numeric coefficient and other factors
+type product struct {
+	coefficient float64
+	factors     []ast.Node
+	// numbers is count of numeric factors and numberToken is token of the first one
+	numbers     int
+	numberToken *lexer.Token
+	tokens      []*lexer.Token
+	// removable is false when some of the factors cannot be dropped by zero coefficient
+	removable bool
+	// signedZero keeps factors of the product with zero coefficient, as they decide the sign of zero
+	signedZero bool
+}
+
+func (s simplifier) splitProduct(node ast.Node) *product {
+	p := &product{coefficient: 1, signedZero: s.exact}
+	p.add(node)
+	p.removable = true
+	for _, f := range p.factors {
+		p.removable = p.removable && s.removable(f)
+	}
+	return p
+}
+
+func (p *product) add(node ast.Node) {
+	switch n := node.(type) {
+	case *ast.BinaryNode:
+		if n.Operator() == ast.Multiplication {
+			p.add(n.Left())
+			p.add(n.Right())
+			p.tokens = append(p.tokens, n.GetToken())
+			return
+		}
+	case *ast.UnaryNode:
+		if n.Operator() == ast.Substraction {
+			p.coefficient = -p.coefficient
+			p.add(n.Next())
+			return
+		}
+	case *ast.NumericNode:
+		if !n.Imaginary() {
+			if p.numbers == 0 {
+				p.numberToken = n.GetToken()
+			}
+			p.numbers++
+			p.coefficient *= n.Value()
+			return
+		}
+	}
+	p.factors = append(p.factors, node)
+}
+
+// build creates multiplication of all factors with given coefficient. Coefficient is always the first factor.
+func (p *product) build(coefficient float64, token *lexer.Token) ast.Node {
+	tokens := p.tokens
+	nextToken := func() *lexer.Token {
+		if len(tokens) == 0 {
+			return token
+		}
+		t := tokens[0]
+		tokens = tokens[1:]
+		return t
+	}
+
+	numberToken := token
+	if p.numberToken != nil {
+		numberToken = p.numberToken
+	}
+	switch {
+	case len(p.factors) == 0:
+		return ast.NewNumericNode(coefficient, numberToken)
+	case coefficient == 0 && p.removable && !p.signedZero:
+		return ast.NewNumericNode(0, numberToken)
+	}
+	var result ast.Node
+	if math.Abs(coefficient) != 1 {
+		result = ast.NewNumericNode(coefficient, numberToken)
+	}
+	for _, f := range p.factors {
+		if result == nil {
+			result = f
+			continue
+		}
+		result = ast.NewBinaryNode(ast.Multiplication, result, f, nextToken())
+	}
+	if coefficient == -1 {
+		return ast.NewUnaryNode(ast.Substraction, result, token)
+	}
+	return result
+}
+
+// multiply folds numbers of the product into single coefficient
+func (s simplifier) multiply(n *ast.BinaryNode) ast.Node {
+	p := s.splitProduct(n)
+	// Nothing to fold, keep the original structure
+	_, leftIsNumber := n.Left().(*ast.NumericNode)
+	if p.numbers == 0 && p.coefficient == 1 || p.numbers == 1 && leftIsNumber && math.Abs(p.coefficient) > 1 {
+		return n
+	}
+	return p.build(p.coefficient, n.GetToken())
+}
+
+// term is one part of the sum, constants have no factors
+type term struct {
+	node        ast.Node
+	original    float64
+	coefficient float64
+	product     *product
+}
+
+type termCollector struct {
+	simplifier
+	terms  []*term
+	tokens []*lexer.Token
+}
+
+// collectTerms flattens chain of additions and substractions and merges terms with same factors
+func (s simplifier) collectTerms(n *ast.BinaryNode) ast.Node {
+	c := &termCollector{simplifier: s}
+	c.add(n, 1)
+
+	var result ast.Node
+	for _, t := range c.terms {
+		if t.coefficient == 0 && t.product.removable {
+			continue
+		}
+		node := t.node
+		if math.Abs(t.coefficient) != math.Abs(t.original) {
+			node = t.product.build(math.Abs(t.coefficient), t.node.GetToken())
+		}
+
+		switch {
+		case result == nil && t.coefficient < 0:
+			result = s.unary(ast.Substraction, node, t.node.GetToken())
+		case result == nil:
+			result = node
+		case t.coefficient < 0:
+			result = ast.NewBinaryNode(ast.Substraction, result, node, c.nextToken(n))
+		default:
+			result = ast.NewBinaryNode(ast.Addition, result, node, c.nextToken(n))
+		}
+	}
+	if result == nil {
+		return ast.NewNumericNode(0, n.GetToken())
+	}
+	return result
+}
+
+func (c *termCollector) nextToken(n ast.Node) *lexer.Token {
+	if len(c.tokens) == 0 {
+		return n.GetToken()
+	}
+	t := c.tokens[0]
+	c.tokens = c.tokens[1:]
+	return t
+}
+
+func (c *termCollector) add(node ast.Node, sign float64) {
+	switch n := node.(type) {
+	case *ast.BinaryNode:
+		switch n.Operator() {
+		case ast.Addition:
+			c.add(n.Left(), sign)
+			c.tokens = append(c.tokens, n.GetToken())
+			c.add(n.Right(), sign)
+			return
+		case ast.Substraction:
+			c.add(n.Left(), sign)
+			c.tokens = append(c.tokens, n.GetToken())
+			c.add(n.Right(), -sign)
+			return
+		}
+	case *ast.UnaryNode:
+		if n.Operator() == ast.Substraction {
+			c.add(n.Next(), -sign)
+			return
+		}
+	}
+
+	p := c.splitProduct(node)
+	for _, t := range c.terms {
+		// Terms which cannot be removed are not merged, as they could be cancelled, like rand() - rand()
+		if p.removable && t.product.removable && sameFactors(t.product.factors, p.factors) {
+			t.coefficient += sign * p.coefficient
+			return
+		}
+	}
+	// Node of the term is always positive, the sign is handled by the operator
+	if p.coefficient < 0 {
+		node = p.build(-p.coefficient, node.GetToken())
+	}
+	c.terms = append(c.terms, &term{
+		node:        node,
+		original:    p.coefficient,
+		coefficient: sign * p.coefficient,
+		product:     p,
+	})
+}
+
+// sameFactors checks if both lists contain same factors, regardless the order
+func sameFactors(a, b []ast.Node) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	used := make([]bool, len(b))
+	for _, fa := range a {
+		found := false
+		for i, fb := range b {
+			if !used[i] && equalNodes(fa, fb) {
+				used[i], found = true, true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func equalNodeLists(a, b []ast.Node) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !equalNodes(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// equalNodes compares structure of 2 trees, tokens are ignored
+func equalNodes(a, b ast.Node) bool {
+	switch na := a.(type) {
+	case *ast.NumericNode:
+		nb, ok := b.(*ast.NumericNode)
+		return ok && na.Value() == nb.Value() && na.Imaginary() == nb.Imaginary()
+	case *ast.VariableNode:
+		nb, ok := b.(*ast.VariableNode)
+		return ok && strings.EqualFold(na.Name(), nb.Name())
+	case *ast.FunctionNode:
+		nb, ok := b.(*ast.FunctionNode)
+		return ok && strings.EqualFold(na.Name(), nb.Name()) && equalNodeLists(na.Params(), nb.Params())
+	}
+	return equalOperatorNodes(a, b)
+}
+
+// equalOperatorNodes compares structure of unary, postfix, binary and conditional nodes
+func equalOperatorNodes(a, b ast.Node) bool {
+	switch na := a.(type) {
+	case *ast.UnaryNode:
+		nb, ok := b.(*ast.UnaryNode)
+		return ok && na.Operator() == nb.Operator() && equalNodes(na.Next(), nb.Next())
+	case *ast.PostfixNode:
+		nb, ok := b.(*ast.PostfixNode)
+		return ok && na.Operator() == nb.Operator() && equalNodes(na.Prev(), nb.Prev())
+	case *ast.BinaryNode:
+		nb, ok := b.(*ast.BinaryNode)
+		return ok && na.Operator() == nb.Operator() &&
+			equalNodeLists([]ast.Node{na.Left(), na.Right()}, []ast.Node{nb.Left(), nb.Right()})
+	case *ast.ConditionalNode:
+		nb, ok := b.(*ast.ConditionalNode)
+		return ok && equalNodeLists(
+			[]ast.Node{na.Condition(), na.Then(), na.Else()}, []ast.Node{nb.Condition(), nb.Then(), nb.Else()})
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package symbolic_test
+
+import (
+	"github.com/arxeiss/go-expression-calculator/ast"
+	"github.com/arxeiss/go-expression-calculator/ast/symbolic"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Simplify", func() {
+	DescribeTable("Simplified expression",
+		func(expr, expected string) {
+			node := parseExpression(expr)
+			res := symbolic.Simplify(node)
+			Expect(format(res)).To(Equal(expected))
+			for _, x := range []float64{-2.5, 0.5, 4} {
+				Expect(evaluate(res, x)).To(BeNumerically("~", evaluate(node, x), 1e-9))
+			}
+		},
+		Entry("Constant folding", "2 * 3 + 4 ^ 0.5", "8"),
+		Entry("Constant folding inside function", "sin(2 * 3 // 4)", "sin(1)"),
+		Entry("Multiplication by one", "x * 1 + 1 * y", "x + y"),
+		Entry("Multiplication by zero", "(x + y) * 0 + 5", "5"),
+		Entry("Addition of zero", "0 + x + 0", "x"),
+		Entry("Exponent one", "x ^ 1", "x"),
+		Entry("Exponent zero", "x ^ (2 - 2)", "1"),
+		Entry("Division by one", "x / (3 - 2)", "x"),
+		Entry("Double negation", "--x", "x"),
+		Entry("Unary plus", "+x", "x"),
+		Entry("Substraction of same terms", "x - x", "0"),
+		Entry("Substraction of same functions", "sin(x) * y - y * sin(x) + sin(X) * y", "sin(X) * y"),
+		Entry("Collect like terms in different order", "x * sin(y) * 2 + 3 * sin(y) * x", "5 * x * sin(y)"),
+		Entry("Collect like terms", "2 * x + y - x + 3 * y", "x + 4 * y"),
+		Entry("Collect constants", "1 + x + 2 - 4", "-1 + x"),
+		Entry("Negative coefficient", "y + x * -2", "y - 2 * x"),
+		Entry("Negative first term", "x - 3 * x + y", "-(2 * x) + y"),
+		Entry("Negation of term", "y - (x - 2 * x)", "y + x"),
+		Entry("Multiple numbers in product", "2 * x * 3", "6 * x"),
+		Entry("Product with minus one", "x * (2 - 3) * y", "-(x * y)"),
+		Entry("Keep product order", "x * y * sin(x)", "x * y * sin(x)"),
+		Entry("Conditional", "x > 1 * 0 ? x * 1 : -x", "x > 0 ? x : -x"),
+		Entry("Conditional with constant condition", "(2 - 2) ? x : y * 1", "y"),
+		Entry("Conditional with same branches", "x > y ? x + 0 : x", "x"),
+		Entry("Statements", "y = x * 1; y + 0", "y = x; y"),
+		Entry("Factorial of number", "x * 4!", "24 * x"),
+		Entry("Factorial of variable", "(x + 0)!", "x!"),
+		Entry("Percent", "x * 50%", "0.5 * x"),
+		Entry("Percent relative to the left operand", "x + 10%", "1.1 * x"),
+		Entry("Percent of variable", "x%", "x / 100"),
+		Entry("Product with negative zero", "-0 * x", "0"),
+	)
+
+	DescribeTable("Exact simplification",
+		func(expr, expected string) {
+			Expect(format(symbolic.SimplifyExact(parseExpression(expr)))).To(Equal(expected))
+		},
+		Entry("Removable terms are simplified", "x * 1 + 2 * 3 - 6 + y ^ 1", "x + y"),
+		Entry("Zero times division", "0 * (1 / x)", "0 * (1 / x)"),
+		Entry("Zero times function", "sqrt(x) * 0", "0 * sqrt(x)"),
+		Entry("Zero times assignment", "0 * (y = 5)", "0 * (y = 5)"),
+		Entry("Functions are not cancelled", "rand_f() - rand_f()", "rand_f() - rand_f()"),
+		Entry("Condition with assignment", "(y = 5) ? x : x", "(y = 5) ? x : x"),
+		Entry("Zero product keeps its sign", "-0 * x", "-0 * x"),
+		Entry("Variables are not dropped", "x * 0 + y - y", "0 * x + y - y"),
+	)
+
+	It("Keeps division by zero", func() {
+		Expect(format(symbolic.Simplify(parseExpression("x / 0 + 1 // 0")))).To(Equal("x / 0 + 1 // 0"))
+	})
+
+	It("Keeps tokens of the original nodes", func() {
+		node := parseExpression("2 * 3 + x * 1")
+		res := symbolic.Simplify(node).(*ast.BinaryNode)
+
+		Expect(res.GetToken().StartPosition()).To(Equal(6))
+		Expect(res.Left().GetToken().StartPosition()).To(Equal(2))
+		Expect(res.Right().GetToken().StartPosition()).To(Equal(8))
+		Expect(format(node)).To(Equal("2 * 3 + x * 1"))
+	})
+
+	It("Simplifies assignment and function definition", func() {
+		Expect(format(symbolic.Simplify(parseExpression("a = x + 0")))).To(Equal("a = x"))
+		Expect(format(symbolic.Simplify(parseExpression("f(x) = x * (1 + 1)")))).To(Equal("f(x) = 2 * x"))
+	})
+})
@@ -0,0 +1,13 @@
+package symbolic_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestSymbolic(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Symbolic Suite")
+}
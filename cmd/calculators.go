@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/arxeiss/go-expression-calculator/ast"
+	"github.com/arxeiss/go-expression-calculator/evaluator"
+)
+
+const precisionExact = "exact"
+
+type variableRow struct {
+	Name  string
+	Value string
+}
+
+// calculator hides evaluators with different number representation, so REPL does not need to care
+type calculator interface {
+	Evaluate(rootNode ast.Node) (string, error)
+	Variables() []variableRow
+	Functions() []evaluator.FunctionTuple
+}
+
+type numericCalculator struct {
+	evaluator *evaluator.NumericEvaluator
+}
+
+func (c *numericCalculator) Evaluate(rootNode ast.Node) (string, error) {
+	val, err := c.evaluator.Eval(rootNode)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%.8f", val), nil
+}
+
+func (c *numericCalculator) Variables() []variableRow {
+	vars := c.evaluator.VariableList()
+	rows := make([]variableRow, 0, len(vars))
+	for _, v := range vars {
+		rows = append(rows, variableRow{Name: v.Name, Value: fmt.Sprintf("%.8f", v.Value)})
+	}
+	return rows
+}
+
+func (c *numericCalculator) Functions() []evaluator.FunctionTuple {
+	return c.evaluator.FunctionList()
+}
+
+type bigCalculator struct {
+	evaluator *evaluator.BigEvaluator
+}
+
+func (c *bigCalculator) Evaluate(rootNode ast.Node) (string, error) {
+	val, err := c.evaluator.Eval(rootNode)
+	if err != nil || val == nil {
+		return "", err
+	}
+	return formatBigNumber(val), nil
+}
+
+func (c *bigCalculator) Variables() []variableRow {
+	vars := c.evaluator.VariableList()
+	rows := make([]variableRow, 0, len(vars))
+	for _, v := range vars {
+		rows = append(rows, variableRow{Name: v.Name, Value: formatBigNumber(v.Value)})
+	}
+	return rows
+}
+
+func (c *bigCalculator) Functions() []evaluator.FunctionTuple {
+	return c.evaluator.FunctionList()
+}
+
+type complexCalculator struct {
+	evaluator *evaluator.ComplexEvaluator
+}
+
+func (c *complexCalculator) Evaluate(rootNode ast.Node) (string, error) {
+	val, err := c.evaluator.Eval(rootNode)
+	if err != nil {
+		return "", err
+	}
+	return formatComplex(val), nil
+}
+
+func (c *complexCalculator) Variables() []variableRow {
+	vars := c.evaluator.VariableList()
+	rows := make([]variableRow, 0, len(vars))
+	for _, v := range vars {
+		rows = append(rows, variableRow{Name: v.Name, Value: formatComplex(v.Value)})
+	}
+	return rows
+}
+
+// Functions converts complex functions into FunctionTuple, handler is not needed to print them
+func (c *complexCalculator) Functions() []evaluator.FunctionTuple {
+	funcs := c.evaluator.FunctionList()
+	ret := make([]evaluator.FunctionTuple, 0, len(funcs))
+	for _, f := range funcs {
+		ret = append(ret, evaluator.FunctionTuple{Name: f.Name, Function: evaluator.FunctionHandler{
+			Description:  f.Function.Description,
+			MinArguments: f.Function.MinArguments,
+			MaxArguments: f.Function.MaxArguments,
+			ArgsNames:    f.Function.ArgsNames,
+		}})
+	}
+	return ret
+}
+
+// formatComplex prints complex number in a+bi form
+func formatComplex(c complex128) string {
+	// Avoid printing negative zero, like 2.00000000-0.00000000i
+	im := imag(c)
+	if im == 0 {
+		im = 0
+	}
+	return fmt.Sprintf("%.8f%+.8fi", real(c), im)
+}
+
+// formatBigNumber prints rational numbers also as decimal number to be readable
+func formatBigNumber(n *evaluator.BigNumber) string {
+	if !n.IsRat() || n.IsInt() {
+		return n.String()
+	}
+	decimal := strings.TrimRight(n.Rat().FloatString(20), "0")
+	return n.String() + " ≈ " + decimal
+}
+
+type calculatorOptions struct {
+	precision      string
+	complexNumbers bool
+	functions      bool
+}
+
+// newCalculator creates calculator based on flags. Empty precision means float64,
+// 'exact' means rational numbers and the number is count of significant decimal digits
+func newCalculator(opts calculatorOptions, vars map[string]float64) (calculator, string, error) {
+	var funcs []map[string]evaluator.FunctionHandler
+	if opts.functions {
+		funcs = append(funcs,
+			evaluator.MathFunctions(), evaluator.MathFunctionsWithVarArgs(), evaluator.LazyFunctions())
+	}
+
+	if opts.complexNumbers {
+		if opts.precision != "" {
+			return nil, "", errors.New("complex numbers cannot be evaluated with arbitrary precision")
+		}
+		complexVars := make(map[string]complex128, len(vars))
+		for k, v := range vars {
+			complexVars[k] = complex(v, 0)
+		}
+		var complexFuncs []map[string]evaluator.ComplexFunctionHandler
+		if opts.functions {
+			complexFuncs = append(complexFuncs, evaluator.ComplexFunctions())
+		}
+		ev, err := evaluator.NewComplexEvaluator(complexVars, complexFuncs...)
+		return &complexCalculator{evaluator: ev}, "complex128", err
+	}
+
+	switch opts.precision {
+	case "":
+		ev, err := evaluator.NewNumericEvaluator(vars, funcs...)
+		return &numericCalculator{evaluator: ev}, "float64", err
+	case precisionExact:
+		ev, err := evaluator.NewBigEvaluator(evaluator.BigRatMode, 0, vars, funcs...)
+		return &bigCalculator{evaluator: ev}, "exact rational numbers", err
+	}
+	digits, err := strconv.ParseUint(opts.precision, 10, 16)
+	if err != nil || digits == 0 {
+		return nil, "", fmt.Errorf(
+			"invalid precision '%s', use positive number of digits or '%s'", opts.precision, precisionExact)
+	}
+	// Add few more bits, so last printed digit is not affected by rounding errors
+	bits := uint(math.Ceil(float64(digits)*math.Log2(10))) + 8
+	ev, err := evaluator.NewBigEvaluator(evaluator.BigFloatMode, bits, vars, funcs...)
+	return &bigCalculator{evaluator: ev}, fmt.Sprintf("%d significant digits", digits), err
+}
@@ -3,32 +3,48 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/c-bata/go-prompt"
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 
-	"github.com/arxeiss/go-expression-calculator/evaluator"
 	"github.com/arxeiss/go-expression-calculator/parser"
+	"github.com/arxeiss/go-expression-calculator/parser/pratt"
 	"github.com/arxeiss/go-expression-calculator/parser/recursivedescent"
 	"github.com/arxeiss/go-expression-calculator/parser/shuntyard"
 )
 
 var (
-	flagInitVars *bool
-	flagNoFuncs  *bool
-	flagParser   *string
+	flagInitVars  *bool
+	flagNoFuncs   *bool
+	flagParser    *string
+	flagPrecision *string
+	flagComplex   *bool
+	flagImplicit  *bool
 
-	availableParsers = []string{"shunt-yard", "recursive"}
+	availableParsers = []string{"shunt-yard", "recursive", "pratt"}
 )
 
 func init() {
 	flagInitVars = rootCmd.Flags().BoolP("init-vars", "i", false, "Before start, initialize values")
-	flagParser = rootCmd.Flags().StringP("parser", "p", "recursive", fmt.Sprintf(
+	flagParser = rootCmd.PersistentFlags().StringP("parser", "p", "recursive", fmt.Sprintf(
 		"Parser to be used, available ones are: '"+strings.Join(availableParsers, "', '")+"'",
 	))
-	flagNoFuncs = rootCmd.Flags().Bool("no-functions", false, "Disable functions for parser")
+	flagNoFuncs = rootCmd.PersistentFlags().Bool("no-functions", false, "Disable functions for parser")
+	flagPrecision = rootCmd.PersistentFlags().String("precision", "", fmt.Sprintf(
+		"Evaluate with arbitrary precision, set number of significant digits or '%s' for rational numbers. "+
+			"Float64 is used when empty", precisionExact,
+	))
+	flagComplex = rootCmd.PersistentFlags().Bool(
+		"complex", false, "Evaluate with complex numbers, imaginary unit is written as suffix, like 2i or 2j",
+	)
+	flagImplicit = rootCmd.PersistentFlags().Bool(
+		"implicit-multiplication", false,
+		"Multiply adjacent operands, like 2x, 3(a + b) or (a)(b). Identifier followed by parenthesis is multiplied "+
+			"only if it is not a function",
+	)
 }
 
 // rootCmd represents the base command when called without any subcommands
@@ -37,7 +53,7 @@ var rootCmd = &cobra.Command{
 	Short: "Expression Calculator",
 	Long:  `Expression calculator in Go with REPL. Write 'help' to REPL console to get more info.`,
 	Args:  cobra.NoArgs,
-	PreRunE: func(cmd *cobra.Command, args []string) error {
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 		if !strInStrSlice(availableParsers, *flagParser) {
 			return errors.New("Invalid parser, available ones are: '" + strings.Join(availableParsers, "', '") + "'")
 		}
@@ -49,34 +65,19 @@ var rootCmd = &cobra.Command{
 			return err
 		}
 
-		var funcs []map[string]evaluator.FunctionHandler
-		if !*flagNoFuncs {
-			funcs = append(funcs, evaluator.MathFunctions())
-		}
-
-		parserName := "Recursive descent"
-		var p parser.Parser
-		switch *flagParser {
-		case "shunt-yard":
-			p, err = shuntyard.NewParser(parser.DefaultTokenPriorities())
-			parserName = "Shunting Yard"
-		default:
-			p, err = recursivedescent.NewParser(parser.DefaultTokenPriorities())
-			if !*flagNoFuncs {
-				funcs = append(funcs, evaluator.MathFunctionsWithVarArgs())
-			}
-		}
+		p, parserName, err := newParser(*flagParser)
 		if err != nil {
 			return err
 		}
-
-		numEvaluator, err := evaluator.NewNumericEvaluator(vars, funcs...)
+		calc, precisionName, err := newCalculator(flagCalculatorOptions(), vars)
 		if err != nil {
 			return err
 		}
+		setImplicitMultiplication(p, calc)
 
 		fmt.Printf("Welcome to the expression calculator, write '%s' to get more info\n", color.HiCyanString("help"))
 		fmt.Printf("Current parser is '%s'\n", color.HiGreenString(parserName))
+		fmt.Printf("Numbers are evaluated with '%s'\n", color.HiGreenString(precisionName))
 
 		controlC := false
 		emptyLine := true
@@ -86,7 +87,7 @@ var rootCmd = &cobra.Command{
 			func(s string) {
 				controlC = false
 				if s != "" && s != "exit" {
-					parseLine(s, numEvaluator, p)
+					parseLine(s, calc, p)
 				}
 			},
 			func(d prompt.Document) []prompt.Suggest {
@@ -98,6 +99,9 @@ var rootCmd = &cobra.Command{
 					{Text: "functions", Description: "Show all available functions"},
 					{Text: "variables", Description: "Show all available variables"},
 					{Text: "tree", Description: "Prints AST tree"},
+					{Text: "diff", Description: "Prints derivative of expression"},
+					{Text: "simplify", Description: "Prints simplified expression"},
+					{Text: "latex", Description: "Prints expression in LaTeX notation"},
 					{Text: "exit", Description: "Quits console"},
 				}
 				return prompt.FilterHasPrefix(s, d.Text, true)
@@ -138,8 +142,32 @@ var rootCmd = &cobra.Command{
 	},
 }
 
+func newParser(name string) (parser.Parser, string, error) {
+	if name == "shunt-yard" {
+		p, err := shuntyard.NewParser(parser.DefaultTokenPriorities())
+		return p, "Shunting Yard", err
+	}
+	if name == "pratt" {
+		p, err := pratt.NewParser(parser.DefaultTokenPriorities())
+		return p, "Pratt", err
+	}
+	p, err := recursivedescent.NewParser(parser.DefaultTokenPriorities())
+	return p, "Recursive descent", err
+}
+
+func flagCalculatorOptions() calculatorOptions {
+	return calculatorOptions{
+		precision:      *flagPrecision,
+		complexNumbers: *flagComplex,
+		functions:      !*flagNoFuncs,
+	}
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
+// Process exits with non-zero code when the command fails.
 func Execute() {
-	_ = rootCmd.Execute()
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(1)
+	}
 }
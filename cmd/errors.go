@@ -3,11 +3,15 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"io"
+	"os"
 	"regexp"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/fatih/color"
 
+	"github.com/arxeiss/go-expression-calculator/ast/symbolic"
 	"github.com/arxeiss/go-expression-calculator/evaluator"
 	"github.com/arxeiss/go-expression-calculator/lexer"
 	"github.com/arxeiss/go-expression-calculator/parser"
@@ -16,48 +20,93 @@ import (
 const PrettyPrintErrorOffset = 15
 
 func prettyPrintError(expr string, err error) {
-	if err == nil {
-		return
-	}
-	pos := -1
+	fprintError(os.Stdout, expr, err)
+}
+
+// errorLocation returns kind of the error and its location in the expression, which is unknown for other errors
+func errorLocation(err error) (string, lexer.Location) {
+	location := lexer.UnknownLocation()
 	prefix := "Error"
 	lexerErr := &lexer.Error{}
 	if errors.As(err, &lexerErr) {
-		pos = lexerErr.Position()
+		location = lexerErr.Location()
 		prefix = "Lexer error"
 	}
 	parserErr := &parser.Error{}
 	if errors.As(err, &parserErr) {
-		pos = parserErr.Position()
+		location = parserErr.Location()
 		prefix = "Parser error"
 	}
 	evalErr := &evaluator.Error{}
 	if errors.As(err, &evalErr) {
-		pos = evalErr.Position()
+		location = evalErr.Location()
 		prefix = "Evaluator error"
 	}
+	deriveErr := &symbolic.Error{}
+	if errors.As(err, &deriveErr) {
+		location = deriveErr.Location()
+		prefix = "Derivative error"
+	}
+	return prefix, location
+}
 
-	if pos < 0 {
-		fmt.Println(err.Error())
+// fprintError prints the line of the expression with the error and underlines whole span of the error
+func fprintError(w io.Writer, expr string, err error) {
+	if err == nil {
+		return
+	}
+	prefix, location := errorLocation(err)
+	if !location.Known() || location.Offset > len(expr) {
+		fmt.Fprintln(w, err.Error())
 		return
 	}
-	start := pos - PrettyPrintErrorOffset
-	end := pos + PrettyPrintErrorOffset
-	if start < 0 {
-		start = 0
+	pos := location.Offset
+	// Only the line with the error is printed from multi-line input
+	lineStart := strings.LastIndexByte(expr[:pos], '\n') + 1
+	if lineEnd := strings.IndexByte(expr[pos:], '\n'); lineEnd >= 0 {
+		expr = expr[:pos+lineEnd]
+	}
+	start := runeStart(expr, pos-PrettyPrintErrorOffset)
+	if start < lineStart {
+		start = lineStart
 	}
-	if end > len(expr) {
-		end = len(expr)
+	spanEnd := location.End()
+	if spanEnd > len(expr) {
+		spanEnd = len(expr)
 	}
+	end := runeStart(expr, spanEnd+PrettyPrintErrorOffset)
 
-	fmt.Print(color.RedString("\n  %s: ", prefix), color.HiRedString(err.Error()))
-	fmt.Printf(
-		"\n   | %s\n   | %s^\n\n",
+	// Span of EOL token is empty, but the caret is still printed
+	carets := utf8.RuneCountInString(expr[pos:spanEnd])
+	if carets == 0 {
+		carets = 1
+	}
+
+	fmt.Fprint(w, color.RedString("\n  %s: ", prefix), color.HiRedString(err.Error()))
+	fmt.Fprintf(
+		w,
+		"\n   | %s\n   | %s%s\n\n",
 		colorizeCode(expr[start:end]),
-		color.HiBlackString(strings.Repeat(".", pos-start)),
+		color.HiBlackString(strings.Repeat(".", utf8.RuneCountInString(expr[start:pos]))),
+		strings.Repeat("^", carets),
 	)
 }
 
+// runeStart moves the index back to the first byte of the rune, so the text is not cut in the middle of the rune.
+// Index is also limited to the bounds of the text
+func runeStart(text string, i int) int {
+	if i <= 0 {
+		return 0
+	}
+	if i >= len(text) {
+		return len(text)
+	}
+	for i > 0 && !utf8.RuneStart(text[i]) {
+		i--
+	}
+	return i
+}
+
 func colorizeCode(text string) string {
 	r := regexp.MustCompile(`(\S+)|\s+`)
 	b := strings.Builder{}
@@ -65,7 +114,7 @@ func colorizeCode(text string) string {
 		if v[1] != "" {
 			b.WriteString(color.HiYellowString(v[1]))
 		} else {
-			b.WriteString(color.BlackString(strings.Repeat(".", len(v[0]))))
+			b.WriteString(color.BlackString(strings.Repeat(".", utf8.RuneCountInString(v[0]))))
 		}
 	}
 	return b.String()
@@ -0,0 +1,228 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/arxeiss/go-expression-calculator/ast"
+	"github.com/arxeiss/go-expression-calculator/lexer"
+	"github.com/arxeiss/go-expression-calculator/parser"
+)
+
+const (
+	outputPlain = "plain"
+	outputJSON  = "json"
+)
+
+var (
+	flagEvalFile   *string
+	flagEvalOutput *string
+
+	availableOutputs = []string{outputPlain, outputJSON}
+
+	errEvalFailed = errors.New("evaluation failed")
+)
+
+func init() {
+	flagEvalFile = evalCmd.Flags().StringP(
+		"file", "f", "", "Read expressions from the file, one per line. Use '-' to read from standard input",
+	)
+	flagEvalOutput = evalCmd.Flags().StringP(
+		"output", "o", outputPlain, "Output format, available ones are: '"+strings.Join(availableOutputs, "', '")+"'",
+	)
+	rootCmd.AddCommand(evalCmd)
+}
+
+var evalCmd = &cobra.Command{
+	Use:   "eval [expression...]",
+	Short: "Evaluate expressions without REPL",
+	Long: `Evaluate expressions given as arguments, or read them from the file or standard input, one per line.
+Expression with unclosed parenthesis continues on the next line.
+Each expression can contain more statements separated by semicolon, the value of the last one is printed.
+Variables and functions defined by one expression are available in the following ones.
+Evaluation stops on the first error and the command exits with non-zero code.`,
+	Example: `  calculator eval "x = 3" "x ^ 2"
+  calculator eval "a = 3; b = a * 2; b ^ 2"
+  echo "2 * pi()" | calculator eval
+  calculator eval --file expressions.txt --output json`,
+	// Errors of expressions are printed with the position, usage is not related to them
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !strInStrSlice(availableOutputs, *flagEvalOutput) {
+			return fmt.Errorf("Invalid output, available ones are: '%s'", strings.Join(availableOutputs, "', '"))
+		}
+		if len(args) > 0 && *flagEvalFile != "" {
+			return errors.New("Expressions cannot be passed as arguments and read from the file together")
+		}
+		p, _, err := newParser(*flagParser)
+		if err != nil {
+			return err
+		}
+		calc, _, err := newCalculator(flagCalculatorOptions(), nil)
+		if err != nil {
+			return err
+		}
+		setImplicitMultiplication(p, calc)
+
+		e := &batchEvaluator{calc: calc, parser: p, output: *flagEvalOutput, out: os.Stdout, errOut: os.Stderr}
+		err = e.run(args, *flagEvalFile)
+		if errors.Is(err, errEvalFailed) {
+			// Failed expression is already printed with the position of the error
+			cmd.SilenceErrors = true
+		}
+		return err
+	},
+}
+
+// batchEvaluator evaluates expressions one by one with shared calculator, so variables are kept between them
+type batchEvaluator struct {
+	calc   calculator
+	parser parser.Parser
+	output string
+	out    io.Writer
+	errOut io.Writer
+}
+
+// evalResult is one line of JSON output, only one of result, function and error is set
+type evalResult struct {
+	Line       int    `json:"line"`
+	Expression string `json:"expression"`
+	Result     string `json:"result,omitempty"`
+	Function   string `json:"function,omitempty"`
+	Error      string `json:"error,omitempty"`
+	ErrorKind  string `json:"errorKind,omitempty"`
+	Position   *int   `json:"position,omitempty"`
+}
+
+// run evaluates expressions from arguments, or from the file or standard input when there are none
+func (e *batchEvaluator) run(args []string, file string) error {
+	if len(args) > 0 {
+		return e.evalAll(args)
+	}
+	if file == "" || file == "-" {
+		return e.evalReader(os.Stdin)
+	}
+	input, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+	return e.evalReader(input)
+}
+
+func (e *batchEvaluator) evalAll(expressions []string) error {
+	for i, expr := range expressions {
+		if err := e.eval(i+1, expr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// evalReader evaluates input line by line, empty lines are skipped.
+// Same as in the lexer, new line inside of parentheses does not end the expression, so it continues on the next line
+func (e *batchEvaluator) evalReader(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	line, start := 0, 0
+	expr := &strings.Builder{}
+	for scanner.Scan() {
+		line++
+		if expr.Len() == 0 {
+			start = line
+		}
+		expr.WriteString(scanner.Text())
+		if hasUnclosedParenthesis(expr.String()) {
+			expr.WriteByte('\n')
+			continue
+		}
+		if err := e.eval(start, expr.String()); err != nil {
+			return err
+		}
+		expr.Reset()
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	// Parenthesis left open at the end of the input is reported by the parser
+	return e.eval(start, expr.String())
+}
+
+// hasUnclosedParenthesis returns true when the expression can be tokenized and some parenthesis is not closed.
+// Invalid expressions are left to be evaluated, so the error is reported
+func hasUnclosedParenthesis(expr string) bool {
+	tokens, err := lexer.NewLexer(expr).Tokenize()
+	if err != nil {
+		return false
+	}
+	depth := 0
+	for _, t := range tokens {
+		switch {
+		case t.Type() == lexer.LPar:
+			depth++
+		case t.Type() == lexer.RPar && depth > 0:
+			depth--
+		}
+	}
+	return depth > 0
+}
+
+func (e *batchEvaluator) eval(line int, expr string) error {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return nil
+	}
+	result := evalResult{Line: line, Expression: expr}
+
+	rootNode, err := e.parse(expr)
+	if err == nil {
+		result.Result, err = e.calc.Evaluate(rootNode)
+	}
+	if err != nil {
+		e.printError(result, err)
+		return fmt.Errorf("%w on line %d", errEvalFailed, line)
+	}
+	if def, ok := ast.LastStatement(rootNode).(*ast.FunctionDefNode); ok {
+		result.Result, result.Function = "", def.Name()
+	}
+
+	switch {
+	case e.output == outputJSON:
+		return json.NewEncoder(e.out).Encode(result)
+	case result.Function == "":
+		_, err = fmt.Fprintln(e.out, result.Result)
+	}
+	return err
+}
+
+func (e *batchEvaluator) parse(expr string) (ast.Node, error) {
+	tokenized, err := lexer.NewLexer(expr).Tokenize()
+	if err != nil {
+		return nil, err
+	}
+	return e.parser.Parse(tokenized)
+}
+
+func (e *batchEvaluator) printError(result evalResult, err error) {
+	if e.output == outputJSON {
+		kind, location := errorLocation(err)
+		result.Result, result.Error, result.ErrorKind = "", err.Error(), kind
+		if location.Known() {
+			result.Position = &location.Offset
+		}
+		_ = json.NewEncoder(e.out).Encode(result)
+		return
+	}
+	if _, location := errorLocation(err); !location.Known() {
+		fmt.Fprintf(e.errOut, "Error on line %d: %s\n", result.Line, err.Error())
+		return
+	}
+	fmt.Fprintf(e.errOut, "Error on line %d:", result.Line)
+	fprintError(e.errOut, result.Expression, err)
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"bufio"
 	"fmt"
+	"math/big"
 	"os"
 	"regexp"
 	"strconv"
@@ -12,10 +13,13 @@ import (
 	"github.com/olekukonko/tablewriter"
 
 	"github.com/arxeiss/go-expression-calculator/evaluator"
+	"github.com/arxeiss/go-expression-calculator/lexer"
+	"github.com/arxeiss/go-expression-calculator/parser"
 )
 
 var (
 	variableRegex = regexp.MustCompile("^[a-zA-Z_][a-zA-Z0-9_]*$")
+	basePrefixes  = map[int]string{2: "0b", 8: "0o", 16: "0x"}
 )
 
 func strInStrSlice(slice []string, elem string) bool {
@@ -27,6 +31,61 @@ func strInStrSlice(slice []string, elem string) bool {
 	return false
 }
 
+// literalBase returns base of non-decimal numbers in the expression, like 16 for 0xFF + 1.
+// Decimal base is returned when there are none of them or they are written in different bases
+func literalBase(expr string) int {
+	tokens, err := lexer.NewLexer(expr).Tokenize()
+	if err != nil {
+		return 10
+	}
+	base := 10
+	for _, t := range tokens {
+		if t.Type() != lexer.Number || t.Base() == 10 {
+			continue
+		}
+		if base != 10 && base != t.Base() {
+			return 10
+		}
+		base = t.Base()
+	}
+	return base
+}
+
+// formatInBase prints integer result in the base with its prefix, like 0xFF.
+// Empty string is returned if the result is not an integer or the base is decimal
+func formatInBase(value string, base int) string {
+	r, ok := new(big.Rat).SetString(value)
+	if !ok || !r.IsInt() || base == 10 {
+		return ""
+	}
+	sign, n := "", new(big.Int).Set(r.Num())
+	if n.Sign() < 0 {
+		sign = "-"
+		n.Neg(n)
+	}
+	return sign + basePrefixes[base] + strings.ToUpper(n.Text(base))
+}
+
+// setImplicitMultiplication enables implicit multiplication when it is requested by the flag.
+// Functions are taken from the calculator every time, so user defined functions are not multiplied as well
+func setImplicitMultiplication(p parser.Parser, calc calculator) {
+	ip, ok := p.(parser.ImplicitMultiplicationParser)
+	if !ok || !*flagImplicit {
+		return
+	}
+	ip.SetImplicitMultiplication(parser.ImplicitMultiplication{
+		Enabled: true,
+		IsFunction: func(name string) bool {
+			for _, f := range calc.Functions() {
+				if strings.EqualFold(f.Name, name) {
+					return true
+				}
+			}
+			return false
+		},
+	})
+}
+
 func initVariables(flagInitVars bool) (map[string]float64, error) {
 	if !flagInitVars {
 		return nil, nil
@@ -80,7 +139,7 @@ func initVariables(flagInitVars bool) (map[string]float64, error) {
 	return vars, nil
 }
 
-func prettyPrintVariables(vars []evaluator.VariableTuple) {
+func prettyPrintVariables(vars []variableRow) {
 	table := tablewriter.NewWriter(os.Stdout)
 	table.SetHeader([]string{"Name", "Value"})
 	table.SetAutoWrapText(false)
@@ -89,7 +148,7 @@ func prettyPrintVariables(vars []evaluator.VariableTuple) {
 	for _, v := range vars {
 		table.Append([]string{
 			color.HiBlueString(v.Name),
-			fmt.Sprintf("%.8f", v.Value),
+			v.Value,
 		})
 	}
 
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/arxeiss/go-expression-calculator/evaluator"
+	"github.com/arxeiss/go-expression-calculator/lsp"
+)
+
+func init() {
+	rootCmd.AddCommand(lspCmd)
+}
+
+var lspCmd = &cobra.Command{
+	Use:   "lsp",
+	Short: "Start Language Server Protocol server over standard input and output",
+	Long: `Start Language Server Protocol server over standard input and output, so editors can check expression files.
+Every file is one program with statements separated by semicolon or new line.
+Server reports errors of lexer, parser and evaluator, completes function names and assigned variables,
+shows description of functions on hover, signature help of function calls and goes to definition of variables.
+Files are evaluated with float64 numbers.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if *flagPrecision != "" || *flagComplex {
+			return errors.New("Language server evaluates expressions only with float64 numbers")
+		}
+		if *flagImplicit {
+			return errors.New("Language server does not support implicit multiplication")
+		}
+		p, _, err := newParser(*flagParser)
+		if err != nil {
+			return err
+		}
+		var funcs []map[string]evaluator.FunctionHandler
+		if !*flagNoFuncs {
+			funcs = append(funcs,
+				evaluator.MathFunctions(), evaluator.MathFunctionsWithVarArgs(), evaluator.LazyFunctions())
+		}
+		server, err := lsp.NewServer(p, funcs...)
+		if err != nil {
+			return err
+		}
+		return server.Serve(os.Stdin, os.Stdout)
+	},
+}
@@ -3,71 +3,148 @@ package cmd
 import (
 	"fmt"
 	"strings"
+	"unicode"
 
 	"github.com/fatih/color"
 
 	"github.com/arxeiss/go-expression-calculator/ast"
-	"github.com/arxeiss/go-expression-calculator/evaluator"
+	"github.com/arxeiss/go-expression-calculator/ast/export"
+	"github.com/arxeiss/go-expression-calculator/ast/symbolic"
 	"github.com/arxeiss/go-expression-calculator/lexer"
 	"github.com/arxeiss/go-expression-calculator/parser"
 )
 
-func parseLine(expr string, numEvaluator *evaluator.NumericEvaluator, p parser.Parser) {
+func parseLine(expr string, calc calculator, p parser.Parser) {
 	expr = strings.TrimSpace(expr)
 	switch expr {
 	case "help":
 		fmt.Printf(
-			"%s\n   %s - %s\n   %s - %s\n   %s - %s\n   %s - %s\n   %s - %s\n",
+			"%s\n   %s - %s\n   %s - %s\n   %s - %s\n   %s - %s\n   %s - %s\n   %s - %s\n   %s - %s\n   %s - %s\n",
 			"Write directly any expression to evaluate, or one of those commands:",
-			color.HiYellowString("functions  "), "Show all available functions",
-			color.HiYellowString("variables  "), "Prints all variables with values",
-			color.HiYellowString("help       "), "Show this help",
-			color.HiYellowString("tree {expr}"), "Write tree and then expression to print AST tree",
-			color.HiYellowString("exit       "), "Quit this REPL",
+			color.HiYellowString("functions    "), "Show all available functions",
+			color.HiYellowString("variables    "), "Prints all variables with values",
+			color.HiYellowString("help         "), "Show this help",
+			color.HiYellowString("tree {expr}  "), "Write tree and then expression to print AST tree",
+			color.HiYellowString("diff x {expr}"), "Print derivative of expression with respect to variable x",
+			color.HiYellowString("simplify {e} "), "Print expression with folded constants and collected like terms",
+			color.HiYellowString("latex {expr} "), "Print expression in LaTeX notation",
+			color.HiYellowString("exit         "), "Quit this REPL",
 		)
 	case "func", "funcs", "functions":
-		funcs := numEvaluator.FunctionList()
+		funcs := calc.Functions()
 		if len(funcs) == 0 {
 			fmt.Println(color.YellowString("There are no defined functions"))
 			return
 		}
 		prettyPrintFunctions(funcs)
 	case "vars", "variables":
-		vars := numEvaluator.VariableList()
+		vars := calc.Variables()
 		if len(vars) == 0 {
 			fmt.Println(color.YellowString("There are no variables now"))
 			return
 		}
 		prettyPrintVariables(vars)
 	default:
-		parseExpression(numEvaluator, p, expr)
+		switch {
+		case strings.HasPrefix(expr, "diff "):
+			deriveExpression(p, expr[5:])
+			return
+		case strings.HasPrefix(expr, "simplify "):
+			simplifyExpression(p, strings.TrimSpace(expr[9:]))
+			return
+		case strings.HasPrefix(expr, "latex "):
+			latexExpression(p, strings.TrimSpace(expr[6:]))
+			return
+		}
+		parseExpression(calc, p, expr)
 	}
 }
 
-func parseExpression(numEval *evaluator.NumericEvaluator, p parser.Parser, expr string) {
-	printTree := false
-	if strings.HasPrefix(expr, "tree") {
-		expr = strings.TrimSpace(expr[4:])
-		printTree = true
+// deriveExpression expects variable name followed by the expression, like `x x^2 + 3*x`
+func deriveExpression(p parser.Parser, input string) {
+	input = strings.TrimSpace(input)
+	variable := input
+	expr := ""
+	if i := strings.IndexFunc(input, unicode.IsSpace); i >= 0 {
+		variable, expr = input[:i], strings.TrimSpace(input[i:])
+	}
+	if !variableRegex.MatchString(variable) || expr == "" {
+		fmt.Println(color.RedString("Error: write variable name and then the expression, like 'diff x x^2'"))
+		return
 	}
 
-	l := lexer.NewLexer(expr)
-	tokenized, err := l.Tokenize()
+	rootNode, ok := parseInput(p, expr)
+	if !ok {
+		return
+	}
+	derivative, err := symbolic.Derive(rootNode, variable)
 	if err != nil {
 		prettyPrintError(expr, err)
 		return
 	}
+	derivative = symbolic.Simplify(derivative)
+	formatted := parser.Format(derivative, parser.DefaultTokenPriorities())
+	fmt.Printf("%s d/d%s = %s\n", color.HiBlackString("<-"), variable, formatted)
+	fmt.Println(color.HiCyanString(" Here comes the AST Tree: "))
+	fmt.Print(ast.ToTreeDrawer(derivative))
+}
+
+func simplifyExpression(p parser.Parser, expr string) {
+	rootNode, ok := parseInput(p, expr)
+	if !ok {
+		return
+	}
+	simplified := symbolic.Simplify(rootNode)
+	fmt.Printf("%s %s\n", color.HiBlackString("<-"), parser.Format(simplified, parser.DefaultTokenPriorities()))
+}
+
+func latexExpression(p parser.Parser, expr string) {
+	rootNode, ok := parseInput(p, expr)
+	if !ok {
+		return
+	}
+	fmt.Printf("%s %s\n", color.HiBlackString("<-"), export.ToLaTeX(rootNode))
+}
+
+// parseInput tokenizes and parses the expression, errors are printed directly
+func parseInput(p parser.Parser, expr string) (ast.Node, bool) {
+	tokenized, err := lexer.NewLexer(expr).Tokenize()
+	if err != nil {
+		prettyPrintError(expr, err)
+		return nil, false
+	}
 	rootNode, err := p.Parse(tokenized)
 	if err != nil {
-		prettyPrintError(l.Expression(), err)
+		prettyPrintError(expr, err)
+		return nil, false
+	}
+	return rootNode, true
+}
+
+func parseExpression(calc calculator, p parser.Parser, expr string) {
+	printTree := false
+	if strings.HasPrefix(expr, "tree") {
+		expr = strings.TrimSpace(expr[4:])
+		printTree = true
+	}
+
+	rootNode, ok := parseInput(p, expr)
+	if !ok {
 		return
 	}
-	value, err := numEval.Eval(rootNode)
+	value, err := calc.Evaluate(rootNode)
 	if err != nil {
-		prettyPrintError(l.Expression(), err)
+		prettyPrintError(expr, err)
 		return
 	}
-	fmt.Printf("%s %.8f\n", color.HiBlackString("<-"), value)
+	if def, ok := ast.LastStatement(rootNode).(*ast.FunctionDefNode); ok {
+		fmt.Printf("%s function '%s' was defined\n", color.HiBlackString("<-"), color.HiBlueString(def.Name()))
+	} else if inBase := formatInBase(value, literalBase(expr)); inBase != "" {
+		// Result is echoed also in the base the numbers were written in
+		fmt.Printf("%s %s = %s\n", color.HiBlackString("<-"), value, color.HiBlueString(inBase))
+	} else {
+		fmt.Printf("%s %s\n", color.HiBlackString("<-"), value)
+	}
 	if printTree {
 		fmt.Println(color.HiCyanString(" Here comes the AST Tree: "))
 		fmt.Print(ast.ToTreeDrawer(rootNode))
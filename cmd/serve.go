@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/arxeiss/go-expression-calculator/evaluator"
+	"github.com/arxeiss/go-expression-calculator/httpapi"
+)
+
+var (
+	flagServeAddr     *string
+	flagServeInputLen *int
+	flagServeTokens   *int
+	flagServeDepth    *int
+	flagServeNodes    *int
+	flagServeSteps    *int
+	flagServeSessions *int
+	flagServeIdle     *time.Duration
+)
+
+func init() {
+	flagServeAddr = serveCmd.Flags().StringP("addr", "a", ":8080", "Address the server listens on")
+	flagServeInputLen = serveCmd.Flags().Int(
+		"max-input", 4096, "Maximal length of the expression in bytes, 0 means no limit",
+	)
+	flagServeTokens = serveCmd.Flags().Int("max-tokens", 1000, "Maximal number of tokens, 0 means no limit")
+	flagServeDepth = serveCmd.Flags().Int(
+		"max-depth", 100, "Maximal depth of AST and nesting of parentheses, 0 means no limit",
+	)
+	flagServeNodes = serveCmd.Flags().Int("max-nodes", 1000, "Maximal number of nodes of AST, 0 means no limit")
+	flagServeSteps = serveCmd.Flags().Int(
+		"max-steps", 100000, "Maximal number of evaluated nodes of one request, 0 means no limit",
+	)
+	flagServeSessions = serveCmd.Flags().Int("max-sessions", 1000, "Maximal number of sessions, 0 means no limit")
+	flagServeIdle = serveCmd.Flags().Duration(
+		"session-idle", 30*time.Minute, "Time after which unused session is removed, 0 means sessions are kept",
+	)
+	rootCmd.AddCommand(serveCmd)
+}
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Start HTTP server evaluating expressions",
+	Long: `Start HTTP server evaluating expressions with float64 numbers. Requests and responses are JSON.
+POST /eval evaluates {"expression": "...", "session": "..."} and returns the result,
+requests with the same session share variables and user defined functions.
+POST /parse returns AST of {"expression": "..."}.
+GET /functions lists available functions, user defined ones are included with ?session=...
+DELETE /sessions?session=... removes the session, unused sessions are removed after --session-idle.
+Errors are returned as {"error": {"kind": "...", "message": "...", "position": ..., "location": {...}}}.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if *flagPrecision != "" || *flagComplex {
+			return errors.New("HTTP server evaluates expressions only with float64 numbers")
+		}
+		if *flagImplicit {
+			return errors.New("HTTP server does not support implicit multiplication")
+		}
+		p, parserName, err := newParser(*flagParser)
+		if err != nil {
+			return err
+		}
+		var funcs []map[string]evaluator.FunctionHandler
+		if !*flagNoFuncs {
+			funcs = append(funcs,
+				evaluator.MathFunctions(), evaluator.MathFunctionsWithVarArgs(), evaluator.LazyFunctions())
+		}
+		server, err := httpapi.NewServer(p, httpapi.Limits{
+			InputLength: *flagServeInputLen,
+			Tokens:      *flagServeTokens,
+			Depth:       *flagServeDepth,
+			Nodes:       *flagServeNodes,
+			Steps:       *flagServeSteps,
+			Sessions:    *flagServeSessions,
+			SessionIdle: *flagServeIdle,
+		}, funcs...)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Listening on '%s' with parser '%s'\n", *flagServeAddr, parserName)
+		return http.ListenAndServe(*flagServeAddr, server)
+	},
+}
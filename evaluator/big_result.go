@@ -0,0 +1,699 @@
+package evaluator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/arxeiss/go-expression-calculator/ast"
+	"github.com/arxeiss/go-expression-calculator/lexer"
+)
+
+type BigMode uint8
+
+const (
+	// BigFloatMode evaluates expressions with big.Float with configured precision
+	BigFloatMode BigMode = iota
+	// BigRatMode evaluates expressions with big.Rat, so results are exact rational numbers
+	BigRatMode
+)
+
+// DefaultBigPrecision is precision in bits used when 0 is passed to NewBigEvaluator
+const DefaultBigPrecision = 256
+
+var (
+	ErrDivisionByZero   = errors.New("division by zero")
+	ErrNotANumber       = errors.New("result is not a number")
+	ErrNotExact         = errors.New("result cannot be represented as exact rational number")
+	ErrNonIntegerPower  = errors.New("exponent must be an integer to be evaluated exactly")
+	ErrExponentTooLarge = errors.New("exponent is too large")
+
+	ErrNonIntegerFactorial = errors.New("factorial must be of an integer to be evaluated exactly")
+	ErrFactorialTooLarge   = errors.New("factorial argument is too large")
+)
+
+// BigNumber is value of BigEvaluator. It holds big.Rat in BigRatMode or big.Float in BigFloatMode
+type BigNumber struct {
+	f *big.Float
+	r *big.Rat
+}
+
+type BigVariableTuple struct {
+	Name  string
+	Value *BigNumber
+}
+
+// BigEvaluator evaluates same AST as NumericEvaluator, but with arbitrary precision.
+// Functions are still implemented with float64, so their arguments and results are converted.
+// In BigRatMode only functions marked as Rational are called, and only with arguments kept exactly
+// by the shortest decimal representation of float64, like 0.1 but not 1/3. Otherwise ErrNotExact is returned.
+type BigEvaluator struct {
+	mode          BigMode
+	precision     uint
+	variables     map[string]*BigNumber
+	functions     map[string]FunctionHandler
+	userFunctions map[string]*ast.FunctionDefNode
+	// parent is set only for scope of user defined function, so global variables can be accessed
+	parent *BigEvaluator
+}
+
+// NewBigEvaluator creates evaluator with given mode. Precision is in bits and is used only in BigFloatMode
+func NewBigEvaluator(
+	mode BigMode,
+	precision uint,
+	vars map[string]float64,
+	functions ...map[string]FunctionHandler,
+) (*BigEvaluator, error) {
+	if precision == 0 {
+		precision = DefaultBigPrecision
+	}
+	floatVars, err := normalizeVariables(vars)
+	if err != nil {
+		return nil, err
+	}
+	finalFuncs, err := mergeFunctions(functions...)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &BigEvaluator{
+		mode:          mode,
+		precision:     precision,
+		variables:     make(map[string]*BigNumber, len(floatVars)),
+		functions:     finalFuncs,
+		userFunctions: make(map[string]*ast.FunctionDefNode),
+	}
+	for k, v := range floatVars {
+		if e.variables[k], err = e.fromFloat64(v); err != nil {
+			return nil, fmt.Errorf("variable '%s': %w", k, err)
+		}
+	}
+	return e, nil
+}
+
+// Mode returns if the evaluator uses big.Float or big.Rat
+func (e *BigEvaluator) Mode() BigMode {
+	return e.mode
+}
+
+// Precision returns precision in bits used in BigFloatMode
+func (e *BigEvaluator) Precision() uint {
+	return e.precision
+}
+
+func (e *BigEvaluator) VariableList() []BigVariableTuple {
+	keys := make([]string, 0, len(e.variables))
+	for k := range e.variables {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	ret := make([]BigVariableTuple, 0, len(keys))
+	for _, k := range keys {
+		ret = append(ret, BigVariableTuple{Name: k, Value: e.variables[k]})
+	}
+
+	return ret
+}
+
+func (e *BigEvaluator) FunctionList() []FunctionTuple {
+	functions := make(map[string]FunctionHandler, len(e.functions)+len(e.userFunctions))
+	for k, v := range e.functions {
+		functions[k] = v
+	}
+	for k, def := range e.userFunctions {
+		params := def.ParamNames()
+		functions[k] = FunctionHandler{
+			Description:  "User defined function.",
+			MinArguments: len(params), MaxArguments: len(params),
+			ArgsNames: params,
+		}
+	}
+	return functionList(functions)
+}
+
+// Eval evaluates AST with arbitrary precision numbers
+func (e *BigEvaluator) Eval(rootNode ast.Node) (result *BigNumber, err error) {
+	// big.Float panics when operation would lead to NaN, like Inf - Inf
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(big.ErrNaN); !ok {
+				panic(r)
+			}
+			result, err = nil, EvalError(rootNode.GetToken(), ErrNotANumber)
+		}
+	}()
+	return e.eval(rootNode)
+}
+
+func (e *BigEvaluator) eval(rootNode ast.Node) (*BigNumber, error) {
+	switch n := rootNode.(type) {
+	case *ast.BinaryNode:
+		return e.handleBinary(n)
+	case *ast.UnaryNode:
+		return e.handleUnary(n)
+	case *ast.PostfixNode:
+		return e.handlePostfix(n)
+	case *ast.ConditionalNode:
+		return e.handleConditional(n)
+	case *ast.BlockNode:
+		return e.handleBlock(n)
+	case *ast.FunctionNode:
+		return e.handleFunction(n)
+	case *ast.VariableNode:
+		if v, has := e.variable(strings.ToLower(n.Name())); has {
+			return v, nil
+		}
+		return nil, EvalError(n.GetToken(), fmt.Errorf("undefined variable '%s'", n.Name()))
+	case *ast.AssignNode:
+		val, err := e.eval(n.Right())
+		if err != nil {
+			return nil, err
+		}
+		e.variables[strings.ToLower(n.Left().Name())] = val
+		return val, nil
+	case *ast.FunctionDefNode:
+		return nil, e.defineFunction(n)
+	case *ast.NumericNode:
+		if n.Imaginary() {
+			return nil, EvalError(n.GetToken(), ErrImaginaryNumber)
+		}
+		return e.fromLiteral(n.Literal(), n.GetToken())
+	}
+	return nil, EvalError(rootNode.GetToken(), fmt.Errorf("unimplemented node type %T", rootNode))
+}
+
+func (e *BigEvaluator) variable(name string) (*BigNumber, bool) {
+	if v, has := e.variables[name]; has {
+		return v, true
+	}
+	if e.parent != nil {
+		return e.parent.variable(name)
+	}
+	return nil, false
+}
+
+func (e *BigEvaluator) defineFunction(n *ast.FunctionDefNode) error {
+	name := strings.ToLower(n.Name())
+	if _, has := e.functions[name]; has {
+		return EvalError(n.GetToken(), fmt.Errorf("cannot redefine built-in function '%s'", n.Name()))
+	}
+	if callsFunction(n.Body(), name, e.userFunctions, make(map[string]bool)) {
+		return EvalError(n.GetToken(), fmt.Errorf("function '%s' cannot call itself", n.Name()))
+	}
+	e.userFunctions[name] = n
+	return nil
+}
+
+func (e *BigEvaluator) handleUnary(n *ast.UnaryNode) (*BigNumber, error) {
+	val, err := e.eval(n.Next())
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.Operator() {
+	case ast.Substraction:
+		if e.mode == BigRatMode {
+			return &BigNumber{r: new(big.Rat).Neg(val.r)}, nil
+		}
+		return &BigNumber{f: e.newFloat().Neg(val.f)}, nil
+	case ast.Addition:
+		return val, nil
+	case ast.Not:
+		return e.fromBool(val.sign() == 0), nil
+	}
+
+	return nil, EvalError(n.GetToken(), errors.New("unary node supports only Addition, Substraction and Not operator"))
+}
+
+func (e *BigEvaluator) handlePostfix(n *ast.PostfixNode) (*BigNumber, error) {
+	val, err := e.eval(n.Prev())
+	if err != nil {
+		return nil, err
+	}
+
+	var res *BigNumber
+	switch n.Operator() {
+	case ast.Factorial:
+		res, err = e.factorial(val)
+	case ast.Percent:
+		hundred, _ := e.fromFloat64(100)
+		res, err = e.quo(val, hundred)
+	default:
+		return nil, EvalError(n.GetToken(), errors.New("postfix node supports only Factorial and Percent operator"))
+	}
+	if err != nil {
+		return nil, EvalError(n.GetToken(), err)
+	}
+	return res, nil
+}
+
+func (e *BigEvaluator) handleBinary(n *ast.BinaryNode) (*BigNumber, error) {
+	if n.Operator() == ast.And || n.Operator() == ast.Or {
+		return e.handleLogical(n)
+	}
+	l, err := e.eval(n.Left())
+	if err != nil {
+		return nil, err
+	}
+	r, err := e.eval(n.Right())
+	if err != nil {
+		return nil, err
+	}
+	// Percent is relative to the left operand, so 100 + 10% is 110
+	if n.RelativePercent() {
+		r = e.arithmetic(ast.Multiplication, l, r)
+	}
+
+	var res *BigNumber
+	switch n.Operator() {
+	case ast.Addition, ast.Substraction, ast.Multiplication:
+		res = e.arithmetic(n.Operator(), l, r)
+	case ast.Division:
+		res, err = e.quo(l, r)
+	case ast.FloorDiv:
+		if res, err = e.quo(l, r); err == nil {
+			res = e.floor(res)
+		}
+	case ast.Exponent:
+		res, err = e.pow(l, r)
+	case ast.Modulus:
+		res, err = e.mod(l, r)
+	case ast.Less, ast.LessOrEqual, ast.Greater, ast.GreaterOrEqual, ast.IsEqual, ast.NotEqual:
+		// Result of Cmp compared with zero gives same answer as comparing both numbers
+		res = e.fromBool(compare(n.Operator(), float64(l.cmp(r)), 0) != 0)
+	default:
+		return nil, EvalError(n.GetToken(), fmt.Errorf("unimplemented operator %s", n.Operator()))
+	}
+	if err != nil {
+		return nil, EvalError(n.GetToken(), err)
+	}
+	return res, nil
+}
+
+// handleLogical evaluates right side only when the left one does not decide the result yet
+func (e *BigEvaluator) handleLogical(n *ast.BinaryNode) (*BigNumber, error) {
+	l, err := e.eval(n.Left())
+	if err != nil {
+		return nil, err
+	}
+	if (l.sign() != 0) == (n.Operator() == ast.Or) {
+		return e.fromBool(l.sign() != 0), nil
+	}
+	r, err := e.eval(n.Right())
+	if err != nil {
+		return nil, err
+	}
+	return e.fromBool(r.sign() != 0), nil
+}
+
+// handleConditional evaluates only the branch selected by the condition
+func (e *BigEvaluator) handleConditional(n *ast.ConditionalNode) (*BigNumber, error) {
+	condition, err := e.eval(n.Condition())
+	if err != nil {
+		return nil, err
+	}
+	if condition.sign() != 0 {
+		return e.eval(n.Then())
+	}
+	return e.eval(n.Else())
+}
+
+// handleBlock evaluates statements in order and returns the value of the last one.
+// Each statement is evaluated by Eval, so the error of Not-a-Number result points to the statement
+func (e *BigEvaluator) handleBlock(n *ast.BlockNode) (*BigNumber, error) {
+	var val *BigNumber
+	var err error
+	for _, statement := range n.Statements() {
+		if val, err = e.Eval(statement); err != nil {
+			return nil, err
+		}
+	}
+	return val, nil
+}
+
+func (e *BigEvaluator) handleFunction(n *ast.FunctionNode) (*BigNumber, error) {
+	name := strings.ToLower(n.Name())
+	if def, has := e.userFunctions[name]; has {
+		return e.callUserFunction(def, n)
+	}
+	f, has := e.functions[name]
+	if !has {
+		return nil, EvalError(n.GetToken(), fmt.Errorf("undefined function '%s'", n.Name()))
+	}
+	if err := checkArgumentsCount(f, n); err != nil {
+		return nil, err
+	}
+	if f.LazyHandler != nil {
+		return e.callLazy(f, n)
+	}
+	if e.mode == BigRatMode && !f.Rational {
+		return nil, EvalError(n.GetToken(), fmt.Errorf("%w in function '%s'", ErrNotExact, n.Name()))
+	}
+
+	args := []float64{}
+	for _, p := range n.Params() {
+		v, err := e.eval(p)
+		if err != nil {
+			return nil, err
+		}
+		arg, err := e.exactArgument(v)
+		if err != nil {
+			return nil, EvalError(n.GetToken(), fmt.Errorf("%w in function '%s'", err, n.Name()))
+		}
+		args = append(args, arg)
+	}
+
+	val, err := f.call(context.Background(), CallSite{Name: n.Name(), Token: n.GetToken()}, args...)
+	if err != nil {
+		return nil, functionError(n.GetToken(), n.Name(), err)
+	}
+	res, err := e.fromFloat64(val)
+	if err != nil {
+		return nil, EvalError(n.GetToken(), fmt.Errorf("%w in function '%s'", err, n.Name()))
+	}
+	return res, nil
+}
+
+// exactArgument converts the argument to float64 for functions in BigRatMode.
+// Only arguments, which come back unchanged through their shortest decimal representation, like 0.1, are exact
+func (e *BigEvaluator) exactArgument(v *BigNumber) (float64, error) {
+	f := v.Float64()
+	if e.mode != BigRatMode {
+		return f, nil
+	}
+	back, err := e.fromFloat64(f)
+	if err != nil || back.r.Cmp(v.r) != 0 {
+		return 0, ErrNotExact
+	}
+	return f, nil
+}
+
+// callLazy passes arguments converted to float64 into the lazy handler.
+// When the handler returns some argument unchanged, like if() does, the argument keeps its full precision
+func (e *BigEvaluator) callLazy(f FunctionHandler, n *ast.FunctionNode) (*BigNumber, error) {
+	params := n.Params()
+	values := make([]*BigNumber, len(params))
+	lazyRes, err := callLazy(f.LazyHandler, len(params), func(i int) (float64, error) {
+		v, err := e.eval(params[i])
+		if err != nil {
+			return 0, err
+		}
+		values[i] = v
+		return v.Float64(), nil
+	}, n.GetToken(), n.Name())
+	if err != nil {
+		return nil, err
+	}
+	if lazyRes.Arg >= 0 && lazyRes.Arg < len(values) && values[lazyRes.Arg] != nil {
+		return values[lazyRes.Arg], nil
+	}
+	res, err := e.fromFloat64(lazyRes.Value)
+	if err != nil {
+		return nil, EvalError(n.GetToken(), fmt.Errorf("%w in function '%s'", err, n.Name()))
+	}
+	return res, nil
+}
+
+func (e *BigEvaluator) callUserFunction(def *ast.FunctionDefNode, n *ast.FunctionNode) (*BigNumber, error) {
+	params := def.ParamNames()
+	f := FunctionHandler{MinArguments: len(params), MaxArguments: len(params)}
+	if err := checkArgumentsCount(f, n); err != nil {
+		return nil, err
+	}
+	scope := &BigEvaluator{
+		mode:          e.mode,
+		precision:     e.precision,
+		variables:     make(map[string]*BigNumber, len(params)),
+		functions:     e.functions,
+		userFunctions: e.userFunctions,
+		parent:        e,
+	}
+	for i, p := range n.Params() {
+		v, err := e.eval(p)
+		if err != nil {
+			return nil, err
+		}
+		scope.variables[strings.ToLower(params[i])] = v
+	}
+	return scope.eval(def.Body())
+}
+
+func (e *BigEvaluator) newFloat() *big.Float {
+	return new(big.Float).SetPrec(e.precision)
+}
+
+func (e *BigEvaluator) fromLiteral(literal string, token *lexer.Token) (*BigNumber, error) {
+	if e.mode == BigRatMode {
+		if r, ok := new(big.Rat).SetString(literal); ok {
+			return &BigNumber{r: r}, nil
+		}
+	} else if f, ok := e.newFloat().SetString(literal); ok {
+		return &BigNumber{f: f}, nil
+	}
+	return nil, EvalError(token, fmt.Errorf("cannot parse number '%s'", literal))
+}
+
+// fromFloat64 converts float64 through its shortest decimal representation, so 0.1 is not stored as binary fraction
+func (e *BigEvaluator) fromFloat64(v float64) (*BigNumber, error) {
+	switch {
+	case math.IsNaN(v):
+		return nil, ErrNotANumber
+	case math.IsInf(v, 0):
+		if e.mode == BigRatMode {
+			return nil, ErrNotExact
+		}
+		return &BigNumber{f: e.newFloat().SetInf(v < 0)}, nil
+	}
+	return e.fromLiteral(strconv.FormatFloat(v, 'g', -1, 64), nil)
+}
+
+func (e *BigEvaluator) arithmetic(op ast.Operation, l, r *BigNumber) *BigNumber {
+	if e.mode == BigRatMode {
+		res := new(big.Rat)
+		switch op {
+		case ast.Addition:
+			res.Add(l.r, r.r)
+		case ast.Substraction:
+			res.Sub(l.r, r.r)
+		case ast.Multiplication:
+			res.Mul(l.r, r.r)
+		}
+		return &BigNumber{r: res}
+	}
+	res := e.newFloat()
+	switch op {
+	case ast.Addition:
+		res.Add(l.f, r.f)
+	case ast.Substraction:
+		res.Sub(l.f, r.f)
+	case ast.Multiplication:
+		res.Mul(l.f, r.f)
+	}
+	return &BigNumber{f: res}
+}
+
+func (e *BigEvaluator) quo(l, r *BigNumber) (*BigNumber, error) {
+	if e.mode == BigRatMode {
+		if r.r.Sign() == 0 {
+			return nil, ErrDivisionByZero
+		}
+		return &BigNumber{r: new(big.Rat).Quo(l.r, r.r)}, nil
+	}
+	return &BigNumber{f: e.newFloat().Quo(l.f, r.f)}, nil
+}
+
+func (e *BigEvaluator) floor(x *BigNumber) *BigNumber {
+	if e.mode == BigRatMode {
+		// Denominator is always positive, so Euclidean division is same as floor
+		return &BigNumber{r: new(big.Rat).SetInt(new(big.Int).Div(x.r.Num(), x.r.Denom()))}
+	}
+	if x.f.IsInf() {
+		return x
+	}
+	i, acc := x.f.Int(nil)
+	// Int truncates towards zero, so negative numbers with fraction part are above the original value
+	if acc == big.Above {
+		i.Sub(i, big.NewInt(1))
+	}
+	return &BigNumber{f: e.newFloat().SetInt(i)}
+}
+
+// mod returns remainder with same sign as l, same as math.Mod does
+func (e *BigEvaluator) mod(l, r *BigNumber) (*BigNumber, error) {
+	if e.mode == BigRatMode {
+		if r.r.Sign() == 0 {
+			return nil, ErrDivisionByZero
+		}
+		q := new(big.Rat).Quo(l.r, r.r)
+		trunc := new(big.Rat).SetInt(new(big.Int).Quo(q.Num(), q.Denom()))
+		return &BigNumber{r: new(big.Rat).Sub(l.r, trunc.Mul(trunc, r.r))}, nil
+	}
+	if r.f.Sign() == 0 || l.f.IsInf() {
+		return nil, ErrNotANumber
+	}
+	if r.f.IsInf() {
+		return l, nil
+	}
+	q := e.newFloat().Quo(l.f, r.f)
+	i, _ := q.Int(nil)
+	trunc := e.newFloat().SetInt(i)
+	return &BigNumber{f: e.newFloat().Sub(l.f, trunc.Mul(trunc, r.f))}, nil
+}
+
+// maxBigExponent limits the size of integer exponent, as the result would not fit into memory
+const maxBigExponent = 1 << 24
+
+func (e *BigEvaluator) pow(base, exp *BigNumber) (*BigNumber, error) {
+	if !exp.IsInt() {
+		if e.mode == BigRatMode {
+			return nil, ErrNonIntegerPower
+		}
+		// There is no arbitrary precision Pow with fraction exponent, fallback to float64
+		return e.fromFloat64(math.Pow(base.Float64(), exp.Float64()))
+	}
+	n, ok := exp.int64()
+	if !ok || n > maxBigExponent || n < -maxBigExponent {
+		return nil, ErrExponentTooLarge
+	}
+	negative := n < 0
+	if negative {
+		n = -n
+	}
+
+	res := e.one()
+	sq := base
+	for ; n > 0; n >>= 1 {
+		if n&1 == 1 {
+			res = e.arithmetic(ast.Multiplication, res, sq)
+		}
+		sq = e.arithmetic(ast.Multiplication, sq, sq)
+	}
+	if negative {
+		return e.quo(e.one(), res)
+	}
+	return res, nil
+}
+
+// maxBigFactorial limits the argument of factorial, as the result would not fit into memory
+const maxBigFactorial = 1 << 16
+
+// factorial is exact for integers, non-integer values fallback to gamma function of float64
+func (e *BigEvaluator) factorial(x *BigNumber) (*BigNumber, error) {
+	if !x.IsInt() {
+		if e.mode == BigRatMode {
+			return nil, ErrNonIntegerFactorial
+		}
+		return e.fromFloat64(factorial(x.Float64()))
+	}
+	n, ok := x.int64()
+	switch {
+	case !ok || n > maxBigFactorial:
+		return nil, ErrFactorialTooLarge
+	case n < 0:
+		return nil, ErrNotANumber
+	}
+	res := new(big.Int).MulRange(1, n)
+	if e.mode == BigRatMode {
+		return &BigNumber{r: new(big.Rat).SetInt(res)}, nil
+	}
+	return &BigNumber{f: e.newFloat().SetInt(res)}, nil
+}
+
+func (e *BigEvaluator) one() *BigNumber {
+	if e.mode == BigRatMode {
+		return &BigNumber{r: big.NewRat(1, 1)}
+	}
+	return &BigNumber{f: e.newFloat().SetInt64(1)}
+}
+
+// fromBool returns 1 for true and 0 for false
+func (e *BigEvaluator) fromBool(b bool) *BigNumber {
+	if b {
+		return e.one()
+	}
+	if e.mode == BigRatMode {
+		return &BigNumber{r: new(big.Rat)}
+	}
+	return &BigNumber{f: e.newFloat()}
+}
+
+// IsRat returns true when number was evaluated in BigRatMode
+func (n *BigNumber) IsRat() bool {
+	return n.r != nil
+}
+
+// IsInt checks if the number is integer
+func (n *BigNumber) IsInt() bool {
+	if n.r != nil {
+		return n.r.IsInt()
+	}
+	return n.f.IsInt()
+}
+
+func (n *BigNumber) int64() (int64, bool) {
+	if n.r != nil {
+		return n.r.Num().Int64(), n.r.Num().IsInt64()
+	}
+	i, acc := n.f.Int64()
+	return i, acc == big.Exact
+}
+
+func (n *BigNumber) sign() int {
+	if n.r != nil {
+		return n.r.Sign()
+	}
+	return n.f.Sign()
+}
+
+func (n *BigNumber) cmp(o *BigNumber) int {
+	if n.r != nil {
+		return n.r.Cmp(o.r)
+	}
+	return n.f.Cmp(o.f)
+}
+
+// Float returns copy of value as big.Float, rational number is converted with given precision
+func (n *BigNumber) Float(precision uint) *big.Float {
+	if n.r != nil {
+		return new(big.Float).SetPrec(precision).SetRat(n.r)
+	}
+	return new(big.Float).Copy(n.f)
+}
+
+// Rat returns copy of value as big.Rat, returns nil when the value is infinite
+func (n *BigNumber) Rat() *big.Rat {
+	if n.r != nil {
+		return new(big.Rat).Set(n.r)
+	}
+	if n.f.IsInf() {
+		return nil
+	}
+	r, _ := n.f.Rat(nil)
+	return r
+}
+
+// Float64 returns nearest float64 value
+func (n *BigNumber) Float64() float64 {
+	if n.r != nil {
+		f, _ := n.r.Float64()
+		return f
+	}
+	f, _ := n.f.Float64()
+	return f
+}
+
+// String returns fraction `a/b` for rational numbers and decimal number with all significant digits otherwise
+func (n *BigNumber) String() string {
+	if n.r != nil {
+		return n.r.RatString()
+	}
+	// Last digit is not printed, as it is affected by rounding errors
+	digits := int(float64(n.f.Prec())*math.Log10(2)) - 1
+	return n.f.Text('g', digits)
+}
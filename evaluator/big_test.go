@@ -0,0 +1,160 @@
+package evaluator_test
+
+import (
+	"math/big"
+
+	"github.com/arxeiss/go-expression-calculator/ast"
+	"github.com/arxeiss/go-expression-calculator/evaluator"
+	"github.com/arxeiss/go-expression-calculator/lexer"
+	"github.com/arxeiss/go-expression-calculator/parser"
+	"github.com/arxeiss/go-expression-calculator/parser/recursivedescent"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+func parseExpression(expr string) ast.Node {
+	tokens, err := lexer.NewLexer(expr).Tokenize()
+	Expect(err).To(Succeed())
+	p, err := recursivedescent.NewParser(parser.DefaultTokenPriorities())
+	Expect(err).To(Succeed())
+	rootNode, err := p.Parse(tokens)
+	Expect(err).To(Succeed())
+	return rootNode
+}
+
+var _ = Describe("Big evaluator", func() {
+	DescribeTable("Rational mode",
+		func(expr, expected string) {
+			ev, err := evaluator.NewBigEvaluator(evaluator.BigRatMode, 0, map[string]float64{"x": 0.1},
+				evaluator.MathFunctions(), evaluator.MathFunctionsWithVarArgs(), evaluator.LazyFunctions())
+			Expect(err).To(Succeed())
+			res, err := ev.Eval(parseExpression(expr))
+			Expect(err).To(Succeed())
+			Expect(res.IsRat()).To(BeTrue())
+			Expect(res.String()).To(Equal(expected))
+		},
+		Entry("Decimal numbers", "0.1 + 0.2", "3/10"),
+		Entry("Numbers in other bases", "0xFF + 0b1010 - 0o7 + 1_000.5", "2517/2"),
+		Entry("Variable is converted from decimal representation", "x * 3", "3/10"),
+		Entry("Division", "1 / 3 - 1 / 6", "1/6"),
+		Entry("Large exponent", "2 ^ 100", "1267650600228229401496703205376"),
+		Entry("Negative exponent", "(2/3) ^ -2", "9/4"),
+		Entry("Exponent in scientific notation", "1.5e-3 * 2e3", "3"),
+		Entry("Floor division", "7 // 2", "3"),
+		Entry("Negative floor division", "-7 // 2", "-4"),
+		Entry("Modulus", "7.5 % 2", "3/2"),
+		Entry("Negative modulus", "-7 % 3", "-1"),
+		Entry("Unary operators", "-+-4", "4"),
+		Entry("Function", "abs(-16) + max(0.5, 0.25)", "33/2"),
+		Entry("Rational functions of decimal arguments", "floor(-0.1) + min(0.1, 0.2) * ceil(2.5)", "-7/10"),
+		Entry("Assign", "y = 1/8", "1/8"),
+		Entry("Exact comparison", "0.1 + 0.2 == 0.3", "1"),
+		Entry("Logical operators", "1/3 < 0.34 && !(2 >= 3) || x", "1"),
+		Entry("Short-circuit skips division by zero", "0 && 1 / 0", "0"),
+		Entry("Conditional", "x > 1 ? 1 / 0 : x / 3", "1/30"),
+		Entry("Lazy function keeps precision", "if(1, 1/3, 0)", "1/3"),
+		Entry(
+			"Lazy function returns chosen argument", "if(1, 1 + 2^-60, 0)", "1152921504606846977/1152921504606846976",
+		),
+		Entry("Lazy function computing the result", "and(1/3, 2)", "1"),
+		Entry("Statements", "y = 1/3; y * 3", "1"),
+		Entry("Large factorial", "25!", "15511210043330985984000000"),
+		Entry("Factorial of zero", "0!", "1"),
+		Entry("Percent", "1/3 * 10%", "1/30"),
+		Entry("Percent added to the left operand", "x + 15%", "23/200"),
+	)
+
+	DescribeTable("Float mode",
+		func(expr, expected string) {
+			ev, err := evaluator.NewBigEvaluator(evaluator.BigFloatMode, 128, nil, evaluator.MathFunctions())
+			Expect(err).To(Succeed())
+			res, err := ev.Eval(parseExpression(expr))
+			Expect(err).To(Succeed())
+			Expect(res.IsRat()).To(BeFalse())
+			Expect(res.String()).To(Equal(expected))
+		},
+		Entry("Decimal numbers", "0.1 + 0.2", "0.3"),
+		Entry("More digits than float64", "0.1234567890123456789 * 10", "1.234567890123456789"),
+		Entry("Hexadecimal over float64 precision", "0xFFFF_FFFF_FFFF_FFFF - 0xFFFF_FFFF_FFFF_FFFE", "1"),
+		Entry("Large exponent", "2 ^ 100 + 1", "1267650600228229401496703205377"),
+		Entry("Fraction exponent", "4 ^ 0.5", "2"),
+		Entry("Floor division", "-7 // 2", "-4"),
+		Entry("Modulus", "-7.5 % 2", "-1.5"),
+		Entry("Division by zero", "1 / 0", "+Inf"),
+		Entry("Function", "floor(2.7)", "2"),
+		Entry("Comparison", "0.1 + 0.2 != 0.3", "0"),
+		Entry("Logical negation", "!0 || 1 / 0", "1"),
+		Entry("Factorial", "30! / 29!", "30"),
+		Entry("Factorial of non-integer falls back to float64", "0.5!", "0.8862269254527579"),
+		Entry("Percent substracted from the left operand", "0.3 - 10%", "0.27"),
+	)
+
+	DescribeTable("Errors",
+		func(mode evaluator.BigMode, expr string, errMatcher error) {
+			ev, err := evaluator.NewBigEvaluator(mode, 0, nil, evaluator.MathFunctions())
+			Expect(err).To(Succeed())
+			_, err = ev.Eval(parseExpression(expr))
+			Expect(err).To(MatchError(errMatcher))
+		},
+		Entry("Rational division by zero", evaluator.BigRatMode, "1 / (2 - 2)", evaluator.ErrDivisionByZero),
+		Entry("Rational modulus by zero", evaluator.BigRatMode, "1 % 0", evaluator.ErrDivisionByZero),
+		Entry("Rational fraction exponent", evaluator.BigRatMode, "2 ^ 0.5", evaluator.ErrNonIntegerPower),
+		Entry("Too large exponent", evaluator.BigRatMode, "2 ^ 1e10", evaluator.ErrExponentTooLarge),
+		Entry("Not a number result", evaluator.BigFloatMode, "1 / 0 - 1 / 0", evaluator.ErrNotANumber),
+		Entry("Not a number from function", evaluator.BigFloatMode, "sqrt(-1)", evaluator.ErrNotANumber),
+		Entry("Rational factorial of fraction", evaluator.BigRatMode, "0.5!", evaluator.ErrNonIntegerFactorial),
+		Entry("Factorial of negative integer", evaluator.BigFloatMode, "(-3)!", evaluator.ErrNotANumber),
+		Entry("Too large factorial", evaluator.BigRatMode, "1e6!", evaluator.ErrFactorialTooLarge),
+		Entry("Rational non-rational function", evaluator.BigRatMode, "sqrt(2)", evaluator.ErrNotExact),
+		Entry("Rational non-rational function of a square", evaluator.BigRatMode, "sqrt(16)", evaluator.ErrNotExact),
+		Entry("Rational function of integer", evaluator.BigRatMode, "cos(0)", evaluator.ErrNotExact),
+		Entry("Rational function of fraction", evaluator.BigRatMode, "abs(-1/3)", evaluator.ErrNotExact),
+	)
+
+	It("Evaluates numbers out of float64 range", func() {
+		for _, mode := range []evaluator.BigMode{evaluator.BigRatMode, evaluator.BigFloatMode} {
+			bigEv, err := evaluator.NewBigEvaluator(mode, 0, nil)
+			Expect(err).To(Succeed())
+			res, err := bigEv.Eval(parseExpression("1e400 / 1e399"))
+			Expect(err).To(Succeed())
+			Expect(res.String()).To(Equal("10"))
+		}
+
+		ev, err := evaluator.NewNumericEvaluator(nil)
+		Expect(err).To(Succeed())
+		_, err = ev.Eval(parseExpression("1 + 1e400"))
+		Expect(err).To(MatchError(lexer.ErrNumberOutOfRange))
+		Expect(err).To(MatchError("number is out of range at position 4"))
+		_, err = ev.Compile(parseExpression("1 + 1e400"))
+		Expect(err).To(MatchError(lexer.ErrNumberOutOfRange))
+		_, err = ev.CompileOptimized(parseExpression("-1e400"))
+		Expect(err).To(MatchError(lexer.ErrNumberOutOfRange))
+	})
+
+	It("Keeps variables and user defined functions", func() {
+		ev, err := evaluator.NewBigEvaluator(evaluator.BigRatMode, 0, nil, evaluator.MathFunctions())
+		Expect(err).To(Succeed())
+		_, err = ev.Eval(parseExpression("a = 1/3"))
+		Expect(err).To(Succeed())
+		_, err = ev.Eval(parseExpression("f(x) = x * a"))
+		Expect(err).To(Succeed())
+
+		res, err := ev.Eval(parseExpression("f(0.3)"))
+		Expect(err).To(Succeed())
+		Expect(res.Rat()).To(Equal(big.NewRat(1, 10)))
+		Expect(res.Float64()).To(Equal(0.1))
+		Expect(res.Float(64).String()).To(Equal("0.1"))
+
+		vars := ev.VariableList()
+		Expect(vars).To(HaveLen(1))
+		Expect(vars[0].Name).To(Equal("a"))
+		Expect(vars[0].Value.String()).To(Equal("1/3"))
+
+		funcs := ev.FunctionList()
+		Expect(funcs).To(HaveLen(len(evaluator.MathFunctions()) + 1))
+		_, err = ev.Eval(parseExpression("sin(x) = x"))
+		Expect(err).To(MatchError(ContainSubstring("cannot redefine built-in function 'sin'")))
+	})
+})
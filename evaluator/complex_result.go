@@ -0,0 +1,390 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/cmplx"
+	"sort"
+	"strings"
+
+	"github.com/arxeiss/go-expression-calculator/ast"
+)
+
+var (
+	ErrImaginaryNumber = errors.New("imaginary numbers are supported only by complex evaluator")
+	ErrNotRealNumber   = errors.New("operation is defined only for real numbers")
+)
+
+type ComplexFunctionHandler struct {
+	Description  string
+	Handler      func(x ...complex128) (complex128, error)
+	MinArguments int
+	MaxArguments int
+	ArgsNames    []string
+}
+
+type ComplexVariableTuple struct {
+	Name  string
+	Value complex128
+}
+type ComplexFunctionTuple struct {
+	Name     string
+	Function ComplexFunctionHandler
+}
+
+// ComplexEvaluator evaluates same AST as NumericEvaluator, but with complex128 numbers.
+// Numbers with imaginary suffix, like 3i, are evaluated as imaginary part.
+type ComplexEvaluator struct {
+	variables     map[string]complex128
+	functions     map[string]ComplexFunctionHandler
+	userFunctions map[string]*ast.FunctionDefNode
+	// parent is set only for scope of user defined function, so global variables can be accessed
+	parent *ComplexEvaluator
+}
+
+func NewComplexEvaluator(
+	vars map[string]complex128,
+	functions ...map[string]ComplexFunctionHandler,
+) (*ComplexEvaluator, error) {
+	variables := make(map[string]complex128)
+	varNames := make(map[string]string)
+	for kcs, v := range vars {
+		k := strings.ToLower(kcs)
+		if pn, has := varNames[k]; has {
+			return nil, fmt.Errorf(
+				"variable with name '%s' was defined as '%s' before, variables are case insensitive", kcs, pn)
+		}
+		varNames[k] = kcs
+		variables[k] = v
+	}
+
+	finalFuncs := make(map[string]ComplexFunctionHandler)
+	funcsNames := make(map[string]string)
+	for _, funcs := range functions {
+		for kcs, v := range funcs {
+			k := strings.ToLower(kcs)
+			if pn, has := funcsNames[k]; has {
+				return nil, fmt.Errorf(
+					"function named '%s' was defined as '%s' before, function names are case insensitive", kcs, pn)
+			}
+			funcsNames[k] = kcs
+			finalFuncs[k] = v
+		}
+	}
+
+	return &ComplexEvaluator{
+		variables:     variables,
+		functions:     finalFuncs,
+		userFunctions: make(map[string]*ast.FunctionDefNode),
+	}, nil
+}
+
+func (e *ComplexEvaluator) VariableList() []ComplexVariableTuple {
+	keys := make([]string, 0, len(e.variables))
+	for k := range e.variables {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	ret := make([]ComplexVariableTuple, 0, len(keys))
+	for _, k := range keys {
+		ret = append(ret, ComplexVariableTuple{Name: k, Value: e.variables[k]})
+	}
+
+	return ret
+}
+
+func (e *ComplexEvaluator) FunctionList() []ComplexFunctionTuple {
+	functions := make(map[string]ComplexFunctionHandler, len(e.functions)+len(e.userFunctions))
+	for k, v := range e.functions {
+		functions[k] = v
+	}
+	for k, def := range e.userFunctions {
+		params := def.ParamNames()
+		functions[k] = ComplexFunctionHandler{
+			Description:  "User defined function.",
+			MinArguments: len(params), MaxArguments: len(params),
+			ArgsNames: params,
+		}
+	}
+
+	keys := make([]string, 0, len(functions))
+	for k := range functions {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	ret := make([]ComplexFunctionTuple, 0, len(keys))
+	for _, k := range keys {
+		ret = append(ret, ComplexFunctionTuple{Name: k, Function: functions[k]})
+	}
+	return ret
+}
+
+func (e *ComplexEvaluator) Eval(rootNode ast.Node) (complex128, error) {
+	switch n := rootNode.(type) {
+	case *ast.BinaryNode:
+		return e.handleBinary(n)
+	case *ast.UnaryNode:
+		return e.handleUnary(n)
+	case *ast.PostfixNode:
+		return e.handlePostfix(n)
+	case *ast.ConditionalNode:
+		return e.handleConditional(n)
+	case *ast.BlockNode:
+		return e.handleBlock(n)
+	case *ast.FunctionNode:
+		return e.handleFunction(n)
+	case *ast.VariableNode:
+		if v, has := e.variable(strings.ToLower(n.Name())); has {
+			return v, nil
+		}
+		return 0, EvalError(n.GetToken(), fmt.Errorf("undefined variable '%s'", n.Name()))
+	case *ast.AssignNode:
+		val, err := e.Eval(n.Right())
+		if err != nil {
+			return 0, err
+		}
+		e.variables[strings.ToLower(n.Left().Name())] = val
+		return val, nil
+	case *ast.FunctionDefNode:
+		return 0, e.defineFunction(n)
+	case *ast.NumericNode:
+		v, err := numberValue(n)
+		if err != nil {
+			return 0, err
+		}
+		if n.Imaginary() {
+			return complex(0, v), nil
+		}
+		return complex(v, 0), nil
+	}
+	return 0, EvalError(rootNode.GetToken(), fmt.Errorf("unimplemented node type %T", rootNode))
+}
+
+func (e *ComplexEvaluator) variable(name string) (complex128, bool) {
+	if v, has := e.variables[name]; has {
+		return v, true
+	}
+	if e.parent != nil {
+		return e.parent.variable(name)
+	}
+	return 0, false
+}
+
+func (e *ComplexEvaluator) defineFunction(n *ast.FunctionDefNode) error {
+	name := strings.ToLower(n.Name())
+	if _, has := e.functions[name]; has {
+		return EvalError(n.GetToken(), fmt.Errorf("cannot redefine built-in function '%s'", n.Name()))
+	}
+	if callsFunction(n.Body(), name, e.userFunctions, make(map[string]bool)) {
+		return EvalError(n.GetToken(), fmt.Errorf("function '%s' cannot call itself", n.Name()))
+	}
+	e.userFunctions[name] = n
+	return nil
+}
+
+func (e *ComplexEvaluator) handleUnary(n *ast.UnaryNode) (complex128, error) {
+	val, err := e.Eval(n.Next())
+	if err != nil {
+		return 0, err
+	}
+
+	switch n.Operator() {
+	case ast.Substraction:
+		// Adding 0 turns negative zero into positive one, otherwise sqrt(-1) would end on the other side of branch cut
+		return -val + 0, nil
+	case ast.Addition:
+		return val, nil
+	case ast.Not:
+		return complex(boolToFloat(val == 0), 0), nil
+	}
+
+	return 0, EvalError(n.GetToken(), errors.New("unary node supports only Addition, Substraction and Not operator"))
+}
+
+func (e *ComplexEvaluator) handlePostfix(n *ast.PostfixNode) (complex128, error) {
+	val, err := e.Eval(n.Prev())
+	if err != nil {
+		return 0, err
+	}
+
+	switch n.Operator() {
+	case ast.Factorial:
+		// Gamma function of complex numbers is not implemented
+		if imag(val) != 0 {
+			return 0, EvalError(n.GetToken(), ErrNotRealNumber)
+		}
+		return complex(factorial(real(val)), 0), nil
+	case ast.Percent:
+		return val / 100, nil
+	}
+
+	return 0, EvalError(n.GetToken(), errors.New("postfix node supports only Factorial and Percent operator"))
+}
+
+func (e *ComplexEvaluator) handleBinary(n *ast.BinaryNode) (complex128, error) {
+	if n.Operator() == ast.And || n.Operator() == ast.Or {
+		return e.handleLogical(n)
+	}
+	l, r, err := e.operands(n)
+	if err != nil {
+		return 0, err
+	}
+
+	switch n.Operator() {
+	case ast.Addition:
+		return l + r, nil
+	case ast.Substraction:
+		return l - r, nil
+	case ast.Multiplication:
+		return l * r, nil
+	case ast.Division:
+		return l / r, nil
+	case ast.Exponent:
+		return complexPow(l, r), nil
+	case ast.FloorDiv, ast.Modulus:
+		// Rounding and remainder have no meaning for complex numbers
+		if imag(l) != 0 || imag(r) != 0 {
+			return 0, EvalError(n.GetToken(), ErrNotRealNumber)
+		}
+		if n.Operator() == ast.FloorDiv {
+			return complex(math.Floor(real(l)/real(r)), 0), nil
+		}
+		return complex(math.Mod(real(l), real(r)), 0), nil
+	case ast.Less, ast.LessOrEqual, ast.Greater, ast.GreaterOrEqual, ast.IsEqual, ast.NotEqual:
+		return compareComplex(n, l, r)
+	}
+
+	return 0, EvalError(n.GetToken(), fmt.Errorf("unimplemented operator %s", n.Operator()))
+}
+
+// operands evaluates both operands of the binary node, percent on the right side is taken from the left operand
+func (e *ComplexEvaluator) operands(n *ast.BinaryNode) (complex128, complex128, error) {
+	l, err := e.Eval(n.Left())
+	if err != nil {
+		return 0, 0, err
+	}
+	r, err := e.Eval(n.Right())
+	if err != nil {
+		return 0, 0, err
+	}
+	if n.RelativePercent() {
+		r *= l
+	}
+	return l, r, nil
+}
+
+// handleLogical evaluates right side only when the left one does not decide the result yet
+func (e *ComplexEvaluator) handleLogical(n *ast.BinaryNode) (complex128, error) {
+	l, err := e.Eval(n.Left())
+	if err != nil {
+		return 0, err
+	}
+	if (l != 0) == (n.Operator() == ast.Or) {
+		return complex(boolToFloat(l != 0), 0), nil
+	}
+	r, err := e.Eval(n.Right())
+	if err != nil {
+		return 0, err
+	}
+	return complex(boolToFloat(r != 0), 0), nil
+}
+
+// handleConditional evaluates only the branch selected by the condition, any non-zero number is true
+func (e *ComplexEvaluator) handleConditional(n *ast.ConditionalNode) (complex128, error) {
+	condition, err := e.Eval(n.Condition())
+	if err != nil {
+		return 0, err
+	}
+	if condition != 0 {
+		return e.Eval(n.Then())
+	}
+	return e.Eval(n.Else())
+}
+
+// handleBlock evaluates statements in order and returns the value of the last one
+func (e *ComplexEvaluator) handleBlock(n *ast.BlockNode) (complex128, error) {
+	var val complex128
+	var err error
+	for _, statement := range n.Statements() {
+		if val, err = e.Eval(statement); err != nil {
+			return 0, err
+		}
+	}
+	return val, nil
+}
+
+// compareComplex checks equality of complex numbers, other comparisons are defined only for real numbers
+func compareComplex(n *ast.BinaryNode, l, r complex128) (complex128, error) {
+	switch op := n.Operator(); {
+	case op == ast.IsEqual:
+		return complex(boolToFloat(l == r), 0), nil
+	case op == ast.NotEqual:
+		return complex(boolToFloat(l != r), 0), nil
+	case imag(l) != 0 || imag(r) != 0:
+		return 0, EvalError(n.GetToken(), ErrNotRealNumber)
+	}
+	return complex(compare(n.Operator(), real(l), real(r)), 0), nil
+}
+
+// complexPow uses math.Pow when the result is real, so (-1)^2 does not end with tiny imaginary part
+func complexPow(base, exp complex128) complex128 {
+	if imag(base) == 0 && imag(exp) == 0 && (real(base) >= 0 || real(exp) == math.Trunc(real(exp))) {
+		return complex(math.Pow(real(base), real(exp)), 0)
+	}
+	return cmplx.Pow(base, exp)
+}
+
+func (e *ComplexEvaluator) handleFunction(n *ast.FunctionNode) (complex128, error) {
+	name := strings.ToLower(n.Name())
+	if def, has := e.userFunctions[name]; has {
+		return e.callUserFunction(def, n)
+	}
+	f, has := e.functions[name]
+	if !has {
+		return 0, EvalError(n.GetToken(), fmt.Errorf("undefined function '%s'", n.Name()))
+	}
+	argsCount := FunctionHandler{MinArguments: f.MinArguments, MaxArguments: f.MaxArguments}
+	if err := checkArgumentsCount(argsCount, n); err != nil {
+		return 0, err
+	}
+
+	args := []complex128{}
+	for _, p := range n.Params() {
+		v, err := e.Eval(p)
+		if err != nil {
+			return 0, err
+		}
+		args = append(args, v)
+	}
+
+	val, err := f.Handler(args...)
+	if err != nil {
+		return 0, EvalError(n.GetToken(), fmt.Errorf("%s in function '%s'", err.Error(), n.Name()))
+	}
+	return val, nil
+}
+
+func (e *ComplexEvaluator) callUserFunction(def *ast.FunctionDefNode, n *ast.FunctionNode) (complex128, error) {
+	params := def.ParamNames()
+	argsCount := FunctionHandler{MinArguments: len(params), MaxArguments: len(params)}
+	if err := checkArgumentsCount(argsCount, n); err != nil {
+		return 0, err
+	}
+	scope := &ComplexEvaluator{
+		variables:     make(map[string]complex128, len(params)),
+		functions:     e.functions,
+		userFunctions: e.userFunctions,
+		parent:        e,
+	}
+	for i, p := range n.Params() {
+		v, err := e.Eval(p)
+		if err != nil {
+			return 0, err
+		}
+		scope.variables[strings.ToLower(params[i])] = v
+	}
+	return scope.Eval(def.Body())
+}
@@ -0,0 +1,112 @@
+package evaluator_test
+
+import (
+	"math"
+
+	"github.com/arxeiss/go-expression-calculator/ast"
+	"github.com/arxeiss/go-expression-calculator/evaluator"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Complex evaluator", func() {
+	DescribeTable("Evaluate",
+		func(expr string, expected complex128) {
+			ev, err := evaluator.NewComplexEvaluator(map[string]complex128{"z": 3 + 4i}, evaluator.ComplexFunctions())
+			Expect(err).To(Succeed())
+			res, err := ev.Eval(parseExpression(expr))
+			Expect(err).To(Succeed())
+			Expect(real(res)).To(BeNumerically("~", real(expected), 1e-12))
+			Expect(imag(res)).To(BeNumerically("~", imag(expected), 1e-12))
+		},
+		Entry("Square root of negative number", "sqrt(-1)", 1i),
+		Entry("Imaginary literals", "2 + 3i - 0.5j", 2+2.5i),
+		Entry("Multiplication", "(1 + 2i) * (3 - 1i)", 5+5i),
+		Entry("Division", "(1 + 2i) / (3 - 4i)", -0.2+0.4i),
+		Entry("Square of imaginary unit", "1i ^ 2", complex(-1, 0)),
+		Entry("Real power of negative number", "(-1) ^ 2", complex(1, 0)),
+		Entry("Principal root of negative number", "(-8) ^ (1 / 3)", complex(1, math.Sqrt(3))),
+		Entry("Euler's identity", "exp(1i * pi()) + 1", complex(0, 0)),
+		Entry("Real part functions", "abs(z) + re(z) + im(z) + arg(1i)", complex(12+math.Pi/2, 0)),
+		Entry("Conjugate", "z * conj(z)", complex(25, 0)),
+		Entry("Floor division of real numbers", "7 // 2", complex(3, 0)),
+		Entry("Modulus of real numbers", "-7 % 3", complex(-1, 0)),
+		Entry("Assign", "w = 2j", 2i),
+		Entry("Equality of complex numbers", "z == 3 + 4i && z != 3", complex(1, 0)),
+		Entry("Comparison of real numbers", "re(z) < im(z) || 1i", complex(1, 0)),
+		Entry("Logical negation", "!1i", complex(0, 0)),
+		Entry("Conditional", "1i ? z : 1 / 0", 3+4i),
+		Entry("Statements", "w = 1i; w * w", complex(-1, 0)),
+		Entry("Factorial of real number", "4! + 1i", 24+1i),
+		Entry("Percent of complex number", "z * 10%", 0.3+0.4i),
+		Entry("Percent added to complex number", "z + 10%", 3.3+4.4i),
+	)
+
+	DescribeTable("Errors",
+		func(expr string, errStr string) {
+			ev, err := evaluator.NewComplexEvaluator(nil, evaluator.ComplexFunctions())
+			Expect(err).To(Succeed())
+			_, err = ev.Eval(parseExpression(expr))
+			Expect(err).To(MatchError(errStr))
+		},
+		Entry("Floor division of complex number", "1i // 2",
+			"operation is defined only for real numbers at position 3"),
+		Entry("Modulus of complex number", "5 % 1i", "operation is defined only for real numbers at position 2"),
+		Entry("Ordering of complex numbers", "1 < 1i", "operation is defined only for real numbers at position 2"),
+		Entry("Factorial of complex number", "(1 + 1i)!",
+			"operation is defined only for real numbers at position 8"),
+		Entry("Undefined variable", "2 * x", "undefined variable 'x' at position 4"),
+		Entry("Undefined function", "foo(1i)", "undefined function 'foo' at position 0"),
+		Entry("Wrong arguments count", "conj(1, 2)", "function 'conj' require 1 arguments, got 2 at position 0"),
+		Entry("Number out of range", "2 * 1e400i", "number is out of range at position 4"),
+	)
+
+	It("Rejects imaginary numbers in real evaluators", func() {
+		ev, err := evaluator.NewNumericEvaluator(nil)
+		Expect(err).To(Succeed())
+		_, err = ev.Eval(parseExpression("1 + 2i"))
+		Expect(err).To(MatchError(evaluator.ErrImaginaryNumber))
+		_, err = ev.Compile(parseExpression("1 + 2i"))
+		Expect(err).To(MatchError(evaluator.ErrImaginaryNumber))
+
+		bigEv, err := evaluator.NewBigEvaluator(evaluator.BigRatMode, 0, nil)
+		Expect(err).To(Succeed())
+		_, err = bigEv.Eval(parseExpression("1 + 2i"))
+		Expect(err).To(MatchError(evaluator.ErrImaginaryNumber))
+	})
+
+	It("Keeps variables and user defined functions", func() {
+		ev, err := evaluator.NewComplexEvaluator(nil, evaluator.ComplexFunctions())
+		Expect(err).To(Succeed())
+		_, err = ev.Eval(parseExpression("a = 1 + 1i"))
+		Expect(err).To(Succeed())
+		_, err = ev.Eval(parseExpression("wrap(x) = x * a"))
+		Expect(err).To(Succeed())
+
+		Expect(ev.Eval(parseExpression("wrap(1 - 1i)"))).To(Equal(complex(2, 0)))
+
+		vars := ev.VariableList()
+		Expect(vars).To(HaveLen(1))
+		Expect(vars[0].Name).To(Equal("a"))
+		Expect(vars[0].Value).To(Equal(1 + 1i))
+
+		funcs := ev.FunctionList()
+		Expect(funcs).To(HaveLen(len(evaluator.ComplexFunctions()) + 1))
+		Expect(funcs[len(funcs)-1].Name).To(Equal("wrap"))
+		Expect(funcs[len(funcs)-1].Function.ArgsNames).To(Equal([]string{"x"}))
+
+		_, err = ev.Eval(parseExpression("conj(x) = x"))
+		Expect(err).To(MatchError(ContainSubstring("cannot redefine built-in function 'conj'")))
+		_, err = ev.Eval(ast.NewFunctionNode("wrap", nil, nil))
+		Expect(err).To(MatchError(ContainSubstring("function 'wrap' require 1 arguments, got 0")))
+	})
+
+	It("Check error when defining same variable with different case sensitivity", func() {
+		_, err := evaluator.NewComplexEvaluator(map[string]complex128{"ab": 1, "aB": 2})
+		Expect(err).To(MatchError(ContainSubstring("variables are case insensitive")))
+		_, err = evaluator.NewComplexEvaluator(nil, evaluator.ComplexFunctions(), evaluator.ComplexFunctions())
+		Expect(err).To(MatchError(ContainSubstring("function names are case insensitive")))
+	})
+})
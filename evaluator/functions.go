@@ -3,6 +3,7 @@ package evaluator
 import (
 	"fmt"
 	"math"
+	"math/cmplx"
 	"math/rand"
 )
 
@@ -11,6 +12,7 @@ func MathFunctions() map[string]FunctionHandler {
 		"abs": {
 			Description:  "Returns the absolute value of x.",
 			Handler:      func(x ...float64) (float64, error) { return math.Abs(x[0]), nil },
+			Rational:     true,
 			MinArguments: 1, MaxArguments: 1,
 			ArgsNames: []string{"x"},
 		},
@@ -35,6 +37,7 @@ func MathFunctions() map[string]FunctionHandler {
 		"ceil": {
 			Description:  "Returns the least integer value greater than or equal to x.",
 			Handler:      func(x ...float64) (float64, error) { return math.Ceil(x[0]), nil },
+			Rational:     true,
 			MinArguments: 1, MaxArguments: 1,
 			ArgsNames: []string{"x"},
 		},
@@ -47,6 +50,7 @@ func MathFunctions() map[string]FunctionHandler {
 		"floor": {
 			Description:  "Returns the greatest integer value less than or equal to x.",
 			Handler:      func(x ...float64) (float64, error) { return math.Floor(x[0]), nil },
+			Rational:     true,
 			MinArguments: 1, MaxArguments: 1,
 			ArgsNames: []string{"x"},
 		},
@@ -116,6 +120,7 @@ func MathFunctionsWithVarArgs() map[string]FunctionHandler {
 				}
 				return c, nil
 			},
+			Rational:     true,
 			MinArguments: 1, MaxArguments: 0,
 			ArgsNames: []string{"a", "b"},
 		},
@@ -128,6 +133,7 @@ func MathFunctionsWithVarArgs() map[string]FunctionHandler {
 				}
 				return c, nil
 			},
+			Rational:     true,
 			MinArguments: 1, MaxArguments: 0,
 			ArgsNames: []string{"a", "b"},
 		},
@@ -151,6 +157,7 @@ func MathFunctionsWithVarArgs() map[string]FunctionHandler {
 				}
 				return float64(rand.Int63n(max-min) + min), nil // #nosec G404
 			},
+			Rational:     true,
 			MinArguments: 1, MaxArguments: 2,
 			ArgsNames: []string{"a", "b"},
 		},
@@ -195,3 +202,160 @@ func MathFunctionsWithVarArgs() map[string]FunctionHandler {
 		},
 	}
 }
+
+// LazyFunctions returns functions which evaluate only arguments they need, so skipped ones cannot fail
+func LazyFunctions() map[string]FunctionHandler {
+	return map[string]FunctionHandler{
+		"if": {
+			Description: "Returns a if condition c is not zero, otherwise b. Only the returned argument is evaluated.",
+			LazyHandler: func(args ...Thunk) (LazyResult, error) {
+				c, err := args[0]()
+				if err != nil {
+					return LazyResult{}, err
+				}
+				arg := 2
+				if c != 0 {
+					arg = 1
+				}
+				v, err := args[arg]()
+				return LazyResult{Value: v, Arg: arg}, err
+			},
+			MinArguments: 3, MaxArguments: 3,
+			ArgsNames: []string{"c", "a", "b"},
+		},
+		"coalesce": {
+			Description: "Returns the first argument which is evaluated without error and is not NaN.",
+			LazyHandler: func(args ...Thunk) (LazyResult, error) {
+				var v float64
+				var err error
+				for i, arg := range args {
+					if v, err = arg(); err == nil && !math.IsNaN(v) {
+						return LazyResult{Value: v, Arg: i}, nil
+					}
+				}
+				return LazyResult{Value: v, Arg: len(args) - 1}, err
+			},
+			MinArguments: 1, MaxArguments: 0,
+			ArgsNames: []string{"a", "b"},
+		},
+		"and": {
+			Description: "Returns 1 if all arguments are not zero, otherwise 0. Evaluation stops at the first zero.",
+			LazyHandler: func(args ...Thunk) (LazyResult, error) {
+				return allOrAny(args, false)
+			},
+			MinArguments: 1, MaxArguments: 0,
+			ArgsNames: []string{"a", "b"},
+		},
+		"or": {
+			Description: "Returns 1 if any argument is not zero, otherwise 0. Evaluation stops at the first non-zero.",
+			LazyHandler: func(args ...Thunk) (LazyResult, error) {
+				return allOrAny(args, true)
+			},
+			MinArguments: 1, MaxArguments: 0,
+			ArgsNames: []string{"a", "b"},
+		},
+	}
+}
+
+// allOrAny evaluates arguments until some of them equals to stopOn, which is then the result
+func allOrAny(args []Thunk, stopOn bool) (LazyResult, error) {
+	for _, arg := range args {
+		v, err := arg()
+		if err != nil {
+			return LazyResult{}, err
+		}
+		if (v != 0) == stopOn {
+			return LazyResult{Value: boolToFloat(stopOn), Arg: -1}, nil
+		}
+	}
+	return LazyResult{Value: boolToFloat(!stopOn), Arg: -1}, nil
+}
+
+// ComplexFunctions returns functions for ComplexEvaluator. All of them accept and return complex numbers,
+// functions returning real value, like abs or arg, have imaginary part always 0.
+func ComplexFunctions() map[string]ComplexFunctionHandler {
+	return map[string]ComplexFunctionHandler{
+		"abs": {
+			Description:  "Returns the absolute value (modulus) of z.",
+			Handler:      func(z ...complex128) (complex128, error) { return complex(cmplx.Abs(z[0]), 0), nil },
+			MinArguments: 1, MaxArguments: 1,
+			ArgsNames: []string{"z"},
+		},
+		"arg": {
+			Description:  "Returns the argument (phase) of z in radians, in the range [-Pi, Pi].",
+			Handler:      func(z ...complex128) (complex128, error) { return complex(cmplx.Phase(z[0]), 0), nil },
+			MinArguments: 1, MaxArguments: 1,
+			ArgsNames: []string{"z"},
+		},
+		"conj": {
+			Description:  "Returns the complex conjugate of z.",
+			Handler:      func(z ...complex128) (complex128, error) { return cmplx.Conj(z[0]), nil },
+			MinArguments: 1, MaxArguments: 1,
+			ArgsNames: []string{"z"},
+		},
+		"re": {
+			Description:  "Returns the real part of z.",
+			Handler:      func(z ...complex128) (complex128, error) { return complex(real(z[0]), 0), nil },
+			MinArguments: 1, MaxArguments: 1,
+			ArgsNames: []string{"z"},
+		},
+		"im": {
+			Description:  "Returns the imaginary part of z.",
+			Handler:      func(z ...complex128) (complex128, error) { return complex(imag(z[0]), 0), nil },
+			MinArguments: 1, MaxArguments: 1,
+			ArgsNames: []string{"z"},
+		},
+		"sqrt": {
+			Description:  "Returns the square root of z, negative numbers have imaginary result.",
+			Handler:      func(z ...complex128) (complex128, error) { return cmplx.Sqrt(z[0]), nil },
+			MinArguments: 1, MaxArguments: 1,
+			ArgsNames: []string{"z"},
+		},
+		"exp": {
+			Description:  "Returns e^z.",
+			Handler:      func(z ...complex128) (complex128, error) { return cmplx.Exp(z[0]), nil },
+			MinArguments: 1, MaxArguments: 1,
+			ArgsNames: []string{"z"},
+		},
+		"ln": {
+			Description:  "Returns the natural logarithm of z.",
+			Handler:      func(z ...complex128) (complex128, error) { return cmplx.Log(z[0]), nil },
+			MinArguments: 1, MaxArguments: 1,
+			ArgsNames: []string{"z"},
+		},
+		"log": {
+			Description:  "Returns log of value n with given base.",
+			Handler:      func(z ...complex128) (complex128, error) { return cmplx.Log(z[0]) / cmplx.Log(z[1]), nil },
+			MinArguments: 2, MaxArguments: 2,
+			ArgsNames: []string{"n", "base"},
+		},
+		"sin": {
+			Description:  "Returns the sine of z.",
+			Handler:      func(z ...complex128) (complex128, error) { return cmplx.Sin(z[0]), nil },
+			MinArguments: 1, MaxArguments: 1,
+			ArgsNames: []string{"z"},
+		},
+		"cos": {
+			Description:  "Returns the cosine of z.",
+			Handler:      func(z ...complex128) (complex128, error) { return cmplx.Cos(z[0]), nil },
+			MinArguments: 1, MaxArguments: 1,
+			ArgsNames: []string{"z"},
+		},
+		"tan": {
+			Description:  "Returns the tangent of z.",
+			Handler:      func(z ...complex128) (complex128, error) { return cmplx.Tan(z[0]), nil },
+			MinArguments: 1, MaxArguments: 1,
+			ArgsNames: []string{"z"},
+		},
+		"pi": {
+			Description:  "Returns Pi value.",
+			Handler:      func(z ...complex128) (complex128, error) { return math.Pi, nil },
+			MinArguments: 0, MaxArguments: 0,
+		},
+		"e": {
+			Description:  "Returns e value (base of natural logarithm).",
+			Handler:      func(z ...complex128) (complex128, error) { return math.E, nil },
+			MinArguments: 0, MaxArguments: 0,
+		},
+	}
+}
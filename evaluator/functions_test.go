@@ -159,4 +159,41 @@ var _ = Describe("Functions", func() {
 					errMatcher: Succeed()},
 			}),
 	)
+
+	DescribeTable("Complex Functions",
+		func(name string, args []complex128, resultMatcher types.GomegaMatcher) {
+			f, has := evaluator.ComplexFunctions()[name]
+			Expect(has).To(BeTrue())
+			Expect(f.Description).NotTo(BeEmpty())
+			Expect(f.MinArguments).To(Equal(len(args)))
+			Expect(f.MaxArguments).To(Equal(len(args)))
+			res, err := f.Handler(args...)
+			Expect(err).To(Succeed())
+			Expect(real(res)).To(resultMatcher)
+		},
+		Entry("abs", "abs", []complex128{3 + 4i}, BeEquivalentTo(5)),
+		Entry("arg", "arg", []complex128{1i}, BeNumerically("~", math.Pi/2, precission)),
+		Entry("re", "re", []complex128{3 - 4i}, BeEquivalentTo(3)),
+		Entry("log", "log", []complex128{100, 10}, BeNumerically("~", 2, precission)),
+		Entry("pi", "pi", nil, Equal(math.Pi)),
+	)
+
+	DescribeTable("Complex Functions with complex result",
+		func(name string, arg, expected complex128) {
+			f, has := evaluator.ComplexFunctions()[name]
+			Expect(has).To(BeTrue())
+			res, err := f.Handler(arg)
+			Expect(err).To(Succeed())
+			Expect(real(res)).To(BeNumerically("~", real(expected), precission))
+			Expect(imag(res)).To(BeNumerically("~", imag(expected), precission))
+		},
+		Entry("im", "im", 3-4i, complex(-4, 0)),
+		Entry("conj", "conj", 3-4i, 3+4i),
+		Entry("sqrt of negative", "sqrt", complex(-4, 0), 2i),
+		Entry("exp", "exp", complex(0, math.Pi), complex(-1, 0)),
+		Entry("ln", "ln", complex(-1, 0), complex(0, math.Pi)),
+		Entry("sin", "sin", 1i, complex(0, math.Sinh(1))),
+		Entry("cos", "cos", 1i, complex(math.Cosh(1), 0)),
+		Entry("tan", "tan", 1i, complex(0, math.Tanh(1))),
+	)
 })
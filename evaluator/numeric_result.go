@@ -1,6 +1,7 @@
 package evaluator
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math"
@@ -8,19 +9,75 @@ import (
 	"strings"
 
 	"github.com/arxeiss/go-expression-calculator/ast"
+	"github.com/arxeiss/go-expression-calculator/lexer"
 )
 
+var (
+	ErrStepLimit          = errors.New("evaluation exceeded the limit of steps")
+	ErrFunctionNotAllowed = errors.New("function is not allowed")
+)
+
+// Thunk evaluates argument of lazy function, each call evaluates the argument again
+type Thunk func() (float64, error)
+
+// LazyResult is the result of lazy function. Arg is the index of the argument which value is returned unchanged,
+// or -1 when the value is computed, so evaluators with higher precision than float64 can return the argument itself
+type LazyResult struct {
+	Value float64
+	Arg   int
+}
+
+// CallSite describes the call of the function in the expression
+type CallSite struct {
+	// Name of the function as it is written in the expression
+	Name string
+	// Token is the identifier of the function, so errors can point to the call
+	Token *lexer.Token
+}
+
 type FunctionHandler struct {
-	Description  string
-	Handler      func(x ...float64) (float64, error)
+	Description string
+	Handler     func(x ...float64) (float64, error)
+	// ContextHandler receives context of the evaluation, see NumericEvaluator.EvalContext, and the call site.
+	// When set, Handler is not used. Evaluators without context pass context.Background()
+	ContextHandler func(ctx context.Context, call CallSite, x ...float64) (float64, error)
+	// LazyHandler receives unevaluated arguments, so it can skip some of them. When set, other handlers are not used
+	LazyHandler func(args ...Thunk) (LazyResult, error)
+	// Rational marks functions returning rational result for rational arguments, like max or floor.
+	// BigEvaluator in BigRatMode rejects results of other functions with ErrNotExact
+	Rational     bool
 	MinArguments int
 	MaxArguments int
 	ArgsNames    []string
 }
 
+// call runs ContextHandler when it is set, otherwise Handler
+func (f FunctionHandler) call(ctx context.Context, call CallSite, x ...float64) (float64, error) {
+	if f.ContextHandler != nil {
+		return f.ContextHandler(ctx, call, x...)
+	}
+	return f.Handler(x...)
+}
+
 type NumericEvaluator struct {
 	variables map[string]float64
 	functions map[string]FunctionHandler
+	// userFunctions holds definitions of functions declared inside expressions
+	userFunctions map[string]*ast.FunctionDefNode
+	// parent is set only for scope of user defined function, so global variables can be accessed
+	parent *NumericEvaluator
+	// limits are shared with scopes of user defined functions, so nodes of their bodies are counted as well
+	limits *evalLimits
+}
+
+// evalLimits restricts evaluation of untrusted expressions
+type evalLimits struct {
+	// ctx of the current evaluation, checked before every node
+	ctx context.Context
+	// steps counts nodes evaluated by the current Eval call, zero stepLimit means no limit
+	steps, stepLimit int
+	// allowed holds names of allowed built-in functions, nil allows all of them
+	allowed map[string]bool
 }
 
 type VariableTuple struct {
@@ -33,40 +90,55 @@ type FunctionTuple struct {
 }
 
 func NewNumericEvaluator(vars map[string]float64, functions ...map[string]FunctionHandler) (*NumericEvaluator, error) {
+	variables, err := normalizeVariables(vars)
+	if err != nil {
+		return nil, err
+	}
+	finalFuncs, err := mergeFunctions(functions...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &NumericEvaluator{
+		variables:     variables,
+		functions:     finalFuncs,
+		userFunctions: make(map[string]*ast.FunctionDefNode),
+		limits:        &evalLimits{ctx: context.Background()},
+	}, nil
+}
+
+// normalizeVariables returns copy of variables with lower-cased names
+func normalizeVariables(vars map[string]float64) (map[string]float64, error) {
 	variables := make(map[string]float64)
-	{
-		varNames := make(map[string]string)
-		for kcs, v := range vars {
-			k := strings.ToLower(kcs)
-			if pn, has := varNames[k]; has {
-				return nil, fmt.Errorf(
-					"variable with name '%s' was defined as '%s' before, variables are case insensitive", kcs, pn)
-			}
-			varNames[k] = kcs
-			variables[k] = v
+	varNames := make(map[string]string)
+	for kcs, v := range vars {
+		k := strings.ToLower(kcs)
+		if pn, has := varNames[k]; has {
+			return nil, fmt.Errorf(
+				"variable with name '%s' was defined as '%s' before, variables are case insensitive", kcs, pn)
 		}
+		varNames[k] = kcs
+		variables[k] = v
 	}
+	return variables, nil
+}
 
+// mergeFunctions merges all function maps into single one with lower-cased names
+func mergeFunctions(functions ...map[string]FunctionHandler) (map[string]FunctionHandler, error) {
 	finalFuncs := make(map[string]FunctionHandler)
-	{
-		funcsNames := make(map[string]string)
-		for _, funcs := range functions {
-			for kcs, v := range funcs {
-				k := strings.ToLower(kcs)
-				if pn, has := funcsNames[k]; has {
-					return nil, fmt.Errorf(
-						"function named '%s' was defined as '%s' before, function names are case insensitive", kcs, pn)
-				}
-				funcsNames[k] = kcs
-				finalFuncs[k] = v
+	funcsNames := make(map[string]string)
+	for _, funcs := range functions {
+		for kcs, v := range funcs {
+			k := strings.ToLower(kcs)
+			if pn, has := funcsNames[k]; has {
+				return nil, fmt.Errorf(
+					"function named '%s' was defined as '%s' before, function names are case insensitive", kcs, pn)
 			}
+			funcsNames[k] = kcs
+			finalFuncs[k] = v
 		}
 	}
-
-	return &NumericEvaluator{
-		variables: variables,
-		functions: finalFuncs,
-	}, nil
+	return finalFuncs, nil
 }
 
 func (e *NumericEvaluator) VariableList() []VariableTuple {
@@ -84,9 +156,20 @@ func (e *NumericEvaluator) VariableList() []VariableTuple {
 	return ret
 }
 
+// FunctionList returns functions which can be called, see SetAllowedFunctions
 func (e *NumericEvaluator) FunctionList() []FunctionTuple {
-	keys := make([]string, 0, len(e.functions))
-	for k := range e.functions {
+	functions := make(map[string]FunctionHandler, len(e.functions))
+	for k, v := range e.functions {
+		if e.isAllowed(k) {
+			functions[k] = v
+		}
+	}
+	return functionList(functions)
+}
+
+func functionList(functions map[string]FunctionHandler) []FunctionTuple {
+	keys := make([]string, 0, len(functions))
+	for k := range functions {
 		keys = append(keys, k)
 	}
 	sort.Strings(keys)
@@ -95,41 +178,199 @@ func (e *NumericEvaluator) FunctionList() []FunctionTuple {
 	for _, k := range keys {
 		ret = append(ret, FunctionTuple{
 			Name:     k,
-			Function: e.functions[k],
+			Function: functions[k],
 		})
 	}
 
 	return ret
 }
 
+// SetStepLimit limits number of nodes evaluated by one Eval call, 0 means no limit.
+// Every call of user defined function evaluates all nodes of its body again.
+func (e *NumericEvaluator) SetStepLimit(limit int) {
+	e.limits.stepLimit = limit
+}
+
+// SetAllowedFunctions restricts built-in functions which can be called, names are case insensitive.
+// Without names all functions are allowed. Functions defined by the expression can be called always
+func (e *NumericEvaluator) SetAllowedFunctions(names ...string) {
+	if len(names) == 0 {
+		e.limits.allowed = nil
+		return
+	}
+	e.limits.allowed = make(map[string]bool, len(names))
+	for _, name := range names {
+		e.limits.allowed[strings.ToLower(name)] = true
+	}
+}
+
+func (e *NumericEvaluator) isAllowed(name string) bool {
+	return e.limits.allowed == nil || e.limits.allowed[name] || e.userFunctions[name] != nil
+}
+
 func (e *NumericEvaluator) Eval(rootNode ast.Node) (float64, error) {
+	return e.EvalContext(context.Background(), rootNode)
+}
+
+// EvalContext evaluates the node and stops with the error of ctx when ctx is done
+func (e *NumericEvaluator) EvalContext(ctx context.Context, rootNode ast.Node) (float64, error) {
+	e.limits.ctx = ctx
+	e.limits.steps = 0
+	defer func() { e.limits.ctx = context.Background() }()
+	return e.eval(rootNode)
+}
+
+func (e *NumericEvaluator) eval(rootNode ast.Node) (float64, error) {
+	if err := e.checkLimits(rootNode); err != nil {
+		return 0, err
+	}
 	switch n := rootNode.(type) {
 	case *ast.BinaryNode:
 		return e.handleBinary(n)
 	case *ast.UnaryNode:
 		return e.handleUnary(n)
+	case *ast.PostfixNode:
+		return e.handlePostfix(n)
+	case *ast.ConditionalNode:
+		return e.handleConditional(n)
+	case *ast.BlockNode:
+		return e.handleBlock(n)
 	case *ast.FunctionNode:
 		return e.handleFunction(n)
 	case *ast.VariableNode:
-		if v, has := e.variables[strings.ToLower(n.Name())]; has {
+		if v, has := e.variable(strings.ToLower(n.Name())); has {
 			return v, nil
 		}
 		return 0, EvalError(n.GetToken(), fmt.Errorf("undefined variable '%s'", n.Name()))
+	case *ast.FunctionDefNode:
+		return 0, e.defineFunction(n)
 	case *ast.AssignNode:
-		val, err := e.Eval(n.Right())
+		val, err := e.eval(n.Right())
 		if err != nil {
 			return 0, err
 		}
 		e.variables[strings.ToLower(n.Left().Name())] = val
 		return val, nil
 	case *ast.NumericNode:
-		return n.Value(), nil
+		if n.Imaginary() {
+			return 0, EvalError(n.GetToken(), ErrImaginaryNumber)
+		}
+		return numberValue(n)
 	}
 	return 0, EvalError(rootNode.GetToken(), fmt.Errorf("unimplemented node type %T", rootNode))
 }
 
+// checkLimits counts evaluated nodes and checks whether the evaluation was cancelled
+func (e *NumericEvaluator) checkLimits(node ast.Node) error {
+	return e.limits.check(node.GetToken())
+}
+
+// check counts one step and checks whether the evaluation was cancelled, error points to the token
+func (l *evalLimits) check(token *lexer.Token) error {
+	if err := l.ctx.Err(); err != nil {
+		return EvalError(token, err)
+	}
+	if l.stepLimit > 0 {
+		if l.steps++; l.steps > l.stepLimit {
+			return EvalError(token, ErrStepLimit)
+		}
+	}
+	return nil
+}
+
+// stopsEvaluation checks if the error is caused by limits, which stop the whole evaluation.
+// Such errors are returned unchanged from user defined functions, so they can be checked with errors.Is
+func stopsEvaluation(err error) bool {
+	return errors.Is(err, ErrStepLimit) || errors.Is(err, ErrFunctionNotAllowed) ||
+		errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+func (e *NumericEvaluator) variable(name string) (float64, bool) {
+	if v, has := e.variables[name]; has {
+		return v, true
+	}
+	if e.parent != nil {
+		return e.parent.variable(name)
+	}
+	return 0, false
+}
+
+// defineFunction stores user defined function, which is then callable same way as built-in functions.
+// Function body is evaluated in its own scope, where parameters hide global variables with same name.
+func (e *NumericEvaluator) defineFunction(n *ast.FunctionDefNode) error {
+	name := strings.ToLower(n.Name())
+	if _, has := e.functions[name]; has && e.userFunctions[name] == nil {
+		return EvalError(n.GetToken(), fmt.Errorf("cannot redefine built-in function '%s'", n.Name()))
+	}
+	if callsFunction(n.Body(), name, e.userFunctions, make(map[string]bool)) {
+		return EvalError(n.GetToken(), fmt.Errorf("function '%s' cannot call itself", n.Name()))
+	}
+
+	params := n.ParamNames()
+	body := n.Body()
+	e.userFunctions[name] = n
+	e.functions[name] = FunctionHandler{
+		Description: "User defined function.",
+		Handler: func(x ...float64) (float64, error) {
+			scope := &NumericEvaluator{
+				variables:     make(map[string]float64, len(params)),
+				functions:     e.functions,
+				userFunctions: e.userFunctions,
+				parent:        e,
+				limits:        e.limits,
+			}
+			for i, p := range params {
+				scope.variables[strings.ToLower(p)] = x[i]
+			}
+			return scope.eval(body)
+		},
+		MinArguments: len(params), MaxArguments: len(params),
+		ArgsNames: params,
+	}
+	return nil
+}
+
+// callsFunction checks if node calls function with given name, directly or through another user defined function
+func callsFunction(
+	node ast.Node,
+	name string,
+	userFunctions map[string]*ast.FunctionDefNode,
+	visited map[string]bool,
+) bool {
+	switch n := node.(type) {
+	case *ast.BinaryNode:
+		return callsFunction(n.Left(), name, userFunctions, visited) ||
+			callsFunction(n.Right(), name, userFunctions, visited)
+	case *ast.UnaryNode:
+		return callsFunction(n.Next(), name, userFunctions, visited)
+	case *ast.PostfixNode:
+		return callsFunction(n.Prev(), name, userFunctions, visited)
+	case *ast.ConditionalNode:
+		return callsFunction(n.Condition(), name, userFunctions, visited) ||
+			callsFunction(n.Then(), name, userFunctions, visited) ||
+			callsFunction(n.Else(), name, userFunctions, visited)
+	case *ast.AssignNode:
+		return callsFunction(n.Right(), name, userFunctions, visited)
+	case *ast.FunctionNode:
+		fName := strings.ToLower(n.Name())
+		if fName == name {
+			return true
+		}
+		for _, p := range n.Params() {
+			if callsFunction(p, name, userFunctions, visited) {
+				return true
+			}
+		}
+		if def, has := userFunctions[fName]; has && !visited[fName] {
+			visited[fName] = true
+			return callsFunction(def.Body(), name, userFunctions, visited)
+		}
+	}
+	return false
+}
+
 func (e *NumericEvaluator) handleUnary(n *ast.UnaryNode) (float64, error) {
-	val, err := e.Eval(n.Next())
+	val, err := e.eval(n.Next())
 	if err != nil {
 		return 0, err
 	}
@@ -139,20 +380,51 @@ func (e *NumericEvaluator) handleUnary(n *ast.UnaryNode) (float64, error) {
 		return -val, nil
 	case ast.Addition:
 		return val, nil
+	case ast.Not:
+		return boolToFloat(val == 0), nil
+	}
+
+	return 0, EvalError(n.GetToken(), errors.New("unary node supports only Addition, Substraction and Not operator"))
+}
+
+func (e *NumericEvaluator) handlePostfix(n *ast.PostfixNode) (float64, error) {
+	val, err := e.eval(n.Prev())
+	if err != nil {
+		return 0, err
+	}
+
+	switch n.Operator() {
+	case ast.Factorial:
+		return factorial(val), nil
+	case ast.Percent:
+		return val / 100, nil
 	}
 
-	return 0, EvalError(n.GetToken(), errors.New("unary node supports only Addition and Substraction operator"))
+	return 0, EvalError(n.GetToken(), errors.New("postfix node supports only Factorial and Percent operator"))
+}
+
+// factorial is computed by gamma function, so it is defined also for non-integer values.
+// Negative integers have no factorial, (-1)! is infinity and lower ones are NaN
+func factorial(x float64) float64 {
+	return math.Gamma(x + 1)
 }
 
 func (e *NumericEvaluator) handleBinary(n *ast.BinaryNode) (float64, error) {
-	l, err := e.Eval(n.Left())
+	if n.Operator() == ast.And || n.Operator() == ast.Or {
+		return e.handleLogical(n)
+	}
+	l, err := e.eval(n.Left())
 	if err != nil {
 		return 0, err
 	}
-	r, err := e.Eval(n.Right())
+	r, err := e.eval(n.Right())
 	if err != nil {
 		return 0, err
 	}
+	// Percent is relative to the left operand, so 100 + 10% is 110
+	if n.RelativePercent() {
+		r *= l
+	}
 
 	switch n.Operator() {
 	case ast.Addition:
@@ -169,36 +441,178 @@ func (e *NumericEvaluator) handleBinary(n *ast.BinaryNode) (float64, error) {
 		return math.Pow(l, r), nil
 	case ast.Modulus:
 		return math.Mod(l, r), nil
+	case ast.Less, ast.LessOrEqual, ast.Greater, ast.GreaterOrEqual, ast.IsEqual, ast.NotEqual:
+		return compare(n.Operator(), l, r), nil
 	}
 
 	return 0, EvalError(n.GetToken(), fmt.Errorf("unimplemented operator %s", n.Operator()))
 }
 
+// handleLogical evaluates right side only when the left one does not decide the result yet.
+// Any non-zero value is true, the result is always 1 or 0
+func (e *NumericEvaluator) handleLogical(n *ast.BinaryNode) (float64, error) {
+	l, err := e.eval(n.Left())
+	if err != nil {
+		return 0, err
+	}
+	// True on the left side of || or false on the left side of && is the result already
+	if (l != 0) == (n.Operator() == ast.Or) {
+		return boolToFloat(l != 0), nil
+	}
+	r, err := e.eval(n.Right())
+	if err != nil {
+		return 0, err
+	}
+	return boolToFloat(r != 0), nil
+}
+
+// handleConditional evaluates only the branch selected by the condition, any non-zero value is true
+func (e *NumericEvaluator) handleConditional(n *ast.ConditionalNode) (float64, error) {
+	condition, err := e.eval(n.Condition())
+	if err != nil {
+		return 0, err
+	}
+	if condition != 0 {
+		return e.eval(n.Then())
+	}
+	return e.eval(n.Else())
+}
+
+// handleBlock evaluates statements in order and returns the value of the last one
+func (e *NumericEvaluator) handleBlock(n *ast.BlockNode) (float64, error) {
+	var val float64
+	var err error
+	for _, statement := range n.Statements() {
+		if val, err = e.eval(statement); err != nil {
+			return 0, err
+		}
+	}
+	return val, nil
+}
+
+// compare evaluates comparison operator, true is returned as 1 and false as 0
+func compare(op ast.Operation, l, r float64) float64 {
+	switch op {
+	case ast.Less:
+		return boolToFloat(l < r)
+	case ast.LessOrEqual:
+		return boolToFloat(l <= r)
+	case ast.Greater:
+		return boolToFloat(l > r)
+	case ast.GreaterOrEqual:
+		return boolToFloat(l >= r)
+	case ast.IsEqual:
+		return boolToFloat(l == r)
+	case ast.NotEqual:
+		return boolToFloat(l != r)
+	}
+	return math.NaN()
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
 func (e *NumericEvaluator) handleFunction(n *ast.FunctionNode) (float64, error) {
-	f, has := e.functions[strings.ToLower(n.Name())]
+	name := strings.ToLower(n.Name())
+	f, has := e.functions[name]
 	if !has {
 		return 0, EvalError(n.GetToken(), fmt.Errorf("undefined function '%s'", n.Name()))
 	}
+	if !e.isAllowed(name) {
+		return 0, EvalError(n.GetToken(), fmt.Errorf("%w '%s'", ErrFunctionNotAllowed, n.Name()))
+	}
 
 	params := n.Params()
-	paramsCount := len(params)
+	if err := checkArgumentsCount(f, n); err != nil {
+		return 0, err
+	}
+	if f.LazyHandler != nil {
+		res, err := callLazy(f.LazyHandler, len(params), func(i int) (float64, error) {
+			return e.eval(params[i])
+		}, n.GetToken(), n.Name())
+		return res.Value, err
+	}
+
+	args := []float64{}
+	for _, p := range params {
+		v, err := e.eval(p)
+		if err != nil {
+			return 0, err
+		}
+		args = append(args, v)
+	}
+
+	val, err := f.call(e.limits.ctx, CallSite{Name: n.Name(), Token: n.GetToken()}, args...)
+	if err != nil {
+		return 0, functionError(n.GetToken(), n.Name(), err)
+	}
+	return val, nil
+}
+
+// functionError points the error returned by the function to its call.
+// Errors of limits inside of user defined functions already point into the input, so they are returned unchanged
+func functionError(token *lexer.Token, name string, err error) error {
+	if evalErr := (&Error{}); stopsEvaluation(err) && errors.As(err, &evalErr) {
+		return err
+	}
+	return EvalError(token, fmt.Errorf("%w in function '%s'", err, name))
+}
+
+// callLazy passes arguments as thunks calling evaluate with the index of the argument.
+// Errors of arguments already point into the input, so they are returned unchanged when the handler returns them
+func callLazy(
+	handler func(args ...Thunk) (LazyResult, error),
+	argsCount int,
+	evaluate func(i int) (float64, error),
+	token *lexer.Token,
+	name string,
+) (LazyResult, error) {
+	var argErr error
+	thunks := make([]Thunk, argsCount)
+	for i := range thunks {
+		i := i
+		thunks[i] = func() (float64, error) {
+			v, err := evaluate(i)
+			if err != nil {
+				argErr = err
+			}
+			return v, err
+		}
+	}
+	res, err := handler(thunks...)
+	switch {
+	case err == nil:
+		return res, nil
+	case argErr != nil && errors.Is(err, argErr):
+		return LazyResult{}, err
+	}
+	return LazyResult{}, EvalError(token, fmt.Errorf("%s in function '%s'", err.Error(), name))
+}
+
+// checkArgumentsCount validates number of parameters of function node against function definition
+func checkArgumentsCount(f FunctionHandler, n *ast.FunctionNode) error {
+	paramsCount := len(n.Params())
 	switch {
 	case f.MinArguments == f.MaxArguments && paramsCount != f.MinArguments:
-		return 0, EvalError(n.GetToken(), fmt.Errorf(
+		return EvalError(n.GetToken(), fmt.Errorf(
 			"function '%s' require %d arguments, got %d",
 			n.Name(),
 			f.MinArguments,
 			paramsCount,
 		))
 	case paramsCount < f.MinArguments && f.MaxArguments == 0:
-		return 0, EvalError(n.GetToken(), fmt.Errorf(
+		return EvalError(n.GetToken(), fmt.Errorf(
 			"function '%s' require at least %d arguments, got %d",
 			n.Name(),
 			f.MinArguments,
 			paramsCount,
 		))
 	case paramsCount < f.MinArguments || f.MaxArguments > 0 && paramsCount > f.MaxArguments:
-		return 0, EvalError(n.GetToken(), fmt.Errorf(
+		return EvalError(n.GetToken(), fmt.Errorf(
 			"function '%s' require between %d and %d arguments, got %d",
 			n.Name(),
 			f.MinArguments,
@@ -206,19 +620,14 @@ func (e *NumericEvaluator) handleFunction(n *ast.FunctionNode) (float64, error)
 			paramsCount,
 		))
 	}
+	return nil
+}
 
-	args := []float64{}
-	for _, p := range params {
-		v, err := e.Eval(p)
-		if err != nil {
-			return 0, err
-		}
-		args = append(args, v)
-	}
-
-	val, err := f.Handler(args...)
-	if err != nil {
-		return 0, EvalError(n.GetToken(), fmt.Errorf("%s in function '%s'", err.Error(), n.Name()))
+// numberValue returns value of the number node. Literals too large for float64, like 1e400, are kept
+// by the lexer for arbitrary precision evaluators, but cannot be evaluated with float64
+func numberValue(n *ast.NumericNode) (float64, error) {
+	if math.IsInf(n.Value(), 0) {
+		return 0, EvalError(n.GetToken(), lexer.ErrNumberOutOfRange)
 	}
-	return val, nil
+	return n.Value(), nil
 }
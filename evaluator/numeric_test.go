@@ -1,6 +1,7 @@
 package evaluator_test
 
 import (
+	"context"
 	"errors"
 	"math"
 	"strings"
@@ -16,6 +17,21 @@ import (
 	"github.com/onsi/gomega/types"
 )
 
+// logicalTree represents expression `left op count(7) >= 3`, function count returns its argument
+func logicalTree(op ast.Operation, left float64) ast.Node {
+	return ast.NewBinaryNode(
+		op,
+		ast.NewNumericNode(left, nil),
+		ast.NewBinaryNode(
+			ast.GreaterOrEqual,
+			ast.NewFunctionNode("count", []ast.Node{ast.NewNumericNode(7, nil)}, nil),
+			ast.NewNumericNode(3, nil),
+			nil,
+		),
+		nil,
+	)
+}
+
 var _ = Describe("Evaluator", func() {
 	It("Check value", func() {
 		ev, err := evaluator.NewNumericEvaluator(nil)
@@ -81,11 +97,23 @@ var _ = Describe("Evaluator", func() {
 			Expect(res).To(BeEquivalentTo(-33))
 			Expect(err).To(Succeed())
 		})
+		It("Check logical negation", func() {
+			ev, err := evaluator.NewNumericEvaluator(nil)
+			Expect(err).To(Succeed())
+			res, err := ev.Eval(ast.NewUnaryNode(ast.Not, ast.NewNumericNode(33, nil), nil))
+			Expect(res).To(BeEquivalentTo(0))
+			Expect(err).To(Succeed())
+			res, err = ev.Eval(ast.NewUnaryNode(ast.Not, ast.NewNumericNode(0, nil), nil))
+			Expect(res).To(BeEquivalentTo(1))
+			Expect(err).To(Succeed())
+		})
 		It("Check error", func() {
 			ev, err := evaluator.NewNumericEvaluator(nil)
 			Expect(err).To(Succeed())
 			_, err = ev.Eval(ast.NewUnaryNode(ast.Multiplication, ast.NewNumericNode(33, nil), nil))
-			Expect(err).To(MatchError(ContainSubstring("unary node supports only Addition and Substraction operator")))
+			Expect(err).To(MatchError(
+				ContainSubstring("unary node supports only Addition, Substraction and Not operator"),
+			))
 		})
 	})
 
@@ -126,12 +154,191 @@ var _ = Describe("Evaluator", func() {
 			ast.NewBinaryNode(ast.Modulus, ast.NewNumericNode(39, nil), ast.NewNumericNode(2.5, nil), nil),
 			1.5,
 			Succeed()),
+		Entry("Less",
+			ast.NewBinaryNode(ast.Less, ast.NewNumericNode(3.87, nil), ast.NewVariableNode("myVar", nil), nil),
+			0.0,
+			Succeed()),
+		Entry("LessOrEqual",
+			ast.NewBinaryNode(ast.LessOrEqual, ast.NewNumericNode(3, nil), ast.NewVariableNode("intVar", nil), nil),
+			1.0,
+			Succeed()),
+		Entry("Greater",
+			ast.NewBinaryNode(ast.Greater, ast.NewNumericNode(3.87, nil), ast.NewVariableNode("myVar", nil), nil),
+			1.0,
+			Succeed()),
+		Entry("GreaterOrEqual",
+			ast.NewBinaryNode(ast.GreaterOrEqual, ast.NewNumericNode(2, nil), ast.NewVariableNode("intVar", nil), nil),
+			0.0,
+			Succeed()),
+		Entry("IsEqual",
+			ast.NewBinaryNode(ast.IsEqual, ast.NewNumericNode(3, nil), ast.NewVariableNode("intVar", nil), nil),
+			1.0,
+			Succeed()),
+		Entry("NotEqual",
+			ast.NewBinaryNode(ast.NotEqual, ast.NewNumericNode(3, nil), ast.NewVariableNode("intVar", nil), nil),
+			0.0,
+			Succeed()),
+		Entry("And",
+			ast.NewBinaryNode(ast.And, ast.NewNumericNode(-2, nil), ast.NewVariableNode("myVar", nil), nil),
+			1.0,
+			Succeed()),
+		Entry("Or",
+			ast.NewBinaryNode(ast.Or, ast.NewNumericNode(0, nil), ast.NewNumericNode(0, nil), nil),
+			0.0,
+			Succeed()),
 		Entry("Error operation",
 			ast.NewBinaryNode(ast.Invalid, ast.NewNumericNode(39, nil), ast.NewNumericNode(2.5, nil), nil),
 			0.0,
 			MatchError(ContainSubstring("unimplemented operator Invalid"))),
 	)
 
+	DescribeTable("Handle postfix",
+		func(expr string, expRes float64) {
+			ev, err := evaluator.NewNumericEvaluator(map[string]float64{"x": 4})
+			Expect(err).To(Succeed())
+			tree := parseExpression(expr)
+
+			res, err := ev.Eval(tree)
+			Expect(err).To(Succeed())
+			Expect(res).To(BeNumerically("~", expRes, 1e-12))
+
+			program, err := ev.Compile(tree)
+			Expect(err).To(Succeed())
+			Expect(program.Run([]float64{4})).To(BeNumerically("~", expRes, 1e-12))
+		},
+		Entry("Factorial", "x!", 24.0),
+		Entry("Factorial of zero", "0! + x", 5.0),
+		Entry("Factorial of non-integer", "0.5! * x", 2*math.Sqrt(math.Pi)),
+		Entry("Factorial before unary operator", "-3! + x", -2.0),
+		Entry("Factorial in exponent", "2 ^ 3! + x", 68.0),
+		Entry("Repeated factorial", "3!! - x", 716.0),
+		Entry("Percent", "x * 50%", 2.0),
+		Entry("Percent added to the left operand", "(100 + 10%) + x", 114.0),
+		Entry("Percent substracted from the left operand", "x - 25%", 3.0),
+		Entry("Percent of the left side", "x * 10 + 10%", 44.0),
+		Entry("Percent followed by operator", "(10%) - x", -3.9),
+		Entry("Percent followed by substraction", "100 + 10% - x", 106.0),
+		Entry("Sum of percents", "10% + 20% + x", 4.12),
+		Entry("Percent followed by sign", "10% - 1 + x", 3.1),
+		Entry("Factorial of percent", "x + 10%!", 4+math.Gamma(1.1)),
+		Entry("Modulus of signed operand", "10 % -x", 2.0),
+		Entry("Modulus", "10 % x", 2.0),
+	)
+
+	It("Factorial of negative integers and invalid postfix operator", func() {
+		ev, err := evaluator.NewNumericEvaluator(nil)
+		Expect(err).To(Succeed())
+		Expect(ev.Eval(parseExpression("(-1)!"))).To(Equal(math.Inf(1)))
+		res, err := ev.Eval(parseExpression("(-2)!"))
+		Expect(err).To(Succeed())
+		Expect(math.IsNaN(res)).To(BeTrue())
+
+		_, err = ev.Eval(ast.NewPostfixNode(ast.Not, ast.NewNumericNode(3, nil), nil))
+		Expect(err).To(MatchError(ContainSubstring("postfix node supports only Factorial and Percent operator")))
+	})
+
+	DescribeTable("Short-circuit logical operators",
+		func(op ast.Operation, left float64, expRes float64, expCalls int) {
+			calls := 0
+			ev, err := evaluator.NewNumericEvaluator(nil, map[string]evaluator.FunctionHandler{
+				"count": {
+					Handler: func(x ...float64) (float64, error) {
+						calls++
+						return x[0], nil
+					},
+					MinArguments: 1, MaxArguments: 1,
+				},
+			})
+			Expect(err).To(Succeed())
+			tree := logicalTree(op, left)
+
+			Expect(ev.Eval(tree)).To(Equal(expRes))
+			Expect(calls).To(Equal(expCalls))
+
+			program, err := ev.Compile(tree)
+			Expect(err).To(Succeed())
+			Expect(program.Run(nil)).To(Equal(expRes))
+			Expect(calls).To(Equal(2 * expCalls))
+		},
+		Entry("And with false left side", ast.And, 0.0, 0.0, 0),
+		Entry("And with true left side", ast.And, 5.0, 1.0, 1),
+		Entry("Or with true left side", ast.Or, -1.0, 1.0, 0),
+		Entry("Or with false left side", ast.Or, 0.0, 1.0, 1),
+	)
+
+	It("Does not evaluate skipped side of logical operator", func() {
+		ev, err := evaluator.NewNumericEvaluator(nil)
+		Expect(err).To(Succeed())
+		// 0 && undefinedVar || 1
+		res, err := ev.Eval(ast.NewBinaryNode(
+			ast.Or,
+			ast.NewBinaryNode(ast.And, ast.NewNumericNode(0, nil), ast.NewVariableNode("undefinedVar", nil), nil),
+			ast.NewNumericNode(1, nil),
+			nil,
+		))
+		Expect(err).To(Succeed())
+		Expect(res).To(BeEquivalentTo(1))
+	})
+
+	DescribeTable("Evaluate only needed arguments",
+		func(expr string, expRes float64) {
+			ev, err := evaluator.NewNumericEvaluator(
+				map[string]float64{"x": 4, "nan": math.NaN()},
+				evaluator.MathFunctions(),
+				evaluator.LazyFunctions(),
+			)
+			Expect(err).To(Succeed())
+			tree := parseExpression(expr)
+
+			Expect(ev.Eval(tree)).To(Equal(expRes))
+
+			program, err := ev.Compile(tree)
+			Expect(err).To(Succeed())
+			// Variables of the program are slotted, skipped undefined variables are just filled with 0
+			vars := make([]float64, len(program.Variables()))
+			for i, name := range program.Variables() {
+				vars[i] = map[string]float64{"x": 4, "nan": math.NaN()}[name]
+			}
+			Expect(program.Run(vars)).To(Equal(expRes))
+		},
+		Entry("Conditional true branch", "x > 0 ? 1 / x : undefinedVar", 0.25),
+		Entry("Conditional false branch", "x < 0 ? undefinedVar : -x", -4.0),
+		Entry("Nested conditional", "x > 5 ? 1 : x > 3 ? 2 : 3", 2.0),
+		Entry("If function", "if(x - 4, undefinedVar, sqrt(x))", 2.0),
+		Entry("Coalesce skips NaN", "coalesce(sqrt(-1), nan, x, undefinedVar)", 4.0),
+		Entry("And function", "and(x, 0, undefinedVar)", 0.0),
+		Entry("Or function", "or(0, x, undefinedVar)", 1.0),
+	)
+
+	It("Evaluates statements in order", func() {
+		ev, err := evaluator.NewNumericEvaluator(nil, evaluator.MathFunctions())
+		Expect(err).To(Succeed())
+		tree := parseExpression("a = 3; f(x) = x * a\nb = f(2)\n\nb ^ 2;")
+		Expect(ev.Eval(tree)).To(BeEquivalentTo(36))
+
+		program, err := ev.Compile(parseExpression("a = a + 1; a * 2"))
+		Expect(err).To(Succeed())
+		vars := []float64{4}
+		Expect(program.Run(vars)).To(BeEquivalentTo(10))
+		Expect(vars).To(Equal([]float64{5}))
+
+		_, err = ev.Eval(parseExpression("c = 1\nd = c / zz"))
+		Expect(err).To(MatchError("undefined variable 'zz' at line 2, column 9"))
+	})
+
+	DescribeTable("Lazy function errors",
+		func(expr string, errStr string) {
+			ev, err := evaluator.NewNumericEvaluator(nil, evaluator.LazyFunctions())
+			Expect(err).To(Succeed())
+			_, err = ev.Eval(parseExpression(expr))
+			Expect(err).To(MatchError(errStr))
+		},
+		Entry("Argument error keeps its position", "if(1, y, 0)", "undefined variable 'y' at position 6"),
+		Entry("Coalesce skips errors", "coalesce(a, 1) + b", "undefined variable 'b' at position 17"),
+		Entry("Last error of coalesce", "coalesce(a, b)", "undefined variable 'b' at position 12"),
+		Entry("Wrong arguments count", "if(1, 2)", "function 'if' require 3 arguments, got 2 at position 0"),
+	)
+
 	DescribeTable(
 		"Handle function",
 		func(rootNode ast.Node, errStr string) {
@@ -192,7 +399,9 @@ var _ = Describe("Evaluator", func() {
 			ev, err := evaluator.NewNumericEvaluator(nil)
 			Expect(err).To(Succeed())
 			_, err = ev.Eval(ast.NewUnaryNode(ast.Multiplication, ast.NewNumericNode(33, nil), nil))
-			Expect(err).To(MatchError(ContainSubstring("unary node supports only Addition and Substraction operator")))
+			Expect(err).To(MatchError(
+				ContainSubstring("unary node supports only Addition, Substraction and Not operator"),
+			))
 		})
 	})
 
@@ -278,6 +487,215 @@ var _ = Describe("Evaluator", func() {
 		Expect(err).To(Succeed())
 	})
 
+	Describe("User defined functions", func() {
+		// f(x, Y) = x^2 + y * a
+		definition := ast.NewFunctionDefNode(
+			"f",
+			[]*ast.VariableNode{ast.NewVariableNode("x", nil), ast.NewVariableNode("Y", nil)},
+			ast.NewBinaryNode(
+				ast.Addition,
+				ast.NewBinaryNode(ast.Exponent, ast.NewVariableNode("x", nil), ast.NewNumericNode(2, nil), nil),
+				ast.NewBinaryNode(
+					ast.Multiplication, ast.NewVariableNode("y", nil), ast.NewVariableNode("a", nil), nil,
+				),
+				nil,
+			),
+			lexer.NewToken(lexer.Equal, 0, "", 5, 6),
+		)
+
+		It("Define and call function", func() {
+			ev, err := evaluator.NewNumericEvaluator(map[string]float64{"a": 10, "x": 100})
+			Expect(err).To(Succeed())
+			_, err = ev.Eval(definition)
+			Expect(err).To(Succeed())
+
+			res, err := ev.Eval(ast.NewFunctionNode("F", []ast.Node{
+				ast.NewNumericNode(3, nil),
+				ast.NewNumericNode(2, nil),
+			}, nil))
+			Expect(err).To(Succeed())
+			Expect(res).To(BeEquivalentTo(29))
+			// Parameters do not leak into global scope
+			Expect(ev.VariableList()).To(ConsistOf(
+				MatchAllFields(Fields{"Name": Equal("a"), "Value": BeEquivalentTo(10)}),
+				MatchAllFields(Fields{"Name": Equal("x"), "Value": BeEquivalentTo(100)}),
+			))
+
+			funcs := ev.FunctionList()
+			Expect(funcs).To(HaveLen(1))
+			Expect(funcs[0].Name).To(Equal("f"))
+			Expect(funcs[0].Function.ArgsNames).To(Equal([]string{"x", "Y"}))
+			Expect(funcs[0].Function.MinArguments).To(Equal(2))
+			Expect(funcs[0].Function.MaxArguments).To(Equal(2))
+		})
+
+		It("Check number of arguments", func() {
+			ev, err := evaluator.NewNumericEvaluator(nil)
+			Expect(err).To(Succeed())
+			_, err = ev.Eval(definition)
+			Expect(err).To(Succeed())
+			_, err = ev.Eval(ast.NewFunctionNode("f", []ast.Node{ast.NewNumericNode(3, nil)}, nil))
+			Expect(err).To(MatchError(ContainSubstring("function 'f' require 2 arguments, got 1")))
+		})
+
+		It("Cannot redefine built-in function", func() {
+			ev, err := evaluator.NewNumericEvaluator(nil, map[string]evaluator.FunctionHandler{"F": {}})
+			Expect(err).To(Succeed())
+			_, err = ev.Eval(definition)
+			Expect(err).To(MatchError("cannot redefine built-in function 'f' at position 5"))
+		})
+
+		It("Can redefine user defined function", func() {
+			ev, err := evaluator.NewNumericEvaluator(nil)
+			Expect(err).To(Succeed())
+			_, err = ev.Eval(definition)
+			Expect(err).To(Succeed())
+			_, err = ev.Eval(ast.NewFunctionDefNode("f", nil, ast.NewNumericNode(7, nil), nil))
+			Expect(err).To(Succeed())
+			Expect(ev.Eval(ast.NewFunctionNode("f", nil, nil))).To(BeEquivalentTo(7))
+		})
+
+		It("Cannot call itself", func() {
+			ev, err := evaluator.NewNumericEvaluator(nil)
+			Expect(err).To(Succeed())
+			// g(x) = f(x, 1)
+			_, err = ev.Eval(ast.NewFunctionDefNode(
+				"f", nil, ast.NewNumericNode(7, nil), nil,
+			))
+			Expect(err).To(Succeed())
+			_, err = ev.Eval(ast.NewFunctionDefNode(
+				"g", []*ast.VariableNode{ast.NewVariableNode("x", nil)},
+				ast.NewUnaryNode(ast.Substraction, ast.NewFunctionNode("f", nil, nil), nil),
+				nil,
+			))
+			Expect(err).To(Succeed())
+			// f() = g(1) would create the cycle
+			_, err = ev.Eval(ast.NewFunctionDefNode(
+				"f", nil, ast.NewFunctionNode("g", []ast.Node{ast.NewNumericNode(1, nil)}, nil),
+				lexer.NewToken(lexer.Equal, 0, "", 4, 5),
+			))
+			Expect(err).To(MatchError("function 'f' cannot call itself at position 4"))
+		})
+	})
+
+	It("Limits number of evaluated steps", func() {
+		ev, err := evaluator.NewNumericEvaluator(nil)
+		Expect(err).To(Succeed())
+		_, err = ev.Eval(parseExpression("f(x) = x * x + 1"))
+		Expect(err).To(Succeed())
+		ev.SetStepLimit(13)
+
+		// Call, argument and 5 nodes of the body are evaluated for each call
+		Expect(ev.Eval(parseExpression("f(1) - 1"))).To(BeEquivalentTo(1))
+		Expect(ev.Eval(parseExpression("f(f(1))"))).To(BeEquivalentTo(5))
+		_, err = ev.Eval(parseExpression("f(f(1)) + 1"))
+		Expect(errors.Is(err, evaluator.ErrStepLimit)).To(BeTrue())
+		// The last step is the number at the end of the function body
+		Expect(err).To(MatchError("evaluation exceeded the limit of steps at position 15"))
+
+		ev.SetStepLimit(0)
+		Expect(ev.Eval(parseExpression("f(f(f(1)))"))).To(BeEquivalentTo(26))
+	})
+
+	It("Allows only selected functions", func() {
+		ev, err := evaluator.NewNumericEvaluator(nil, evaluator.MathFunctions())
+		Expect(err).To(Succeed())
+		ev.SetAllowedFunctions("ABS", "floor")
+
+		Expect(ev.Eval(parseExpression("abs(-2) + floor(1.5)"))).To(BeEquivalentTo(3))
+		_, err = ev.Eval(parseExpression("1 + sqrt(4)"))
+		Expect(errors.Is(err, evaluator.ErrFunctionNotAllowed)).To(BeTrue())
+		Expect(err).To(MatchError("function is not allowed 'sqrt' at position 4"))
+
+		// Functions defined by the expression are always allowed, but they cannot call forbidden functions
+		_, err = ev.Eval(parseExpression("f(x) = abs(x); g(x) = sqrt(x)"))
+		Expect(err).To(Succeed())
+		Expect(ev.Eval(parseExpression("f(-4)"))).To(BeEquivalentTo(4))
+		_, err = ev.Eval(parseExpression("g(4)"))
+		Expect(errors.Is(err, evaluator.ErrFunctionNotAllowed)).To(BeTrue())
+
+		names := []string{}
+		for _, f := range ev.FunctionList() {
+			names = append(names, f.Name)
+		}
+		Expect(names).To(Equal([]string{"abs", "f", "floor", "g"}))
+
+		ev.SetAllowedFunctions()
+		Expect(ev.Eval(parseExpression("sqrt(4)"))).To(BeEquivalentTo(2))
+	})
+
+	It("Stops evaluation when context is done", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		calls := 0
+		ev, err := evaluator.NewNumericEvaluator(nil, map[string]evaluator.FunctionHandler{
+			"cancel": {Handler: func(x ...float64) (float64, error) {
+				calls++
+				cancel()
+				return 1, nil
+			}},
+		})
+		Expect(err).To(Succeed())
+
+		_, err = ev.EvalContext(ctx, parseExpression("cancel() + cancel()"))
+		Expect(errors.Is(err, context.Canceled)).To(BeTrue())
+		Expect(err).To(MatchError("context canceled at position 11"))
+		Expect(calls).To(Equal(1))
+
+		// Context is used only by the single evaluation
+		Expect(ev.Eval(parseExpression("1 + 2"))).To(BeEquivalentTo(3))
+
+		ctx, cancel = context.WithTimeout(context.Background(), 0)
+		defer cancel()
+		_, err = ev.EvalContext(ctx, parseExpression("1 + 2"))
+		Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue())
+	})
+
+	It("Passes context and call site to context handlers", func() {
+		type ratesKey struct{}
+		errUnknownRate := errors.New("unknown rate")
+		calls := []evaluator.CallSite{}
+		ev, err := evaluator.NewNumericEvaluator(nil, map[string]evaluator.FunctionHandler{
+			"rate": {
+				ContextHandler: func(ctx context.Context, call evaluator.CallSite, x ...float64) (float64, error) {
+					calls = append(calls, call)
+					if err := ctx.Err(); err != nil {
+						return 0, err
+					}
+					rates, _ := ctx.Value(ratesKey{}).(map[float64]float64)
+					if rate, ok := rates[x[0]]; ok {
+						return rate, nil
+					}
+					return 0, errUnknownRate
+				},
+				MinArguments: 1, MaxArguments: 1,
+			},
+		})
+		Expect(err).To(Succeed())
+
+		ctx := context.WithValue(context.Background(), ratesKey{}, map[float64]float64{1: 25.5, 2: 0.5})
+		Expect(ev.EvalContext(ctx, parseExpression("100 * RATE(1) + rate(2)"))).To(BeEquivalentTo(2550.5))
+		Expect(calls).To(HaveLen(2))
+		Expect(calls[0].Name).To(Equal("RATE"))
+		Expect(calls[0].Token.Location()).To(Equal(lexer.Location{Offset: 6, Length: 4, Line: 1, Column: 7}))
+		Expect(calls[1].Name).To(Equal("rate"))
+
+		_, err = ev.EvalContext(ctx, parseExpression("1 + rate(3)"))
+		Expect(errors.Is(err, errUnknownRate)).To(BeTrue())
+		Expect(err).To(MatchError("unknown rate in function 'rate' at position 4"))
+
+		// Eval uses context without values
+		_, err = ev.Eval(parseExpression("rate(1)"))
+		Expect(errors.Is(err, errUnknownRate)).To(BeTrue())
+
+		// Cancellation is checked before the function is called
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		callsCount := len(calls)
+		_, err = ev.EvalContext(ctx, parseExpression("rate(1)"))
+		Expect(errors.Is(err, context.Canceled)).To(BeTrue())
+		Expect(calls).To(HaveLen(callsCount))
+	})
+
 	It("Check error when defining same variable with different case sensitivity", func() {
 		_, err := evaluator.NewNumericEvaluator(map[string]float64{
 			"my_variable": 123,
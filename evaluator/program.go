@@ -0,0 +1,515 @@
+package evaluator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/arxeiss/go-expression-calculator/ast"
+	"github.com/arxeiss/go-expression-calculator/ast/symbolic"
+	"github.com/arxeiss/go-expression-calculator/lexer"
+)
+
+type opCode uint8
+
+const (
+	opPush opCode = iota
+	opLoad
+	opStore
+	opNegate
+	opAddition
+	opSubstraction
+	opMultiplication
+	opDivision
+	opFloorDiv
+	opExponent
+	opModulus
+	opLess
+	opLessOrEqual
+	opGreater
+	opGreaterOrEqual
+	opIsEqual
+	opNotEqual
+	opNot
+	opCall
+	// opJumpIfFalse and opJumpIfTrue implement short-circuit of && and ||. When the value at the top of the stack
+	// decides the result, it is replaced with 1 or 0 and evaluation continues at arg, otherwise the value is popped
+	opJumpIfFalse
+	opJumpIfTrue
+	// opBool replaces the value at the top of the stack with 1 or 0
+	opBool
+	// opBranch pops the condition and continues at arg when it is zero, opJump continues at arg always
+	opBranch
+	opJump
+	// opCallLazy runs arguments compiled into own programs only when the handler asks for them
+	opCallLazy
+	// opPop drops the value of finished statement
+	opPop
+	opFactorial
+	opPercent
+	// opRelative multiplies the value at the top of the stack by the value below it, so 100 + 10% is 100 + 100*0.1
+	opRelative
+)
+
+var comparisonOpCodes = map[ast.Operation]opCode{
+	ast.Less:           opLess,
+	ast.LessOrEqual:    opLessOrEqual,
+	ast.Greater:        opGreater,
+	ast.GreaterOrEqual: opGreaterOrEqual,
+	ast.IsEqual:        opIsEqual,
+	ast.NotEqual:       opNotEqual,
+}
+
+type instruction struct {
+	op opCode
+	// value is constant pushed by opPush
+	value float64
+	// arg is variable slot for opLoad and opStore, number of arguments for opCall or target of jumps
+	arg         int
+	handler     func(x ...float64) (float64, error)
+	lazyHandler func(args ...Thunk) (LazyResult, error)
+	lazyArgs    []*Program
+	name        string
+	token       *lexer.Token
+}
+
+// Program is AST compiled into the flat list of instructions for the stack machine.
+// All variables are resolved into slots and functions into direct handlers, so evaluation
+// does not need any map lookup. Program is reusable, but it is not safe for concurrent use.
+// Limits are shared with the evaluator which compiled the program, so it cannot run concurrently with Eval either.
+type Program struct {
+	instructions []instruction
+	variables    []string
+	stack        []float64
+	limits       *evalLimits
+}
+
+type compiler struct {
+	evaluator *NumericEvaluator
+	program   *Program
+	slots     map[string]int
+	depth     int
+	maxDepth  int
+	// parent is set for arguments of lazy functions, which share variable slots with the main program
+	parent *compiler
+}
+
+// Compile converts AST into the Program, which can be evaluated repeatedly with different variable values.
+// Functions not allowed by SetAllowedFunctions cannot be compiled and the step limit applies to every Run,
+// where each executed instruction is one step
+func (e *NumericEvaluator) Compile(rootNode ast.Node) (*Program, error) {
+	c := &compiler{
+		evaluator: e,
+		program:   &Program{limits: e.limits},
+		slots:     make(map[string]int),
+	}
+	if err := c.compile(rootNode); err != nil {
+		return nil, err
+	}
+	c.program.stack = make([]float64, c.maxDepth)
+	return c.program, nil
+}
+
+// CompileOptimized simplifies AST with symbolic.SimplifyExact before it is compiled, so constants are folded
+// and identities are removed, but the program gives the same results as Eval. Terms with variables are never
+// dropped, as variables can be NaN or infinity. Variables which disappear during simplification,
+// like in 0 ? x : y, are not part of the program anymore.
+func (e *NumericEvaluator) CompileOptimized(rootNode ast.Node) (*Program, error) {
+	return e.Compile(symbolic.SimplifyExact(rootNode))
+}
+
+// Variables returns names of variables in the order of slots expected by Run
+func (p *Program) Variables() []string {
+	return append([]string(nil), p.variables...)
+}
+
+// Slot returns index of the variable in the slice passed to Run
+func (p *Program) Slot(name string) (int, bool) {
+	name = strings.ToLower(name)
+	for i, v := range p.variables {
+		if v == name {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// Run evaluates the program with given variable values, ordered same as Variables returns.
+// Assignments inside the program are written back into vars slice.
+func (p *Program) Run(vars []float64) (float64, error) {
+	return p.RunContext(context.Background(), vars)
+}
+
+// RunContext evaluates the program same as Run, but stops with the error of ctx when ctx is done.
+// Context handlers of functions receive ctx as well
+func (p *Program) RunContext(ctx context.Context, vars []float64) (float64, error) {
+	if len(vars) != len(p.variables) {
+		return 0, fmt.Errorf("program expects %d variables, got %d", len(p.variables), len(vars))
+	}
+	p.limits.ctx = ctx
+	p.limits.steps = 0
+	defer func() { p.limits.ctx = context.Background() }()
+	return p.run(vars)
+}
+
+func (p *Program) run(vars []float64) (float64, error) {
+	stack := p.stack
+	sp := 0
+	for i := 0; i < len(p.instructions); i++ {
+		ins := &p.instructions[i]
+		if err := p.limits.check(ins.token); err != nil {
+			return 0, err
+		}
+		switch ins.op {
+		case opPush:
+			stack[sp] = ins.value
+			sp++
+		case opLoad:
+			stack[sp] = vars[ins.arg]
+			sp++
+		case opStore:
+			vars[ins.arg] = stack[sp-1]
+		case opNegate:
+			stack[sp-1] = -stack[sp-1]
+		case opNot, opBool, opFactorial, opPercent, opRelative:
+			stack[sp-1] = topOperation(ins.op, stack[:sp])
+		case opPop:
+			sp--
+		case opJumpIfFalse, opJumpIfTrue, opBranch, opJump:
+			var jumped bool
+			if sp, jumped = jump(ins, stack, sp); jumped {
+				// Loop increments the index, so it must point one instruction before the target
+				i = ins.arg - 1
+			}
+		case opCall:
+			val, err := ins.handler(stack[sp-ins.arg : sp : sp]...)
+			if err != nil {
+				return 0, functionError(ins.token, ins.name, err)
+			}
+			sp -= ins.arg
+			stack[sp] = val
+			sp++
+		case opCallLazy:
+			res, err := callLazy(ins.lazyHandler, len(ins.lazyArgs), func(i int) (float64, error) {
+				return ins.lazyArgs[i].run(vars)
+			}, ins.token, ins.name)
+			if err != nil {
+				return 0, err
+			}
+			stack[sp] = res.Value
+			sp++
+		default:
+			sp--
+			stack[sp-1] = binaryOperation(ins.op, stack[sp-1], stack[sp])
+		}
+	}
+	return stack[0], nil
+}
+
+// jump returns the stack pointer after the jump instruction and true when evaluation continues at its target
+func jump(ins *instruction, stack []float64, sp int) (int, bool) {
+	switch ins.op {
+	case opJump:
+		return sp, true
+	case opBranch:
+		return sp - 1, stack[sp-1] == 0
+	}
+	if isTrue := stack[sp-1] != 0; isTrue == (ins.op == opJumpIfTrue) {
+		stack[sp-1] = boolToFloat(isTrue)
+		return sp, true
+	}
+	return sp - 1, false
+}
+
+// topOperation returns new value of the top of the stack, which is the last item of the stack slice
+func topOperation(op opCode, stack []float64) float64 {
+	x := stack[len(stack)-1]
+	switch op {
+	case opNot:
+		return boolToFloat(x == 0)
+	case opBool:
+		return boolToFloat(x != 0)
+	case opFactorial:
+		return factorial(x)
+	case opPercent:
+		return x / 100
+	}
+	return x * stack[len(stack)-2]
+}
+
+func binaryOperation(op opCode, l, r float64) float64 {
+	switch op {
+	case opAddition:
+		return l + r
+	case opSubstraction:
+		return l - r
+	case opMultiplication:
+		return l * r
+	case opDivision:
+		return l / r
+	case opFloorDiv:
+		return math.Floor(l / r)
+	case opExponent:
+		return math.Pow(l, r)
+	case opModulus:
+		return math.Mod(l, r)
+	case opLess:
+		return compare(ast.Less, l, r)
+	case opLessOrEqual:
+		return compare(ast.LessOrEqual, l, r)
+	case opGreater:
+		return compare(ast.Greater, l, r)
+	case opGreaterOrEqual:
+		return compare(ast.GreaterOrEqual, l, r)
+	case opIsEqual:
+		return compare(ast.IsEqual, l, r)
+	case opNotEqual:
+		return compare(ast.NotEqual, l, r)
+	}
+	return math.NaN()
+}
+
+func (c *compiler) emit(ins instruction, stackChange int) {
+	c.program.instructions = append(c.program.instructions, ins)
+	c.depth += stackChange
+	if c.depth > c.maxDepth {
+		c.maxDepth = c.depth
+	}
+}
+
+func (c *compiler) slot(name string) int {
+	if c.parent != nil {
+		return c.parent.slot(name)
+	}
+	name = strings.ToLower(name)
+	if s, has := c.slots[name]; has {
+		return s
+	}
+	s := len(c.program.variables)
+	c.slots[name] = s
+	c.program.variables = append(c.program.variables, name)
+	return s
+}
+
+func (c *compiler) compile(node ast.Node) error {
+	switch n := node.(type) {
+	case *ast.NumericNode:
+		if n.Imaginary() {
+			return EvalError(n.GetToken(), ErrImaginaryNumber)
+		}
+		value, err := numberValue(n)
+		if err != nil {
+			return err
+		}
+		c.emit(instruction{op: opPush, value: value, token: n.GetToken()}, 1)
+	case *ast.VariableNode:
+		c.emit(instruction{op: opLoad, arg: c.slot(n.Name()), token: n.GetToken()}, 1)
+	case *ast.AssignNode:
+		if err := c.compile(n.Right()); err != nil {
+			return err
+		}
+		c.emit(instruction{op: opStore, arg: c.slot(n.Left().Name()), token: n.GetToken()}, 0)
+	case *ast.UnaryNode:
+		return c.compileUnary(n)
+	case *ast.PostfixNode:
+		return c.compilePostfix(n)
+	case *ast.BinaryNode:
+		return c.compileBinary(n)
+	case *ast.ConditionalNode:
+		return c.compileConditional(n)
+	case *ast.FunctionNode:
+		return c.compileFunction(n)
+	case *ast.BlockNode:
+		return c.compileBlock(n)
+	default:
+		return EvalError(node.GetToken(), fmt.Errorf("unimplemented node type %T", node))
+	}
+	return nil
+}
+
+// compileBlock compiles statements in order, only the value of the last one is kept on the stack
+func (c *compiler) compileBlock(n *ast.BlockNode) error {
+	for i, statement := range n.Statements() {
+		if i > 0 {
+			c.emit(instruction{op: opPop, token: statement.GetToken()}, -1)
+		}
+		if err := c.compile(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *compiler) compileUnary(n *ast.UnaryNode) error {
+	if err := c.compile(n.Next()); err != nil {
+		return err
+	}
+	switch n.Operator() {
+	case ast.Substraction:
+		c.emit(instruction{op: opNegate, token: n.GetToken()}, 0)
+	case ast.Addition:
+		// Unary addition does not change the value, nothing to emit
+	case ast.Not:
+		c.emit(instruction{op: opNot, token: n.GetToken()}, 0)
+	default:
+		return EvalError(n.GetToken(), errors.New("unary node supports only Addition, Substraction and Not operator"))
+	}
+	return nil
+}
+
+func (c *compiler) compilePostfix(n *ast.PostfixNode) error {
+	if err := c.compile(n.Prev()); err != nil {
+		return err
+	}
+	switch n.Operator() {
+	case ast.Factorial:
+		c.emit(instruction{op: opFactorial, token: n.GetToken()}, 0)
+	case ast.Percent:
+		c.emit(instruction{op: opPercent, token: n.GetToken()}, 0)
+	default:
+		return EvalError(n.GetToken(), errors.New("postfix node supports only Factorial and Percent operator"))
+	}
+	return nil
+}
+
+func (c *compiler) compileBinary(n *ast.BinaryNode) error {
+	var op opCode
+	switch n.Operator() {
+	case ast.Addition:
+		op = opAddition
+	case ast.Substraction:
+		op = opSubstraction
+	case ast.Multiplication:
+		op = opMultiplication
+	case ast.Division:
+		op = opDivision
+	case ast.FloorDiv:
+		op = opFloorDiv
+	case ast.Exponent:
+		op = opExponent
+	case ast.Modulus:
+		op = opModulus
+	case ast.Less, ast.LessOrEqual, ast.Greater, ast.GreaterOrEqual, ast.IsEqual, ast.NotEqual:
+		op = comparisonOpCodes[n.Operator()]
+	case ast.And, ast.Or:
+		return c.compileLogical(n)
+	default:
+		return EvalError(n.GetToken(), fmt.Errorf("unimplemented operator %s", n.Operator()))
+	}
+	if err := c.compile(n.Left()); err != nil {
+		return err
+	}
+	if err := c.compile(n.Right()); err != nil {
+		return err
+	}
+	if n.RelativePercent() {
+		c.emit(instruction{op: opRelative, token: n.Right().GetToken()}, 0)
+	}
+	c.emit(instruction{op: op, token: n.GetToken()}, -1)
+	return nil
+}
+
+// compileLogical emits jump after the left side, so the right side is skipped when the left one decides the result
+func (c *compiler) compileLogical(n *ast.BinaryNode) error {
+	if err := c.compile(n.Left()); err != nil {
+		return err
+	}
+	jump := instruction{op: opJumpIfFalse, token: n.GetToken()}
+	if n.Operator() == ast.Or {
+		jump.op = opJumpIfTrue
+	}
+	jumpIndex := len(c.program.instructions)
+	// When the jump is not taken, the left value is popped and replaced by the right one
+	c.emit(jump, -1)
+	if err := c.compile(n.Right()); err != nil {
+		return err
+	}
+	c.emit(instruction{op: opBool, token: n.GetToken()}, 0)
+	c.program.instructions[jumpIndex].arg = len(c.program.instructions)
+	return nil
+}
+
+// compileConditional emits branch over the first part and jump over the second one, only one of them is evaluated
+func (c *compiler) compileConditional(n *ast.ConditionalNode) error {
+	if err := c.compile(n.Condition()); err != nil {
+		return err
+	}
+	branchIndex := len(c.program.instructions)
+	c.emit(instruction{op: opBranch, token: n.GetToken()}, -1)
+	if err := c.compile(n.Then()); err != nil {
+		return err
+	}
+	jumpIndex := len(c.program.instructions)
+	// Only one branch leaves its value on the stack
+	c.emit(instruction{op: opJump, token: n.GetToken()}, -1)
+	c.program.instructions[branchIndex].arg = len(c.program.instructions)
+	if err := c.compile(n.Else()); err != nil {
+		return err
+	}
+	c.program.instructions[jumpIndex].arg = len(c.program.instructions)
+	return nil
+}
+
+func (c *compiler) compileFunction(n *ast.FunctionNode) error {
+	name := strings.ToLower(n.Name())
+	f, has := c.evaluator.functions[name]
+	if !has {
+		return EvalError(n.GetToken(), fmt.Errorf("undefined function '%s'", n.Name()))
+	}
+	if !c.evaluator.isAllowed(name) {
+		return EvalError(n.GetToken(), fmt.Errorf("%w '%s'", ErrFunctionNotAllowed, n.Name()))
+	}
+	if err := checkArgumentsCount(f, n); err != nil {
+		return err
+	}
+	params := n.Params()
+	if f.LazyHandler != nil {
+		return c.compileLazyFunction(f, n)
+	}
+	for _, p := range params {
+		if err := c.compile(p); err != nil {
+			return err
+		}
+	}
+	handler := f.Handler
+	if f.ContextHandler != nil {
+		// Context is taken from the limits, where RunContext stores it
+		call := CallSite{Name: n.Name(), Token: n.GetToken()}
+		limits := c.program.limits
+		handler = func(x ...float64) (float64, error) {
+			return f.ContextHandler(limits.ctx, call, x...)
+		}
+	}
+	// Function pops all arguments and pushes single result
+	c.emit(instruction{
+		op:      opCall,
+		arg:     len(params),
+		handler: handler,
+		name:    n.Name(),
+		token:   n.GetToken(),
+	}, 1-len(params))
+	return nil
+}
+
+// compileLazyFunction compiles every argument into own program, which is run only when the handler needs the value
+func (c *compiler) compileLazyFunction(f FunctionHandler, n *ast.FunctionNode) error {
+	args := make([]*Program, 0, len(n.Params()))
+	for _, p := range n.Params() {
+		sub := &compiler{evaluator: c.evaluator, program: &Program{limits: c.program.limits}, parent: c}
+		if err := sub.compile(p); err != nil {
+			return err
+		}
+		sub.program.stack = make([]float64, sub.maxDepth)
+		args = append(args, sub.program)
+	}
+	c.emit(instruction{
+		op:          opCallLazy,
+		lazyHandler: f.LazyHandler,
+		lazyArgs:    args,
+		name:        n.Name(),
+		token:       n.GetToken(),
+	}, 1)
+	return nil
+}
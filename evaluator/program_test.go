@@ -0,0 +1,457 @@
+package evaluator_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/arxeiss/go-expression-calculator/ast"
+	"github.com/arxeiss/go-expression-calculator/evaluator"
+	"github.com/arxeiss/go-expression-calculator/lexer"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+// programTree represents expression `-max(x % 3, 2) + abs(5 - X) * ceil(2.2 ^ y) // 1`
+func programTree() ast.Node {
+	return ast.NewBinaryNode(
+		ast.Addition,
+		ast.NewUnaryNode(ast.Substraction, ast.NewFunctionNode(
+			"max",
+			[]ast.Node{
+				ast.NewBinaryNode(ast.Modulus, ast.NewVariableNode("x", nil), ast.NewNumericNode(3, nil), nil),
+				ast.NewNumericNode(2, nil),
+			},
+			nil,
+		), nil),
+		ast.NewBinaryNode(
+			ast.FloorDiv,
+			ast.NewBinaryNode(
+				ast.Multiplication,
+				ast.NewFunctionNode("abs", []ast.Node{ast.NewBinaryNode(
+					ast.Substraction,
+					ast.NewNumericNode(5, nil),
+					ast.NewVariableNode("X", nil),
+					nil,
+				)}, nil),
+				ast.NewFunctionNode("ceil", []ast.Node{ast.NewBinaryNode(
+					ast.Exponent,
+					ast.NewNumericNode(2.2, nil),
+					ast.NewVariableNode("y", nil),
+					nil,
+				)}, nil),
+				nil,
+			),
+			ast.NewNumericNode(1, nil),
+			nil,
+		),
+		nil,
+	)
+}
+
+func newProgramEvaluator(vars map[string]float64) *evaluator.NumericEvaluator {
+	ev, err := evaluator.NewNumericEvaluator(vars, evaluator.MathFunctions(), evaluator.MathFunctionsWithVarArgs())
+	Expect(err).To(Succeed())
+	return ev
+}
+
+// expectSameAsEval checks that compiled and optimized programs give the same result and variables as Eval
+func expectSameAsEval(expr string, values map[string]float64) {
+	ev := newProgramEvaluator(values)
+	expected, expectedErr := ev.Eval(parseExpression(expr))
+	expectedVars := map[string]float64{}
+	for _, v := range ev.VariableList() {
+		expectedVars[v.Name] = v.Value
+	}
+
+	for _, compile := range []func(ast.Node) (*evaluator.Program, error){ev.Compile, ev.CompileOptimized} {
+		program, err := compile(parseExpression(expr))
+		Expect(err).To(Succeed())
+		vars := make([]float64, len(program.Variables()))
+		for i, name := range program.Variables() {
+			vars[i] = values[name]
+		}
+		res, err := program.Run(vars)
+		if expectedErr != nil {
+			Expect(err).To(MatchError(expectedErr))
+			continue
+		}
+		Expect(err).To(Succeed())
+		expectSameFloat(res, expected, "%s with %v", expr, values)
+		for i, name := range program.Variables() {
+			expectSameFloat(vars[i], expectedVars[name], "variable %s of %s with %v", name, expr, values)
+		}
+	}
+}
+
+// expectSameFloat compares floats including NaN and the sign of zero
+func expectSameFloat(actual, expected float64, description ...interface{}) {
+	Expect(math.IsNaN(actual)).To(Equal(math.IsNaN(expected)), description...)
+	if !math.IsNaN(expected) {
+		Expect(actual).To(Equal(expected), description...)
+		Expect(math.Signbit(actual)).To(Equal(math.Signbit(expected)), description...)
+	}
+}
+
+var _ = Describe("Compiled program", func() {
+	It("Returns same results as tree-walking evaluator", func() {
+		for _, vars := range []map[string]float64{
+			{"x": 13.8, "y": 3},
+			{"x": -4, "y": 0},
+			{"x": 8, "y": 2.5},
+		} {
+			ev := newProgramEvaluator(vars)
+			expected, err := ev.Eval(programTree())
+			Expect(err).To(Succeed())
+
+			program, err := ev.Compile(programTree())
+			Expect(err).To(Succeed())
+			Expect(program.Variables()).To(Equal([]string{"x", "y"}))
+
+			res, err := program.Run([]float64{vars["x"], vars["y"]})
+			Expect(err).To(Succeed())
+			Expect(res).To(Equal(expected))
+		}
+	})
+
+	It("Evaluates comparison and logical operators", func() {
+		// !(x < y) || x == 3 && y != 0
+		tree := ast.NewBinaryNode(
+			ast.Or,
+			ast.NewUnaryNode(ast.Not, ast.NewBinaryNode(
+				ast.Less, ast.NewVariableNode("x", nil), ast.NewVariableNode("y", nil), nil,
+			), nil),
+			ast.NewBinaryNode(
+				ast.And,
+				ast.NewBinaryNode(ast.IsEqual, ast.NewVariableNode("x", nil), ast.NewNumericNode(3, nil), nil),
+				ast.NewBinaryNode(ast.NotEqual, ast.NewVariableNode("y", nil), ast.NewNumericNode(0, nil), nil),
+				nil,
+			),
+			nil,
+		)
+		for _, vars := range [][]float64{{5, 2}, {1, 2}, {3, 4}, {3, 0}} {
+			ev := newProgramEvaluator(map[string]float64{"x": vars[0], "y": vars[1]})
+			expected, err := ev.Eval(tree)
+			Expect(err).To(Succeed())
+
+			program, err := ev.Compile(tree)
+			Expect(err).To(Succeed())
+			Expect(program.Run(vars)).To(Equal(expected), "x = %v, y = %v", vars[0], vars[1])
+		}
+	})
+
+	It("Resolves variable slots case insensitively", func() {
+		program, err := newProgramEvaluator(nil).Compile(programTree())
+		Expect(err).To(Succeed())
+
+		slot, has := program.Slot("Y")
+		Expect(has).To(BeTrue())
+		Expect(slot).To(Equal(1))
+
+		slot, has = program.Slot("z")
+		Expect(has).To(BeFalse())
+		Expect(slot).To(Equal(-1))
+	})
+
+	It("Writes assignment back into variables", func() {
+		program, err := newProgramEvaluator(nil).Compile(ast.NewAssignNode(
+			ast.NewVariableNode("a", nil),
+			ast.NewBinaryNode(
+				ast.Multiplication, ast.NewVariableNode("b", nil), ast.NewFunctionNode("pi", nil, nil), nil,
+			),
+			nil,
+		))
+		Expect(err).To(Succeed())
+		Expect(program.Variables()).To(Equal([]string{"b", "a"}))
+
+		vars := []float64{2, 0}
+		res, err := program.Run(vars)
+		Expect(err).To(Succeed())
+		Expect(res).To(BeNumerically("~", 6.283185307))
+		Expect(vars[1]).To(Equal(res))
+	})
+
+	It("Simplifies the tree in optimized program", func() {
+		ev := newProgramEvaluator(nil)
+		// (2 * 3 + x * 1) - (0 ? y : 6)
+		tree := ast.NewBinaryNode(
+			ast.Substraction,
+			ast.NewBinaryNode(
+				ast.Addition,
+				ast.NewBinaryNode(ast.Multiplication, ast.NewNumericNode(2, nil), ast.NewNumericNode(3, nil), nil),
+				ast.NewBinaryNode(ast.Multiplication, ast.NewVariableNode("x", nil), ast.NewNumericNode(1, nil), nil),
+				nil,
+			),
+			ast.NewConditionalNode(
+				ast.NewNumericNode(0, nil), ast.NewVariableNode("y", nil), ast.NewNumericNode(6, nil), nil,
+			),
+			nil,
+		)
+		program, err := ev.Compile(tree)
+		Expect(err).To(Succeed())
+		optimized, err := ev.CompileOptimized(tree)
+		Expect(err).To(Succeed())
+		Expect(optimized.Variables()).To(Equal([]string{"x"}))
+
+		expected, err := program.Run([]float64{4, 7})
+		Expect(err).To(Succeed())
+		Expect(optimized.Run([]float64{4})).To(Equal(expected))
+
+		program, err = ev.Compile(programTree())
+		Expect(err).To(Succeed())
+		expected, err = program.Run([]float64{13.8, 3})
+		Expect(err).To(Succeed())
+		optimized, err = ev.CompileOptimized(programTree())
+		Expect(err).To(Succeed())
+		Expect(optimized.Run([]float64{13.8, 3})).To(Equal(expected))
+	})
+
+	DescribeTable("Optimized program gives same results as Eval",
+		func(expr string) {
+			for _, values := range []map[string]float64{
+				{"x": 2, "y": 1},
+				{"x": 0, "y": -1},
+				{"x": math.Inf(1), "y": math.NaN()},
+				{"x": math.NaN(), "y": math.Inf(-1)},
+			} {
+				expectSameAsEval(expr, values)
+			}
+		},
+		Entry("Zero times division by zero", "0 * (1 / 0)"),
+		Entry("Zero times NaN", "x * 0 + sqrt(-1) * 0"),
+		Entry("Zero times assignment", "0 * (y = 5) + y"),
+		Entry("Cancelled division", "1 / (x - 2) - 1 / (x - 2)"),
+		Entry("Condition with assignment", "((y = 5) ? x : x) + y"),
+		Entry("Sign of zero product", "-0 * (x - 3)"),
+		Entry("Cancelled variables", "x - x + y * 0"),
+		Entry("Cancelled variable holding division", "a = 1 / x; a - a"),
+	)
+
+	It("Optimized program does not cancel impure functions", func() {
+		program, err := newProgramEvaluator(nil).CompileOptimized(parseExpression("rand_f() - rand_f()"))
+		Expect(err).To(Succeed())
+		results := map[float64]bool{}
+		for i := 0; i < 10; i++ {
+			res, err := program.Run(nil)
+			Expect(err).To(Succeed())
+			results[res] = true
+		}
+		Expect(len(results)).To(BeNumerically(">", 1))
+	})
+
+	It("Optimized program keeps percent relative to the left operand", func() {
+		ev := newProgramEvaluator(map[string]float64{"x": 40})
+		tree := parseExpression("((x + 10%) - 3!%) + (x - 50%) * 2")
+		expected, err := ev.Eval(tree)
+		Expect(err).To(Succeed())
+		Expect(expected).To(BeNumerically("~", 81.36, 1e-12))
+
+		optimized, err := ev.CompileOptimized(tree)
+		Expect(err).To(Succeed())
+		Expect(optimized.Run([]float64{40})).To(BeNumerically("~", expected, 1e-12))
+	})
+
+	It("Does not allocate during run", func() {
+		program, err := newProgramEvaluator(nil).Compile(programTree())
+		Expect(err).To(Succeed())
+		vars := []float64{13.8, 3}
+
+		Expect(testing.AllocsPerRun(100, func() {
+			_, _ = program.Run(vars)
+		})).To(BeZero())
+	})
+
+	It("Allows only selected functions", func() {
+		ev := newProgramEvaluator(nil)
+		ev.SetAllowedFunctions("abs")
+		_, err := ev.Compile(parseExpression("abs(-2) + sqrt(4)"))
+		Expect(errors.Is(err, evaluator.ErrFunctionNotAllowed)).To(BeTrue())
+		Expect(err).To(MatchError("function is not allowed 'sqrt' at position 10"))
+		_, err = ev.CompileOptimized(parseExpression("2 * max(1, sqrt(4))"))
+		Expect(errors.Is(err, evaluator.ErrFunctionNotAllowed)).To(BeTrue())
+
+		program, err := ev.Compile(parseExpression("abs(-2) + 1"))
+		Expect(err).To(Succeed())
+		Expect(program.Run(nil)).To(BeEquivalentTo(3))
+	})
+
+	It("Limits number of executed steps", func() {
+		ev := newProgramEvaluator(nil)
+		ev.SetStepLimit(4)
+		// Every run starts counting again, each of 3 instructions is one step
+		program, err := ev.Compile(parseExpression("x * 2"))
+		Expect(err).To(Succeed())
+		for i := 0; i < 3; i++ {
+			Expect(program.Run([]float64{3})).To(BeEquivalentTo(6))
+		}
+
+		program, err = ev.Compile(parseExpression("x * 2 + 1"))
+		Expect(err).To(Succeed())
+		_, err = program.Run([]float64{3})
+		Expect(errors.Is(err, evaluator.ErrStepLimit)).To(BeTrue())
+		Expect(err).To(MatchError("evaluation exceeded the limit of steps at position 6"))
+	})
+
+	It("Stops user defined function over the limit of steps same as Eval", func() {
+		ev := newProgramEvaluator(map[string]float64{"x": 3})
+		_, err := ev.Eval(parseExpression("f(a) = a * a + a * a + a * a"))
+		Expect(err).To(Succeed())
+		ev.SetStepLimit(8)
+
+		_, evalErr := ev.Eval(parseExpression("f(x)"))
+		Expect(errors.Is(evalErr, evaluator.ErrStepLimit)).To(BeTrue())
+
+		program, err := ev.Compile(parseExpression("f(x)"))
+		Expect(err).To(Succeed())
+		_, err = program.Run([]float64{3})
+		Expect(errors.Is(err, evaluator.ErrStepLimit)).To(BeTrue())
+		Expect(err).To(MatchError(evalErr.Error()))
+	})
+
+	It("Checks number of variables", func() {
+		program, err := newProgramEvaluator(nil).Compile(programTree())
+		Expect(err).To(Succeed())
+		_, err = program.Run([]float64{1})
+		Expect(err).To(MatchError("program expects 2 variables, got 1"))
+	})
+
+	It("Returns function error with position", func() {
+		ev, err := evaluator.NewNumericEvaluator(nil, map[string]evaluator.FunctionHandler{
+			"f": {
+				Handler:      func(x ...float64) (float64, error) { return 0, errors.New("just some error") },
+				MinArguments: 1, MaxArguments: 1,
+			},
+		})
+		Expect(err).To(Succeed())
+		program, err := ev.Compile(ast.NewFunctionNode(
+			"f", []ast.Node{ast.NewNumericNode(1, nil)}, lexer.NewToken(lexer.Identifier, 0, "f", 4, 5),
+		))
+		Expect(err).To(Succeed())
+		_, err = program.Run(nil)
+		Expect(err).To(MatchError("just some error in function 'f' at position 4"))
+	})
+
+	It("Passes call site to context handlers", func() {
+		ev, err := evaluator.NewNumericEvaluator(nil, map[string]evaluator.FunctionHandler{
+			"f": {
+				ContextHandler: func(ctx context.Context, call evaluator.CallSite, x ...float64) (float64, error) {
+					return x[0] + float64(call.Token.StartPosition()), ctx.Err()
+				},
+				MinArguments: 1, MaxArguments: 1,
+			},
+		})
+		Expect(err).To(Succeed())
+		program, err := ev.Compile(parseExpression("1 + f(10)"))
+		Expect(err).To(Succeed())
+		Expect(program.Run(nil)).To(BeEquivalentTo(15))
+	})
+
+	It("Passes context to context handlers and stops when it is done", func() {
+		type key struct{}
+		ctx, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, 5.0))
+		ev, err := evaluator.NewNumericEvaluator(nil, map[string]evaluator.FunctionHandler{
+			"f": {
+				ContextHandler: func(ctx context.Context, call evaluator.CallSite, x ...float64) (float64, error) {
+					v, _ := ctx.Value(key{}).(float64)
+					cancel()
+					return x[0] + v, nil
+				},
+				MinArguments: 1, MaxArguments: 1,
+			},
+		})
+		Expect(err).To(Succeed())
+		program, err := ev.Compile(parseExpression("f(1)"))
+		Expect(err).To(Succeed())
+		Expect(program.RunContext(ctx, nil)).To(BeEquivalentTo(6))
+		Expect(program.Run(nil)).To(BeEquivalentTo(1))
+
+		program, err = ev.Compile(parseExpression("1 + 2"))
+		Expect(err).To(Succeed())
+		_, err = program.RunContext(ctx, nil)
+		Expect(errors.Is(err, context.Canceled)).To(BeTrue())
+		Expect(err).To(MatchError("context canceled at position 0"))
+	})
+
+	It("Returns errors of context handlers wrapped", func() {
+		errCustom := errors.New("custom error")
+		ctx, cancel := context.WithCancel(context.Background())
+		ev, err := evaluator.NewNumericEvaluator(nil, map[string]evaluator.FunctionHandler{
+			"wait": {
+				// Cancellation arrives while the handler is running
+				ContextHandler: func(ctx context.Context, call evaluator.CallSite, x ...float64) (float64, error) {
+					cancel()
+					<-ctx.Done()
+					return 0, ctx.Err()
+				},
+			},
+			"fail": {
+				ContextHandler: func(ctx context.Context, call evaluator.CallSite, x ...float64) (float64, error) {
+					return 0, errCustom
+				},
+			},
+		})
+		Expect(err).To(Succeed())
+
+		program, err := ev.Compile(parseExpression("1 + wait()"))
+		Expect(err).To(Succeed())
+		_, err = program.RunContext(ctx, nil)
+		Expect(errors.Is(err, context.Canceled)).To(BeTrue())
+		Expect(err).To(MatchError("context canceled in function 'wait' at position 4"))
+
+		program, err = ev.Compile(parseExpression("fail()"))
+		Expect(err).To(Succeed())
+		_, err = program.RunContext(context.Background(), nil)
+		Expect(errors.Is(err, errCustom)).To(BeTrue())
+		Expect(errors.Is(err, context.Canceled)).To(BeFalse())
+	})
+
+	DescribeTable("Compile errors",
+		func(rootNode ast.Node, errStr string) {
+			_, err := newProgramEvaluator(nil).Compile(rootNode)
+			Expect(err).To(MatchError(ContainSubstring(errStr)))
+		},
+		Entry("Undefined function",
+			ast.NewFunctionNode("myFunc", []ast.Node{ast.NewNumericNode(7, nil)}, nil),
+			"undefined function 'myFunc'"),
+		Entry("Wrong arguments count",
+			ast.NewFunctionNode("sin", nil, nil),
+			"function 'sin' require 1 arguments, got 0"),
+		Entry("Invalid unary operator",
+			ast.NewUnaryNode(ast.Multiplication, ast.NewNumericNode(33, nil), nil),
+			"unary node supports only Addition, Substraction and Not operator"),
+		Entry("Invalid postfix operator",
+			ast.NewPostfixNode(ast.Addition, ast.NewNumericNode(33, nil), nil),
+			"postfix node supports only Factorial and Percent operator"),
+		Entry("Invalid binary operator",
+			ast.NewBinaryNode(ast.Invalid, ast.NewNumericNode(39, nil), ast.NewNumericNode(2.5, nil), nil),
+			"unimplemented operator Invalid"),
+	)
+})
+
+func BenchmarkEval(b *testing.B) {
+	ev, _ := evaluator.NewNumericEvaluator(map[string]float64{"x": 13.8, "y": 3}, evaluator.MathFunctions(),
+		evaluator.MathFunctionsWithVarArgs())
+	tree := programTree()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = ev.Eval(tree)
+	}
+}
+
+func BenchmarkProgramRun(b *testing.B) {
+	ev, _ := evaluator.NewNumericEvaluator(nil, evaluator.MathFunctions(), evaluator.MathFunctionsWithVarArgs())
+	program, err := ev.Compile(programTree())
+	if err != nil {
+		b.Fatal(err)
+	}
+	vars := []float64{13.8, 3}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = program.Run(vars)
+	}
+}
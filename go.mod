@@ -7,7 +7,7 @@ require (
 	github.com/fatih/color v1.12.0
 	github.com/golangci/golangci-lint v1.41.1
 	github.com/m1gwings/treedrawer v0.3.3-beta
-	github.com/olekukonko/tablewriter v0.0.5 // indirect
+	github.com/olekukonko/tablewriter v0.0.5
 	github.com/onsi/ginkgo v1.16.4
 	github.com/onsi/gomega v1.13.0
 	github.com/spf13/cobra v1.1.3
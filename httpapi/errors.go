@@ -0,0 +1,90 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/arxeiss/go-expression-calculator/evaluator"
+	"github.com/arxeiss/go-expression-calculator/lexer"
+	"github.com/arxeiss/go-expression-calculator/parser"
+)
+
+var (
+	ErrTooManySessions = errors.New("too many sessions")
+	ErrUnknownSession  = errors.New("unknown session")
+
+	// limitErrors are reported with KindLimit, even if they are returned by lexer, parser or evaluator
+	limitErrors = []error{lexer.ErrTokenLimit, parser.ErrDepthLimit, parser.ErrNodeLimit, evaluator.ErrStepLimit}
+)
+
+// Kinds of errors in the response
+const (
+	KindRequest   = "request"
+	KindLimit     = "limit"
+	KindLexer     = "lexer"
+	KindParser    = "parser"
+	KindEvaluator = "evaluator"
+)
+
+// errorResponse is the body of every failed request
+type errorResponse struct {
+	Error errorBody `json:"error"`
+}
+
+// errorBody describes the error, position and location are set only when the error points into the expression
+type errorBody struct {
+	Kind     string          `json:"kind"`
+	Message  string          `json:"message"`
+	Position *int            `json:"position,omitempty"`
+	Location *lexer.Location `json:"location,omitempty"`
+}
+
+// requestError is error of the request itself, with status code to be returned
+type requestError struct {
+	status int
+	kind   string
+	err    error
+}
+
+func (e *requestError) Error() string {
+	return e.err.Error()
+}
+
+func (e *requestError) Unwrap() error {
+	return e.err
+}
+
+func newRequestError(status int, kind string, err error) *requestError {
+	return &requestError{status: status, kind: kind, err: err}
+}
+
+// describeError returns status code and body of the error, expression errors carry the location in the input
+func describeError(err error) (int, errorResponse) {
+	body := errorBody{Kind: KindRequest, Message: err.Error()}
+	status := http.StatusUnprocessableEntity
+	location := lexer.UnknownLocation()
+
+	reqErr := &requestError{}
+	lexerErr := &lexer.Error{}
+	parserErr := &parser.Error{}
+	evalErr := &evaluator.Error{}
+	switch {
+	case errors.As(err, &reqErr):
+		status, body.Kind = reqErr.status, reqErr.kind
+	case errors.As(err, &lexerErr):
+		body.Kind, location = KindLexer, lexerErr.Location()
+	case errors.As(err, &parserErr):
+		body.Kind, location = KindParser, parserErr.Location()
+	case errors.As(err, &evalErr):
+		body.Kind, location = KindEvaluator, evalErr.Location()
+	}
+	for _, limitErr := range limitErrors {
+		if errors.Is(err, limitErr) {
+			body.Kind = KindLimit
+		}
+	}
+	if location.Known() {
+		body.Position, body.Location = &location.Offset, &location
+	}
+	return status, errorResponse{Error: body}
+}
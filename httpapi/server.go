@@ -0,0 +1,330 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/arxeiss/go-expression-calculator/ast"
+	"github.com/arxeiss/go-expression-calculator/evaluator"
+	"github.com/arxeiss/go-expression-calculator/lexer"
+	"github.com/arxeiss/go-expression-calculator/parser"
+)
+
+// maxBodySize limits size of the request body regardless of the limit of the expression
+const maxBodySize = 1 << 20
+
+// Limits of requests, zero value means no limit
+type Limits struct {
+	// InputLength is maximal length of the expression in bytes
+	InputLength int
+	// Tokens is maximal number of tokens of the expression, see lexer.Limits
+	Tokens int
+	// Depth is maximal depth of AST, see parser.Limits. It is used only with parser.LimitedParser
+	Depth int
+	// Nodes is maximal number of nodes of AST, see parser.Limits. It is used only with parser.LimitedParser
+	Nodes int
+	// Steps is maximal number of nodes evaluated by one request, see NumericEvaluator.SetStepLimit
+	Steps int
+	// Sessions is maximal number of sessions kept by the server
+	Sessions int
+	// SessionIdle is time after which the session not used by any request is removed
+	SessionIdle time.Duration
+}
+
+// Server evaluates expressions over HTTP with JSON requests and responses.
+// Requests with the same session share variables and user defined functions, without session
+// every request is evaluated separately. Sessions are removed after Limits.SessionIdle or by DELETE /sessions.
+type Server struct {
+	parser    parser.Parser
+	functions []map[string]evaluator.FunctionHandler
+	limits    Limits
+	mux       *http.ServeMux
+
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+// session keeps evaluator with variables, requests of one session are evaluated one by one
+type session struct {
+	mu        sync.Mutex
+	evaluator *evaluator.NumericEvaluator
+	// lastUsed is guarded by the mutex of the server
+	lastUsed time.Time
+}
+
+type expressionRequest struct {
+	Expression string `json:"expression"`
+	Session    string `json:"session,omitempty"`
+}
+
+// evalResponse contains result or name of the function, when the last statement defines the function
+type evalResponse struct {
+	Result   *number `json:"result,omitempty"`
+	Function string  `json:"function,omitempty"`
+}
+
+type sessionResponse struct {
+	Deleted string `json:"deleted"`
+}
+
+type parseResponse struct {
+	AST json.RawMessage `json:"ast"`
+}
+
+type functionResponse struct {
+	Name         string   `json:"name"`
+	Description  string   `json:"description"`
+	MinArguments int      `json:"minArguments"`
+	MaxArguments int      `json:"maxArguments"`
+	ArgsNames    []string `json:"argsNames"`
+}
+
+// number is written as JSON number, infinity and NaN are written as strings "+Inf", "-Inf" and "NaN"
+type number float64
+
+func (n number) MarshalJSON() ([]byte, error) {
+	f := float64(n)
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return json.Marshal(strconv.FormatFloat(f, 'g', -1, 64))
+	}
+	return json.Marshal(f)
+}
+
+// NewServer creates the server, functions are available in all sessions.
+// Limits of depth and nodes are set to the parser, when it implements parser.LimitedParser
+func NewServer(p parser.Parser, limits Limits, functions ...map[string]evaluator.FunctionHandler) (*Server, error) {
+	if lp, ok := p.(parser.LimitedParser); ok {
+		lp.SetLimits(parser.Limits{Depth: limits.Depth, Nodes: limits.Nodes})
+	}
+	s := &Server{
+		parser:    p,
+		functions: functions,
+		limits:    limits,
+		mux:       http.NewServeMux(),
+		sessions:  make(map[string]*session),
+	}
+	// Check functions can be merged, so requests do not fail on it later
+	if _, err := s.newSession(); err != nil {
+		return nil, err
+	}
+	s.mux.HandleFunc("/eval", s.handle(http.MethodPost, s.eval))
+	s.mux.HandleFunc("/parse", s.handle(http.MethodPost, s.parse))
+	s.mux.HandleFunc("/functions", s.handle(http.MethodGet, s.listFunctions))
+	s.mux.HandleFunc("/sessions", s.handle(http.MethodDelete, s.deleteSession))
+	return s, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// handle checks the method of the request and writes the response or the error as JSON
+func (s *Server) handle(method string, handler func(r *http.Request) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			writeError(w, newRequestError(
+				http.StatusMethodNotAllowed, KindRequest, fmt.Errorf("method %s is not allowed", r.Method),
+			))
+			return
+		}
+		response, err := handler(r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(response)
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status, body := describeError(err)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func (s *Server) eval(r *http.Request) (interface{}, error) {
+	req, err := s.decodeRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	rootNode, err := s.parseExpression(req.Expression)
+	if err != nil {
+		return nil, err
+	}
+	sess, err := s.session(req.Session)
+	if err != nil {
+		return nil, err
+	}
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	// Evaluation is stopped when the client closes the connection
+	val, err := sess.evaluator.EvalContext(r.Context(), rootNode)
+	if err != nil {
+		return nil, err
+	}
+	if def, ok := ast.LastStatement(rootNode).(*ast.FunctionDefNode); ok {
+		return &evalResponse{Function: def.Name()}, nil
+	}
+	result := number(val)
+	return &evalResponse{Result: &result}, nil
+}
+
+func (s *Server) parse(r *http.Request) (interface{}, error) {
+	req, err := s.decodeRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	rootNode, err := s.parseExpression(req.Expression)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := ast.Encode(rootNode)
+	if err != nil {
+		return nil, err
+	}
+	return &parseResponse{AST: encoded}, nil
+}
+
+// listFunctions returns built-in functions, user defined functions are included when the session is given.
+// Only existing session can be listed, so listing never creates new session
+func (s *Server) listFunctions(r *http.Request) (interface{}, error) {
+	var functions []evaluator.FunctionTuple
+	if name := r.URL.Query().Get("session"); name != "" {
+		sess, err := s.existingSession(name)
+		if err != nil {
+			return nil, err
+		}
+		sess.mu.Lock()
+		functions = sess.evaluator.FunctionList()
+		sess.mu.Unlock()
+	} else {
+		sess, err := s.newSession()
+		if err != nil {
+			return nil, err
+		}
+		functions = sess.evaluator.FunctionList()
+	}
+	response := make([]functionResponse, 0, len(functions))
+	for _, f := range functions {
+		argsNames := f.Function.ArgsNames
+		if argsNames == nil {
+			argsNames = []string{}
+		}
+		response = append(response, functionResponse{
+			Name:         f.Name,
+			Description:  f.Function.Description,
+			MinArguments: f.Function.MinArguments,
+			MaxArguments: f.Function.MaxArguments,
+			ArgsNames:    argsNames,
+		})
+	}
+	return response, nil
+}
+
+// deleteSession removes the session given in the query, so its variables and functions are forgotten
+func (s *Server) deleteSession(r *http.Request) (interface{}, error) {
+	name := r.URL.Query().Get("session")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.sessions[name]; !ok {
+		return nil, unknownSession(name)
+	}
+	delete(s.sessions, name)
+	return &sessionResponse{Deleted: name}, nil
+}
+
+func (s *Server) decodeRequest(r *http.Request) (*expressionRequest, error) {
+	req := &expressionRequest{}
+	if err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxBodySize)).Decode(req); err != nil {
+		return nil, newRequestError(http.StatusBadRequest, KindRequest, fmt.Errorf("invalid request body: %w", err))
+	}
+	if s.limits.InputLength > 0 && len(req.Expression) > s.limits.InputLength {
+		return nil, newRequestError(http.StatusRequestEntityTooLarge, KindLimit, fmt.Errorf(
+			"%w, it has %d bytes and the limit is %d", lexer.ErrInputTooLong, len(req.Expression), s.limits.InputLength,
+		))
+	}
+	return req, nil
+}
+
+func (s *Server) parseExpression(expression string) (ast.Node, error) {
+	l := lexer.NewLexer(expression)
+	l.SetLimits(lexer.Limits{Tokens: s.limits.Tokens})
+	tokens, err := l.Tokenize()
+	if err != nil {
+		return nil, err
+	}
+	return s.parser.Parse(tokens)
+}
+
+// session returns existing session or creates new one, empty name means new session just for one request
+func (s *Server) session(name string) (*session, error) {
+	if name == "" {
+		return s.newSession()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.removeIdleSessions()
+	if sess, ok := s.sessions[name]; ok {
+		sess.lastUsed = now
+		return sess, nil
+	}
+	if s.limits.Sessions > 0 && len(s.sessions) >= s.limits.Sessions {
+		return nil, newRequestError(http.StatusTooManyRequests, KindLimit, ErrTooManySessions)
+	}
+	sess, err := s.newSession()
+	if err != nil {
+		return nil, err
+	}
+	sess.lastUsed = now
+	s.sessions[name] = sess
+	return sess, nil
+}
+
+// existingSession returns the session with given name, it never creates new one
+func (s *Server) existingSession(name string) (*session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.removeIdleSessions()
+	sess, ok := s.sessions[name]
+	if !ok {
+		return nil, unknownSession(name)
+	}
+	sess.lastUsed = now
+	return sess, nil
+}
+
+func unknownSession(name string) error {
+	return newRequestError(http.StatusNotFound, KindRequest, fmt.Errorf("%w '%s'", ErrUnknownSession, name))
+}
+
+// removeIdleSessions removes sessions not used for Limits.SessionIdle and returns current time.
+// It must be called with locked mutex of the server
+func (s *Server) removeIdleSessions() time.Time {
+	now := time.Now()
+	if s.limits.SessionIdle <= 0 {
+		return now
+	}
+	for name, sess := range s.sessions {
+		if now.Sub(sess.lastUsed) > s.limits.SessionIdle {
+			delete(s.sessions, name)
+		}
+	}
+	return now
+}
+
+func (s *Server) newSession() (*session, error) {
+	ev, err := evaluator.NewNumericEvaluator(nil, s.functions...)
+	if err != nil {
+		return nil, err
+	}
+	ev.SetStepLimit(s.limits.Steps)
+	return &session{evaluator: ev}, nil
+}
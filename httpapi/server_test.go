@@ -0,0 +1,245 @@
+package httpapi_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/arxeiss/go-expression-calculator/evaluator"
+	"github.com/arxeiss/go-expression-calculator/httpapi"
+	"github.com/arxeiss/go-expression-calculator/parser"
+	"github.com/arxeiss/go-expression-calculator/parser/recursivedescent"
+)
+
+func request(server http.Handler, method, path, body string) (int, map[string]interface{}) {
+	response := map[string]interface{}{}
+	return requestInto(server, method, path, body, &response), response
+}
+
+func requestInto(server http.Handler, method, path, body string, response interface{}) int {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, r)
+	Expect(w.Header().Get("Content-Type")).To(Equal("application/json"))
+	Expect(json.Unmarshal(w.Body.Bytes(), response)).To(Succeed())
+	return w.Code
+}
+
+func expression(expr, session string) string {
+	encoded, err := json.Marshal(map[string]string{"expression": expr, "session": session})
+	Expect(err).ToNot(HaveOccurred())
+	return string(encoded)
+}
+
+var _ = Describe("HTTP API", func() {
+	var server *httpapi.Server
+
+	BeforeEach(func() {
+		p, err := recursivedescent.NewParser(parser.DefaultTokenPriorities())
+		Expect(err).ToNot(HaveOccurred())
+		limits := httpapi.Limits{InputLength: 50, Tokens: 40, Depth: 10, Steps: 20, Sessions: 2}
+		server, err = httpapi.NewServer(p, limits, evaluator.MathFunctions(), evaluator.MathFunctionsWithVarArgs())
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	DescribeTable("Evaluate expressions",
+		func(expr string, expected map[string]interface{}) {
+			status, response := request(server, http.MethodPost, "/eval", expression(expr, ""))
+			Expect(status).To(Equal(http.StatusOK))
+			Expect(response).To(Equal(expected))
+		},
+		Entry("Number", "5", map[string]interface{}{"result": 5.0}),
+		Entry("Functions", "max(2, 3) * sqrt(16)", map[string]interface{}{"result": 12.0}),
+		Entry("Statements", "a = 3; b = a * 2; a + b", map[string]interface{}{"result": 9.0}),
+		Entry("Function definition", "f(x) = x ^ 2", map[string]interface{}{"function": "f"}),
+		Entry("Infinity", "1 / 0", map[string]interface{}{"result": "+Inf"}),
+	)
+
+	It("Returns AST of the expression", func() {
+		status, response := request(server, http.MethodPost, "/parse", expression("1 + x", ""))
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(response["ast"]).To(And(
+			HaveKeyWithValue("kind", "binary"),
+			HaveKeyWithValue("operator", "+"),
+			HaveKeyWithValue("right", HaveKeyWithValue("name", "x")),
+		))
+	})
+
+	It("Lists functions", func() {
+		functions := []map[string]interface{}{}
+		Expect(requestInto(server, http.MethodGet, "/functions", "", &functions)).To(Equal(http.StatusOK))
+		Expect(functions).To(ContainElement(map[string]interface{}{
+			"name":         "max",
+			"description":  "Returns maximum of provided numbers.",
+			"minArguments": 1.0,
+			"maxArguments": 0.0,
+			"argsNames":    []interface{}{"a", "b"},
+		}))
+	})
+
+	It("Keeps variables and functions in the session", func() {
+		status, response := request(server, http.MethodPost, "/eval", expression("a = 3; f(x) = x * a", "s1"))
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(response).To(Equal(map[string]interface{}{"function": "f"}))
+
+		status, response = request(server, http.MethodPost, "/eval", expression("f(4) + a", "s1"))
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(response).To(Equal(map[string]interface{}{"result": 15.0}))
+
+		status, response = request(server, http.MethodPost, "/eval", expression("a", "s2"))
+		Expect(status).To(Equal(http.StatusUnprocessableEntity))
+		Expect(response["error"]).To(HaveKeyWithValue("kind", "evaluator"))
+
+		status, response = request(server, http.MethodPost, "/eval", expression("a", ""))
+		Expect(status).To(Equal(http.StatusUnprocessableEntity))
+		Expect(response["error"]).To(HaveKeyWithValue("kind", "evaluator"))
+
+		status, response = request(server, http.MethodPost, "/eval", expression("1", "s3"))
+		Expect(status).To(Equal(http.StatusTooManyRequests))
+		Expect(response).To(Equal(map[string]interface{}{
+			"error": map[string]interface{}{"kind": "limit", "message": "too many sessions"},
+		}))
+
+		functions := []map[string]interface{}{}
+		Expect(requestInto(server, http.MethodGet, "/functions?session=s1", "", &functions)).To(Equal(http.StatusOK))
+		Expect(functions).To(ContainElement(map[string]interface{}{
+			"name":         "f",
+			"description":  "User defined function.",
+			"minArguments": 1.0,
+			"maxArguments": 1.0,
+			"argsNames":    []interface{}{"x"},
+		}))
+	})
+
+	It("Does not create sessions by listing functions", func() {
+		status, response := request(server, http.MethodGet, "/functions?session=s1", "")
+		Expect(status).To(Equal(http.StatusNotFound))
+		Expect(response).To(Equal(map[string]interface{}{
+			"error": map[string]interface{}{"kind": "request", "message": "unknown session 's1'"},
+		}))
+
+		status, _ = request(server, http.MethodPost, "/eval", expression("1", "s2"))
+		Expect(status).To(Equal(http.StatusOK))
+		status, _ = request(server, http.MethodPost, "/eval", expression("1", "s3"))
+		Expect(status).To(Equal(http.StatusOK))
+	})
+
+	It("Deletes sessions", func() {
+		status, _ := request(server, http.MethodPost, "/eval", expression("a = 1", "s1"))
+		Expect(status).To(Equal(http.StatusOK))
+		status, _ = request(server, http.MethodPost, "/eval", expression("a = 2", "s2"))
+		Expect(status).To(Equal(http.StatusOK))
+
+		status, response := request(server, http.MethodDelete, "/sessions?session=s1", "")
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(response).To(Equal(map[string]interface{}{"deleted": "s1"}))
+
+		status, response = request(server, http.MethodPost, "/eval", expression("a", "s1"))
+		Expect(status).To(Equal(http.StatusUnprocessableEntity))
+		Expect(response["error"]).To(HaveKeyWithValue("message", "undefined variable 'a' at position 0"))
+		status, response = request(server, http.MethodPost, "/eval", expression("a", "s2"))
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(response).To(Equal(map[string]interface{}{"result": 2.0}))
+
+		status, response = request(server, http.MethodDelete, "/sessions?session=s3", "")
+		Expect(status).To(Equal(http.StatusNotFound))
+		Expect(response).To(Equal(map[string]interface{}{
+			"error": map[string]interface{}{"kind": "request", "message": "unknown session 's3'"},
+		}))
+	})
+
+	It("Removes idle sessions", func() {
+		p, err := recursivedescent.NewParser(parser.DefaultTokenPriorities())
+		Expect(err).ToNot(HaveOccurred())
+		server, err = httpapi.NewServer(p, httpapi.Limits{Sessions: 1, SessionIdle: 20 * time.Millisecond})
+		Expect(err).ToNot(HaveOccurred())
+
+		status, _ := request(server, http.MethodPost, "/eval", expression("a = 1", "s1"))
+		Expect(status).To(Equal(http.StatusOK))
+		status, _ = request(server, http.MethodPost, "/eval", expression("1", "s2"))
+		Expect(status).To(Equal(http.StatusTooManyRequests))
+
+		time.Sleep(40 * time.Millisecond)
+		status, _ = request(server, http.MethodPost, "/eval", expression("1", "s2"))
+		Expect(status).To(Equal(http.StatusOK))
+		status, _ = request(server, http.MethodGet, "/functions?session=s1", "")
+		Expect(status).To(Equal(http.StatusNotFound))
+	})
+
+	DescribeTable("Report errors",
+		func(method, path, body string, expectedStatus int, expected map[string]interface{}) {
+			status, response := request(server, method, path, body)
+			Expect(status).To(Equal(expectedStatus))
+			Expect(response).To(Equal(map[string]interface{}{"error": expected}))
+		},
+		Entry("Lexer error", http.MethodPost, "/eval", expression("2 + $", ""), http.StatusUnprocessableEntity,
+			map[string]interface{}{
+				"kind":     "lexer",
+				"message":  "unexpected character at position 4",
+				"position": 4.0,
+				"location": map[string]interface{}{"offset": 4.0, "length": 1.0, "line": 1.0, "column": 5.0},
+			}),
+		Entry("Parser error", http.MethodPost, "/parse", expression("2 + * 3", ""), http.StatusUnprocessableEntity,
+			map[string]interface{}{
+				"kind":     "parser",
+				"message":  "expected number, identifier or left parenthesis; found Multiplication token at position 4",
+				"position": 4.0,
+				"location": map[string]interface{}{"offset": 4.0, "length": 1.0, "line": 1.0, "column": 5.0},
+			}),
+		Entry("Evaluator error", http.MethodPost, "/eval", expression("1 + foo", ""),
+			http.StatusUnprocessableEntity,
+			map[string]interface{}{
+				"kind":     "evaluator",
+				"message":  "undefined variable 'foo' at position 4",
+				"position": 4.0,
+				"location": map[string]interface{}{"offset": 4.0, "length": 3.0, "line": 1.0, "column": 5.0},
+			}),
+		Entry("Limit of steps", http.MethodPost, "/eval", expression("f(x) = x + 1; f(f(f(f(f(f(f(f(1))))))))", ""),
+			http.StatusUnprocessableEntity,
+			map[string]interface{}{
+				"kind":     "limit",
+				"message":  "evaluation exceeded the limit of steps at position 9",
+				"position": 9.0,
+				"location": map[string]interface{}{"offset": 9.0, "length": 1.0, "line": 1.0, "column": 10.0},
+			}),
+		Entry("Too many tokens", http.MethodPost, "/eval", expression(strings.Repeat("1+", 20)+"1", ""),
+			http.StatusUnprocessableEntity,
+			map[string]interface{}{
+				"kind":     "limit",
+				"message":  "expression exceeded the limit of tokens; found Number token at position 40",
+				"position": 40.0,
+				"location": map[string]interface{}{"offset": 40.0, "length": 1.0, "line": 1.0, "column": 41.0},
+			}),
+		Entry("Too deep expression", http.MethodPost, "/parse",
+			expression(strings.Repeat("(", 11)+"1"+strings.Repeat(")", 11), ""),
+			http.StatusUnprocessableEntity,
+			map[string]interface{}{
+				"kind":     "limit",
+				"message":  "expression exceeded the limit of depth; found LPar token at position 10",
+				"position": 10.0,
+				"location": map[string]interface{}{"offset": 10.0, "length": 1.0, "line": 1.0, "column": 11.0},
+			}),
+		Entry("Too long input", http.MethodPost, "/eval", expression(strings.Repeat("1+", 30)+"1", ""),
+			http.StatusRequestEntityTooLarge,
+			map[string]interface{}{
+				"kind":    "limit",
+				"message": "expression exceeded the limit of length, it has 61 bytes and the limit is 50",
+			}),
+		Entry("Invalid body", http.MethodPost, "/eval", "{", http.StatusBadRequest,
+			map[string]interface{}{
+				"kind":    "request",
+				"message": "invalid request body: unexpected EOF",
+			}),
+		Entry("Wrong method", http.MethodGet, "/eval", "", http.StatusMethodNotAllowed,
+			map[string]interface{}{
+				"kind":    "request",
+				"message": "method GET is not allowed",
+			}),
+	)
+})
@@ -2,7 +2,6 @@ package lexer
 
 import (
 	"errors"
-	"strconv"
 	"strings"
 )
 
@@ -11,24 +10,32 @@ var (
 	ErrNumberOutOfRange = errors.New("number is out of range")
 	ErrInvalidNumber    = errors.New("cannot parse number")
 	ErrInvalidUnary     = errors.New("only addition and substraction can be changed to unary")
+	ErrInvalidPostfix   = errors.New("only logical negation and modulus can be changed to postfix")
+	ErrInputTooLong     = errors.New("expression exceeded the limit of length")
+	ErrTokenLimit       = errors.New("expression exceeded the limit of tokens")
 )
 
 type Error struct {
 	token    *Token
-	position *int
+	location *Location
 	err      error
 }
 
 // Position returns 0 based index of error in original input expression
 // If position is not set, -1 is returned
 func (e *Error) Position() int {
+	return e.Location().Offset
+}
+
+// Location returns span of the error in original input expression
+func (e *Error) Location() Location {
 	if e.token != nil {
-		return e.token.startPos
+		return e.token.Location()
 	}
-	if e.position != nil {
-		return *e.position
+	if e.location != nil {
+		return *e.location
 	}
-	return -1
+	return UnknownLocation()
 }
 
 func (e *Error) Unwrap() error {
@@ -36,38 +43,47 @@ func (e *Error) Unwrap() error {
 }
 
 func (e *Error) Error() string {
+	return FormatError(e.err, e.token, e.Location())
+}
+
+// FormatError creates message shared by errors of all packages, like "<err>; found <type> token at <location>".
+// Type of the found token is written only when the token is passed.
+func FormatError(err error, found *Token, location Location) string {
 	b := strings.Builder{}
-	pos := e.Position()
 
-	if pos < 0 {
+	if !location.Known() {
 		b.WriteString("unexpected error")
-		if e.err != nil {
+		if err != nil {
 			b.WriteByte(' ')
-			b.WriteString(e.err.Error())
+			b.WriteString(err.Error())
 		}
 		return b.String()
 	}
 
-	if e.err != nil {
-		b.WriteString(e.err.Error())
+	if err != nil {
+		b.WriteString(err.Error())
 	} else {
 		b.WriteString("error")
 	}
-	if e.token != nil {
+	if found != nil {
 		b.WriteString("; found ")
-		b.WriteString(e.token.tType.String())
+		b.WriteString(found.tType.String())
 		b.WriteString(" token")
 	}
 
-	b.WriteString(" at position ")
-	b.WriteString(strconv.Itoa(pos))
+	b.WriteString(" at ")
+	b.WriteString(location.String())
 
 	return b.String()
 }
 
 func PositionError(pos int, err error) *Error {
+	return LocationError(Location{Offset: pos}, err)
+}
+
+func LocationError(location Location, err error) *Error {
 	return &Error{
-		position: &pos,
+		location: &location,
 		err:      err,
 	}
 }
@@ -0,0 +1,75 @@
+package lexer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var ErrUnknownTokenType = errors.New("unknown token type")
+
+// jsonToken is serialized form of the token, type is stored by name so the schema does not depend on constants order
+type jsonToken struct {
+	Type       string  `json:"type"`
+	Literal    string  `json:"literal,omitempty"`
+	Start      int     `json:"start"`
+	End        int     `json:"end"`
+	Line       int     `json:"line,omitempty"`
+	Column     int     `json:"column,omitempty"`
+	Value      float64 `json:"value,omitempty"`
+	Identifier string  `json:"identifier,omitempty"`
+	Imaginary  bool    `json:"imaginary,omitempty"`
+	Base       int     `json:"base,omitempty"`
+}
+
+// MarshalJSON encodes token as {"type": "Number", "literal": "2.5", "start": 0, "end": 3, "line": 1, "column": 1,
+// "value": 2.5}.
+// Value, identifier, imaginary flag, base of non-decimal numbers, line and column are present only when they are set.
+func (t *Token) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonToken{
+		Type:       t.tType.String(),
+		Literal:    t.literal,
+		Start:      t.startPos,
+		End:        t.endPos,
+		Line:       t.line,
+		Column:     t.column,
+		Value:      t.value,
+		Identifier: t.idName,
+		Imaginary:  t.imaginary,
+		Base:       t.base,
+	})
+}
+
+// UnmarshalJSON decodes token encoded by MarshalJSON
+func (t *Token) UnmarshalJSON(data []byte) error {
+	var decoded jsonToken
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	tType, err := parseTokenType(decoded.Type)
+	if err != nil {
+		return err
+	}
+	*t = Token{
+		tType:     tType,
+		value:     decoded.Value,
+		idName:    decoded.Identifier,
+		literal:   decoded.Literal,
+		imaginary: decoded.Imaginary,
+		base:      decoded.Base,
+		startPos:  decoded.Start,
+		endPos:    decoded.End,
+		line:      decoded.Line,
+		column:    decoded.Column,
+	}
+	return nil
+}
+
+func parseTokenType(name string) (TokenType, error) {
+	for i, s := range tokenTypeStr {
+		if s == name {
+			return TokenType(i), nil
+		}
+	}
+	return EOL, fmt.Errorf("%w '%s'", ErrUnknownTokenType, name)
+}
@@ -2,19 +2,32 @@ package lexer
 
 import (
 	"errors"
+	"math/big"
 	"regexp"
 	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 var (
 	//nolint:lll
 	tokenRegexp = regexp.MustCompile(
-		`\(|\)|\*\*|\^|//|%|\+|\-|\*|/|=|,|(?P<num>(?:[0-9]+(?:\.[0-9]+)?|\.[0-9]+)(?:e[+-]?[0-9]+)?)|(?P<id>(?i)[a-z_][a-z0-9_]*)|(?P<ws>\s+)`,
+		`\(|\)|\*\*|\^|//|%|\+|\-|\*|/|<=|>=|==|!=|&&|\|\||<|>|!|=|,|\?|:|;|(?P<num>0[xXbBoO][0-9a-zA-Z_]*|(?:[0-9][0-9_]*(?:\.[0-9_]+)?|\.[0-9][0-9_]*)(?:e[+-]?[0-9_]+)?(?:[ij]\b)?)|(?P<id>(?i)[a-z_][a-z0-9_]*)|(?P<nl>\n)|(?P<ws>[^\S\n]+)`,
 	)
 )
 
 type Lexer struct {
-	expr string
+	expr   string
+	limits Limits
+}
+
+// Limits restricts size of the input, zero value of each field means no limit
+type Limits struct {
+	// InputLength is maximal length of the expression in bytes
+	InputLength int
+	// Tokens is maximal number of tokens, whitespace and the end of input are not counted
+	Tokens int
 }
 
 func NewLexer(expression string) *Lexer {
@@ -25,17 +38,38 @@ func (l *Lexer) Expression() string {
 	return l.expr
 }
 
-// Tokenize converts input expresion into the list of tokens
+// SetLimits restricts size of the input, see Limits
+func (l *Lexer) SetLimits(limits Limits) {
+	l.limits = limits
+}
+
+// Tokenize converts input expresion into the list of tokens.
+// Statements are separated by semicolon or new line, new line inside of parentheses is just whitespace
 func (l *Lexer) Tokenize() ([]*Token, error) {
+	if l.limits.InputLength > 0 && len(l.expr) > l.limits.InputLength {
+		// Line and column are not counted, the input is not processed at all
+		return nil, LocationError(
+			Location{Offset: l.limits.InputLength, Length: len(l.expr) - l.limits.InputLength},
+			ErrInputTooLong,
+		)
+	}
 	expr := make([]*Token, 0)
+	tokens := 0
 	subMatchNames := tokenRegexp.SubexpNames()
+	c := &cursor{line: 1, column: 1}
 
 	lastIndex := 0
 	for _, indexes := range tokenRegexp.FindAllStringSubmatchIndex(l.expr, -1) {
-		t := &Token{startPos: indexes[0], endPos: indexes[1]}
+		t := &Token{
+			startPos: indexes[0],
+			endPos:   indexes[1],
+			literal:  l.expr[indexes[0]:indexes[1]],
+			line:     c.line,
+			column:   c.column,
+		}
 		// If current token does not start where previous ended, there is something unexpected
 		if t.startPos != lastIndex {
-			return nil, PositionError(lastIndex, ErrUnexpectedChar)
+			return nil, c.unexpectedChar(lastIndex, t.startPos)
 		}
 		lastIndex = t.endPos
 
@@ -48,20 +82,62 @@ func (l *Lexer) Tokenize() ([]*Token, error) {
 		}
 		// Returned EOL means some internal error, ie unhandled characters
 		if t.tType == EOL {
-			return nil, PositionError(lastIndex, ErrUnexpectedChar)
+			return nil, c.unexpectedChar(t.startPos, t.endPos)
 		}
 
+		c.advance(t)
+		if t.tType != Whitespace {
+			if tokens++; l.limits.Tokens > 0 && tokens > l.limits.Tokens {
+				return nil, TokenError(t, ErrTokenLimit)
+			}
+		}
 		expr = append(expr, t)
 	}
 	// If all regex matches are processed, but there is still some text
 	if lastIndex != len(l.expr) {
-		return nil, PositionError(lastIndex, ErrUnexpectedChar)
+		return nil, c.unexpectedChar(lastIndex, len(l.expr))
 	}
 	// Always add EOL for easier handling in parsers
-	expr = append(expr, &Token{tType: EOL, startPos: lastIndex, endPos: lastIndex})
+	expr = append(expr, &Token{tType: EOL, startPos: lastIndex, endPos: lastIndex, line: c.line, column: c.column})
 	return expr, nil
 }
 
+// cursor tracks line, column and depth of parentheses at the end of the last token
+type cursor struct {
+	line, column, depth int
+}
+
+// unexpectedChar returns error spanning over the text between start and end, which starts at the cursor
+func (c *cursor) unexpectedChar(start, end int) *Error {
+	return LocationError(
+		Location{Offset: start, Length: end - start, Line: c.line, Column: c.column},
+		ErrUnexpectedChar,
+	)
+}
+
+// advance moves the cursor after the token, new line inside of parentheses is changed to whitespace.
+// Columns are counted in runes, so non-ASCII characters take one column
+func (c *cursor) advance(t *Token) {
+	switch t.tType {
+	case LPar:
+		c.depth++
+	case RPar:
+		if c.depth > 0 {
+			c.depth--
+		}
+	case Separator:
+		if c.depth > 0 && t.literal == "\n" {
+			t.tType = Whitespace
+		}
+	}
+	if i := strings.LastIndexByte(t.literal, '\n'); i >= 0 {
+		c.line += strings.Count(t.literal, "\n")
+		c.column = utf8.RuneCountInString(t.literal[i+1:]) + 1
+		return
+	}
+	c.column += utf8.RuneCountInString(t.literal)
+}
+
 func (l *Lexer) handleSubMatches(t *Token, indexes []int, subMatchNames []string) (bool, error) {
 	for i := 1; i < len(subMatchNames); i++ {
 		// There are always begin and end index for each submatch
@@ -73,18 +149,17 @@ func (l *Lexer) handleSubMatches(t *Token, indexes []int, subMatchNames []string
 		switch subMatchNames[i] {
 		case "num":
 			t.tType = Number
-			var err error
-			if t.value, err = strconv.ParseFloat(l.expr[t.startPos:t.endPos], 64); err != nil {
-				if errors.Is(err, strconv.ErrRange) {
-					return false, TokenError(t, ErrNumberOutOfRange)
-				}
-				return false, TokenError(t, ErrInvalidNumber)
+			if err := parseNumber(t, l.expr[t.startPos:t.endPos]); err != nil {
+				return false, TokenError(t, err)
 			}
 			return true, nil
 		case "id":
 			t.tType = Identifier
 			t.idName = l.expr[t.startPos:t.endPos]
 			return true, nil
+		case "nl":
+			t.tType = Separator
+			return true, nil
 		case "ws":
 			t.tType = Whitespace
 			return true, nil
@@ -93,6 +168,66 @@ func (l *Lexer) handleSubMatches(t *Token, indexes []int, subMatchNames []string
 	return false, nil
 }
 
+// parseNumber sets value, base and imaginary flag of the number token.
+// Integers can be written with base prefix 0x, 0b or 0o, digits of any number can be separated by single underscore
+func parseNumber(t *Token, numStr string) error {
+	// Imaginary suffix is allowed only at the end of the number, like 3i or 2.5j
+	if last := numStr[len(numStr)-1]; last == 'i' || last == 'j' {
+		t.imaginary = true
+		numStr = numStr[:len(numStr)-1]
+	}
+	base := 10
+	if len(numStr) > 1 && numStr[0] == '0' {
+		switch numStr[1] {
+		case 'x', 'X':
+			base = 16
+		case 'b', 'B':
+			base = 2
+		case 'o', 'O':
+			base = 8
+		}
+	}
+	if base != 10 {
+		// Decimal numbers keep zero base, see Token.Base
+		t.base = base
+		numStr = numStr[2:]
+	}
+	if !validSeparators(numStr, base) {
+		return ErrInvalidNumber
+	}
+	numStr = strings.ReplaceAll(numStr, "_", "")
+
+	// Numbers out of range of float64 are kept as infinity, the literal still holds the exact value.
+	// So arbitrary precision evaluators can use them and float64 evaluators report ErrNumberOutOfRange
+	if base != 10 {
+		value, ok := new(big.Int).SetString(numStr, base)
+		if !ok {
+			return ErrInvalidNumber
+		}
+		t.value, _ = new(big.Float).SetInt(value).Float64()
+		return nil
+	}
+	var err error
+	t.value, err = strconv.ParseFloat(numStr, 64)
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
+		return ErrInvalidNumber
+	}
+	return nil
+}
+
+// validSeparators checks every underscore is placed between two digits of the base
+func validSeparators(digits string, base int) bool {
+	isDigit := func(c byte) bool {
+		return strings.ContainsRune("0123456789abcdef"[:base], unicode.ToLower(rune(c)))
+	}
+	for i := 0; i < len(digits); i++ {
+		if digits[i] == '_' && (i == 0 || i == len(digits)-1 || !isDigit(digits[i-1]) || !isDigit(digits[i+1])) {
+			return false
+		}
+	}
+	return true
+}
+
 func operatorTokenType(operator string) TokenType {
 	switch operator {
 	case "(":
@@ -117,6 +252,67 @@ func operatorTokenType(operator string) TokenType {
 		return Equal
 	case ",":
 		return Comma
+	case ";":
+		return Separator
+	}
+	return comparisonTokenType(operator)
+}
+
+// comparisonTokenType recognizes comparison, logical and conditional operators
+func comparisonTokenType(operator string) TokenType {
+	switch operator {
+	case "<":
+		return Less
+	case "<=":
+		return LessOrEqual
+	case ">":
+		return Greater
+	case ">=":
+		return GreaterOrEqual
+	case "==":
+		return IsEqual
+	case "!=":
+		return NotEqual
+	case "&&":
+		return And
+	case "||":
+		return Or
+	case "!":
+		return Not
+	case "?":
+		return Question
+	case ":":
+		return Colon
 	}
 	return EOL
 }
+
+// SplitStatements splits tokens by separators, every statement ends with own EOL token placed at the separator.
+// Statements with whitespaces only are skipped, but at least one statement is always returned.
+func SplitStatements(tokenList []*Token) [][]*Token {
+	statements := make([][]*Token, 0, 1)
+	statement := make([]*Token, 0, len(tokenList))
+	empty := true
+	for _, t := range tokenList {
+		switch t.tType {
+		case Separator, EOL:
+			eol := t
+			if t.tType == Separator {
+				eol = &Token{tType: EOL, startPos: t.startPos, endPos: t.startPos, line: t.line, column: t.column}
+			}
+			if !empty || (t.tType == EOL && len(statements) == 0) {
+				statements = append(statements, append(statement, eol))
+			}
+			statement, empty = make([]*Token, 0, len(tokenList)), true
+		case Whitespace:
+			statement = append(statement, t)
+		default:
+			statement, empty = append(statement, t), false
+		}
+	}
+	// Token list without EOL, the last statement is not closed
+	if !empty || len(statements) == 0 {
+		statements = append(statements, statement)
+	}
+	return statements
+}
@@ -1,6 +1,9 @@
 package lexer_test
 
 import (
+	"errors"
+	"math"
+
 	"github.com/onsi/gomega/types"
 
 	"github.com/arxeiss/go-expression-calculator/lexer"
@@ -38,6 +41,91 @@ var _ = Describe("Tokenize", func() {
 		}))
 	})
 
+	It("Handle comparison and logical operators", func() {
+		tokens, err := lexer.NewLexer("<<=>>===!=&&||!=!").Tokenize()
+		Expect(err).To(Succeed())
+		Expect(tokens).To(MatchAllElementsWithIndex(IndexIdentity, Elements{
+			"0":  PointTo(MatchToken(lexer.Less, 0, "", 0, 1)),
+			"1":  PointTo(MatchToken(lexer.LessOrEqual, 0, "", 1, 3)),
+			"2":  PointTo(MatchToken(lexer.Greater, 0, "", 3, 4)),
+			"3":  PointTo(MatchToken(lexer.GreaterOrEqual, 0, "", 4, 6)),
+			"4":  PointTo(MatchToken(lexer.IsEqual, 0, "", 6, 8)),
+			"5":  PointTo(MatchToken(lexer.NotEqual, 0, "", 8, 10)),
+			"6":  PointTo(MatchToken(lexer.And, 0, "", 10, 12)),
+			"7":  PointTo(MatchToken(lexer.Or, 0, "", 12, 14)),
+			"8":  PointTo(MatchToken(lexer.NotEqual, 0, "", 14, 16)),
+			"9":  PointTo(MatchToken(lexer.Not, 0, "", 16, 17)),
+			"10": PointTo(MatchToken(lexer.EOL, 0, "", 17, 17)),
+		}))
+	})
+
+	It("Handle conditional operator", func() {
+		tokens, err := lexer.NewLexer("a?b:c").Tokenize()
+		Expect(err).To(Succeed())
+		Expect(tokens).To(MatchAllElementsWithIndex(IndexIdentity, Elements{
+			"0": PointTo(MatchToken(lexer.Identifier, 0, "a", 0, 1)),
+			"1": PointTo(MatchToken(lexer.Question, 0, "", 1, 2)),
+			"2": PointTo(MatchToken(lexer.Identifier, 0, "b", 2, 3)),
+			"3": PointTo(MatchToken(lexer.Colon, 0, "", 3, 4)),
+			"4": PointTo(MatchToken(lexer.Identifier, 0, "c", 4, 5)),
+			"5": PointTo(MatchToken(lexer.EOL, 0, "", 5, 5)),
+		}))
+	})
+
+	It("Handle statement separators", func() {
+		tokens, err := lexer.NewLexer("a;b\n(c\n)").Tokenize()
+		Expect(err).To(Succeed())
+		Expect(tokens).To(MatchAllElementsWithIndex(IndexIdentity, Elements{
+			"0": PointTo(MatchToken(lexer.Identifier, 0, "a", 0, 1)),
+			"1": PointTo(MatchToken(lexer.Separator, 0, "", 1, 2)),
+			"2": PointTo(MatchToken(lexer.Identifier, 0, "b", 2, 3)),
+			"3": PointTo(MatchToken(lexer.Separator, 0, "", 3, 4)),
+			"4": PointTo(MatchToken(lexer.LPar, 0, "", 4, 5)),
+			"5": PointTo(MatchToken(lexer.Identifier, 0, "c", 5, 6)),
+			// New line inside of parentheses does not separate statements
+			"6": PointTo(MatchToken(lexer.Whitespace, 0, "", 6, 7)),
+			"7": PointTo(MatchToken(lexer.RPar, 0, "", 7, 8)),
+			"8": PointTo(MatchToken(lexer.EOL, 0, "", 8, 8)),
+		}))
+	})
+
+	It("Tracks lines and columns", func() {
+		tokens, err := lexer.NewLexer("a = 1\r\n  b\n\nc").Tokenize()
+		Expect(err).To(Succeed())
+		lines, columns := []int{}, []int{}
+		for _, t := range tokens {
+			lines = append(lines, t.Line())
+			columns = append(columns, t.Column())
+		}
+		Expect(lines).To(Equal([]int{1, 1, 1, 1, 1, 1, 1, 2, 2, 2, 3, 4, 4}))
+		Expect(columns).To(Equal([]int{1, 2, 3, 4, 5, 6, 7, 1, 3, 4, 1, 1, 2}))
+		Expect(tokens[8].Location()).To(Equal(lexer.Location{Offset: 9, Length: 1, Line: 2, Column: 3}))
+		Expect(tokens[8].Location().String()).To(Equal("line 2, column 3"))
+		Expect(tokens[2].Location().String()).To(Equal("position 2"))
+	})
+
+	DescribeTable("Split statements",
+		func(expr string, expected []string) {
+			tokens, err := lexer.NewLexer(expr).Tokenize()
+			Expect(err).To(Succeed())
+			statements := []string{}
+			for _, statement := range lexer.SplitStatements(tokens) {
+				literals := ""
+				for _, t := range statement {
+					literals += t.Literal()
+				}
+				Expect(statement[len(statement)-1].Type()).To(Equal(lexer.EOL))
+				statements = append(statements, literals)
+			}
+			Expect(statements).To(Equal(expected))
+		},
+		Entry("Single statement", "a + b", []string{"a + b"}),
+		Entry("Semicolons and new lines", "a = 3; b = a\n b", []string{"a = 3", " b = a", " b"}),
+		Entry("Empty statements are skipped", ";a;; \n\nb;", []string{"a", "b"}),
+		Entry("Empty input", "", []string{""}),
+		Entry("Separators only", " ; \n", []string{""}),
+	)
+
 	DescribeTable("Handle valid numbers",
 		func(expr string, valueMatcher types.GomegaMatcher) {
 			l := lexer.NewLexer(expr)
@@ -45,6 +133,7 @@ var _ = Describe("Tokenize", func() {
 			Expect(err).To(Succeed())
 			Expect(tokens).To(HaveLen(2))
 			Expect(tokens[0].Value()).To(valueMatcher)
+			Expect(tokens[0].Literal()).To(Equal(expr))
 			Expect(tokens[1].Type()).To(Equal(lexer.EOL))
 		},
 		Entry("Whole numbers only", "123", BeEquivalentTo(123)),
@@ -57,8 +146,87 @@ var _ = Describe("Tokenize", func() {
 
 		Entry("With positive exponent", ".047e+5", BeEquivalentTo(4700)),
 		Entry("With negative exponent", "4.7e-5", BeEquivalentTo(0.000047)),
+		Entry("More digits than float64 can hold", "0.12345678901234567890123", BeNumerically("~", 0.123456789012)),
+		Entry("Smaller than float64 is kept as zero", "1e-400", BeZero()),
+	)
+
+	DescribeTable("Handle numbers in other bases and with separators",
+		func(expr string, value float64, base int) {
+			tokens, err := lexer.NewLexer(expr).Tokenize()
+			Expect(err).To(Succeed())
+			Expect(tokens).To(HaveLen(2))
+			Expect(tokens[0].Type()).To(Equal(lexer.Number))
+			Expect(tokens[0].Value()).To(Equal(value))
+			Expect(tokens[0].Base()).To(Equal(base))
+			Expect(tokens[0].Literal()).To(Equal(expr))
+		},
+		Entry("Hexadecimal", "0xFF", 255.0, 16),
+		Entry("Hexadecimal with upper case prefix", "0Xff", 255.0, 16),
+		Entry("Hexadecimal with separators", "0xdead_beef", 3735928559.0, 16),
+		Entry("Binary", "0b1010", 10.0, 2),
+		Entry("Binary with separators", "0B1111_0000", 240.0, 2),
+		Entry("Octal", "0o755", 493.0, 8),
+		Entry("Hexadecimal over 64 bits", "0x1_0000_0000_0000_0000", 18446744073709551616.0, 16),
+		Entry("Decimal with separators", "1_000_000", 1000000.0, 10),
+		Entry("Separators in fraction and exponent", "1_000.000_5e1_0", 1000.0005e10, 10),
+		Entry("Leading zero is decimal", "0755", 755.0, 10),
 	)
 
+	DescribeTable("Handle invalid numbers",
+		func(expr string, expectedErr error, location lexer.Location) {
+			tokens, err := lexer.NewLexer(expr).Tokenize()
+			Expect(tokens).To(BeNil())
+			Expect(errors.Is(err, expectedErr)).To(BeTrue())
+			Expect(err.(*lexer.Error).Location()).To(Equal(location))
+		},
+		Entry("Prefix without digits", "0x", lexer.ErrInvalidNumber, lexer.Location{Length: 2, Line: 1, Column: 1}),
+		Entry("Digit out of base", "1 + 0b12", lexer.ErrInvalidNumber,
+			lexer.Location{Offset: 4, Length: 4, Line: 1, Column: 5}),
+		Entry("Octal with digit 8", "0o78", lexer.ErrInvalidNumber, lexer.Location{Length: 4, Line: 1, Column: 1}),
+		Entry("Hexadecimal with letter out of base", "0xFG", lexer.ErrInvalidNumber,
+			lexer.Location{Length: 4, Line: 1, Column: 1}),
+		Entry("Double separator", "1__0", lexer.ErrInvalidNumber, lexer.Location{Length: 4, Line: 1, Column: 1}),
+		Entry("Trailing separator", "1_", lexer.ErrInvalidNumber, lexer.Location{Length: 2, Line: 1, Column: 1}),
+		Entry("Separator after prefix", "0x_FF", lexer.ErrInvalidNumber, lexer.Location{Length: 5, Line: 1, Column: 1}),
+		Entry("Separator next to decimal point", "1_.5", lexer.ErrInvalidNumber,
+			lexer.Location{Length: 4, Line: 1, Column: 1}),
+	)
+
+	DescribeTable("Handle imaginary numbers",
+		func(expr string, value float64) {
+			tokens, err := lexer.NewLexer(expr).Tokenize()
+			Expect(err).To(Succeed())
+			Expect(tokens).To(HaveLen(2))
+			Expect(tokens[0].Type()).To(Equal(lexer.Number))
+			Expect(tokens[0].Value()).To(Equal(value))
+			Expect(tokens[0].Imaginary()).To(BeTrue())
+			Expect(tokens[0].Literal()).To(Equal(expr))
+		},
+		Entry("Whole number with i", "3i", 3.0),
+		Entry("Decimal with j", "2.5j", 2.5),
+		Entry("Fraction part only", ".5i", 0.5),
+		Entry("With exponent", "1.2e3i", 1200.0),
+		Entry("Hexadecimal", "0x1Fi", 31.0),
+	)
+
+	It("Does not take suffix from longer identifier", func() {
+		tokens, err := lexer.NewLexer("2in + 3i*j").Tokenize()
+		Expect(err).To(Succeed())
+		Expect(tokens).To(MatchAllElementsWithIndex(IndexIdentity, Elements{
+			"0": PointTo(MatchToken(lexer.Number, 2, "", 0, 1)),
+			"1": PointTo(MatchToken(lexer.Identifier, 0, "in", 1, 3)),
+			"2": PointTo(MatchToken(lexer.Whitespace, 0, "", 3, 4)),
+			"3": PointTo(MatchToken(lexer.Addition, 0, "", 4, 5)),
+			"4": PointTo(MatchToken(lexer.Whitespace, 0, "", 5, 6)),
+			"5": PointTo(MatchToken(lexer.Number, 3, "", 6, 8)),
+			"6": PointTo(MatchToken(lexer.Multiplication, 0, "", 8, 9)),
+			"7": PointTo(MatchToken(lexer.Identifier, 0, "j", 9, 10)),
+			"8": PointTo(MatchToken(lexer.EOL, 0, "", 10, 10)),
+		}))
+		Expect(tokens[0].Imaginary()).To(BeFalse())
+		Expect(tokens[5].Imaginary()).To(BeTrue())
+	})
+
 	DescribeTable("Handle invalid character error",
 		func(expr string, pos int, errStr string, wrapperErr error) {
 			l := lexer.NewLexer(expr)
@@ -69,25 +237,67 @@ var _ = Describe("Tokenize", func() {
 			Expect(lexErr.Error()).To(Equal(errStr))
 			Expect(lexErr.Unwrap()).To(Equal(wrapperErr))
 		},
-		Entry("At the begining", "? 123", 0, "unexpected character at position 0", lexer.ErrUnexpectedChar),
-		Entry("In the middle", "+ < 123", 2, "unexpected character at position 2", lexer.ErrUnexpectedChar),
+		Entry("At the begining", "@ 123", 0, "unexpected character at position 0", lexer.ErrUnexpectedChar),
+		Entry("In the middle", "+ $ 123", 2, "unexpected character at position 2", lexer.ErrUnexpectedChar),
 		Entry("At the end", "123.", 3, "unexpected character at position 3", lexer.ErrUnexpectedChar),
+		Entry("Single ampersand", "a & b", 2, "unexpected character at position 2", lexer.ErrUnexpectedChar),
+		Entry("Single pipe", "a | b", 2, "unexpected character at position 2", lexer.ErrUnexpectedChar),
+		Entry("On the next line", "a;\n b @", 6, "unexpected character at line 2, column 4", lexer.ErrUnexpectedChar),
+	)
+
+	DescribeTable("Location of unexpected characters",
+		func(expr string, expected lexer.Location) {
+			_, err := lexer.NewLexer(expr).Tokenize()
+			lexErr := err.(*lexer.Error)
+			Expect(lexErr.Location()).To(Equal(expected))
+			Expect(lexErr.Position()).To(Equal(expected.Offset))
+		},
+		Entry("Span over all unexpected characters", "a $$$ b",
+			lexer.Location{Offset: 2, Length: 3, Line: 1, Column: 3}),
+		Entry("Multi-byte character", "1 + é", lexer.Location{Offset: 4, Length: 2, Line: 1, Column: 5}),
+		Entry("Multi-byte character on next line", "x;\n  € + 1",
+			lexer.Location{Offset: 5, Length: 3, Line: 2, Column: 3}),
+		Entry("At the end", "123.", lexer.Location{Offset: 3, Length: 1, Line: 1, Column: 4}),
 	)
 
 	It("Handle empty error", func() {
 		err := lexer.Error{}
 		Expect(err.Position()).To(Equal(-1))
+		Expect(err.Location().Known()).To(BeFalse())
 		Expect(err.Error()).To(Equal("unexpected error"))
 		Expect(err.Unwrap()).To(BeNil())
 	})
 
-	It("Handle number out of range", func() {
+	It("Keeps number out of range with its literal", func() {
 		l := lexer.NewLexer("1.7976931348623159e308")
 		tokens, err := l.Tokenize()
-		Expect(tokens).To(BeNil())
-		lexErr := err.(*lexer.Error)
-		Expect(lexErr.Position()).To(Equal(0))
-		Expect(lexErr.Error()).To(ContainSubstring("number is out of range; found Number token at position 0"))
-		Expect(lexErr.Unwrap()).To(MatchError(ContainSubstring("number is out of range")))
+		Expect(err).To(Succeed())
+		Expect(tokens).To(HaveLen(2))
+		Expect(tokens[0].Value()).To(Equal(math.Inf(1)))
+		Expect(tokens[0].Literal()).To(Equal("1.7976931348623159e308"))
 	})
+
+	DescribeTable("Limits",
+		func(expression string, limits lexer.Limits, expectedErr error, message string) {
+			l := lexer.NewLexer(expression)
+			l.SetLimits(limits)
+			tokens, err := l.Tokenize()
+			if expectedErr == nil {
+				Expect(err).To(Succeed())
+				Expect(tokens).ToNot(BeEmpty())
+				return
+			}
+			Expect(tokens).To(BeNil())
+			Expect(errors.Is(err, expectedErr)).To(BeTrue())
+			Expect(err).To(MatchError(message))
+		},
+		Entry("Within limits", "1 + 2", lexer.Limits{InputLength: 5, Tokens: 3}, nil, ""),
+		Entry("Whitespace is not counted", "1   +\t2 ", lexer.Limits{Tokens: 3}, nil, ""),
+		Entry("Too long input", "1 + 2 + 3", lexer.Limits{InputLength: 5}, lexer.ErrInputTooLong,
+			"expression exceeded the limit of length at position 5"),
+		Entry("Too many tokens", "1 + 2 + 3", lexer.Limits{Tokens: 3}, lexer.ErrTokenLimit,
+			"expression exceeded the limit of tokens; found Addition token at position 6"),
+		Entry("Separators are counted", "1;2;3", lexer.Limits{Tokens: 4}, lexer.ErrTokenLimit,
+			"expression exceeded the limit of tokens; found Number token at position 4"),
+	)
 })
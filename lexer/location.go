@@ -0,0 +1,39 @@
+package lexer
+
+import "strconv"
+
+// Location points to the span of the input expression
+type Location struct {
+	// Offset is 0 based byte offset where the span starts, -1 when the location is not known
+	Offset int `json:"offset"`
+	// Length is length of the span in bytes
+	Length int `json:"length"`
+	// Line is 1 based line where the span starts, 0 when it is not known
+	Line int `json:"line,omitempty"`
+	// Column is 1 based column where the span starts counted in runes, 0 when it is not known
+	Column int `json:"column,omitempty"`
+}
+
+// UnknownLocation returns location of errors without position
+func UnknownLocation() Location {
+	return Location{Offset: -1}
+}
+
+// Known returns false when there is no position in the input
+func (l Location) Known() bool {
+	return l.Offset >= 0
+}
+
+// End returns byte offset right after the span
+func (l Location) End() int {
+	return l.Offset + l.Length
+}
+
+// String returns "position 4" for the first line, so single line expressions keep 0 based byte offset.
+// Following lines of multi-line input are described as "line 2, column 3"
+func (l Location) String() string {
+	if l.Line > 1 {
+		return "line " + strconv.Itoa(l.Line) + ", column " + strconv.Itoa(l.Column)
+	}
+	return "position " + strconv.Itoa(l.Offset)
+}
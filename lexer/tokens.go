@@ -1,9 +1,13 @@
 package lexer
 
+import "strconv"
+
 var (
 	tokenTypeStr = []string{
 		"EOL", "Whitespace", "Identifier", "LPar", "RPar", "Exponent", "Multiplication", "Division", "FloorDiv",
-		"Modulus", "Addition", "Substraction", "Number", "Equal", "Comma", "UnaryAddition", "UnarySubstraction"}
+		"Modulus", "Addition", "Substraction", "Number", "Equal", "Comma", "UnaryAddition", "UnarySubstraction",
+		"Less", "LessOrEqual", "Greater", "GreaterOrEqual", "IsEqual", "NotEqual", "And", "Or", "Not",
+		"Question", "Colon", "Separator", "Invalid", "ImplicitMultiplication", "Factorial", "Percent"}
 )
 
 type TokenType uint8
@@ -30,6 +34,40 @@ const (
 
 	UnaryAddition
 	UnarySubstraction
+
+	// Comparison and logical operators
+
+	Less
+	LessOrEqual
+	Greater
+	GreaterOrEqual
+	IsEqual
+	NotEqual
+	And
+	Or
+	Not
+
+	// Conditional operator cond ? a : b
+
+	Question
+	Colon
+
+	// Separator of statements, semicolon or new line outside of parentheses
+
+	Separator
+
+	// Invalid marks part of the input skipped by error recovery of parsers
+
+	Invalid
+
+	// ImplicitMultiplication is not recognized by lexer, parsers insert it between adjacent operands, like 2x
+
+	ImplicitMultiplication
+
+	// Postfix operators cannot be recognized by lexer, parsers change Not and Modulus to them after an operand
+
+	Factorial
+	Percent
 )
 
 func (tt TokenType) String() string {
@@ -40,7 +78,11 @@ type Token struct {
 	tType            TokenType
 	value            float64
 	idName           string
+	literal          string
+	imaginary        bool
+	base             int
 	startPos, endPos int
+	line, column     int
 }
 
 func NewToken(tType TokenType, value float64, idName string, startPos, endPos int) *Token {
@@ -53,6 +95,44 @@ func NewToken(tType TokenType, value float64, idName string, startPos, endPos in
 	}
 }
 
+// InvalidToken creates token spanning from the start of first to the end of last token.
+// If last is nil, token has zero length at the start of first.
+// Parsers use it as a placeholder for the part of the input which could not be parsed.
+func InvalidToken(first, last *Token) *Token {
+	endPos := first.StartPosition()
+	if last != nil {
+		endPos = last.EndPosition()
+	}
+	return &Token{
+		tType:    Invalid,
+		startPos: first.StartPosition(),
+		endPos:   endPos,
+		line:     first.Line(),
+		column:   first.Column(),
+	}
+}
+
+// ImplicitMultiplicationToken creates token with zero length at the start of the next operand.
+// Parsers insert it between adjacent operands, like 2x or 3(a + b)
+func ImplicitMultiplicationToken(next *Token) *Token {
+	return &Token{
+		tType:    ImplicitMultiplication,
+		startPos: next.StartPosition(),
+		endPos:   next.StartPosition(),
+		line:     next.Line(),
+		column:   next.Column(),
+	}
+}
+
+// Clone returns copy of the token, so parsers can change it without affecting the original one
+func (t *Token) Clone() *Token {
+	if t == nil {
+		return nil
+	}
+	clone := *t
+	return &clone
+}
+
 func (t *Token) Type() TokenType {
 	if t == nil {
 		return EOL
@@ -67,6 +147,22 @@ func (t *Token) Value() float64 {
 	return t.value
 }
 
+// Imaginary returns true for numbers written with imaginary suffix, like 3i or 2.5j
+func (t *Token) Imaginary() bool {
+	if t == nil {
+		return false
+	}
+	return t.imaginary
+}
+
+// Base returns base in which the number was written, 16 for 0xFF, 2 for 0b1010, 8 for 0o755 and 10 otherwise
+func (t *Token) Base() int {
+	if t == nil || t.base == 0 {
+		return 10
+	}
+	return t.base
+}
+
 func (t *Token) Identifier() string {
 	if t == nil {
 		return ""
@@ -74,6 +170,18 @@ func (t *Token) Identifier() string {
 	return t.idName
 }
 
+// Literal returns original text of the token as it was written in the input expression.
+// Tokens not created by lexer do not have literal, so for numbers the formatted value is returned.
+func (t *Token) Literal() string {
+	if t == nil {
+		return ""
+	}
+	if t.literal == "" && t.tType == Number {
+		return strconv.FormatFloat(t.value, 'g', -1, 64)
+	}
+	return t.literal
+}
+
 func (t *Token) StartPosition() int {
 	if t == nil {
 		return 0
@@ -88,6 +196,30 @@ func (t *Token) EndPosition() int {
 	return t.endPos
 }
 
+// Line returns 1 based line where the token starts, tokens not created by lexer return 0
+func (t *Token) Line() int {
+	if t == nil {
+		return 0
+	}
+	return t.line
+}
+
+// Column returns 1 based column where the token starts counted in runes, tokens not created by lexer return 0
+func (t *Token) Column() int {
+	if t == nil {
+		return 0
+	}
+	return t.column
+}
+
+// Location returns span of the token in the input, nil token has unknown location
+func (t *Token) Location() Location {
+	if t == nil {
+		return UnknownLocation()
+	}
+	return Location{Offset: t.startPos, Length: t.endPos - t.startPos, Line: t.line, Column: t.column}
+}
+
 func (t *Token) ChangeToUnary() error {
 	if t == nil {
 		return ErrInvalidUnary
@@ -97,8 +229,26 @@ func (t *Token) ChangeToUnary() error {
 		t.tType = UnaryAddition
 	case Substraction, UnarySubstraction:
 		t.tType = UnarySubstraction
+	case Not:
+		// Logical negation is always unary
 	default:
 		return ErrInvalidUnary
 	}
 	return nil
 }
+
+// ChangeToPostfix changes Not into Factorial and Modulus into Percent, like in 5! or 10%
+func (t *Token) ChangeToPostfix() error {
+	if t == nil {
+		return ErrInvalidPostfix
+	}
+	switch t.tType {
+	case Not, Factorial:
+		t.tType = Factorial
+	case Modulus, Percent:
+		t.tType = Percent
+	default:
+		return ErrInvalidPostfix
+	}
+	return nil
+}
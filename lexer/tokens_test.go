@@ -1,6 +1,8 @@
 package lexer_test
 
 import (
+	"encoding/json"
+
 	"github.com/arxeiss/go-expression-calculator/lexer"
 
 	. "github.com/onsi/ginkgo"
@@ -20,6 +22,85 @@ var _ = Describe("Token", func() {
 
 		Expect(t).To(PointTo(MatchToken(lexer.Addition, 10.123, "identifier", 10, 12)))
 	})
+
+	It("JSON round trip", func() {
+		tokens, err := lexer.NewLexer("x + 2.5i;\ny - 0xFF").Tokenize()
+		Expect(err).To(Succeed())
+		for _, t := range tokens {
+			encoded, err := json.Marshal(t)
+			Expect(err).To(Succeed())
+			decoded := &lexer.Token{}
+			Expect(json.Unmarshal(encoded, decoded)).To(Succeed())
+			Expect(decoded).To(Equal(t))
+		}
+
+		err = json.Unmarshal([]byte(`{"type": "Unknown"}`), &lexer.Token{})
+		Expect(err).To(MatchError(lexer.ErrUnknownTokenType))
+		Expect(err).To(MatchError("unknown token type 'Unknown'"))
+	})
+
+	It("Location of token not created by lexer", func() {
+		t := lexer.NewToken(lexer.Addition, 0, "", 10, 12)
+		Expect(t.Line()).To(Equal(0))
+		Expect(t.Column()).To(Equal(0))
+		Expect(t.Location()).To(Equal(lexer.Location{Offset: 10, Length: 2}))
+		Expect(t.Location().String()).To(Equal("position 10"))
+
+		var nilToken *lexer.Token
+		Expect(nilToken.Location().Known()).To(BeFalse())
+		Expect(lexer.Location{Offset: 10, Line: 3, Column: 5}.String()).To(Equal("line 3, column 5"))
+	})
+
+	It("Literal of number not created by lexer", func() {
+		Expect(lexer.NewToken(lexer.Number, 10.125, "", 0, 0).Literal()).To(Equal("10.125"))
+		Expect(lexer.NewToken(lexer.Addition, 0, "", 0, 0).Literal()).To(BeEmpty())
+		Expect(lexer.NewToken(lexer.Number, 255, "", 0, 0).Base()).To(Equal(10))
+	})
+
+	It("Invalid token spans given tokens", func() {
+		tokens, err := lexer.NewLexer("1 +\n max(2, 3)").Tokenize()
+		Expect(err).To(Succeed())
+
+		invalid := lexer.InvalidToken(tokens[5], tokens[11])
+		Expect(invalid.Type()).To(Equal(lexer.Invalid))
+		Expect(invalid.Location()).To(Equal(lexer.Location{Offset: 5, Length: 9, Line: 2, Column: 2}))
+
+		empty := lexer.InvalidToken(tokens[11], nil)
+		Expect(empty.Location()).To(Equal(lexer.Location{Offset: 13, Length: 0, Line: 2, Column: 10}))
+	})
+
+	It("Implicit multiplication token is at the start of the next operand", func() {
+		tokens, err := lexer.NewLexer("1 +\n 2x").Tokenize()
+		Expect(err).To(Succeed())
+
+		implicit := lexer.ImplicitMultiplicationToken(tokens[6])
+		Expect(implicit.Type()).To(Equal(lexer.ImplicitMultiplication))
+		Expect(implicit.Location()).To(Equal(lexer.Location{Offset: 6, Length: 0, Line: 2, Column: 3}))
+	})
+
+	It("Clone does not change the original token", func() {
+		token := lexer.NewToken(lexer.Substraction, 0, "", 2, 3)
+		clone := token.Clone()
+		Expect(clone.ChangeToUnary()).To(Succeed())
+		Expect(clone.Type()).To(Equal(lexer.UnarySubstraction))
+		Expect(token.Type()).To(Equal(lexer.Substraction))
+		Expect(clone.Location()).To(Equal(token.Location()))
+	})
+
+	It("Only logical negation and modulus can be changed to postfix", func() {
+		factorial := lexer.NewToken(lexer.Not, 0, "", 1, 2)
+		Expect(factorial.ChangeToPostfix()).To(Succeed())
+		Expect(factorial.Type()).To(Equal(lexer.Factorial))
+		Expect(factorial.ChangeToPostfix()).To(Succeed())
+		Expect(factorial.Type()).To(Equal(lexer.Factorial))
+
+		percent := lexer.NewToken(lexer.Modulus, 0, "", 2, 3)
+		Expect(percent.ChangeToPostfix()).To(Succeed())
+		Expect(percent.Type()).To(Equal(lexer.Percent))
+
+		Expect(lexer.NewToken(lexer.Substraction, 0, "", 0, 1).ChangeToPostfix()).To(
+			MatchError(lexer.ErrInvalidPostfix))
+	})
 })
 
 var _ = DescribeTable("TokenType stringer",
@@ -43,4 +124,9 @@ var _ = DescribeTable("TokenType stringer",
 	Entry("Comma", lexer.Comma, "Comma"),
 	Entry("UnaryAddition", lexer.UnaryAddition, "UnaryAddition"),
 	Entry("UnarySubstraction", lexer.UnarySubstraction, "UnarySubstraction"),
+	Entry("Separator", lexer.Separator, "Separator"),
+	Entry("Invalid", lexer.Invalid, "Invalid"),
+	Entry("ImplicitMultiplication", lexer.ImplicitMultiplication, "ImplicitMultiplication"),
+	Entry("Factorial", lexer.Factorial, "Factorial"),
+	Entry("Percent", lexer.Percent, "Percent"),
 )
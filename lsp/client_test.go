@@ -0,0 +1,161 @@
+package lsp_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/arxeiss/go-expression-calculator/lsp"
+
+	. "github.com/onsi/gomega"
+)
+
+// client is in-process LSP client connected to the server with pipes
+type client struct {
+	toServer *io.PipeWriter
+	messages chan *lsp.Message
+	served   chan error
+	lastID   int
+	// notifications received while waiting for the response
+	notifications []*lsp.Message
+}
+
+func newClient(s *lsp.Server) *client {
+	serverIn, toServer := io.Pipe()
+	fromServer, serverOut := io.Pipe()
+	c := &client{
+		toServer: toServer,
+		messages: make(chan *lsp.Message, 100),
+		served:   make(chan error, 1),
+	}
+	go func() {
+		c.served <- s.Serve(serverIn, serverOut)
+		serverOut.Close()
+	}()
+	go c.readAll(bufio.NewReader(fromServer))
+	return c
+}
+
+// readAll reads messages from the server until it is closed, so the server is never blocked by writing
+func (c *client) readAll(r *bufio.Reader) {
+	defer close(c.messages)
+	for {
+		length := 0
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimSpace(line)
+			if line == "" {
+				break
+			}
+			if strings.HasPrefix(line, "Content-Length: ") {
+				length, _ = strconv.Atoi(strings.TrimPrefix(line, "Content-Length: "))
+			}
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return
+		}
+		msg := &lsp.Message{}
+		if err := json.Unmarshal(body, msg); err != nil {
+			return
+		}
+		c.messages <- msg
+	}
+}
+
+func (c *client) sendRaw(body []byte) {
+	_, err := fmt.Fprintf(c.toServer, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	Expect(err).To(Succeed())
+}
+
+func (c *client) send(id *json.RawMessage, method string, params interface{}) {
+	msg := map[string]interface{}{"jsonrpc": "2.0", "method": method}
+	if id != nil {
+		msg["id"] = id
+	}
+	if params != nil {
+		msg["params"] = params
+	}
+	body, err := json.Marshal(msg)
+	Expect(err).To(Succeed())
+	c.sendRaw(body)
+}
+
+func (c *client) notify(method string, params interface{}) {
+	c.send(nil, method, params)
+}
+
+// call sends the request and waits for the response, result is decoded into given value
+func (c *client) call(method string, params interface{}, result interface{}) *lsp.ResponseError {
+	c.lastID++
+	id := json.RawMessage(strconv.Itoa(c.lastID))
+	c.send(&id, method, params)
+	msg := c.receive(func(msg *lsp.Message) bool {
+		return msg.ID != nil && string(*msg.ID) == string(id)
+	})
+	if msg.Error != nil {
+		return msg.Error
+	}
+	if result != nil {
+		Expect(json.Unmarshal(msg.Result, result)).To(Succeed())
+	}
+	return nil
+}
+
+// diagnostics returns the next published diagnostics
+func (c *client) diagnostics() lsp.PublishDiagnosticsParams {
+	for i, msg := range c.notifications {
+		if msg.Method == "textDocument/publishDiagnostics" {
+			c.notifications = append(c.notifications[:i], c.notifications[i+1:]...)
+			return decodeDiagnostics(msg)
+		}
+	}
+	return decodeDiagnostics(c.receive(func(msg *lsp.Message) bool {
+		return msg.Method == "textDocument/publishDiagnostics"
+	}))
+}
+
+func decodeDiagnostics(msg *lsp.Message) lsp.PublishDiagnosticsParams {
+	params := lsp.PublishDiagnosticsParams{}
+	Expect(json.Unmarshal(msg.Params, &params)).To(Succeed())
+	return params
+}
+
+// receive waits for the message matching the condition, other messages are kept as notifications
+func (c *client) receive(match func(msg *lsp.Message) bool) *lsp.Message {
+	for {
+		var msg *lsp.Message
+		Eventually(c.messages).Should(Receive(&msg))
+		Expect(msg).NotTo(BeNil(), "server closed the connection")
+		if match(msg) {
+			return msg
+		}
+		c.notifications = append(c.notifications, msg)
+	}
+}
+
+func (c *client) open(uri, text string) lsp.PublishDiagnosticsParams {
+	c.notify("textDocument/didOpen", lsp.DidOpenTextDocumentParams{
+		TextDocument: lsp.TextDocumentItem{URI: uri, LanguageID: "calculator", Version: 1, Text: text},
+	})
+	return c.diagnostics()
+}
+
+// exit stops the server and checks it ended without error
+func (c *client) exit() {
+	c.notify("exit", nil)
+	Eventually(c.served).Should(Receive(BeNil()))
+}
+
+func position(uri string, line, character int) lsp.TextDocumentPositionParams {
+	return lsp.TextDocumentPositionParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+		Position:     lsp.Position{Line: line, Character: character},
+	}
+}
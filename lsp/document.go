@@ -0,0 +1,222 @@
+package lsp
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/arxeiss/go-expression-calculator/ast"
+	"github.com/arxeiss/go-expression-calculator/lexer"
+	"github.com/arxeiss/go-expression-calculator/parser"
+)
+
+const diagnosticSource = "calculator"
+
+// Evaluation of the document is limited, so runaway user defined function is reported as diagnostic
+// instead of blocking the server
+const (
+	evaluationSteps   = 100_000
+	evaluationTimeout = time.Second
+)
+
+// document keeps the text of opened file and results of its analysis
+type document struct {
+	text string
+	// tokens and root are nil when the text cannot be tokenized
+	tokens      []*lexer.Token
+	root        ast.Node
+	diagnostics []Diagnostic
+	// assignments are targets of all assignments in order of appearance
+	assignments []*ast.VariableNode
+	// variables hold values after evaluation of the whole document, keys are lower-cased
+	variables map[string]float64
+}
+
+// analyze tokenizes, parses and evaluates the text and collects all errors as diagnostics.
+// Evaluation is skipped when there are syntax errors, as statements would be incomplete
+func (s *Server) analyze(text string) *document {
+	doc := &document{text: text, diagnostics: []Diagnostic{}, variables: make(map[string]float64)}
+	tokens, err := lexer.NewLexer(text).Tokenize()
+	if err != nil {
+		doc.addDiagnostic(err)
+		return doc
+	}
+	doc.tokens = tokens
+	if isEmpty(tokens) {
+		return doc
+	}
+
+	if p, ok := s.parser.(parser.RecoveringParser); ok {
+		var errs []*parser.Error
+		doc.root, errs = p.ParseWithRecovery(tokens)
+		for _, e := range errs {
+			doc.addDiagnostic(e)
+		}
+	} else if doc.root, err = s.parser.Parse(tokens); err != nil {
+		doc.addDiagnostic(err)
+	}
+	if doc.root == nil {
+		return doc
+	}
+	collectAssignments(doc.root, &doc.assignments)
+	if len(doc.diagnostics) == 0 {
+		s.evaluate(doc)
+	}
+	return doc
+}
+
+// evaluate evaluates statements one by one, so errors of all of them are reported
+func (s *Server) evaluate(doc *document) {
+	ev, err := s.newEvaluator()
+	if err != nil {
+		doc.addDiagnostic(err)
+		return
+	}
+	ev.SetStepLimit(evaluationSteps)
+	ctx, cancel := context.WithTimeout(context.Background(), evaluationTimeout)
+	defer cancel()
+
+	statements := []ast.Node{doc.root}
+	if block, ok := doc.root.(*ast.BlockNode); ok {
+		statements = block.Statements()
+	}
+	for _, statement := range statements {
+		if _, err := ev.EvalContext(ctx, statement); err != nil {
+			doc.addDiagnostic(err)
+		}
+	}
+	for _, v := range ev.VariableList() {
+		doc.variables[v.Name] = v.Value
+	}
+}
+
+// addDiagnostic converts error into diagnostic, errors without location are reported at the start of the document
+func (doc *document) addDiagnostic(err error) {
+	location := lexer.UnknownLocation()
+	var located interface{ Location() lexer.Location }
+	if errors.As(err, &located) {
+		location = located.Location()
+	}
+	message := err.Error()
+	// Message of the wrapped error is enough, as the editor shows the location itself
+	if unwrapped := errors.Unwrap(err); unwrapped != nil {
+		message = unwrapped.Error()
+	}
+	doc.diagnostics = append(doc.diagnostics, Diagnostic{
+		Range:    doc.locationRange(location),
+		Severity: SeverityError,
+		Source:   diagnosticSource,
+		Message:  message,
+	})
+}
+
+// isEmpty returns true if there are only whitespace and separators
+func isEmpty(tokens []*lexer.Token) bool {
+	for _, t := range tokens {
+		switch t.Type() {
+		case lexer.Whitespace, lexer.Separator, lexer.EOL:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// collectAssignments appends targets of all assignments in the tree
+func collectAssignments(node ast.Node, assignments *[]*ast.VariableNode) {
+	switch n := node.(type) {
+	case *ast.AssignNode:
+		*assignments = append(*assignments, n.Left())
+		collectAssignments(n.Right(), assignments)
+	case *ast.BlockNode:
+		for _, s := range n.Statements() {
+			collectAssignments(s, assignments)
+		}
+	case *ast.UnaryNode:
+		collectAssignments(n.Next(), assignments)
+	case *ast.PostfixNode:
+		collectAssignments(n.Prev(), assignments)
+	case *ast.BinaryNode:
+		collectAssignments(n.Left(), assignments)
+		collectAssignments(n.Right(), assignments)
+	case *ast.ConditionalNode:
+		collectAssignments(n.Condition(), assignments)
+		collectAssignments(n.Then(), assignments)
+		collectAssignments(n.Else(), assignments)
+	case *ast.FunctionNode:
+		for _, p := range n.Params() {
+			collectAssignments(p, assignments)
+		}
+	}
+}
+
+// tokenAt returns token of given types at the offset, token ending at the offset is accepted as well,
+// so the identifier is found when the cursor is right behind it
+func (doc *document) tokenAt(offset int, types ...lexer.TokenType) (int, *lexer.Token) {
+	for i, t := range doc.tokens {
+		if t.StartPosition() > offset {
+			break
+		}
+		if offset > t.EndPosition() {
+			continue
+		}
+		for _, tt := range types {
+			if t.Type() == tt {
+				return i, t
+			}
+		}
+	}
+	return -1, nil
+}
+
+// locationRange converts location in the text into LSP range, unknown location is the start of the document
+func (doc *document) locationRange(location lexer.Location) Range {
+	if !location.Known() {
+		return Range{}
+	}
+	return Range{Start: doc.position(location.Offset), End: doc.position(location.End())}
+}
+
+// position converts byte offset into line and character counted in UTF-16 code units
+func (doc *document) position(offset int) Position {
+	if offset > len(doc.text) {
+		offset = len(doc.text)
+	}
+	lineStart := strings.LastIndexByte(doc.text[:offset], '\n') + 1
+	character := 0
+	for _, r := range doc.text[lineStart:offset] {
+		character += utf16Len(r)
+	}
+	return Position{Line: strings.Count(doc.text[:lineStart], "\n"), Character: character}
+}
+
+// offset converts LSP position into byte offset, position out of the line is moved to the end of the line
+func (doc *document) offset(pos Position) int {
+	offset := 0
+	for line := 0; line < pos.Line; line++ {
+		i := strings.IndexByte(doc.text[offset:], '\n')
+		if i < 0 {
+			return len(doc.text)
+		}
+		offset += i + 1
+	}
+	for character := 0; character < pos.Character && offset < len(doc.text); {
+		r, size := utf8.DecodeRuneInString(doc.text[offset:])
+		if r == '\n' {
+			break
+		}
+		character += utf16Len(r)
+		offset += size
+	}
+	return offset
+}
+
+// utf16Len returns number of UTF-16 code units needed to encode the rune
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,218 @@
+package lsp
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/arxeiss/go-expression-calculator/evaluator"
+	"github.com/arxeiss/go-expression-calculator/lexer"
+)
+
+const markdown = "markdown"
+
+// completion offers functions and variables assigned in the document starting with the identifier before the cursor
+func (s *Server) completion(doc *document, offset int) []CompletionItem {
+	start := offset
+	for start > 0 && isIdentifierChar(doc.text[start-1]) {
+		start--
+	}
+	prefix := strings.ToLower(doc.text[start:offset])
+
+	items := []CompletionItem{}
+	for _, f := range s.functions {
+		if strings.HasPrefix(f.Name, prefix) {
+			items = append(items, CompletionItem{
+				Label:         f.Name,
+				Kind:          CompletionKindFunction,
+				Detail:        signatureLabel(f.Name, f.Function),
+				Documentation: &MarkupContent{Kind: markdown, Value: f.Function.Description},
+			})
+		}
+	}
+	seen := make(map[string]bool)
+	variables := []CompletionItem{}
+	for _, v := range doc.assignments {
+		name := strings.ToLower(v.Name())
+		if seen[name] || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		seen[name] = true
+		variables = append(variables, CompletionItem{Label: v.Name(), Kind: CompletionKindVariable})
+	}
+	sort.Slice(variables, func(i, j int) bool { return variables[i].Label < variables[j].Label })
+	return append(items, variables...)
+}
+
+// hover describes function or variable under the cursor
+func (s *Server) hover(doc *document, offset int) *Hover {
+	i, token := doc.tokenAt(offset, lexer.Identifier)
+	if token == nil {
+		return nil
+	}
+	name := strings.ToLower(token.Identifier())
+	var text string
+	if doc.isFunctionCall(i) {
+		f, ok := s.function(name)
+		if !ok {
+			return nil
+		}
+		text = fmt.Sprintf("```\n%s\n```\n%s", signatureLabel(f.Name, f.Function), f.Function.Description)
+	} else {
+		value, ok := doc.variables[name]
+		if !ok {
+			return nil
+		}
+		text = fmt.Sprintf("```\n%s = %s\n```\nValue after evaluation of the document", token.Identifier(),
+			strconv.FormatFloat(value, 'g', -1, 64))
+	}
+	tokenRange := doc.locationRange(token.Location())
+	return &Hover{Contents: MarkupContent{Kind: markdown, Value: text}, Range: &tokenRange}
+}
+
+// openCall is function call or parenthesis not closed before the cursor, argument is 0 based index
+type openCall struct {
+	name     string
+	argument int
+}
+
+// openCalls returns calls opened before the offset from the outermost one, name is empty for plain parentheses
+func (doc *document) openCalls(offset int) []openCall {
+	calls := []openCall{}
+	for i, t := range doc.tokens {
+		if t.EndPosition() > offset {
+			break
+		}
+		switch t.Type() {
+		case lexer.LPar:
+			name := ""
+			if p := doc.previous(i); doc.isFunctionCall(p) {
+				name = doc.tokens[p].Identifier()
+			}
+			calls = append(calls, openCall{name: name})
+		case lexer.Comma:
+			if len(calls) > 0 {
+				calls[len(calls)-1].argument++
+			}
+		case lexer.RPar:
+			if len(calls) > 0 {
+				calls = calls[:len(calls)-1]
+			}
+		case lexer.Separator:
+			calls = calls[:0]
+		}
+	}
+	return calls
+}
+
+// signatureHelp finds the innermost function call around the cursor and the argument being written
+func (s *Server) signatureHelp(doc *document, offset int) *SignatureHelp {
+	calls := doc.openCalls(offset)
+	// Parentheses inside of arguments are not calls, the closest function is used
+	for c := len(calls) - 1; c >= 0; c-- {
+		if calls[c].name == "" {
+			continue
+		}
+		f, ok := s.function(strings.ToLower(calls[c].name))
+		if !ok {
+			return nil
+		}
+		params := []ParameterInformation{}
+		for _, p := range signatureParams(f.Function) {
+			params = append(params, ParameterInformation{Label: p})
+		}
+		active := calls[c].argument
+		if active >= len(params) && len(params) > 0 {
+			active = len(params) - 1
+		}
+		return &SignatureHelp{
+			Signatures: []SignatureInformation{{
+				Label:         signatureLabel(f.Name, f.Function),
+				Documentation: &MarkupContent{Kind: markdown, Value: f.Function.Description},
+				Parameters:    params,
+			}},
+			ActiveParameter: active,
+		}
+	}
+	return nil
+}
+
+// definition finds the assignment of the variable under the cursor. The last assignment before the cursor is used,
+// if the variable is assigned only later, the first assignment is used
+func (s *Server) definition(doc *document, uri string, offset int) *Location {
+	i, token := doc.tokenAt(offset, lexer.Identifier)
+	if token == nil || doc.isFunctionCall(i) {
+		return nil
+	}
+	var found *lexer.Token
+	for _, v := range doc.assignments {
+		if !strings.EqualFold(v.Name(), token.Identifier()) {
+			continue
+		}
+		if found != nil && v.GetToken().StartPosition() > token.StartPosition() {
+			break
+		}
+		found = v.GetToken()
+	}
+	if found == nil {
+		return nil
+	}
+	return &Location{URI: uri, Range: doc.locationRange(found.Location())}
+}
+
+// isFunctionCall checks if the token at index is identifier followed by left parenthesis
+func (doc *document) isFunctionCall(i int) bool {
+	if i < 0 || doc.tokens[i].Type() != lexer.Identifier {
+		return false
+	}
+	for _, t := range doc.tokens[i+1:] {
+		if t.Type() != lexer.Whitespace {
+			return t.Type() == lexer.LPar
+		}
+	}
+	return false
+}
+
+// previous returns index of the closest token before index which is not whitespace, or -1
+func (doc *document) previous(i int) int {
+	for i--; i >= 0; i-- {
+		if doc.tokens[i].Type() != lexer.Whitespace {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Server) function(name string) (evaluator.FunctionTuple, bool) {
+	for _, f := range s.functions {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return evaluator.FunctionTuple{}, false
+}
+
+// signatureParams returns names of arguments, optional ones are in brackets and variadic one ends with dots
+func signatureParams(f evaluator.FunctionHandler) []string {
+	params := make([]string, 0, len(f.ArgsNames))
+	for i, name := range f.ArgsNames {
+		switch {
+		case f.MaxArguments == 0 && f.MinArguments > 0 && i == len(f.ArgsNames)-1:
+			params = append(params, name+"...")
+		case i >= f.MinArguments:
+			params = append(params, "["+name+"]")
+		default:
+			params = append(params, name)
+		}
+	}
+	return params
+}
+
+func signatureLabel(name string, f evaluator.FunctionHandler) string {
+	return name + "(" + strings.Join(signatureParams(f), ", ") + ")"
+}
+
+func isIdentifierChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
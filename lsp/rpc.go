@@ -0,0 +1,63 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrMissingContentLength = errors.New("message header does not contain Content-Length")
+	ErrInvalidHeader        = errors.New("invalid message header")
+)
+
+// readMessage reads one message with its header, only Content-Length header is used
+func readMessage(r *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		colon := strings.IndexByte(line, ':')
+		if colon < 0 {
+			return nil, fmt.Errorf("%w '%s'", ErrInvalidHeader, line)
+		}
+		if !strings.EqualFold(line[:colon], "Content-Length") {
+			continue
+		}
+		if length, err = strconv.Atoi(strings.TrimSpace(line[colon+1:])); err != nil || length < 0 {
+			return nil, fmt.Errorf("%w '%s'", ErrInvalidHeader, line)
+		}
+	}
+	if length < 0 {
+		return nil, ErrMissingContentLength
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// writeMessage writes message as JSON with Content-Length header
+func writeMessage(w io.Writer, msg *Message) error {
+	msg.JSONRPC = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = w.Write(body)
+	return err
+}
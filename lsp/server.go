@@ -0,0 +1,206 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/arxeiss/go-expression-calculator/evaluator"
+	"github.com/arxeiss/go-expression-calculator/parser"
+)
+
+var (
+	ErrUnknownDocument = errors.New("document is not opened")
+)
+
+// Server speaks Language Server Protocol, every opened document is one program with statements
+// separated by semicolon or new line. Documents are evaluated with NumericEvaluator.
+type Server struct {
+	parser       parser.Parser
+	functionMaps []map[string]evaluator.FunctionHandler
+	functions    []evaluator.FunctionTuple
+	documents    map[string]*document
+	out          io.Writer
+	shutdown     bool
+}
+
+// NewServer creates the server, functions are available in all documents.
+// Parser implementing parser.RecoveringParser reports all syntax errors, otherwise only the first one
+func NewServer(p parser.Parser, functions ...map[string]evaluator.FunctionHandler) (*Server, error) {
+	s := &Server{
+		parser:       p,
+		functionMaps: functions,
+		documents:    make(map[string]*document),
+	}
+	ev, err := s.newEvaluator()
+	if err != nil {
+		return nil, err
+	}
+	s.functions = ev.FunctionList()
+	return s, nil
+}
+
+func (s *Server) newEvaluator() (*evaluator.NumericEvaluator, error) {
+	return evaluator.NewNumericEvaluator(nil, s.functionMaps...)
+}
+
+// Serve reads messages from r and writes responses and notifications into w.
+// It returns when exit notification is received or r is closed
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	s.out = w
+	reader := bufio.NewReader(r)
+	for {
+		body, err := readMessage(reader)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		msg := &Message{}
+		if err := json.Unmarshal(body, msg); err != nil {
+			if err := s.reply(nil, nil, &ResponseError{Code: CodeParseError, Message: err.Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+		if msg.Method == "exit" {
+			return nil
+		}
+		result, respErr := s.handle(msg)
+		// Notifications do not have ID and are never answered
+		if msg.ID == nil {
+			continue
+		}
+		if err := s.reply(msg.ID, result, respErr); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(msg *Message) (interface{}, *ResponseError) {
+	if s.shutdown && msg.ID != nil {
+		return nil, &ResponseError{Code: CodeInvalidRequest, Message: "server is shut down"}
+	}
+	switch msg.Method {
+	case "initialize":
+		return s.initialize(), nil
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		params := &DidOpenTextDocumentParams{}
+		return nil, s.withParams(msg, params, func() error {
+			return s.update(params.TextDocument.URI, params.TextDocument.Text)
+		})
+	case "textDocument/didChange":
+		params := &DidChangeTextDocumentParams{}
+		return nil, s.withParams(msg, params, func() error {
+			if len(params.ContentChanges) == 0 {
+				return nil
+			}
+			// Full synchronization is used, so the last change contains the whole text
+			return s.update(params.TextDocument.URI, params.ContentChanges[len(params.ContentChanges)-1].Text)
+		})
+	case "textDocument/didClose":
+		params := &DidCloseTextDocumentParams{}
+		return nil, s.withParams(msg, params, func() error {
+			delete(s.documents, params.TextDocument.URI)
+			return s.publishDiagnostics(params.TextDocument.URI, []Diagnostic{})
+		})
+	case "textDocument/completion", "textDocument/hover", "textDocument/signatureHelp", "textDocument/definition":
+		return s.handlePosition(msg)
+	}
+	if msg.ID == nil {
+		return nil, nil
+	}
+	return nil, &ResponseError{
+		Code:    CodeMethodNotFound,
+		Message: fmt.Sprintf("method '%s' is not supported", msg.Method),
+	}
+}
+
+// handlePosition handles requests related to the position in the document
+func (s *Server) handlePosition(msg *Message) (interface{}, *ResponseError) {
+	params := &TextDocumentPositionParams{}
+	var result interface{}
+	respErr := s.withParams(msg, params, func() error {
+		doc, ok := s.documents[params.TextDocument.URI]
+		if !ok {
+			return fmt.Errorf("%w '%s'", ErrUnknownDocument, params.TextDocument.URI)
+		}
+		offset := doc.offset(params.Position)
+		switch msg.Method {
+		case "textDocument/completion":
+			result = s.completion(doc, offset)
+		case "textDocument/hover":
+			result = s.hover(doc, offset)
+		case "textDocument/signatureHelp":
+			result = s.signatureHelp(doc, offset)
+		case "textDocument/definition":
+			result = s.definition(doc, params.TextDocument.URI, offset)
+		}
+		return nil
+	})
+	return result, respErr
+}
+
+// withParams decodes params of the message and calls the handler, errors are converted into response error
+func (s *Server) withParams(msg *Message, params interface{}, handler func() error) *ResponseError {
+	if err := json.Unmarshal(msg.Params, params); err != nil {
+		return &ResponseError{Code: CodeInvalidParams, Message: err.Error()}
+	}
+	if err := handler(); err != nil {
+		return &ResponseError{Code: CodeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func (s *Server) initialize() *InitializeResult {
+	return &InitializeResult{
+		Capabilities: ServerCapabilities{
+			TextDocumentSync:      SyncFull,
+			CompletionProvider:    &CompletionOptions{},
+			HoverProvider:         true,
+			SignatureHelpProvider: &SignatureHelpOptions{TriggerCharacters: []string{"(", ","}},
+			DefinitionProvider:    true,
+		},
+		ServerInfo: ServerInfo{Name: "calculator"},
+	}
+}
+
+// update analyzes new text of the document and publishes its diagnostics
+func (s *Server) update(uri, text string) error {
+	doc := s.analyze(text)
+	s.documents[uri] = doc
+	return s.publishDiagnostics(uri, doc.diagnostics)
+}
+
+func (s *Server) publishDiagnostics(uri string, diagnostics []Diagnostic) error {
+	params, err := json.Marshal(&PublishDiagnosticsParams{URI: uri, Diagnostics: diagnostics})
+	if err != nil {
+		return err
+	}
+	return writeMessage(s.out, &Message{Method: "textDocument/publishDiagnostics", Params: params})
+}
+
+func (s *Server) reply(id *json.RawMessage, result interface{}, respErr *ResponseError) error {
+	msg := &Message{ID: id, Error: respErr}
+	if id == nil {
+		// Response to unreadable request must have null ID
+		null := json.RawMessage("null")
+		msg.ID = &null
+	}
+	if respErr == nil {
+		encoded, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		msg.Result = encoded
+	}
+	return writeMessage(s.out, msg)
+}
@@ -0,0 +1,238 @@
+package lsp_test
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/arxeiss/go-expression-calculator/evaluator"
+	"github.com/arxeiss/go-expression-calculator/lsp"
+	"github.com/arxeiss/go-expression-calculator/parser"
+	"github.com/arxeiss/go-expression-calculator/parser/recursivedescent"
+	"github.com/arxeiss/go-expression-calculator/parser/shuntyard"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gstruct"
+)
+
+const (
+	uri = "file:///formulas.calc"
+
+	program = `rate = 0.2
+price = 100
+total = price * (1 + rate)
+max(total, log(price, 10))
+`
+)
+
+func newServer(p parser.Parser, err error) *lsp.Server {
+	Expect(err).To(Succeed())
+	s, err := lsp.NewServer(p, evaluator.MathFunctions(), evaluator.MathFunctionsWithVarArgs())
+	Expect(err).To(Succeed())
+	return s
+}
+
+func diagnostic(startLine, startChar, endLine, endChar int, message string) lsp.Diagnostic {
+	return lsp.Diagnostic{
+		Range: lsp.Range{
+			Start: lsp.Position{Line: startLine, Character: startChar},
+			End:   lsp.Position{Line: endLine, Character: endChar},
+		},
+		Severity: lsp.SeverityError,
+		Source:   "calculator",
+		Message:  message,
+	}
+}
+
+var _ = Describe("Language server", func() {
+	var c *client
+
+	BeforeEach(func() {
+		c = newClient(newServer(recursivedescentParser()))
+	})
+	AfterEach(func() {
+		c.exit()
+	})
+
+	It("Initializes with capabilities", func() {
+		result := lsp.InitializeResult{}
+		Expect(c.call("initialize", map[string]interface{}{"capabilities": map[string]interface{}{}}, &result)).
+			To(BeNil())
+		c.notify("initialized", map[string]interface{}{})
+
+		Expect(result.ServerInfo.Name).To(Equal("calculator"))
+		Expect(result.Capabilities.TextDocumentSync).To(Equal(lsp.SyncFull))
+		Expect(result.Capabilities.HoverProvider).To(BeTrue())
+		Expect(result.Capabilities.DefinitionProvider).To(BeTrue())
+		Expect(result.Capabilities.CompletionProvider).NotTo(BeNil())
+		Expect(result.Capabilities.SignatureHelpProvider.TriggerCharacters).To(ConsistOf("(", ","))
+	})
+
+	It("Publishes diagnostics of changed and closed documents", func() {
+		Expect(c.open(uri, program)).To(Equal(lsp.PublishDiagnosticsParams{URI: uri, Diagnostics: []lsp.Diagnostic{}}))
+
+		c.notify("textDocument/didChange", lsp.DidChangeTextDocumentParams{
+			TextDocument:   lsp.TextDocumentIdentifier{URI: uri},
+			ContentChanges: []lsp.TextDocumentContentChangeEvent{{Text: "a = 1 +\nb = a"}},
+		})
+		Expect(c.diagnostics().Diagnostics).To(ConsistOf(
+			diagnostic(0, 7, 0, 7, "expected number, identifier or left parenthesis"),
+		))
+
+		c.notify("textDocument/didClose", lsp.DidCloseTextDocumentParams{
+			TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+		})
+		Expect(c.diagnostics()).To(Equal(lsp.PublishDiagnosticsParams{URI: uri, Diagnostics: []lsp.Diagnostic{}}))
+
+		err := c.call("textDocument/hover", position(uri, 0, 0), nil)
+		Expect(err).NotTo(BeNil())
+		Expect(err.Code).To(Equal(lsp.CodeInvalidParams))
+		Expect(err.Message).To(ContainSubstring(lsp.ErrUnknownDocument.Error()))
+	})
+
+	It("Offers functions and assigned variables", func() {
+		c.open(uri, program+"pr")
+
+		items := []lsp.CompletionItem{}
+		Expect(c.call("textDocument/completion", position(uri, 4, 2), &items)).To(BeNil())
+		Expect(items).To(Equal([]lsp.CompletionItem{{Label: "price", Kind: lsp.CompletionKindVariable}}))
+
+		Expect(c.call("textDocument/completion", position(uri, 3, 2), &items)).To(BeNil())
+		Expect(items).To(HaveLen(1))
+		Expect(items[0].Label).To(Equal("max"))
+		Expect(items[0].Kind).To(Equal(lsp.CompletionKindFunction))
+		Expect(items[0].Detail).To(Equal("max(a, b...)"))
+		Expect(items[0].Documentation.Value).To(Equal("Returns maximum of provided numbers."))
+
+		Expect(c.call("textDocument/completion", position(uri, 4, 0), &items)).To(BeNil())
+		Expect(items).To(ContainElement(lsp.CompletionItem{Label: "rate", Kind: lsp.CompletionKindVariable}))
+		Expect(items).To(ContainElement(MatchFields(IgnoreExtras, Fields{"Detail": Equal("log(n, base)")})))
+	})
+
+	It("Describes functions and variables on hover", func() {
+		c.open(uri, program)
+
+		hover := &lsp.Hover{}
+		Expect(c.call("textDocument/hover", position(uri, 3, 12), hover)).To(BeNil())
+		Expect(hover.Contents.Value).To(Equal("```\nlog(n, base)\n```\nReturns log of value n with given base."))
+		Expect(*hover.Range).To(Equal(lsp.Range{
+			Start: lsp.Position{Line: 3, Character: 11},
+			End:   lsp.Position{Line: 3, Character: 14},
+		}))
+
+		Expect(c.call("textDocument/hover", position(uri, 2, 3), hover)).To(BeNil())
+		Expect(hover.Contents.Value).To(HavePrefix("```\ntotal = 120\n```"))
+
+		Expect(c.call("textDocument/hover", position(uri, 2, 14), &hover)).To(BeNil())
+		Expect(hover).To(BeNil())
+	})
+
+	DescribeTable("Signature help",
+		func(text string, character int, label string, active int) {
+			c.open(uri, text)
+
+			help := &lsp.SignatureHelp{}
+			Expect(c.call("textDocument/signatureHelp", position(uri, 0, character), &help)).To(BeNil())
+			if label == "" {
+				Expect(help).To(BeNil())
+				return
+			}
+			Expect(help.Signatures).To(HaveLen(1))
+			Expect(help.Signatures[0].Label).To(Equal(label))
+			Expect(help.ActiveParameter).To(Equal(active))
+		},
+		Entry("First argument", "max(", 4, "max(a, b...)", 0),
+		Entry("Nested call", "max(1, log(2, ", 14, "log(n, base)", 1),
+		Entry("Back in outer call", "max(1, log(2, 3), ", 18, "max(a, b...)", 1),
+		Entry("Parentheses inside argument", "log(2, (1 + ", 12, "log(n, base)", 1),
+		Entry("Variadic argument stays last", "max(1, 2, 3, ", 13, "max(a, b...)", 1),
+		Entry("Outside of call", "max(1, 2) + ", 12, "", 0),
+		Entry("Unknown function", "foo(1, ", 7, "", 0),
+		Entry("Previous statement is closed", "max(1; 2 + ", 11, "", 0),
+	)
+
+	It("Goes to definition of assigned variables", func() {
+		c.open(uri, "a = 1\nb = a * 2\na = b\nc = a + d")
+
+		location := &lsp.Location{}
+		Expect(c.call("textDocument/definition", position(uri, 1, 4), location)).To(BeNil())
+		Expect(*location).To(Equal(lsp.Location{URI: uri, Range: lsp.Range{
+			Start: lsp.Position{Line: 0, Character: 0},
+			End:   lsp.Position{Line: 0, Character: 1},
+		}}))
+
+		Expect(c.call("textDocument/definition", position(uri, 3, 5), location)).To(BeNil())
+		Expect(location.Range.Start).To(Equal(lsp.Position{Line: 2, Character: 0}))
+
+		Expect(c.call("textDocument/definition", position(uri, 3, 8), &location)).To(BeNil())
+		Expect(location).To(BeNil())
+	})
+
+	It("Answers unknown requests with error", func() {
+		err := c.call("workspace/symbol", map[string]interface{}{}, nil)
+		Expect(err).NotTo(BeNil())
+		Expect(err.Code).To(Equal(lsp.CodeMethodNotFound))
+
+		c.sendRaw([]byte("{not json"))
+		msg := c.receive(func(msg *lsp.Message) bool { return msg.Error != nil })
+		Expect(msg.Error.Code).To(Equal(lsp.CodeParseError))
+
+		Expect(c.call("shutdown", nil, nil)).To(BeNil())
+		err = c.call("textDocument/hover", position(uri, 0, 0), nil)
+		Expect(err).NotTo(BeNil())
+		Expect(err.Code).To(Equal(lsp.CodeInvalidRequest))
+	})
+})
+
+var _ = DescribeTable("Diagnostics",
+	func(newParser func() (parser.Parser, error), text string, diagnostics ...lsp.Diagnostic) {
+		c := newClient(newServer(newParser()))
+		defer c.exit()
+
+		Expect(c.open(uri, text).Diagnostics).To(Equal(append([]lsp.Diagnostic{}, diagnostics...)))
+	},
+	Entry("Valid program", recursivedescentParser, program),
+	Entry("Empty document", recursivedescentParser, "\n\n"),
+	Entry("Lexer error", recursivedescentParser, "a = 1\nb = 2 $ 3",
+		diagnostic(1, 6, 1, 7, "unexpected character"),
+	),
+	Entry("Lexer error counts characters in UTF-16", recursivedescentParser, "a = 1\nb = 😀",
+		diagnostic(1, 4, 1, 6, "unexpected character"),
+	),
+	Entry("All syntax errors", recursivedescentParser, "a = 1 +\nb = max(1, , 2)\nc = 3",
+		diagnostic(0, 7, 0, 7, "expected number, identifier or left parenthesis"),
+		diagnostic(1, 11, 1, 12, "expected number, identifier or left parenthesis"),
+	),
+	Entry("All syntax errors of shunting yard", shuntyardParser, "max(1, , 2)\n(1 + 2",
+		diagnostic(0, 7, 0, 8, "expected number, identifier or left parenthesis"),
+		diagnostic(1, 0, 1, 1, "cannot find matching right parenthesis"),
+	),
+	Entry("All evaluation errors", recursivedescentParser, "a = 1\nb = a + c\nsqrt(1, 2)\nd = a * 2",
+		diagnostic(1, 8, 1, 9, "undefined variable 'c'"),
+		diagnostic(2, 0, 2, 4, "function 'sqrt' require 1 arguments, got 2"),
+	),
+	Entry("Runaway user defined function", recursivedescentParser, runawayProgram(40),
+		diagnostic(0, 12, 0, 13, "evaluation exceeded the limit of steps"),
+		diagnostic(42, 4, 42, 5, "undefined variable 'a'"),
+	),
+)
+
+func recursivedescentParser() (parser.Parser, error) {
+	return recursivedescent.NewParser(parser.DefaultTokenPriorities())
+}
+
+func shuntyardParser() (parser.Parser, error) {
+	return shuntyard.NewParser(parser.DefaultTokenPriorities())
+}
+
+// runawayProgram defines functions, where each one calls the previous one twice, so the last one evaluates
+// 2^n nodes and the document would be evaluated for hours without limits
+func runawayProgram(n int) string {
+	lines := []string{"f0(x) = x + 1"}
+	for i := 1; i <= n; i++ {
+		lines = append(lines, fmt.Sprintf("f%d(x) = f%d(x) + f%d(x)", i, i-1, i-1))
+	}
+	lines = append(lines, fmt.Sprintf("a = f%d(1)", n), "b = a + c")
+	return strings.Join(lines, "\n")
+}
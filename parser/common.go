@@ -8,3 +8,25 @@ import (
 type Parser interface {
 	Parse(tokenList []*lexer.Token) (ast.Node, error)
 }
+
+// ParseStatements splits tokens into statements and parses each of them with parseStatement.
+// Single statement is returned as it is, more statements are wrapped into ast.BlockNode
+func ParseStatements(
+	tokenList []*lexer.Token,
+	parseStatement func(tokenList []*lexer.Token) (ast.Node, error),
+) (ast.Node, error) {
+	statements := lexer.SplitStatements(tokenList)
+	if len(statements) == 1 {
+		return parseStatement(statements[0])
+	}
+	nodes := make([]ast.Node, 0, len(statements))
+	for _, statement := range statements {
+		node, err := parseStatement(statement)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	// Block starts with the first token of the first statement
+	return ast.NewBlockNode(nodes, firstToken(statements[0])), nil
+}
@@ -0,0 +1,278 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/arxeiss/go-expression-calculator/ast"
+	"github.com/arxeiss/go-expression-calculator/lexer"
+)
+
+type formatter struct {
+	priorities TokenPriorities
+	b          strings.Builder
+}
+
+// Format converts AST back into the expression with the fewest parentheses needed for given priorities.
+// Parsing the output with the same priorities gives the structurally identical AST.
+func Format(node ast.Node, priorities TokenPriorities) string {
+	f := &formatter{priorities: priorities}
+	f.format(node)
+	return f.b.String()
+}
+
+func (f *formatter) format(node ast.Node) {
+	switch n := node.(type) {
+	case *ast.NumericNode:
+		if n.Value() < 0 {
+			// Negative number cannot be written directly, it is always parsed as unary operator
+			f.b.WriteByte('-')
+			f.b.WriteString(strconv.FormatFloat(-n.Value(), 'g', -1, 64))
+		} else {
+			f.b.WriteString(n.Literal())
+		}
+		if n.Imaginary() {
+			f.b.WriteByte('i')
+		}
+	case *ast.VariableNode:
+		f.b.WriteString(n.Name())
+	case *ast.UnaryNode:
+		f.b.WriteString(n.Operator().String())
+		f.formatOperand(n.Next(), func(childPrecedence TokenPrecedence) bool {
+			// Same precedence is wrapped as well, so the operand is never merged with following operators
+			return childPrecedence <= f.precedence(n)
+		})
+	case *ast.PostfixNode:
+		f.formatOperand(n.Prev(), func(childPrecedence TokenPrecedence) bool {
+			// Same precedence is not wrapped, as postfix operators are applied from left to right, like 3!%
+			return childPrecedence < f.precedence(n)
+		})
+		f.b.WriteString(n.Operator().String())
+	case *ast.BinaryNode:
+		f.formatBinary(n)
+	case *ast.ConditionalNode:
+		f.formatConditional(n)
+	case *ast.AssignNode:
+		f.b.WriteString(n.Left().Name())
+		f.b.WriteString(" = ")
+		f.format(n.Right())
+	case *ast.FunctionNode:
+		f.b.WriteString(n.Name())
+		f.b.WriteByte('(')
+		f.formatList(n.Params(), ", ")
+		f.b.WriteByte(')')
+	case *ast.FunctionDefNode:
+		f.b.WriteString(n.Name())
+		f.b.WriteByte('(')
+		f.b.WriteString(strings.Join(n.ParamNames(), ", "))
+		f.b.WriteString(") = ")
+		f.format(n.Body())
+	case *ast.BlockNode:
+		f.formatList(n.Statements(), "; ")
+	}
+}
+
+func (f *formatter) formatList(nodes []ast.Node, separator string) {
+	for i, n := range nodes {
+		if i > 0 {
+			f.b.WriteString(separator)
+		}
+		f.format(n)
+	}
+}
+
+func (f *formatter) formatBinary(n *ast.BinaryNode) {
+	precedence := f.precedence(n)
+	associativity := f.priorities.GetAssociativity(operatorTokenType(n))
+
+	// Left operand with same precedence must be wrapped only for right associative operators, like (a^b)^c
+	writeLeft := func(f *formatter) {
+		f.formatOperand(n.Left(), func(childPrecedence TokenPrecedence) bool {
+			return childPrecedence < precedence || childPrecedence == precedence && associativity == RightAssociativity
+		})
+	}
+	if n.Implicit() {
+		f.formatImplicit(n, writeLeft)
+		return
+	}
+	writeLeft(f)
+	f.b.WriteByte(' ')
+	f.b.WriteString(n.Operator().String())
+	f.b.WriteByte(' ')
+	// Unary operator right after binary one is always parsed first, so it never needs parentheses
+	if isUnary(n.Right()) {
+		f.format(n.Right())
+		return
+	}
+	f.formatOperand(n.Right(), func(childPrecedence TokenPrecedence) bool {
+		return childPrecedence < precedence || childPrecedence == precedence && associativity == LeftAssociativity
+	})
+}
+
+// formatImplicit writes implicit multiplication, like 2 x, 3(a + b) or (a)(b). Multiplication is inserted only
+// after a number or right parenthesis, so any other left operand is wrapped, like (x)(y) or (10%)(y),
+// otherwise it would be read as function call or modulus. Number and identifier are separated by space,
+// so they are not read as one number like 2e5. Any other right operand is wrapped, so it is not read as function call
+func (f *formatter) formatImplicit(n *ast.BinaryNode, writeLeft func(f *formatter)) {
+	left := &formatter{priorities: f.priorities}
+	writeLeft(left)
+	switch lastTokenType(left.b.String()) {
+	case lexer.Number, lexer.RPar:
+		f.b.WriteString(left.b.String())
+	default:
+		f.b.WriteByte('(')
+		f.b.WriteString(left.b.String())
+		f.b.WriteByte(')')
+	}
+
+	switch n.Right().(type) {
+	case *ast.VariableNode, *ast.FunctionNode:
+		if _, ok := n.Left().(*ast.NumericNode); ok {
+			f.b.WriteByte(' ')
+			f.format(n.Right())
+			return
+		}
+	}
+	f.b.WriteByte('(')
+	f.format(n.Right())
+	f.b.WriteByte(')')
+}
+
+// lastTokenType returns type of the last token of the formatted expression
+func lastTokenType(expr string) lexer.TokenType {
+	tokens, err := lexer.NewLexer(expr).Tokenize()
+	if err != nil || len(tokens) < 2 {
+		return lexer.Invalid
+	}
+	// The last token is always EOL
+	return tokens[len(tokens)-2].Type()
+}
+
+// formatConditional writes cond ? a : b, the middle branch is delimited by both operators so it is never wrapped
+func (f *formatter) formatConditional(n *ast.ConditionalNode) {
+	precedence := f.precedence(n)
+	// Operator is right associative, so nested conditional is wrapped only in the condition
+	f.formatOperand(n.Condition(), func(childPrecedence TokenPrecedence) bool {
+		return childPrecedence <= precedence
+	})
+	f.b.WriteString(" ? ")
+	f.format(n.Then())
+	f.b.WriteString(" : ")
+	f.formatOperand(n.Else(), func(childPrecedence TokenPrecedence) bool {
+		return childPrecedence < precedence
+	})
+}
+
+// formatOperand wraps the operand into parentheses when needsParentheses returns true for its precedence.
+// Numbers, variables and functions have no precedence and are never wrapped.
+func (f *formatter) formatOperand(node ast.Node, needsParentheses func(childPrecedence TokenPrecedence) bool) {
+	childPrecedence, isOperator := f.operandPrecedence(node)
+	if !isOperator || !needsParentheses(childPrecedence) {
+		f.format(node)
+		return
+	}
+	f.b.WriteByte('(')
+	f.format(node)
+	f.b.WriteByte(')')
+}
+
+// operandPrecedence returns precedence of the node, if the node is written as an operator
+func (f *formatter) operandPrecedence(node ast.Node) (TokenPrecedence, bool) {
+	switch n := node.(type) {
+	case *ast.UnaryNode, *ast.PostfixNode, *ast.BinaryNode, *ast.ConditionalNode:
+		return f.precedence(n), true
+	case *ast.NumericNode:
+		if isUnary(n) {
+			return f.priorities.GetPrecedence(lexer.UnarySubstraction), true
+		}
+	case *ast.AssignNode, *ast.FunctionDefNode, *ast.BlockNode:
+		return 0, true
+	}
+	return 0, false
+}
+
+func (f *formatter) precedence(node ast.Node) TokenPrecedence {
+	switch n := node.(type) {
+	case *ast.UnaryNode:
+		switch n.Operator() {
+		case ast.Addition:
+			return f.priorities.GetPrecedence(lexer.UnaryAddition)
+		case ast.Not:
+			return f.priorities.GetPrecedence(lexer.Not)
+		}
+		return f.priorities.GetPrecedence(lexer.UnarySubstraction)
+	case *ast.PostfixNode:
+		if n.Operator() == ast.Percent {
+			return f.priorities.GetPrecedence(lexer.Percent)
+		}
+		return f.priorities.GetPrecedence(lexer.Factorial)
+	case *ast.BinaryNode:
+		return f.priorities.GetPrecedence(operatorTokenType(n))
+	case *ast.ConditionalNode:
+		return f.priorities.GetPrecedence(lexer.Question)
+	}
+	return 0
+}
+
+// isUnary checks if the node is written with unary operator, negative numbers included
+func isUnary(node ast.Node) bool {
+	switch n := node.(type) {
+	case *ast.UnaryNode:
+		return true
+	case *ast.NumericNode:
+		return n.Value() < 0
+	}
+	return false
+}
+
+// operatorTokenType returns token type of the operator, which sets its precedence and associativity
+func operatorTokenType(n *ast.BinaryNode) lexer.TokenType {
+	if n.Implicit() {
+		return lexer.ImplicitMultiplication
+	}
+	return binaryTokenType(n.Operator())
+}
+
+func binaryTokenType(op ast.Operation) lexer.TokenType {
+	switch op {
+	case ast.Addition:
+		return lexer.Addition
+	case ast.Substraction:
+		return lexer.Substraction
+	case ast.Multiplication:
+		return lexer.Multiplication
+	case ast.Division:
+		return lexer.Division
+	case ast.FloorDiv:
+		return lexer.FloorDiv
+	case ast.Modulus:
+		return lexer.Modulus
+	case ast.Exponent:
+		return lexer.Exponent
+	case ast.Assign:
+		return lexer.Equal
+	}
+	return comparisonTokenType(op)
+}
+
+func comparisonTokenType(op ast.Operation) lexer.TokenType {
+	switch op {
+	case ast.Less:
+		return lexer.Less
+	case ast.LessOrEqual:
+		return lexer.LessOrEqual
+	case ast.Greater:
+		return lexer.Greater
+	case ast.GreaterOrEqual:
+		return lexer.GreaterOrEqual
+	case ast.IsEqual:
+		return lexer.IsEqual
+	case ast.NotEqual:
+		return lexer.NotEqual
+	case ast.And:
+		return lexer.And
+	case ast.Or:
+		return lexer.Or
+	}
+	return lexer.EOL
+}
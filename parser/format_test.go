@@ -0,0 +1,240 @@
+package parser_test
+
+import (
+	"math/rand"
+	"strconv"
+
+	"github.com/arxeiss/go-expression-calculator/ast"
+	. "github.com/arxeiss/go-expression-calculator/ast/astutils"
+	"github.com/arxeiss/go-expression-calculator/lexer"
+	"github.com/arxeiss/go-expression-calculator/parser"
+	"github.com/arxeiss/go-expression-calculator/parser/pratt"
+	"github.com/arxeiss/go-expression-calculator/parser/recursivedescent"
+	"github.com/arxeiss/go-expression-calculator/parser/shuntyard"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+type parserConstructor func(priorities parser.TokenPriorities) (parser.Parser, error)
+
+var parsers = map[string]parserConstructor{
+	"shuntyard":        shuntyard.NewParser,
+	"recursivedescent": recursivedescent.NewParser,
+	"pratt":            pratt.NewParser,
+}
+
+// customPriorities swap addition with multiplication and switch associativity of them and exponent.
+// Logical operators are swapped as well, conditional operator is between them
+// and comparisons are between addition and multiplication. Percent binds weaker than unary operators.
+func customPriorities() parser.TokenPriorities {
+	return parser.TokenPriorities{
+		lexer.Equal:             parser.TokenMeta{Precedence: 10, Associativity: parser.RightAssociativity},
+		lexer.And:               parser.TokenMeta{Precedence: 12},
+		lexer.Question:          parser.TokenMeta{Precedence: 13, Associativity: parser.RightAssociativity},
+		lexer.Or:                parser.TokenMeta{Precedence: 14, Associativity: parser.RightAssociativity},
+		lexer.Less:              parser.TokenMeta{Precedence: 30},
+		lexer.LessOrEqual:       parser.TokenMeta{Precedence: 30},
+		lexer.Greater:           parser.TokenMeta{Precedence: 30},
+		lexer.GreaterOrEqual:    parser.TokenMeta{Precedence: 30},
+		lexer.IsEqual:           parser.TokenMeta{Precedence: 30},
+		lexer.NotEqual:          parser.TokenMeta{Precedence: 30},
+		lexer.Multiplication:    parser.TokenMeta{Precedence: 20},
+		lexer.Division:          parser.TokenMeta{Precedence: 20},
+		lexer.FloorDiv:          parser.TokenMeta{Precedence: 20},
+		lexer.Modulus:           parser.TokenMeta{Precedence: 20},
+		lexer.Addition:          parser.TokenMeta{Precedence: 40, Associativity: parser.RightAssociativity},
+		lexer.Substraction:      parser.TokenMeta{Precedence: 40, Associativity: parser.RightAssociativity},
+		lexer.UnaryAddition:     parser.TokenMeta{Precedence: 60},
+		lexer.UnarySubstraction: parser.TokenMeta{Precedence: 60},
+		lexer.Not:               parser.TokenMeta{Precedence: 60},
+		lexer.Exponent:          parser.TokenMeta{Precedence: 80},
+		lexer.Percent:           parser.TokenMeta{Precedence: 50},
+		lexer.Factorial:         parser.TokenMeta{Precedence: 70},
+	}
+}
+
+func parseWith(constructor parserConstructor, priorities parser.TokenPriorities, expr string) ast.Node {
+	tokens, err := lexer.NewLexer(expr).Tokenize()
+	Expect(err).To(Succeed(), expr)
+	p, err := constructor(priorities)
+	Expect(err).To(Succeed())
+	rootNode, err := p.Parse(tokens)
+	Expect(err).To(Succeed(), expr)
+	return rootNode
+}
+
+// randomNode generates random expression tree with non negative numbers, as negative ones are parsed as unary nodes
+func randomNode(r *rand.Rand, depth int) ast.Node {
+	if depth == 0 || r.Intn(4) == 0 {
+		switch r.Intn(3) {
+		case 0:
+			return ast.NewNumericNode(float64(r.Intn(100))/4, nil)
+		case 1:
+			return ast.NewVariableNode(string(rune('a'+r.Intn(5))), nil)
+		}
+		return ast.NewFunctionNode("f"+strconv.Itoa(r.Intn(3)), nil, nil)
+	}
+	switch r.Intn(8) {
+	case 0:
+		ops := []ast.Operation{ast.Addition, ast.Substraction, ast.Not}
+		return ast.NewUnaryNode(ops[r.Intn(len(ops))], randomNode(r, depth-1), nil)
+	case 3:
+		ops := []ast.Operation{ast.Factorial, ast.Percent}
+		return ast.NewPostfixNode(ops[r.Intn(len(ops))], randomNode(r, depth-1), nil)
+	case 1:
+		params := make([]ast.Node, r.Intn(3))
+		for i := range params {
+			params[i] = randomNode(r, depth-1)
+		}
+		return ast.NewFunctionNode("max", params, nil)
+	case 2:
+		return ast.NewConditionalNode(randomNode(r, depth-1), randomNode(r, depth-1), randomNode(r, depth-1), nil)
+	}
+	ops := []ast.Operation{
+		ast.Addition, ast.Substraction, ast.Multiplication, ast.Division, ast.FloorDiv, ast.Modulus, ast.Exponent,
+		ast.Less, ast.LessOrEqual, ast.Greater, ast.GreaterOrEqual, ast.IsEqual, ast.NotEqual, ast.And, ast.Or,
+	}
+	// Percent right after + or - is always relative in parsed trees, so the tree is built the same way
+	return parser.NewBinaryNode(ops[r.Intn(len(ops))], randomNode(r, depth-1), randomNode(r, depth-1), nil)
+}
+
+var _ = Describe("Format", func() {
+	DescribeTable("Minimal parentheses",
+		func(expr, expected string) {
+			for name, constructor := range parsers {
+				rootNode := parseWith(constructor, parser.DefaultTokenPriorities(), expr)
+				Expect(parser.Format(rootNode, parser.DefaultTokenPriorities())).To(Equal(expected), name)
+			}
+		},
+		Entry("Redundant parentheses", "((1 + (a)))", "1 + a"),
+		Entry("Precedence", "(a + b) * c - (d * e)", "(a + b) * c - d * e"),
+		Entry("Left associativity", "a - (b - c) - (d - e)", "a - (b - c) - (d - e)"),
+		Entry("Left associativity of same level", "(a - b) + (c / d) * e / (f * g)", "a - b + c / d * e / (f * g)"),
+		Entry("Right associativity", "(a ^ b) ^ (c ^ d)", "(a ^ b) ^ c ^ d"),
+		Entry("Unary before exponent", "(-a) ^ b + -(a ^ b)", "(-a) ^ b + -a ^ b"),
+		Entry("Unary after binary operator", "a ^ (-b) * (-c)", "a ^ -b * -c"),
+		Entry("Unary followed by higher precedence", "a + ((-b) * c) - (-d ^ e)", "a + -b * c - -d ^ e"),
+		Entry("Unary with binary operand", "-(a * b) - +(c + 2)", "-(a * b) - +(c + 2)"),
+		Entry("Double unary", "-(-a)", "-(-a)"),
+		Entry("Functions", "max((1 + 2), (a), pi()) * (sin(x))", "max(1 + 2, a, pi()) * sin(x)"),
+		Entry("Literals are kept", "1.50 + 2e3 * 0.1", "1.50 + 2e3 * 0.1"),
+		Entry("Imaginary number", "(2i) * 3", "2i * 3"),
+		Entry("Bases and separators are kept", "(0xFF) * 0b1010 + 1_000", "0xFF * 0b1010 + 1_000"),
+		Entry("Comparisons", "((a + 1) < b) == (c >= (d * 2))", "a + 1 < b == c >= d * 2"),
+		Entry("Chained comparisons", "(a < b) < c != (d == e)", "a < b < c != (d == e)"),
+		Entry("Logical operators", "((a && b) || (!c && (d || e)))", "a && b || !c && (d || e)"),
+		Entry("Logical negation", "!(a < b) && !(!c)", "!(a < b) && !(!c)"),
+		Entry("Conditional", "(a < b) ? (c + 1) : (d || e)", "a < b ? c + 1 : d || e"),
+		Entry("Nested conditional", "(a ? b : c) ? (d ? e : f) : (g ? h : i)", "(a ? b : c) ? d ? e : f : g ? h : i"),
+		Entry("Conditional as operand", "-(a ? b : c) * (d ? e : f)", "-(a ? b : c) * (d ? e : f)"),
+		Entry("Postfix operators", "-(a!) + (2 ^ (b!)) * (c%)", "-a! + 2 ^ b! * c%"),
+		Entry("Postfix operator of binary operand", "(a + b)! * ((c!)%)", "(a + b)! * c!%"),
+		Entry("Modulus of negative number", "a % (-b) - (a % (!b))", "a % -b - a % !b"),
+		Entry("Percent followed by sign", "((a%) - b) + ((c!%)!)", "a% - b + c!%!"),
+	)
+
+	DescribeTable("Assignments and definitions",
+		func(expr, expected string) {
+			rootNode := parseWith(recursivedescent.NewParser, parser.DefaultTokenPriorities(), expr)
+			Expect(parser.Format(rootNode, parser.DefaultTokenPriorities())).To(Equal(expected))
+		},
+		Entry("Assignment", "x = (a + (b * c))", "x = a + b * c"),
+		Entry("Function definition", "f(x,y)=((x)^2+y)", "f(x, y) = x ^ 2 + y"),
+		Entry("Statements", "x = (1 + 2);\n\ny = (x * 2)\n", "x = 1 + 2; y = x * 2"),
+	)
+
+	DescribeTable("Custom priorities",
+		func(expr, expected string) {
+			for name, constructor := range parsers {
+				rootNode := parseWith(constructor, customPriorities(), expr)
+				Expect(parser.Format(rootNode, customPriorities())).To(Equal(expected), name)
+			}
+		},
+		Entry("Addition before multiplication", "(a + b) * (c - d)", "a + b * c - d"),
+		Entry("Wrapped multiplication", "a + (b * c)", "a + (b * c)"),
+		Entry("Right associative substraction", "(a - b) - c + d", "(a - b) - c + d"),
+		Entry("Left associative exponent", "(a ^ b) ^ (c ^ d)", "a ^ b ^ (c ^ d)"),
+		Entry("Swapped logical operators", "(a || b) && (c || d)", "a || b && c || d"),
+		Entry("Comparison between addition and multiplication", "(a + b) < (c * d)", "a + b < (c * d)"),
+		Entry("Conditional between logical operators", "(a && b) ? (c && d) : (e && f) || g",
+			"(a && b) ? c && d : (e && f) || g"),
+		Entry("Percent weaker than unary operator", "-(a%) + (-b)%", "-(a%) + -b%"),
+		Entry("Postfix operators weaker than exponent", "(a ^ b)% * a ^ (b!)", "a ^ b% * a ^ (b!)"),
+	)
+
+	It("Negative numbers", func() {
+		node := ast.NewBinaryNode(ast.Exponent,
+			ast.NewNumericNode(-2, nil),
+			ast.NewBinaryNode(ast.Multiplication, ast.NewNumericNode(3, nil), ast.NewNumericNode(-0.5, nil), nil),
+			nil,
+		)
+		Expect(parser.Format(node, parser.DefaultTokenPriorities())).To(Equal("(-2) ^ (3 * -0.5)"))
+	})
+
+	// Property based test, random tree must be formatted and parsed back into the same structure
+	for _, prioritiesName := range []string{"default", "custom"} {
+		priorities := parser.DefaultTokenPriorities()
+		if prioritiesName == "custom" {
+			priorities = customPriorities()
+		}
+		for name, constructor := range parsers {
+			priorities, name, constructor := priorities, name, constructor
+			It("Round trip of random trees with "+prioritiesName+" priorities and "+name+" parser", func() {
+				r := rand.New(rand.NewSource(42))
+				for i := 0; i < 500; i++ {
+					original := randomNode(r, 5)
+					formatted := parser.Format(original, priorities)
+					parsed := parseWith(constructor, priorities, formatted)
+					Expect(parsed).To(MatchTree(original), "%s", formatted)
+				}
+			})
+		}
+	}
+})
+
+var _ = DescribeTable("Format implicit multiplication",
+	func(expr string, precedence parser.TokenPrecedence, expected string) {
+		priorities := parser.DefaultTokenPriorities()
+		priorities[lexer.ImplicitMultiplication] = parser.TokenMeta{Precedence: precedence}
+		implicit := parser.ImplicitMultiplication{
+			Enabled:    true,
+			IsFunction: func(name string) bool { return name == "sin" },
+		}
+		// Formatted expression does not depend on known functions, every identifier can be a function
+		anyFunction := parser.ImplicitMultiplication{Enabled: true}
+		for name, constructor := range parsers {
+			p, err := constructor(priorities)
+			Expect(err).To(Succeed())
+			p.(parser.ImplicitMultiplicationParser).SetImplicitMultiplication(implicit)
+
+			tokens, err := lexer.NewLexer(expr).Tokenize()
+			Expect(err).To(Succeed())
+			original, err := p.Parse(tokens)
+			Expect(err).To(Succeed(), name)
+			Expect(parser.Format(original, priorities)).To(Equal(expected), name)
+
+			p.(parser.ImplicitMultiplicationParser).SetImplicitMultiplication(anyFunction)
+			tokens, err = lexer.NewLexer(expected).Tokenize()
+			Expect(err).To(Succeed())
+			parsed, err := p.Parse(tokens)
+			Expect(err).To(Succeed(), name)
+			Expect(parsed).To(MatchTree(original), name)
+		}
+	},
+	Entry("Number and identifier are separated", "2x", parser.TokenPrecedence(40), "2 x"),
+	Entry("Number and function", "2sin(x)", parser.TokenPrecedence(40), "2 sin(x)"),
+	Entry("Number and parenthesis", "3 (a + b)", parser.TokenPrecedence(40), "3(a + b)"),
+	Entry("Parentheses", "(a + 1)(b - 1)", parser.TokenPrecedence(40), "(a + 1)(b - 1)"),
+	Entry("Identifier and parenthesis", "x(y + 1)", parser.TokenPrecedence(40), "(x)(y + 1)"),
+	Entry("Parentheses with identifiers", "(x)(y)", parser.TokenPrecedence(40), "(x)(y)"),
+	Entry("Implicit multiplication followed by parenthesis", "2x(y)", parser.TokenPrecedence(40), "(2 x)(y)"),
+	Entry("Postfix operator followed by parenthesis", "(a!)(b) + (10%)(c)", parser.TokenPrecedence(40),
+		"(a!)(b) + (10%)(c)"),
+	Entry("Number in exponent", "2e5 e5", parser.TokenPrecedence(40), "2e5 e5"),
+	Entry("Same precedence as multiplication", "1 / 2x", parser.TokenPrecedence(40), "1 / 2(x)"),
+	Entry("Higher precedence than multiplication", "1 / 2x", parser.TokenPrecedence(50), "1 / 2 x"),
+	Entry("Lower precedence than multiplication", "2x * 3", parser.TokenPrecedence(30), "2(x * 3)"),
+	Entry("Nested implicit multiplication", "2(3x)", parser.TokenPrecedence(40), "2(3 x)"),
+)
@@ -0,0 +1,88 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/arxeiss/go-expression-calculator/lexer"
+)
+
+// ImplicitMultiplication configures multiplication inserted between adjacent operands, which is disabled by default.
+// It is inserted between number and identifier like 2x, number and parenthesis like 3(a + b),
+// two parentheses like (a)(b), and identifier and parenthesis like x(y + 1) when the identifier is not a function.
+// Precedence and associativity are set by lexer.ImplicitMultiplication in TokenPriorities
+type ImplicitMultiplication struct {
+	Enabled bool
+	// IsFunction tells if the identifier followed by left parenthesis is function call.
+	// Functions defined in the parsed input are always known. When nil, every identifier is function
+	IsFunction func(name string) bool
+}
+
+// ImplicitMultiplicationParser can insert multiplication between adjacent operands, see ImplicitMultiplication
+type ImplicitMultiplicationParser interface {
+	Parser
+	SetImplicitMultiplication(options ImplicitMultiplication)
+}
+
+// Insert returns tokens with lexer.ImplicitMultiplication tokens between adjacent operands.
+// Tokens of all statements must be passed together, so functions defined by one statement are known in others
+func (o ImplicitMultiplication) Insert(tokenList []*lexer.Token) []*lexer.Token {
+	if !o.Enabled {
+		return tokenList
+	}
+	defined := definedFunctions(tokenList)
+	result := make([]*lexer.Token, 0, len(tokenList))
+	var previous *lexer.Token
+	for _, t := range tokenList {
+		if t.Type() == lexer.Whitespace {
+			result = append(result, t)
+			continue
+		}
+		if previous != nil && o.between(previous, t, defined) {
+			result = append(result, lexer.ImplicitMultiplicationToken(t))
+		}
+		result = append(result, t)
+		previous = t
+	}
+	return result
+}
+
+// between checks if multiplication is written between previous and next token
+func (o ImplicitMultiplication) between(previous, next *lexer.Token, defined map[string]bool) bool {
+	switch previous.Type() {
+	case lexer.Number:
+		return next.Type() == lexer.Identifier || next.Type() == lexer.LPar
+	case lexer.RPar:
+		return next.Type() == lexer.LPar
+	case lexer.Identifier:
+		name := previous.Identifier()
+		return next.Type() == lexer.LPar && o.IsFunction != nil &&
+			!defined[strings.ToLower(name)] && !o.IsFunction(name)
+	}
+	return false
+}
+
+// definedFunctions returns lower case names of functions defined by statements like `f(x, y) = x * y`
+func definedFunctions(tokenList []*lexer.Token) map[string]bool {
+	defined := make(map[string]bool)
+	for _, statement := range lexer.SplitStatements(tokenList) {
+		tokens := make([]*lexer.Token, 0, len(statement))
+		for _, t := range statement {
+			if t.Type() != lexer.Whitespace {
+				tokens = append(tokens, t)
+			}
+		}
+		if len(tokens) < 2 || tokens[0].Type() != lexer.Identifier || tokens[1].Type() != lexer.LPar {
+			continue
+		}
+		// Parameters are closed by the first right parenthesis, the equal sign must follow it
+		for i := 2; i < len(tokens)-1; i++ {
+			if tokens[i].Type() == lexer.RPar {
+				if tokens[i+1].Type() == lexer.Equal {
+					defined[strings.ToLower(tokens[0].Identifier())] = true
+				}
+				break
+			}
+		}
+	}
+	return defined
+}
@@ -0,0 +1,63 @@
+package parser
+
+import (
+	"errors"
+
+	"github.com/arxeiss/go-expression-calculator/ast"
+)
+
+var (
+	ErrDepthLimit = errors.New("expression exceeded the limit of depth")
+	ErrNodeLimit  = errors.New("expression exceeded the limit of nodes")
+)
+
+// Limits restricts size of parsed expressions, so untrusted input cannot exhaust the stack or memory.
+// Zero value of each field means no limit
+type Limits struct {
+	// Depth is maximal depth of AST of each statement and maximal nesting of parentheses
+	Depth int
+	// Nodes is maximal number of nodes of all statements
+	Nodes int
+}
+
+// LimitedParser can reject expressions exceeding the limits, see Limits
+type LimitedParser interface {
+	Parser
+	SetLimits(limits Limits)
+}
+
+// Check walks the AST and returns error at the first node exceeding the limits.
+// Statements of ast.BlockNode are checked separately, so the block itself does not add to the depth
+func (l Limits) Check(rootNode ast.Node) error {
+	if l.Depth <= 0 && l.Nodes <= 0 {
+		return nil
+	}
+	type item struct {
+		node  ast.Node
+		depth int
+	}
+	// Walk with own stack, the depth of AST is not known yet
+	stack := []item{{node: rootNode, depth: 1}}
+	if block, ok := rootNode.(*ast.BlockNode); ok {
+		stack = stack[:0]
+		for i := len(block.Statements()) - 1; i >= 0; i-- {
+			stack = append(stack, item{node: block.Statements()[i], depth: 1})
+		}
+	}
+	// Nodes are visited in the order they are written, so the error points to the first node over the limit
+	for nodes := 1; len(stack) > 0; nodes++ {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if l.Nodes > 0 && nodes > l.Nodes {
+			return ParseError(current.node.GetToken(), ErrNodeLimit)
+		}
+		if l.Depth > 0 && current.depth > l.Depth {
+			return ParseError(current.node.GetToken(), ErrDepthLimit)
+		}
+		children := ast.Children(current.node)
+		for i := len(children) - 1; i >= 0; i-- {
+			stack = append(stack, item{node: children[i], depth: current.depth + 1})
+		}
+	}
+	return nil
+}
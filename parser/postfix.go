@@ -0,0 +1,40 @@
+package parser
+
+import (
+	"github.com/arxeiss/go-expression-calculator/ast"
+	"github.com/arxeiss/go-expression-calculator/lexer"
+)
+
+// NewBinaryNode creates binary node of the parsed operator. Percent written right after + or - is taken
+// from the left operand, see ast.NewRelativePercentNode
+func NewBinaryNode(operator ast.Operation, left, right ast.Node, token *lexer.Token) *ast.BinaryNode {
+	if p, ok := right.(*ast.PostfixNode); ok && p.Operator() == ast.Percent {
+		return ast.NewRelativePercentNode(operator, left, right, token)
+	}
+	return ast.NewBinaryNode(operator, left, right, token)
+}
+
+// PostfixType returns type of the postfix operator, when the token written right after an operand is postfix.
+// Previous token is the last one of the operand. Postfix operator is never followed by number, identifier
+// or parenthesis, so 5! - 3 is factorial and 10 % 3 is modulus. When sign or negation follows,
+// percent must be written right after the operand, so 100 + 10% - 5 is 105, but 10 % -5 keeps modulus
+func PostfixType(prev, token, next *lexer.Token) (lexer.TokenType, bool) {
+	postfix := token.Clone()
+	if postfix.ChangeToPostfix() != nil {
+		return token.Type(), false
+	}
+	switch next.Type() {
+	case lexer.Number, lexer.Identifier, lexer.LPar, lexer.Invalid:
+		return token.Type(), false
+	case lexer.Addition, lexer.Substraction, lexer.Not:
+		if token.Type() == lexer.Modulus && !WrittenTogether(prev, token) {
+			return token.Type(), false
+		}
+	}
+	return postfix.Type(), true
+}
+
+// WrittenTogether checks if the token follows the previous one without any whitespace, like % in 10%
+func WrittenTogether(prev, token *lexer.Token) bool {
+	return prev != nil && prev.EndPosition() == token.StartPosition()
+}
@@ -0,0 +1,402 @@
+package pratt
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/arxeiss/go-expression-calculator/ast"
+	"github.com/arxeiss/go-expression-calculator/lexer"
+	"github.com/arxeiss/go-expression-calculator/parser"
+)
+
+var (
+	ErrEmptyInput      = errors.New("there are no tokens to parse")
+	ErrExpectedOperand = errors.New("expected number, identifier or left parenthesis")
+	ErrExpectedEOL     = errors.New("last token is expected to be the end of input")
+	ErrUnexpectedToken = errors.New("unexpected token")
+	ErrDuplicateParam  = errors.New("duplicate parameter name in function definition")
+	ErrInvalidAssign   = errors.New("only variable can be assigned")
+)
+
+// PrefixParselet parses expression starting with the token, like number, unary operator or parenthesis.
+// The token is already consumed
+type PrefixParselet func(s *State, token *lexer.Token) (ast.Node, error)
+
+// InfixParselet parses the operator token between the left operand and the rest of the expression.
+// The token is already consumed
+type InfixParselet func(s *State, left ast.Node, token *lexer.Token) (ast.Node, error)
+
+// PostfixParselet parses the operator token after the operand. The token is already consumed
+type PostfixParselet func(s *State, left ast.Node, token *lexer.Token) (ast.Node, error)
+
+// Parser is top-down operator precedence parser, also known as Pratt parser.
+// Each token type has own parselets and the precedence of infix and postfix operators is taken from TokenPriorities
+type Parser struct {
+	priorities parser.TokenPriorities
+	limits     parser.Limits
+	implicit   parser.ImplicitMultiplication
+	prefix     map[lexer.TokenType]PrefixParselet
+	infix      map[lexer.TokenType]InfixParselet
+	postfix    map[lexer.TokenType]PostfixParselet
+}
+
+var _ parser.RecoveringParser = &Parser{}
+var _ parser.LimitedParser = &Parser{}
+var _ parser.ImplicitMultiplicationParser = &Parser{}
+
+// NewParser creates parser with parselets for all operators of the lexer
+func NewParser(priorities parser.TokenPriorities) (parser.Parser, error) {
+	if err := priorities.Normalize(); err != nil {
+		return nil, err
+	}
+	p := &Parser{
+		priorities: priorities,
+		prefix:     make(map[lexer.TokenType]PrefixParselet),
+		infix:      make(map[lexer.TokenType]InfixParselet),
+		postfix:    make(map[lexer.TokenType]PostfixParselet),
+	}
+	p.registerDefaults()
+	return p, nil
+}
+
+// RegisterPrefix sets parselet for the token at the beginning of the operand, it replaces the existing one
+func (p *Parser) RegisterPrefix(tokenType lexer.TokenType, parselet PrefixParselet) {
+	p.prefix[tokenType] = parselet
+}
+
+// RegisterInfix sets parselet for the binary operator, it replaces the existing one.
+// The operator must have precedence in TokenPriorities, otherwise it ends the expression
+func (p *Parser) RegisterInfix(tokenType lexer.TokenType, parselet InfixParselet) {
+	p.infix[tokenType] = parselet
+}
+
+// RegisterPostfix sets parselet for the operator after the operand, it replaces the existing one.
+// Postfix parselet is used only if the following token cannot start an operand, so `3! * 2` is factorial
+// and `a ! b` is an error. Tokens, which can continue as operators, like - in `5! - 3`, do not start an operand.
+// Token with infix parselet as well is followed by unary operator only when it is not written right after
+// the operand, so `10% - 5` is percent and `10 % -5` is modulus
+func (p *Parser) RegisterPostfix(tokenType lexer.TokenType, parselet PostfixParselet) {
+	p.postfix[tokenType] = parselet
+}
+
+// SetLimits restricts size of parsed expressions, see parser.Limits.
+// The depth is checked also during parsing, so deeply nested input does not exhaust the stack
+func (p *Parser) SetLimits(limits parser.Limits) {
+	p.limits = limits
+}
+
+// SetImplicitMultiplication enables or disables multiplication between adjacent operands,
+// see parser.ImplicitMultiplication. Parselet of lexer.ImplicitMultiplication is registered only when it is enabled
+func (p *Parser) SetImplicitMultiplication(options parser.ImplicitMultiplication) {
+	p.implicit = options
+	delete(p.infix, lexer.ImplicitMultiplication)
+	if options.Enabled {
+		p.RegisterInfix(lexer.ImplicitMultiplication, parseBinary)
+	}
+}
+
+// Parse parses all statements of the input, see parser.ParseStatements
+func (p *Parser) Parse(tokenList []*lexer.Token) (ast.Node, error) {
+	node, err := parser.ParseStatements(p.implicit.Insert(tokenList), p.parseStatement)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.limits.Check(node); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// ParseWithRecovery parses all statements and continues after errors, see parser.ParseWithRecovery
+func (p *Parser) ParseWithRecovery(tokenList []*lexer.Token) (ast.Node, []*parser.Error) {
+	return parser.ParseWithRecovery(p.implicit.Insert(tokenList), p.parseStatement)
+}
+
+// parseStatement uses Pratt parser
+func (p *Parser) parseStatement(tokenList []*lexer.Token) (ast.Node, error) {
+	noWhiteSpaceList := make([]*lexer.Token, 0)
+	for _, v := range tokenList {
+		if v.Type() != lexer.Whitespace {
+			noWhiteSpaceList = append(noWhiteSpaceList, v)
+		}
+	}
+	if len(noWhiteSpaceList) == 0 {
+		return nil, parser.ParseError(nil, ErrEmptyInput)
+	}
+	if lastToken := noWhiteSpaceList[len(noWhiteSpaceList)-1]; lastToken.Type() != lexer.EOL {
+		return nil, parser.ParseError(lastToken, ErrExpectedEOL)
+	}
+	if len(noWhiteSpaceList) == 1 {
+		return nil, parser.ParseError(noWhiteSpaceList[0], ErrEmptyInput)
+	}
+	return (&State{tokenList: noWhiteSpaceList, parser: p}).parseBlock()
+}
+
+// State of parsing one statement, it is passed to parselets to parse operands
+type State struct {
+	tokenList []*lexer.Token
+	i         int
+	parser    *Parser
+	// depth is number of nested operands, it is lower than the final depth of AST,
+	// because left operands are parsed before their parent is known
+	depth int
+	// parens is number of opened parentheses
+	parens int
+}
+
+func (s *State) parseBlock() (ast.Node, error) {
+	var node ast.Node
+	var err error
+
+	if s.isFunctionDefinition() {
+		node, err = s.parseFunctionDefinition()
+	} else {
+		node, err = s.parseExpression(s.parser.priorities.MinPrecedence())
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !s.Has(lexer.EOL) {
+		return nil, parser.ParseError(s.Current(), ErrUnexpectedToken)
+	}
+	return node, nil
+}
+
+// ParseExpression parses operand of the node, which is one level deeper in AST.
+// Only operators with the same or higher precedence are part of the operand
+func (s *State) ParseExpression(precedence parser.TokenPrecedence) (ast.Node, error) {
+	s.depth++
+	defer func() { s.depth-- }()
+	if limit := s.parser.limits.Depth; limit > 0 && s.depth >= limit {
+		return nil, parser.ParseError(s.Current(), parser.ErrDepthLimit)
+	}
+	return s.parseExpression(precedence)
+}
+
+// OperandPrecedence returns precedence of the right operand of the operator.
+// Right associative operators keep the same precedence, so following operator of the same precedence is nested
+func (s *State) OperandPrecedence(operator lexer.TokenType) parser.TokenPrecedence {
+	precedence := s.parser.priorities.GetPrecedence(operator)
+	if s.parser.priorities.GetAssociativity(operator) == parser.RightAssociativity {
+		return precedence
+	}
+	return precedence + 1
+}
+
+func (s *State) parseExpression(precedence parser.TokenPrecedence) (ast.Node, error) {
+	token, _ := s.Expect()
+	prefix, ok := s.parser.prefix[token.Type()]
+	if !ok {
+		return nil, parser.ParseError(token, ErrExpectedOperand)
+	}
+	node, err := prefix(s, token)
+	if err != nil {
+		return nil, err
+	}
+
+	// Apply operators while they bind stronger than the operator which requested this operand
+	for {
+		current := s.Current()
+		infix, hasInfix := s.parser.infix[current.Type()]
+		postfix, hasPostfix := s.parser.postfix[current.Type()]
+		// Token which is infix operator as well, like modulus, is followed by unary operator only when it is not
+		// written right after the operand, so 10% - 5 is percent and 10 % -5 is modulus
+		isPostfix := hasPostfix && !s.startsOperandOnly(1) &&
+			!(hasInfix && s.startsOperand(1) && !parser.WrittenTogether(s.previous(), current))
+		currentPrecedence := s.operatorPrecedence(current, isPostfix)
+		if currentPrecedence == 0 || currentPrecedence < precedence {
+			return node, nil
+		}
+		_, _ = s.Expect()
+		switch {
+		case isPostfix:
+			node, err = postfix(s, node, current)
+		case hasInfix:
+			node, err = infix(s, node, current)
+		default:
+			return nil, parser.ParseError(current, s.expectedOperator(current))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// hasPrefix checks if the current token can start an operand
+func (s *State) hasPrefix() bool {
+	_, ok := s.parser.prefix[s.Current().Type()]
+	return ok
+}
+
+// startsOperandOnly checks if the nth token can start an operand and cannot continue as an operator
+func (s *State) startsOperandOnly(nth int) bool {
+	tokenType := s.nextNth(nth).Type()
+	_, hasPrefix := s.parser.prefix[tokenType]
+	_, hasInfix := s.parser.infix[tokenType]
+	_, hasPostfix := s.parser.postfix[tokenType]
+	return hasPrefix && !hasInfix && !hasPostfix
+}
+
+// startsOperand checks if the nth token can start an operand, unary operators included
+func (s *State) startsOperand(nth int) bool {
+	_, hasPrefix := s.parser.prefix[s.nextNth(nth).Type()]
+	return hasPrefix
+}
+
+// operatorPrecedence returns precedence of the token as postfix operator, like factorial or percent,
+// when the lexer type of the token can be changed to the postfix one
+func (s *State) operatorPrecedence(token *lexer.Token, isPostfix bool) parser.TokenPrecedence {
+	if postfix := token.Clone(); isPostfix && postfix.ChangeToPostfix() == nil {
+		return s.parser.priorities.GetPrecedence(postfix.Type())
+	}
+	return s.parser.priorities.GetPrecedence(token.Type())
+}
+
+// expectedOperator returns error listing all operators, which could follow the operand
+func (s *State) expectedOperator(current *lexer.Token) error {
+	operators := []lexer.TokenType{}
+	for tokenType := range s.parser.infix {
+		operators = append(operators, tokenType)
+	}
+	for tokenType := range s.parser.postfix {
+		if _, ok := s.parser.infix[tokenType]; !ok {
+			operators = append(operators, tokenType)
+		}
+	}
+	sort.Slice(operators, func(i, j int) bool { return operators[i] < operators[j] })
+	names := make([]string, 0, len(operators))
+	for _, tokenType := range operators {
+		names = append(names, tokenType.String())
+	}
+	return fmt.Errorf("expected one of ['%s'] types, got '%s'", strings.Join(names, "', '"), current.Type())
+}
+
+// isFunctionDefinition looks ahead if tokens match pattern `name(param1, param2, ...) =`
+func (s *State) isFunctionDefinition() bool {
+	if !s.hasNth(0, lexer.Identifier) || !s.hasNth(1, lexer.LPar) {
+		return false
+	}
+	nth := 2
+	if s.hasNth(nth, lexer.Identifier) {
+		nth++
+		for s.hasNth(nth, lexer.Comma) && s.hasNth(nth+1, lexer.Identifier) {
+			nth += 2
+		}
+	}
+	return s.hasNth(nth, lexer.RPar) && s.hasNth(nth+1, lexer.Equal)
+}
+
+func (s *State) parseFunctionDefinition() (ast.Node, error) {
+	name, _ := s.Expect()
+	_, _ = s.Expect() // Pop out left parenthesis, checked by isFunctionDefinition already
+
+	params := []*ast.VariableNode{}
+	paramNames := make(map[string]bool)
+	for s.Has(lexer.Identifier) {
+		param, _ := s.Expect()
+		lowerName := strings.ToLower(param.Identifier())
+		if paramNames[lowerName] {
+			return nil, parser.ParseError(param, ErrDuplicateParam)
+		}
+		paramNames[lowerName] = true
+		params = append(params, ast.NewVariableNode(param.Identifier(), param))
+		if s.Has(lexer.Comma) {
+			_, _ = s.Expect()
+		}
+	}
+	_, _ = s.Expect() // Pop out right parenthesis
+	equalOp, _ := s.Expect()
+
+	body, err := s.ParseExpression(s.OperandPrecedence(equalOp.Type()))
+	if err != nil {
+		return nil, err
+	}
+	return ast.NewFunctionDefNode(name.Identifier(), params, body, equalOp), nil
+}
+
+// openPar counts opened parentheses and checks the limit of depth
+func (s *State) openPar(token *lexer.Token) error {
+	if s.parens++; s.parser.limits.Depth > 0 && s.parens > s.parser.limits.Depth {
+		return parser.ParseError(token, parser.ErrDepthLimit)
+	}
+	return nil
+}
+
+// closePar expects right parenthesis closing the last opened one
+func (s *State) closePar() error {
+	if _, err := s.Expect(lexer.RPar); err != nil {
+		return err
+	}
+	s.parens--
+	return nil
+}
+
+// Current returns the token, which is going to be parsed next
+func (s *State) Current() *lexer.Token {
+	return s.nextNth(0)
+}
+
+// Has checks if the current token is one of expected types
+func (s *State) Has(expectedTypes ...lexer.TokenType) bool {
+	return s.hasNth(0, expectedTypes...)
+}
+
+// Expect returns the current token and moves forward, error is returned if it is not one of expected types.
+// Without types any token is accepted
+func (s *State) Expect(expectedTypes ...lexer.TokenType) (*lexer.Token, error) {
+	defer s.moveForward()
+
+	if len(expectedTypes) == 0 {
+		return s.Current(), nil
+	}
+
+	anyOf := []string{}
+	current := s.Current()
+	for _, expType := range expectedTypes {
+		if current.Type() == expType {
+			return current, nil
+		}
+		anyOf = append(anyOf, expType.String())
+	}
+	var err error
+	if len(anyOf) > 1 {
+		err = fmt.Errorf("expected one of ['%s'] types, got '%s'", strings.Join(anyOf, "', '"), current.Type())
+	} else {
+		err = fmt.Errorf("expected '%s' type, got '%s'", anyOf[0], current.Type())
+	}
+	return nil, parser.ParseError(current, err)
+}
+
+// moveForward never moves after EOL, so parselets always get a token
+func (s *State) moveForward() {
+	if s.i < len(s.tokenList)-1 {
+		s.i++
+	}
+}
+
+func (s *State) hasNth(nth int, expectedTypes ...lexer.TokenType) bool {
+	nthToken := s.nextNth(nth)
+	for _, expType := range expectedTypes {
+		if expType == nthToken.Type() {
+			return true
+		}
+	}
+	return false
+}
+
+// previous returns the token before the current one, or nil at the beginning
+func (s *State) previous() *lexer.Token {
+	if s.i > 0 {
+		return s.tokenList[s.i-1]
+	}
+	return nil
+}
+
+func (s *State) nextNth(nth int) *lexer.Token {
+	if s.i+nth < len(s.tokenList) {
+		return s.tokenList[s.i+nth]
+	}
+	return nil
+}
@@ -0,0 +1,1399 @@
+package pratt_test
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/onsi/gomega/types"
+
+	"github.com/arxeiss/go-expression-calculator/ast"
+	. "github.com/arxeiss/go-expression-calculator/ast/astutils"
+	"github.com/arxeiss/go-expression-calculator/lexer"
+	"github.com/arxeiss/go-expression-calculator/parser"
+	"github.com/arxeiss/go-expression-calculator/parser/pratt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Successful parsing", func() {
+	It("All supported token types", func() {
+		p, err := pratt.NewParser(parser.DefaultTokenPriorities())
+		Expect(err).To(Succeed())
+		input := []*lexer.Token{
+			/* n */ lexer.NewToken(lexer.Identifier, 0, "n", 0, 0),
+			/* = */ lexer.NewToken(lexer.Equal, 0, "", 0, 0),
+			/* ( */ lexer.NewToken(lexer.LPar, 0, "", 0, 0),
+			/* 30 */ lexer.NewToken(lexer.Number, 30, "", 0, 0),
+			/* + */ lexer.NewToken(lexer.Addition, 0, "", 0, 0),
+			/* 10 */ lexer.NewToken(lexer.Number, 10, "", 0, 0),
+			/* * */ lexer.NewToken(lexer.Multiplication, 0, "", 0, 0),
+			/* abc */ lexer.NewToken(lexer.Identifier, 0, "abc", 0, 0),
+			/* ) */ lexer.NewToken(lexer.RPar, 0, "", 0, 0),
+			/* - */ lexer.NewToken(lexer.Substraction, 0, "", 0, 0),
+			/* 10 */ lexer.NewToken(lexer.Number, 10, "", 0, 0),
+			/* / */ lexer.NewToken(lexer.Division, 0, "", 0, 0),
+			/* 3 */ lexer.NewToken(lexer.Number, 3, "", 0, 0),
+			/* // */ lexer.NewToken(lexer.FloorDiv, 0, "", 0, 0),
+			/* 2 */ lexer.NewToken(lexer.Number, 2, "", 0, 0),
+			/* + */ lexer.NewToken(lexer.Addition, 0, "", 0, 0),
+			/* - */ lexer.NewToken(lexer.Substraction, 0, "", 0, 0), // Will be changed to unary
+			/* max */ lexer.NewToken(lexer.Identifier, 0, "max", 0, 0),
+			/* ( */ lexer.NewToken(lexer.LPar, 0, "", 0, 0),
+			/* 5 */ lexer.NewToken(lexer.Number, 5, "", 0, 0),
+			/* , */ lexer.NewToken(lexer.Comma, 0, "", 0, 0),
+			/* 17 */ lexer.NewToken(lexer.Number, 17, "", 0, 0),
+			/* % */ lexer.NewToken(lexer.Modulus, 0, "", 0, 0),
+			/* 7 */ lexer.NewToken(lexer.Number, 7, "", 0, 0),
+			/* ) */ lexer.NewToken(lexer.RPar, 0, "", 0, 0),
+
+			lexer.NewToken(lexer.EOL, 0, "", 0, 0),
+		}
+		rootNode, err := p.Parse(input)
+		Expect(err).To(Succeed())
+		Expect(rootNode).NotTo(BeNil())
+		Expect(rootNode).To(SurviveJSONRoundTrip())
+
+		Expect(rootNode).To(MatchAssignNode(
+			MatchVariableNode("n"),
+			MatchBinaryNode(
+				ast.Addition,
+				MatchBinaryNode(
+					ast.Substraction,
+					MatchBinaryNode(
+						ast.Addition,
+						MatchNumericNode(30),
+						MatchBinaryNode(
+							ast.Multiplication,
+							MatchNumericNode(10),
+							MatchVariableNode("abc"),
+						),
+					),
+					MatchBinaryNode(
+						ast.FloorDiv,
+						MatchBinaryNode(
+							ast.Division,
+							MatchNumericNode(10),
+							MatchNumericNode(3),
+						),
+						MatchNumericNode(2),
+					),
+				),
+				MatchUnaryNode(
+					ast.Substraction,
+					MatchFunctionNode("max",
+						MatchNumericNode(5),
+						MatchBinaryNode(
+							ast.Modulus,
+							MatchNumericNode(17),
+							MatchNumericNode(7),
+						),
+					),
+				),
+			),
+		))
+		// Just test it will not panic
+		Expect(ast.ToTreeDrawer(rootNode)).NotTo(BeNil())
+	})
+
+	It("Simple expression with parenthesis", func() {
+		p, err := pratt.NewParser(parser.DefaultTokenPriorities())
+		Expect(err).To(Succeed())
+		input := []*lexer.Token{
+			lexer.NewToken(lexer.LPar, 0, "", 0, 0),
+			lexer.NewToken(lexer.Number, 23, "", 0, 0),
+			lexer.NewToken(lexer.Addition, 0, "", 0, 0),
+			lexer.NewToken(lexer.Number, 11, "", 0, 0),
+			lexer.NewToken(lexer.RPar, 0, "", 0, 0),
+			lexer.NewToken(lexer.Division, 0, "", 0, 0),
+			lexer.NewToken(lexer.Number, 4, "", 0, 0),
+			lexer.NewToken(lexer.Substraction, 0, "", 0, 0),
+			lexer.NewToken(lexer.Number, 25, "", 0, 0),
+			lexer.NewToken(lexer.Multiplication, 0, "", 0, 0),
+			lexer.NewToken(lexer.Number, 10, "", 0, 0),
+			lexer.NewToken(lexer.EOL, 0, "", 0, 0),
+		}
+		rootNode, err := p.Parse(input)
+		Expect(err).To(Succeed())
+		Expect(rootNode).NotTo(BeNil())
+		Expect(rootNode).To(SurviveJSONRoundTrip())
+
+		Expect(rootNode).To(MatchBinaryNode(
+			ast.Substraction,
+			MatchBinaryNode(
+				ast.Division,
+				MatchBinaryNode(
+					ast.Addition,
+					MatchNumericNode(23),
+					MatchNumericNode(11),
+				),
+				MatchNumericNode(4),
+			),
+			MatchBinaryNode(
+				ast.Multiplication,
+				MatchNumericNode(25),
+				MatchNumericNode(10),
+			),
+		))
+	})
+
+	It("Starts with unary substraction and number", func() {
+		p, err := pratt.NewParser(parser.DefaultTokenPriorities())
+		Expect(err).To(Succeed())
+		input := []*lexer.Token{
+			lexer.NewToken(lexer.Substraction, 0, "", 0, 0),
+			lexer.NewToken(lexer.Number, 46, "", 0, 0),
+			lexer.NewToken(lexer.EOL, 0, "", 0, 0),
+		}
+		rootNode, err := p.Parse(input)
+		Expect(err).To(Succeed())
+		Expect(rootNode).NotTo(BeNil())
+		Expect(rootNode).To(SurviveJSONRoundTrip())
+
+		Expect(rootNode).To(MatchUnaryNode(
+			ast.Substraction,
+			MatchNumericNode(46),
+		))
+	})
+
+	It("Simple multiple unary expressions", func() {
+		p, err := pratt.NewParser(parser.DefaultTokenPriorities())
+		Expect(err).To(Succeed())
+		input := []*lexer.Token{
+			lexer.NewToken(lexer.Number, 20, "", 0, 0),
+			lexer.NewToken(lexer.Substraction, 0, "", 0, 0),
+			lexer.NewToken(lexer.Substraction, 0, "", 0, 0),
+			lexer.NewToken(lexer.Substraction, 0, "", 0, 0),
+			lexer.NewToken(lexer.Substraction, 0, "", 0, 0),
+			lexer.NewToken(lexer.Number, 5, "", 0, 0),
+			lexer.NewToken(lexer.Addition, 0, "", 0, 0),
+			lexer.NewToken(lexer.Addition, 0, "", 0, 0),
+			lexer.NewToken(lexer.Number, 7, "", 0, 0),
+			lexer.NewToken(lexer.EOL, 0, "", 0, 0),
+		}
+		rootNode, err := p.Parse(input)
+		Expect(err).To(Succeed())
+		Expect(rootNode).NotTo(BeNil())
+		Expect(rootNode).To(SurviveJSONRoundTrip())
+
+		Expect(rootNode).To(MatchBinaryNode(
+			ast.Addition,
+			MatchBinaryNode(
+				ast.Substraction,
+				MatchNumericNode(20),
+				MatchUnaryNode(
+					ast.Substraction,
+					MatchUnaryNode(
+						ast.Substraction,
+						MatchUnaryNode(
+							ast.Substraction,
+							MatchNumericNode(5),
+						),
+					),
+				),
+			),
+			MatchUnaryNode(ast.Addition, MatchNumericNode(7)),
+		))
+	})
+
+	It("Simple unary with higher precedence operator", func() {
+		p, err := pratt.NewParser(parser.DefaultTokenPriorities())
+		Expect(err).To(Succeed())
+		input := []*lexer.Token{
+			lexer.NewToken(lexer.Substraction, 0, "", 0, 0),
+			lexer.NewToken(lexer.Number, 5, "", 0, 0),
+			lexer.NewToken(lexer.Exponent, 0, "", 0, 0),
+			lexer.NewToken(lexer.Substraction, 0, "", 0, 0),
+			lexer.NewToken(lexer.Number, 2, "", 0, 0),
+			lexer.NewToken(lexer.EOL, 0, "", 0, 0),
+		}
+		rootNode, err := p.Parse(input)
+		Expect(err).To(Succeed())
+		Expect(rootNode).NotTo(BeNil())
+		Expect(rootNode).To(SurviveJSONRoundTrip())
+
+		Expect(rootNode).To(MatchUnaryNode(
+			ast.Substraction,
+			MatchBinaryNode(
+				ast.Exponent,
+				MatchNumericNode(5),
+				MatchUnaryNode(ast.Substraction, MatchNumericNode(2)),
+			),
+		))
+	})
+
+	It("Unary after operator followed by higher precedence operator", func() {
+		p, err := pratt.NewParser(parser.DefaultTokenPriorities())
+		Expect(err).To(Succeed())
+		input := []*lexer.Token{
+			lexer.NewToken(lexer.Number, 1, "", 0, 0),
+			lexer.NewToken(lexer.Addition, 0, "", 0, 0),
+			lexer.NewToken(lexer.Substraction, 0, "", 0, 0),
+			lexer.NewToken(lexer.Number, 2, "", 0, 0),
+			lexer.NewToken(lexer.Multiplication, 0, "", 0, 0),
+			lexer.NewToken(lexer.Number, 3, "", 0, 0),
+			lexer.NewToken(lexer.EOL, 0, "", 0, 0),
+		}
+		rootNode, err := p.Parse(input)
+		Expect(err).To(Succeed())
+		Expect(rootNode).NotTo(BeNil())
+		Expect(rootNode).To(SurviveJSONRoundTrip())
+
+		Expect(rootNode).To(MatchBinaryNode(
+			ast.Addition,
+			MatchNumericNode(1),
+			MatchBinaryNode(
+				ast.Multiplication,
+				MatchUnaryNode(ast.Substraction, MatchNumericNode(2)),
+				MatchNumericNode(3),
+			),
+		))
+	})
+
+	It("Nested unary operators in parenthesis", func() {
+		p, err := pratt.NewParser(parser.DefaultTokenPriorities())
+		Expect(err).To(Succeed())
+		input := []*lexer.Token{
+			lexer.NewToken(lexer.Addition, 0, "", 0, 0),
+			lexer.NewToken(lexer.LPar, 0, "", 0, 0),
+			lexer.NewToken(lexer.Substraction, 0, "", 0, 0),
+			lexer.NewToken(lexer.Identifier, 0, "abs", 0, 0),
+			lexer.NewToken(lexer.LPar, 0, "", 0, 0),
+			lexer.NewToken(lexer.Substraction, 0, "", 0, 0),
+			lexer.NewToken(lexer.Identifier, 0, "jkl", 0, 0),
+			lexer.NewToken(lexer.RPar, 0, "", 0, 0),
+			lexer.NewToken(lexer.RPar, 0, "", 0, 0),
+			lexer.NewToken(lexer.EOL, 0, "", 0, 0),
+		}
+		rootNode, err := p.Parse(input)
+		Expect(err).To(Succeed())
+		Expect(rootNode).NotTo(BeNil())
+		Expect(rootNode).To(SurviveJSONRoundTrip())
+
+		Expect(rootNode).To(MatchUnaryNode(
+			ast.Addition,
+			MatchUnaryNode(
+				ast.Substraction,
+				MatchFunctionNode("abs",
+					MatchUnaryNode(
+						ast.Substraction,
+						MatchVariableNode("jkl"),
+					),
+				),
+			),
+		))
+	})
+
+	It("Handles unary operators in a row", func() {
+		p, err := pratt.NewParser(parser.DefaultTokenPriorities())
+		Expect(err).To(Succeed())
+		input := []*lexer.Token{
+			lexer.NewToken(lexer.Number, 11, "", 0, 0),
+			lexer.NewToken(lexer.Addition, 0, "", 0, 0),
+			lexer.NewToken(lexer.Addition, 0, "", 0, 0),
+			lexer.NewToken(lexer.LPar, 0, "", 0, 0),
+			lexer.NewToken(lexer.Identifier, 0, "x", 0, 0),
+			lexer.NewToken(lexer.Substraction, 0, "", 0, 0),
+			lexer.NewToken(lexer.Substraction, 0, "", 0, 0),
+			lexer.NewToken(lexer.Number, 150, "", 0, 0),
+			lexer.NewToken(lexer.RPar, 0, "", 0, 0),
+			lexer.NewToken(lexer.Substraction, 0, "", 0, 0),
+			lexer.NewToken(lexer.Addition, 0, "", 0, 0),
+			lexer.NewToken(lexer.Identifier, 0, "y", 0, 0),
+
+			lexer.NewToken(lexer.EOL, 0, "", 0, 0),
+		}
+		rootNode, err := p.Parse(input)
+		Expect(err).To(Succeed())
+		Expect(rootNode).NotTo(BeNil())
+		Expect(rootNode).To(SurviveJSONRoundTrip())
+
+		Expect(rootNode).To(MatchBinaryNode(
+			ast.Substraction,
+			MatchBinaryNode(
+				ast.Addition,
+				MatchNumericNode(11),
+				MatchUnaryNode(
+					ast.Addition,
+					MatchBinaryNode(
+						ast.Substraction,
+						MatchVariableNode("x"),
+						MatchUnaryNode(ast.Substraction, MatchNumericNode(150)),
+					),
+				),
+			),
+			MatchUnaryNode(ast.Addition, MatchVariableNode("y")),
+		))
+	})
+
+	It("Correctly handles right associativity", func() {
+		p, err := pratt.NewParser(parser.DefaultTokenPriorities())
+		Expect(err).To(Succeed())
+		input := []*lexer.Token{
+			lexer.NewToken(lexer.Identifier, 0, "i", 0, 0),
+			lexer.NewToken(lexer.Exponent, 0, "", 0, 0),
+			lexer.NewToken(lexer.LPar, 0, "", 0, 0),
+			lexer.NewToken(lexer.Identifier, 0, "x", 0, 0),
+			lexer.NewToken(lexer.Exponent, 0, "", 0, 0),
+			lexer.NewToken(lexer.Identifier, 0, "y", 0, 0),
+			lexer.NewToken(lexer.RPar, 0, "", 0, 0),
+			lexer.NewToken(lexer.Exponent, 0, "", 0, 0),
+			lexer.NewToken(lexer.Identifier, 0, "j", 0, 0),
+			lexer.NewToken(lexer.Exponent, 0, "", 0, 0),
+			lexer.NewToken(lexer.Identifier, 0, "k", 0, 0),
+			lexer.NewToken(lexer.EOL, 0, "", 0, 0),
+		}
+		rootNode, err := p.Parse(input)
+		Expect(err).To(Succeed())
+		Expect(rootNode).NotTo(BeNil())
+		Expect(rootNode).To(SurviveJSONRoundTrip())
+
+		Expect(rootNode).To(MatchBinaryNode(
+			ast.Exponent,
+			MatchVariableNode("i"),
+			MatchBinaryNode(
+				ast.Exponent,
+				MatchBinaryNode(
+					ast.Exponent,
+					MatchVariableNode("x"),
+					MatchVariableNode("y"),
+				), MatchBinaryNode(
+					ast.Exponent,
+					MatchVariableNode("j"),
+					MatchVariableNode("k"),
+				),
+			),
+		))
+	})
+
+	It("Support assign only number", func() {
+		p, err := pratt.NewParser(parser.DefaultTokenPriorities())
+		Expect(err).To(Succeed())
+		input := []*lexer.Token{
+			lexer.NewToken(lexer.Identifier, 0, "a", 0, 0),
+			lexer.NewToken(lexer.Equal, 0, "", 0, 0),
+			lexer.NewToken(lexer.Number, 46, "", 0, 0),
+			lexer.NewToken(lexer.EOL, 0, "", 0, 0),
+		}
+		rootNode, err := p.Parse(input)
+		Expect(err).To(Succeed())
+		Expect(rootNode).NotTo(BeNil())
+		Expect(rootNode).To(SurviveJSONRoundTrip())
+
+		Expect(rootNode).To(MatchAssignNode(
+			MatchVariableNode("a"),
+			MatchNumericNode(46),
+		))
+	})
+
+	It("Support assign value of another variable", func() {
+		p, err := pratt.NewParser(parser.DefaultTokenPriorities())
+		Expect(err).To(Succeed())
+		input := []*lexer.Token{
+			lexer.NewToken(lexer.Identifier, 0, "a", 0, 0),
+			lexer.NewToken(lexer.Equal, 0, "", 0, 0),
+			lexer.NewToken(lexer.Identifier, 0, "b", 0, 0),
+			lexer.NewToken(lexer.EOL, 0, "", 0, 0),
+		}
+		rootNode, err := p.Parse(input)
+		Expect(err).To(Succeed())
+		Expect(rootNode).NotTo(BeNil())
+		Expect(rootNode).To(SurviveJSONRoundTrip())
+
+		Expect(rootNode).To(MatchAssignNode(
+			MatchVariableNode("a"),
+			MatchVariableNode("b"),
+		))
+	})
+
+	It("Support chained assignment", func() {
+		p, err := pratt.NewParser(parser.DefaultTokenPriorities())
+		Expect(err).To(Succeed())
+		input := []*lexer.Token{
+			lexer.NewToken(lexer.Identifier, 0, "a", 0, 0),
+			lexer.NewToken(lexer.Equal, 0, "", 0, 0),
+			lexer.NewToken(lexer.Identifier, 0, "b", 0, 0),
+			lexer.NewToken(lexer.Equal, 0, "", 0, 0),
+			lexer.NewToken(lexer.Number, 3, "", 0, 0),
+			lexer.NewToken(lexer.EOL, 0, "", 0, 0),
+		}
+		rootNode, err := p.Parse(input)
+		Expect(err).To(Succeed())
+		Expect(rootNode).NotTo(BeNil())
+		Expect(rootNode).To(SurviveJSONRoundTrip())
+
+		Expect(rootNode).To(MatchAssignNode(
+			MatchVariableNode("a"),
+			MatchAssignNode(
+				MatchVariableNode("b"),
+				MatchNumericNode(3),
+			),
+		))
+	})
+
+	It("Support assignment inside expression", func() {
+		p, err := pratt.NewParser(parser.DefaultTokenPriorities())
+		Expect(err).To(Succeed())
+		input := []*lexer.Token{
+			lexer.NewToken(lexer.Identifier, 0, "y", 0, 0),
+			lexer.NewToken(lexer.Equal, 0, "", 0, 0),
+			lexer.NewToken(lexer.LPar, 0, "", 0, 0),
+			lexer.NewToken(lexer.Identifier, 0, "x", 0, 0),
+			lexer.NewToken(lexer.Equal, 0, "", 0, 0),
+			lexer.NewToken(lexer.Number, 2, "", 0, 0),
+			lexer.NewToken(lexer.RPar, 0, "", 0, 0),
+			lexer.NewToken(lexer.Multiplication, 0, "", 0, 0),
+			lexer.NewToken(lexer.Number, 3, "", 0, 0),
+			lexer.NewToken(lexer.EOL, 0, "", 0, 0),
+		}
+		rootNode, err := p.Parse(input)
+		Expect(err).To(Succeed())
+		Expect(rootNode).NotTo(BeNil())
+		Expect(rootNode).To(SurviveJSONRoundTrip())
+
+		Expect(rootNode).To(MatchAssignNode(
+			MatchVariableNode("y"),
+			MatchBinaryNode(
+				ast.Multiplication,
+				MatchAssignNode(
+					MatchVariableNode("x"),
+					MatchNumericNode(2),
+				),
+				MatchNumericNode(3),
+			),
+		))
+	})
+
+	It("Comparison and logical operators", func() {
+		p, err := pratt.NewParser(parser.DefaultTokenPriorities())
+		Expect(err).To(Succeed())
+		input := []*lexer.Token{
+			/* ! */ lexer.NewToken(lexer.Not, 0, "", 0, 0),
+			/* a */ lexer.NewToken(lexer.Identifier, 0, "a", 0, 0),
+			/* < */ lexer.NewToken(lexer.Less, 0, "", 0, 0),
+			/* b */ lexer.NewToken(lexer.Identifier, 0, "b", 0, 0),
+			/* + */ lexer.NewToken(lexer.Addition, 0, "", 0, 0),
+			/* 1 */ lexer.NewToken(lexer.Number, 1, "", 0, 0),
+			/* && */ lexer.NewToken(lexer.And, 0, "", 0, 0),
+			/* c */ lexer.NewToken(lexer.Identifier, 0, "c", 0, 0),
+			/* == */ lexer.NewToken(lexer.IsEqual, 0, "", 0, 0),
+			/* 2 */ lexer.NewToken(lexer.Number, 2, "", 0, 0),
+			/* || */ lexer.NewToken(lexer.Or, 0, "", 0, 0),
+			/* d */ lexer.NewToken(lexer.Identifier, 0, "d", 0, 0),
+			/* >= */ lexer.NewToken(lexer.GreaterOrEqual, 0, "", 0, 0),
+			/* - */ lexer.NewToken(lexer.Substraction, 0, "", 0, 0), // Will be changed to unary
+			/* e */ lexer.NewToken(lexer.Identifier, 0, "e", 0, 0),
+			/* != */ lexer.NewToken(lexer.NotEqual, 0, "", 0, 0),
+			/* ! */ lexer.NewToken(lexer.Not, 0, "", 0, 0),
+			/* f */ lexer.NewToken(lexer.Identifier, 0, "f", 0, 0),
+			lexer.NewToken(lexer.EOL, 0, "", 0, 0),
+		}
+		rootNode, err := p.Parse(input)
+		Expect(err).To(Succeed())
+		Expect(rootNode).NotTo(BeNil())
+		Expect(rootNode).To(SurviveJSONRoundTrip())
+
+		Expect(rootNode).To(MatchBinaryNode(
+			ast.Or,
+			MatchBinaryNode(
+				ast.And,
+				MatchBinaryNode(
+					ast.Less,
+					MatchUnaryNode(ast.Not, MatchVariableNode("a")),
+					MatchBinaryNode(ast.Addition, MatchVariableNode("b"), MatchNumericNode(1)),
+				),
+				MatchBinaryNode(ast.IsEqual, MatchVariableNode("c"), MatchNumericNode(2)),
+			),
+			MatchBinaryNode(
+				ast.NotEqual,
+				MatchBinaryNode(
+					ast.GreaterOrEqual,
+					MatchVariableNode("d"),
+					MatchUnaryNode(ast.Substraction, MatchVariableNode("e")),
+				),
+				MatchUnaryNode(ast.Not, MatchVariableNode("f")),
+			),
+		))
+	})
+
+	It("Conditional operator", func() {
+		p, err := pratt.NewParser(parser.DefaultTokenPriorities())
+		Expect(err).To(Succeed())
+		input := []*lexer.Token{
+			/* a */ lexer.NewToken(lexer.Identifier, 0, "a", 0, 0),
+			/* > */ lexer.NewToken(lexer.Greater, 0, "", 0, 0),
+			/* 0 */ lexer.NewToken(lexer.Number, 0, "", 0, 0),
+			/* ? */ lexer.NewToken(lexer.Question, 0, "", 0, 0),
+			/* b */ lexer.NewToken(lexer.Identifier, 0, "b", 0, 0),
+			/* ? */ lexer.NewToken(lexer.Question, 0, "", 0, 0),
+			/* 1 */ lexer.NewToken(lexer.Number, 1, "", 0, 0),
+			/* : */ lexer.NewToken(lexer.Colon, 0, "", 0, 0),
+			/* 2 */ lexer.NewToken(lexer.Number, 2, "", 0, 0),
+			/* : */ lexer.NewToken(lexer.Colon, 0, "", 0, 0),
+			/* c */ lexer.NewToken(lexer.Identifier, 0, "c", 0, 0),
+			/* || */ lexer.NewToken(lexer.Or, 0, "", 0, 0),
+			/* d */ lexer.NewToken(lexer.Identifier, 0, "d", 0, 0),
+			/* ? */ lexer.NewToken(lexer.Question, 0, "", 0, 0),
+			/* - */ lexer.NewToken(lexer.Substraction, 0, "", 0, 0), // Will be changed to unary
+			/* e */ lexer.NewToken(lexer.Identifier, 0, "e", 0, 0),
+			/* : */ lexer.NewToken(lexer.Colon, 0, "", 0, 0),
+			/* f */ lexer.NewToken(lexer.Identifier, 0, "f", 0, 0),
+			/* * */ lexer.NewToken(lexer.Multiplication, 0, "", 0, 0),
+			/* 3 */ lexer.NewToken(lexer.Number, 3, "", 0, 0),
+			lexer.NewToken(lexer.EOL, 0, "", 0, 0),
+		}
+		rootNode, err := p.Parse(input)
+		Expect(err).To(Succeed())
+		Expect(rootNode).NotTo(BeNil())
+		Expect(rootNode).To(SurviveJSONRoundTrip())
+
+		Expect(rootNode).To(MatchConditionalNode(
+			MatchBinaryNode(ast.Greater, MatchVariableNode("a"), MatchNumericNode(0)),
+			MatchConditionalNode(MatchVariableNode("b"), MatchNumericNode(1), MatchNumericNode(2)),
+			MatchConditionalNode(
+				MatchBinaryNode(ast.Or, MatchVariableNode("c"), MatchVariableNode("d")),
+				MatchUnaryNode(ast.Substraction, MatchVariableNode("e")),
+				MatchBinaryNode(ast.Multiplication, MatchVariableNode("f"), MatchNumericNode(3)),
+			),
+		))
+		Expect(rootNode.GetToken()).To(BeIdenticalTo(input[3]))
+	})
+
+	It("Multiple statements", func() {
+		p, err := pratt.NewParser(parser.DefaultTokenPriorities())
+		Expect(err).To(Succeed())
+		input, err := lexer.NewLexer("max(a, 3); b * 2\n\n;b ^ 2;").Tokenize()
+		Expect(err).To(Succeed())
+		rootNode, err := p.Parse(input)
+		Expect(err).To(Succeed())
+		Expect(rootNode).To(SurviveJSONRoundTrip())
+
+		Expect(rootNode).To(MatchBlockNode(
+			MatchFunctionNode("max", MatchVariableNode("a"), MatchNumericNode(3)),
+			MatchBinaryNode(ast.Multiplication, MatchVariableNode("b"), MatchNumericNode(2)),
+			MatchBinaryNode(ast.Exponent, MatchVariableNode("b"), MatchNumericNode(2)),
+		))
+		Expect(rootNode.GetToken()).To(BeIdenticalTo(input[0]))
+	})
+
+	It("Reports line and column of error in later statement", func() {
+		p, err := pratt.NewParser(parser.DefaultTokenPriorities())
+		Expect(err).To(Succeed())
+		input, err := lexer.NewLexer("a + 3\nb * (a *\n 2 +)").Tokenize()
+		Expect(err).To(Succeed())
+		rootNode, err := p.Parse(input)
+		Expect(rootNode).To(BeNil())
+
+		parseErr, ok := err.(*parser.Error)
+		Expect(ok).To(BeTrue())
+		Expect(parseErr.Position()).To(Equal(19))
+		Expect(parseErr.Location()).To(Equal(lexer.Location{Offset: 19, Length: 1, Line: 3, Column: 5}))
+		Expect(parseErr.Error()).To(ContainSubstring("found RPar token at line 3, column 5"))
+	})
+
+	It("Support functions without arguments", func() {
+		p, err := pratt.NewParser(parser.DefaultTokenPriorities())
+		Expect(err).To(Succeed())
+		input := []*lexer.Token{
+			lexer.NewToken(lexer.Identifier, 0, "rand", 0, 0),
+			lexer.NewToken(lexer.LPar, 0, "", 0, 0),
+			lexer.NewToken(lexer.RPar, 46, "", 0, 0),
+			lexer.NewToken(lexer.EOL, 0, "", 0, 0),
+		}
+		rootNode, err := p.Parse(input)
+		Expect(err).To(Succeed())
+		Expect(rootNode).NotTo(BeNil())
+		Expect(rootNode).To(SurviveJSONRoundTrip())
+
+		Expect(rootNode).To(MatchFunctionNode("rand"))
+	})
+
+	It("Support functions with multiple arguments", func() {
+		p, err := pratt.NewParser(parser.DefaultTokenPriorities())
+		Expect(err).To(Succeed())
+		input := []*lexer.Token{
+			// max(a, 123, b, -55---31^2)
+			lexer.NewToken(lexer.Identifier, 0, "max", 0, 0),
+			lexer.NewToken(lexer.LPar, 0, "", 0, 0),
+			lexer.NewToken(lexer.Identifier, 0, "a", 0, 0),
+			lexer.NewToken(lexer.Comma, 0, "", 0, 0),
+			lexer.NewToken(lexer.Number, 123, "", 0, 0),
+			lexer.NewToken(lexer.Comma, 0, "", 0, 0),
+			lexer.NewToken(lexer.Identifier, 0, "b", 0, 0),
+			lexer.NewToken(lexer.Comma, 0, "", 0, 0),
+			lexer.NewToken(lexer.Substraction, 0, "", 0, 0),
+			lexer.NewToken(lexer.Number, 55, "", 0, 0),
+			lexer.NewToken(lexer.Substraction, 0, "", 0, 0),
+			lexer.NewToken(lexer.Substraction, 0, "", 0, 0),
+			lexer.NewToken(lexer.Substraction, 0, "", 0, 0),
+			lexer.NewToken(lexer.Number, 31, "", 0, 0),
+			lexer.NewToken(lexer.Exponent, 0, "", 0, 0),
+			lexer.NewToken(lexer.Number, 2, "", 0, 0),
+			lexer.NewToken(lexer.RPar, 0, "", 0, 0),
+			lexer.NewToken(lexer.EOL, 0, "", 0, 0),
+		}
+		rootNode, err := p.Parse(input)
+		Expect(err).To(Succeed())
+		Expect(rootNode).NotTo(BeNil())
+		Expect(rootNode).To(SurviveJSONRoundTrip())
+
+		Expect(rootNode).To(MatchFunctionNode(
+			"max",
+			MatchVariableNode("a"),
+			MatchNumericNode(123),
+			MatchVariableNode("b"),
+			MatchBinaryNode(
+				ast.Substraction,
+				MatchUnaryNode(ast.Substraction, MatchNumericNode(55)),
+				MatchUnaryNode(
+					ast.Substraction,
+					MatchUnaryNode(
+						ast.Substraction,
+						MatchBinaryNode(
+							ast.Exponent,
+							MatchNumericNode(31),
+							MatchNumericNode(2),
+						),
+					),
+				),
+			),
+		))
+	})
+
+	It("Support function definition", func() {
+		p, err := pratt.NewParser(parser.DefaultTokenPriorities())
+		Expect(err).To(Succeed())
+		input := []*lexer.Token{
+			// f(x, y) = x^2 + y
+			lexer.NewToken(lexer.Identifier, 0, "f", 0, 0),
+			lexer.NewToken(lexer.LPar, 0, "", 0, 0),
+			lexer.NewToken(lexer.Identifier, 0, "x", 0, 0),
+			lexer.NewToken(lexer.Comma, 0, "", 0, 0),
+			lexer.NewToken(lexer.Identifier, 0, "y", 0, 0),
+			lexer.NewToken(lexer.RPar, 0, "", 0, 0),
+			lexer.NewToken(lexer.Equal, 0, "", 0, 0),
+			lexer.NewToken(lexer.Identifier, 0, "x", 0, 0),
+			lexer.NewToken(lexer.Exponent, 0, "", 0, 0),
+			lexer.NewToken(lexer.Number, 2, "", 0, 0),
+			lexer.NewToken(lexer.Addition, 0, "", 0, 0),
+			lexer.NewToken(lexer.Identifier, 0, "y", 0, 0),
+			lexer.NewToken(lexer.EOL, 0, "", 0, 0),
+		}
+		rootNode, err := p.Parse(input)
+		Expect(err).To(Succeed())
+		Expect(rootNode).NotTo(BeNil())
+		Expect(rootNode).To(SurviveJSONRoundTrip())
+
+		Expect(rootNode).To(MatchFunctionDefNode(
+			"f",
+			[]string{"x", "y"},
+			MatchBinaryNode(
+				ast.Addition,
+				MatchBinaryNode(ast.Exponent, MatchVariableNode("x"), MatchNumericNode(2)),
+				MatchVariableNode("y"),
+			),
+		))
+		// Just test it will not panic
+		Expect(ast.ToTreeDrawer(rootNode)).NotTo(BeNil())
+	})
+
+	It("Support function definition without parameters", func() {
+		p, err := pratt.NewParser(parser.DefaultTokenPriorities())
+		Expect(err).To(Succeed())
+		input := []*lexer.Token{
+			// answer() = 42
+			lexer.NewToken(lexer.Identifier, 0, "answer", 0, 0),
+			lexer.NewToken(lexer.LPar, 0, "", 0, 0),
+			lexer.NewToken(lexer.RPar, 0, "", 0, 0),
+			lexer.NewToken(lexer.Equal, 0, "", 0, 0),
+			lexer.NewToken(lexer.Number, 42, "", 0, 0),
+			lexer.NewToken(lexer.EOL, 0, "", 0, 0),
+		}
+		rootNode, err := p.Parse(input)
+		Expect(err).To(Succeed())
+		Expect(rootNode).To(SurviveJSONRoundTrip())
+		Expect(rootNode).To(MatchFunctionDefNode("answer", nil, MatchNumericNode(42)))
+	})
+
+	It("Support functions inside functions", func() {
+		p, err := pratt.NewParser(parser.DefaultTokenPriorities())
+		Expect(err).To(Succeed())
+		input := []*lexer.Token{
+			/* c */ lexer.NewToken(lexer.Identifier, 0, "c", 0, 0),
+			/* = */ lexer.NewToken(lexer.Equal, 0, "", 0, 0),
+			/* min */ lexer.NewToken(lexer.Identifier, 0, "min", 0, 0),
+			/* ( */ lexer.NewToken(lexer.LPar, 0, "", 0, 0),
+			/* sin */ lexer.NewToken(lexer.Identifier, 0, "sin", 0, 0),
+			/* ( */ lexer.NewToken(lexer.LPar, 0, "", 0, 0),
+			/* 4 */ lexer.NewToken(lexer.Number, 4, "", 0, 0),
+			/* * */ lexer.NewToken(lexer.Multiplication, 0, "", 0, 0),
+			/* 7 */ lexer.NewToken(lexer.Number, 7, "", 0, 0),
+			/* ) */ lexer.NewToken(lexer.RPar, 0, "", 0, 0),
+			/* , */ lexer.NewToken(lexer.Comma, 0, "", 0, 0),
+			/* cos */ lexer.NewToken(lexer.Identifier, 0, "cos", 0, 0),
+			/* ( */ lexer.NewToken(lexer.LPar, 0, "", 0, 0),
+			/* max */ lexer.NewToken(lexer.Identifier, 0, "max", 0, 0),
+			/* ( */ lexer.NewToken(lexer.LPar, 0, "", 0, 0),
+			/* a */ lexer.NewToken(lexer.Identifier, 0, "a", 0, 0),
+			/* , */ lexer.NewToken(lexer.Comma, 0, "", 0, 0),
+			/* sqrt */ lexer.NewToken(lexer.Identifier, 0, "sqrt", 0, 0),
+			/* ( */ lexer.NewToken(lexer.LPar, 0, "", 0, 0),
+			/* 18 */ lexer.NewToken(lexer.Number, 18, "", 0, 0),
+			/* ) */ lexer.NewToken(lexer.RPar, 0, "", 0, 0),
+			/* , */ lexer.NewToken(lexer.Comma, 0, "", 0, 0),
+			/* sqrt */ lexer.NewToken(lexer.Identifier, 0, "sqrt", 0, 0),
+			/* ( */ lexer.NewToken(lexer.LPar, 0, "", 0, 0),
+			/* 25 */ lexer.NewToken(lexer.Number, 25, "", 0, 0),
+			/* ) */ lexer.NewToken(lexer.RPar, 0, "", 0, 0),
+			/* , */ lexer.NewToken(lexer.Comma, 0, "", 0, 0),
+			/* c */ lexer.NewToken(lexer.Identifier, 0, "c", 0, 0),
+			/* ) */ lexer.NewToken(lexer.RPar, 0, "", 0, 0),
+			/* ) */ lexer.NewToken(lexer.RPar, 0, "", 0, 0),
+			/* ) */ lexer.NewToken(lexer.RPar, 0, "", 0, 0),
+			lexer.NewToken(lexer.EOL, 0, "", 0, 0),
+		}
+
+		rootNode, err := p.Parse(input)
+		Expect(err).To(Succeed())
+		Expect(rootNode).NotTo(BeNil())
+		Expect(rootNode).To(SurviveJSONRoundTrip())
+
+		Expect(rootNode).To(MatchAssignNode(
+			MatchVariableNode("c"),
+			MatchFunctionNode(
+				"min",
+				MatchFunctionNode("sin", MatchBinaryNode(ast.Multiplication, MatchNumericNode(4), MatchNumericNode(7))),
+				MatchFunctionNode("cos", MatchFunctionNode(
+					"max",
+					MatchVariableNode("a"),
+					MatchFunctionNode("sqrt", MatchNumericNode(18)),
+					MatchFunctionNode("sqrt", MatchNumericNode(25)),
+					MatchVariableNode("c"),
+				)),
+			),
+		))
+	})
+})
+
+var _ = DescribeTable("Handle errors",
+	func(input []*lexer.Token, posMatcher, errMatcher types.GomegaMatcher) {
+		p, err := pratt.NewParser(parser.DefaultTokenPriorities())
+		Expect(err).To(Succeed())
+
+		rootNode, err := p.Parse(input)
+		Expect(rootNode).To(BeNil())
+
+		parseErr, ok := err.(*parser.Error)
+		Expect(ok).To(BeTrue())
+		Expect(parseErr.Position()).To(posMatcher)
+		Expect(parseErr.Error()).To(errMatcher)
+	},
+	Entry("Empty token list", []*lexer.Token{}, Equal(-1), ContainSubstring(pratt.ErrEmptyInput.Error())),
+	Entry("Empty token list", []*lexer.Token{
+		lexer.NewToken(lexer.EOL, 0, "", 0, 0),
+	}, Equal(0), ContainSubstring(pratt.ErrEmptyInput.Error())),
+
+	Entry("Not EOL at the end", []*lexer.Token{
+		lexer.NewToken(lexer.Identifier, 0, "abc", 0, 3),
+	}, Equal(0), ContainSubstring(pratt.ErrExpectedEOL.Error())),
+
+	Entry("Missing function argument after comma", []*lexer.Token{
+		lexer.NewToken(lexer.Identifier, 0, "max", 0, 3),
+		lexer.NewToken(lexer.LPar, 0, "", 3, 4),
+		lexer.NewToken(lexer.Number, 23, "", 4, 6),
+		lexer.NewToken(lexer.Comma, 0, "", 6, 7),
+		lexer.NewToken(lexer.RPar, 0, "", 7, 8),
+		lexer.NewToken(lexer.EOL, 0, "", 8, 8),
+	}, Equal(7), ContainSubstring("expected number, identifier or left parenthesis; found RPar token at position 7")),
+
+	Entry("Two number tokens in a row", []*lexer.Token{
+		lexer.NewToken(lexer.Number, 20, "", 0, 2),
+		lexer.NewToken(lexer.Whitespace, 0, " ", 2, 3),
+		lexer.NewToken(lexer.Number, 20, "", 3, 5),
+		lexer.NewToken(lexer.EOL, 0, "", 5, 5),
+	}, Equal(3), ContainSubstring("unexpected token; found Number token at position 3")),
+
+	Entry("First token must be operand",
+		[]*lexer.Token{
+			lexer.NewToken(lexer.Whitespace, 0, " ", 0, 1),
+			lexer.NewToken(lexer.Division, 0, "", 1, 2),
+			lexer.NewToken(lexer.EOL, 0, "", 2, 2),
+		},
+		Equal(1),
+		ContainSubstring("expected number, identifier or left parenthesis; found Division token at position 1"),
+	),
+	Entry("Number followed by identifier", []*lexer.Token{
+		lexer.NewToken(lexer.Number, 20, "", 0, 2),
+		lexer.NewToken(lexer.Whitespace, 0, "  ", 3, 4),
+		lexer.NewToken(lexer.Identifier, 0, "VariableName", 4, 16),
+		lexer.NewToken(lexer.EOL, 0, "", 16, 16),
+	}, Equal(4), ContainSubstring("unexpected token; found Identifier token at position 4")),
+
+	Entry("Multiple unary operators in a row",
+		[]*lexer.Token{
+			lexer.NewToken(lexer.Substraction, 0, "", 0, 1),
+			lexer.NewToken(lexer.Identifier, 0, "var", 2, 5),
+			lexer.NewToken(lexer.Addition, 0, "", 5, 6),
+			lexer.NewToken(lexer.Substraction, 0, "", 6, 7),
+			lexer.NewToken(lexer.Substraction, 0, "", 7, 8),
+			lexer.NewToken(lexer.EOL, 0, "", 8, 8),
+		},
+		Equal(8),
+		ContainSubstring("expected number, identifier or left parenthesis; found EOL token at position 8"),
+	),
+	Entry("Only unary operators in a row",
+		[]*lexer.Token{
+			lexer.NewToken(lexer.Substraction, 0, "", 0, 1),
+			lexer.NewToken(lexer.Substraction, 0, "", 1, 2),
+			lexer.NewToken(lexer.EOL, 0, "", 2, 2),
+		},
+		Equal(2),
+		ContainSubstring("expected number, identifier or left parenthesis; found EOL token at position 2"),
+	),
+	Entry("Missing right operand after unary",
+		[]*lexer.Token{
+			lexer.NewToken(lexer.Number, 10, "", 0, 2),
+			lexer.NewToken(lexer.Substraction, 0, "", 2, 3),
+			lexer.NewToken(lexer.Substraction, 0, "", 3, 4),
+			lexer.NewToken(lexer.EOL, 0, "", 4, 4),
+		},
+		Equal(4),
+		ContainSubstring("expected number, identifier or left parenthesis; found EOL token at position 4"),
+	),
+	Entry("Assign to number is not valid",
+		[]*lexer.Token{
+			lexer.NewToken(lexer.Number, 20, "", 0, 2),
+			lexer.NewToken(lexer.Addition, 0, "", 2, 3),
+			lexer.NewToken(lexer.Number, 77, "", 3, 5),
+			lexer.NewToken(lexer.Equal, 0, "", 5, 6),
+			lexer.NewToken(lexer.Number, 99, "", 3, 5),
+			lexer.NewToken(lexer.EOL, 0, "", 6, 6),
+		},
+		Equal(5),
+		ContainSubstring("only variable can be assigned; found Equal token at position 5"),
+	),
+	Entry("Assign to number inside parentheses is not valid",
+		[]*lexer.Token{
+			lexer.NewToken(lexer.Number, 20, "", 0, 2),
+			lexer.NewToken(lexer.Addition, 0, "", 2, 3),
+			lexer.NewToken(lexer.LPar, 0, "", 3, 4),
+			lexer.NewToken(lexer.Number, 3, "", 4, 5),
+			lexer.NewToken(lexer.Equal, 0, "", 5, 6),
+			lexer.NewToken(lexer.Number, 99, "", 6, 8),
+			lexer.NewToken(lexer.RPar, 0, "", 8, 9),
+			lexer.NewToken(lexer.EOL, 0, "", 9, 9),
+		},
+		Equal(5),
+		ContainSubstring("only variable can be assigned; found Equal token at position 5"),
+	),
+	Entry("Duplicate parameter in function definition",
+		[]*lexer.Token{
+			lexer.NewToken(lexer.Identifier, 0, "f", 0, 1),
+			lexer.NewToken(lexer.LPar, 0, "", 1, 2),
+			lexer.NewToken(lexer.Identifier, 0, "x", 2, 3),
+			lexer.NewToken(lexer.Comma, 0, "", 3, 4),
+			lexer.NewToken(lexer.Identifier, 0, "X", 5, 6),
+			lexer.NewToken(lexer.RPar, 0, "", 6, 7),
+			lexer.NewToken(lexer.Equal, 0, "", 7, 8),
+			lexer.NewToken(lexer.Number, 1, "", 8, 9),
+			lexer.NewToken(lexer.EOL, 0, "", 9, 9),
+		},
+		Equal(5),
+		ContainSubstring("duplicate parameter name in function definition; found Identifier token at position 5"),
+	),
+	Entry("Missing function body",
+		[]*lexer.Token{
+			lexer.NewToken(lexer.Identifier, 0, "f", 0, 1),
+			lexer.NewToken(lexer.LPar, 0, "", 1, 2),
+			lexer.NewToken(lexer.Identifier, 0, "x", 2, 3),
+			lexer.NewToken(lexer.RPar, 0, "", 3, 4),
+			lexer.NewToken(lexer.Equal, 0, "", 4, 5),
+			lexer.NewToken(lexer.EOL, 0, "", 5, 5),
+		},
+		Equal(5),
+		ContainSubstring("expected number, identifier or left parenthesis; found EOL token at position 5"),
+	),
+	Entry("Function definition with non identifier parameter",
+		[]*lexer.Token{
+			lexer.NewToken(lexer.Identifier, 0, "f", 0, 1),
+			lexer.NewToken(lexer.LPar, 0, "", 1, 2),
+			lexer.NewToken(lexer.Number, 2, "", 2, 3),
+			lexer.NewToken(lexer.RPar, 0, "", 3, 4),
+			lexer.NewToken(lexer.Equal, 0, "", 4, 5),
+			lexer.NewToken(lexer.Number, 1, "", 5, 6),
+			lexer.NewToken(lexer.EOL, 0, "", 6, 6),
+		},
+		Equal(4),
+		ContainSubstring("only variable can be assigned; found Equal token at position 4"),
+	),
+	Entry("Unexpected operator after another operator - not unary",
+		[]*lexer.Token{
+			lexer.NewToken(lexer.Number, 20, "", 0, 2),
+			lexer.NewToken(lexer.Addition, 0, "", 2, 3),
+			lexer.NewToken(lexer.Division, 0, "", 3, 4),
+			lexer.NewToken(lexer.Number, 20, "", 0, 2),
+			lexer.NewToken(lexer.EOL, 0, "", 4, 4),
+		},
+		Equal(3),
+		ContainSubstring("expected number, identifier or left parenthesis; found Division token at position 3"),
+	),
+	Entry("Logical negation is not binary operator",
+		[]*lexer.Token{
+			lexer.NewToken(lexer.Identifier, 0, "a", 0, 1),
+			lexer.NewToken(lexer.Not, 0, "", 2, 3),
+			lexer.NewToken(lexer.Identifier, 0, "b", 4, 5),
+			lexer.NewToken(lexer.EOL, 0, "", 5, 5),
+		},
+		Equal(2),
+		ContainSubstring("types, got 'Not'; found Not token at position 2"),
+	),
+	Entry("Factorial cannot be followed by operand",
+		[]*lexer.Token{
+			lexer.NewToken(lexer.Number, 3, "", 0, 1),
+			lexer.NewToken(lexer.Not, 0, "", 1, 2),
+			lexer.NewToken(lexer.Number, 4, "", 3, 4),
+			lexer.NewToken(lexer.EOL, 0, "", 4, 4),
+		},
+		Equal(1),
+		ContainSubstring("types, got 'Not'; found Not token at position 1"),
+	),
+	Entry("Missing operand of comparison",
+		[]*lexer.Token{
+			lexer.NewToken(lexer.Identifier, 0, "a", 0, 1),
+			lexer.NewToken(lexer.LessOrEqual, 0, "", 2, 4),
+			lexer.NewToken(lexer.And, 0, "", 5, 7),
+			lexer.NewToken(lexer.Identifier, 0, "b", 8, 9),
+			lexer.NewToken(lexer.EOL, 0, "", 9, 9),
+		},
+		Equal(5),
+		ContainSubstring("expected number, identifier or left parenthesis; found And token at position 5"),
+	),
+	Entry("Conditional without condition",
+		[]*lexer.Token{
+			lexer.NewToken(lexer.Question, 0, "", 0, 1),
+			lexer.NewToken(lexer.Identifier, 0, "a", 2, 3),
+			lexer.NewToken(lexer.Colon, 0, "", 4, 5),
+			lexer.NewToken(lexer.Identifier, 0, "b", 6, 7),
+			lexer.NewToken(lexer.EOL, 0, "", 7, 7),
+		},
+		Equal(0),
+		ContainSubstring("expected number, identifier or left parenthesis; found Question token at position 0"),
+	),
+	Entry("Conditional without middle part",
+		[]*lexer.Token{
+			lexer.NewToken(lexer.Identifier, 0, "a", 0, 1),
+			lexer.NewToken(lexer.Question, 0, "", 2, 3),
+			lexer.NewToken(lexer.Colon, 0, "", 4, 5),
+			lexer.NewToken(lexer.Identifier, 0, "b", 6, 7),
+			lexer.NewToken(lexer.EOL, 0, "", 7, 7),
+		},
+		Equal(4),
+		ContainSubstring("expected number, identifier or left parenthesis; found Colon token at position 4"),
+	),
+	Entry("Conditional without last part",
+		[]*lexer.Token{
+			lexer.NewToken(lexer.Identifier, 0, "a", 0, 1),
+			lexer.NewToken(lexer.Question, 0, "", 2, 3),
+			lexer.NewToken(lexer.Identifier, 0, "b", 4, 5),
+			lexer.NewToken(lexer.Colon, 0, "", 6, 7),
+			lexer.NewToken(lexer.EOL, 0, "", 7, 7),
+		},
+		Equal(7),
+		ContainSubstring("found EOL token at position 7"),
+	),
+	Entry("Conditional without colon",
+		[]*lexer.Token{
+			lexer.NewToken(lexer.Identifier, 0, "a", 0, 1),
+			lexer.NewToken(lexer.Question, 0, "", 2, 3),
+			lexer.NewToken(lexer.Identifier, 0, "b", 4, 5),
+			lexer.NewToken(lexer.EOL, 0, "", 5, 5),
+		},
+		Equal(5),
+		ContainSubstring("expected 'Colon' type, got 'EOL'; found EOL token at position 5"),
+	),
+	Entry("Colon without question mark",
+		[]*lexer.Token{
+			lexer.NewToken(lexer.Identifier, 0, "a", 0, 1),
+			lexer.NewToken(lexer.Colon, 0, "", 2, 3),
+			lexer.NewToken(lexer.Identifier, 0, "b", 4, 5),
+			lexer.NewToken(lexer.EOL, 0, "", 5, 5),
+		},
+		Equal(2),
+		ContainSubstring("unexpected token; found Colon token at position 2"),
+	),
+	Entry("Found left parenthesis, expecting operator",
+		[]*lexer.Token{
+			lexer.NewToken(lexer.Number, 20, "", 0, 2),
+			lexer.NewToken(lexer.LPar, 0, "", 2, 3),
+			lexer.NewToken(lexer.RPar, 0, "", 3, 4),
+			lexer.NewToken(lexer.EOL, 0, "", 4, 4),
+		},
+		Equal(2),
+		ContainSubstring("unexpected token; found LPar token at position 2"),
+	),
+	Entry("Found right parenthesis, expecting operand",
+		[]*lexer.Token{
+			lexer.NewToken(lexer.LPar, 0, "", 0, 1),
+			lexer.NewToken(lexer.Number, 20, "", 1, 3),
+			lexer.NewToken(lexer.Exponent, 0, "", 3, 5),
+			lexer.NewToken(lexer.RPar, 0, "", 5, 6),
+			lexer.NewToken(lexer.EOL, 0, "", 6, 6),
+		},
+		Equal(5),
+		ContainSubstring("expected number, identifier or left parenthesis; found RPar token at position 5"),
+	),
+	Entry("Found EOL, expecting operand",
+		[]*lexer.Token{
+			lexer.NewToken(lexer.Number, 20, "", 0, 2),
+			lexer.NewToken(lexer.FloorDiv, 0, "", 2, 3),
+			lexer.NewToken(lexer.EOL, 0, "", 3, 3),
+		},
+		Equal(3),
+		ContainSubstring("expected number, identifier or left parenthesis; found EOL token at position 3"),
+	),
+	Entry("Extra left parenthesis",
+		[]*lexer.Token{
+			lexer.NewToken(lexer.Whitespace, 0, "    ", 0, 4),
+			lexer.NewToken(lexer.LPar, 0, "", 4, 5),
+			lexer.NewToken(lexer.LPar, 0, "", 5, 6),
+			lexer.NewToken(lexer.Number, 123, "", 6, 9),
+			lexer.NewToken(lexer.RPar, 0, "", 9, 10),
+			lexer.NewToken(lexer.EOL, 0, "", 10, 10),
+		},
+		Equal(10),
+		ContainSubstring("expected 'RPar' type, got 'EOL'; found EOL token at position 10"),
+	),
+	Entry("Extra right parenthesis",
+		[]*lexer.Token{
+			lexer.NewToken(lexer.Whitespace, 0, "  ", 0, 2),
+			lexer.NewToken(lexer.LPar, 0, "", 2, 3),
+			lexer.NewToken(lexer.Number, 123, "", 3, 6),
+			lexer.NewToken(lexer.RPar, 0, "", 7, 8),
+			lexer.NewToken(lexer.RPar, 0, "", 8, 9),
+			lexer.NewToken(lexer.EOL, 0, "", 9, 9),
+		},
+		Equal(8),
+		ContainSubstring("unexpected token; found RPar token at position 8"),
+	),
+)
+
+var _ = DescribeTable("Recover from errors",
+	func(expression string, nodeMatcher types.GomegaMatcher, diagnostics ...string) {
+		p, err := pratt.NewParser(parser.DefaultTokenPriorities())
+		Expect(err).To(Succeed())
+		input, err := lexer.NewLexer(expression).Tokenize()
+		Expect(err).To(Succeed())
+
+		rootNode, errs := p.(parser.RecoveringParser).ParseWithRecovery(input)
+		Expect(rootNode).To(nodeMatcher)
+		Expect(rootNode).To(SurviveJSONRoundTrip())
+
+		Expect(errs).To(HaveLen(len(diagnostics)))
+		for i, d := range diagnostics {
+			Expect(errs[i].Error()).To(ContainSubstring(d))
+		}
+	},
+	Entry("Valid input has no diagnostics",
+		"max(1, 2)",
+		MatchFunctionNode("max", MatchNumericNode(1), MatchNumericNode(2)),
+	),
+	Entry("Missing function argument",
+		"max(1, , 3)",
+		MatchFunctionNode("max", MatchNumericNode(1), MatchErrorNode(), MatchNumericNode(3)),
+		"expected number, identifier or left parenthesis; found Comma token at position 7",
+	),
+	Entry("Invalid function argument",
+		"max(1 2, 3)",
+		MatchFunctionNode("max", MatchErrorNode(), MatchNumericNode(3)),
+		"expected 'RPar' type, got 'Number'; found Number token at position 6",
+	),
+	Entry("Invalid expression in parenthesis",
+		"-(1 +) * 2",
+		MatchBinaryNode(ast.Multiplication, MatchUnaryNode(ast.Substraction, MatchErrorNode()), MatchNumericNode(2)),
+		"expected number, identifier or left parenthesis; found RPar token at position 5",
+	),
+	Entry("Missing right parenthesis",
+		"max(1, 2",
+		MatchFunctionNode("max", MatchNumericNode(1), MatchNumericNode(2)),
+		"expected 'RPar' type, got 'EOL'; found EOL token at position 8",
+	),
+	Entry("Extra right parenthesis",
+		"1 + 2)",
+		MatchBinaryNode(ast.Addition, MatchNumericNode(1), MatchNumericNode(2)),
+		"unexpected token; found RPar token at position 5",
+	),
+	Entry("Errors in nested functions",
+		"min(sin(1 +), 2 * )",
+		MatchFunctionNode("min", MatchFunctionNode("sin", MatchErrorNode()), MatchErrorNode()),
+		"expected number, identifier or left parenthesis; found RPar token at position 11",
+		"expected number, identifier or left parenthesis; found RPar token at position 18",
+	),
+	Entry("Invalid statement outside of parenthesis",
+		"1, 2",
+		MatchErrorNode(),
+		"unexpected token; found Comma token at position 1",
+	),
+	Entry("Empty input",
+		"",
+		MatchErrorNode(),
+		"there are no tokens to parse; found EOL token at position 0",
+	),
+	Entry("Errors in multiple statements",
+		"1 + * 2; max(2, 3 +)\n(4 - 1",
+		MatchBlockNode(
+			MatchErrorNode(),
+			MatchFunctionNode("max", MatchNumericNode(2), MatchErrorNode()),
+			MatchBinaryNode(ast.Substraction, MatchNumericNode(4), MatchNumericNode(1)),
+		),
+		"expected number, identifier or left parenthesis; found Multiplication token at position 4",
+		"expected number, identifier or left parenthesis; found RPar token at position 19",
+		"expected 'RPar' type, got 'EOL'; found EOL token at line 2, column 7",
+	),
+)
+
+var _ = DescribeTable("Limits",
+	func(expression string, limits parser.Limits, expectedErr error, message string) {
+		p, err := pratt.NewParser(parser.DefaultTokenPriorities())
+		Expect(err).To(Succeed())
+		p.(parser.LimitedParser).SetLimits(limits)
+		input, err := lexer.NewLexer(expression).Tokenize()
+		Expect(err).To(Succeed())
+
+		rootNode, err := p.Parse(input)
+		if expectedErr == nil {
+			Expect(err).To(Succeed())
+			Expect(rootNode).ToNot(BeNil())
+			return
+		}
+		Expect(rootNode).To(BeNil())
+		Expect(errors.Is(err, expectedErr)).To(BeTrue())
+		Expect(err).To(MatchError(message))
+	},
+	Entry("Within limits", "max(1, 2 * (3 + 4))", parser.Limits{Depth: 4, Nodes: 7}, nil, ""),
+	Entry("Left associative operators", "1 - 2 - 3 - 4", parser.Limits{Depth: 3}, parser.ErrDepthLimit,
+		"expression exceeded the limit of depth; found Number token at position 0"),
+	Entry("Nested parentheses", "((((1))))", parser.Limits{Depth: 3}, parser.ErrDepthLimit,
+		"expression exceeded the limit of depth; found LPar token at position 3"),
+	Entry("Deeply nested parentheses",
+		strings.Repeat("(", 100000)+"1"+strings.Repeat(")", 100000), parser.Limits{Depth: 100},
+		parser.ErrDepthLimit, "expression exceeded the limit of depth; found LPar token at position 100"),
+	Entry("Unary operators", "- - - 1", parser.Limits{Depth: 3}, parser.ErrDepthLimit,
+		"expression exceeded the limit of depth; found Number token at position 6"),
+	Entry("Right associative operators", "2 ^ 2 ^ 2 ^ 2", parser.Limits{Depth: 3}, parser.ErrDepthLimit,
+		"expression exceeded the limit of depth; found Number token at position 12"),
+	Entry("Function arguments", "max(1, max(2, 3))", parser.Limits{Depth: 2}, parser.ErrDepthLimit,
+		"expression exceeded the limit of depth; found Number token at position 11"),
+	Entry("Statements are checked separately", "1 - 2; -(-3)", parser.Limits{Depth: 3}, nil, ""),
+	Entry("Number of nodes", "1 + 2 * 3", parser.Limits{Nodes: 4}, parser.ErrNodeLimit,
+		"expression exceeded the limit of nodes; found Number token at position 8"),
+	Entry("Number of nodes in all statements", "1 + 2; 3 * 4", parser.Limits{Nodes: 4}, parser.ErrNodeLimit,
+		"expression exceeded the limit of nodes; found Number token at position 7"),
+)
+
+var _ = Describe("Custom parselets", func() {
+	It("Registers postfix operator next to the prefix one", func() {
+		p, err := pratt.NewParser(parser.DefaultTokenPriorities())
+		Expect(err).To(Succeed())
+		p.(*pratt.Parser).RegisterPostfix(lexer.Not,
+			func(s *pratt.State, left ast.Node, token *lexer.Token) (ast.Node, error) {
+				return ast.NewFunctionNode("factorial", []ast.Node{left}, token), nil
+			},
+		)
+		input, err := lexer.NewLexer("!a + 3! * 2").Tokenize()
+		Expect(err).To(Succeed())
+
+		rootNode, err := p.Parse(input)
+		Expect(err).To(Succeed())
+		Expect(rootNode).To(MatchBinaryNode(
+			ast.Addition,
+			MatchUnaryNode(ast.Not, MatchVariableNode("a")),
+			MatchBinaryNode(
+				ast.Multiplication,
+				MatchFunctionNode("factorial", MatchNumericNode(3)),
+				MatchNumericNode(2),
+			),
+		))
+	})
+
+	It("Replaces infix operator", func() {
+		p, err := pratt.NewParser(parser.DefaultTokenPriorities())
+		Expect(err).To(Succeed())
+		p.(*pratt.Parser).RegisterInfix(lexer.Modulus,
+			func(s *pratt.State, left ast.Node, token *lexer.Token) (ast.Node, error) {
+				right, err := s.ParseExpression(s.OperandPrecedence(token.Type()))
+				if err != nil {
+					return nil, err
+				}
+				return ast.NewFunctionNode("mod", []ast.Node{left, right}, token), nil
+			},
+		)
+		input, err := lexer.NewLexer("1 + 7 % 4 % 3").Tokenize()
+		Expect(err).To(Succeed())
+
+		rootNode, err := p.Parse(input)
+		Expect(err).To(Succeed())
+		Expect(rootNode).To(MatchBinaryNode(
+			ast.Addition,
+			MatchNumericNode(1),
+			MatchFunctionNode("mod",
+				MatchFunctionNode("mod", MatchNumericNode(7), MatchNumericNode(4)),
+				MatchNumericNode(3),
+			),
+		))
+	})
+})
+
+var _ = DescribeTable("Postfix operators",
+	func(expression string, nodeMatcher types.GomegaMatcher) {
+		p, err := pratt.NewParser(parser.DefaultTokenPriorities())
+		Expect(err).To(Succeed())
+		input, err := lexer.NewLexer(expression).Tokenize()
+		Expect(err).To(Succeed())
+
+		rootNode, err := p.Parse(input)
+		Expect(err).To(Succeed())
+		Expect(rootNode).To(nodeMatcher)
+		Expect(rootNode).To(SurviveJSONRoundTrip())
+	},
+	Entry("Factorial", "3!", MatchPostfixNode(ast.Factorial, MatchNumericNode(3))),
+	Entry("Factorial of parenthesis", "(a + 1)!",
+		MatchPostfixNode(ast.Factorial, MatchBinaryNode(ast.Addition, MatchVariableNode("a"), MatchNumericNode(1))),
+	),
+	Entry("Factorial binds stronger than unary operator", "-3!",
+		MatchUnaryNode(ast.Substraction, MatchPostfixNode(ast.Factorial, MatchNumericNode(3))),
+	),
+	Entry("Logical negation of factorial", "!3!",
+		MatchUnaryNode(ast.Not, MatchPostfixNode(ast.Factorial, MatchNumericNode(3))),
+	),
+	Entry("Factorial in exponent", "2 ^ 3!",
+		MatchBinaryNode(ast.Exponent, MatchNumericNode(2), MatchPostfixNode(ast.Factorial, MatchNumericNode(3))),
+	),
+	Entry("Factorial before multiplication", "3! * 2",
+		MatchBinaryNode(ast.Multiplication, MatchPostfixNode(ast.Factorial, MatchNumericNode(3)), MatchNumericNode(2)),
+	),
+	Entry("Repeated factorial", "3!!",
+		MatchPostfixNode(ast.Factorial, MatchPostfixNode(ast.Factorial, MatchNumericNode(3))),
+	),
+	Entry("Factorial compared", "a! != 1",
+		MatchBinaryNode(ast.NotEqual, MatchPostfixNode(ast.Factorial, MatchVariableNode("a")), MatchNumericNode(1)),
+	),
+	Entry("Factorial in function argument", "max(3!, 2)",
+		MatchFunctionNode("max", MatchPostfixNode(ast.Factorial, MatchNumericNode(3)), MatchNumericNode(2)),
+	),
+	Entry("Percent", "50%", MatchPostfixNode(ast.Percent, MatchNumericNode(50))),
+	Entry("Percent added to the left operand", "100 + 10%",
+		MatchBinaryNode(ast.Addition, MatchNumericNode(100), MatchPostfixNode(ast.Percent, MatchNumericNode(10))),
+	),
+	Entry("Percent followed by operator", "10% * 5",
+		MatchBinaryNode(ast.Multiplication, MatchPostfixNode(ast.Percent, MatchNumericNode(10)), MatchNumericNode(5)),
+	),
+	Entry("Percent followed by sign in parentheses", "(10%) - 5",
+		MatchBinaryNode(ast.Substraction, MatchPostfixNode(ast.Percent, MatchNumericNode(10)), MatchNumericNode(5)),
+	),
+	Entry("Percent followed by sign", "100 + 10% - 5",
+		MatchBinaryNode(ast.Substraction,
+			MatchBinaryNode(ast.Addition, MatchNumericNode(100), MatchPostfixNode(ast.Percent, MatchNumericNode(10))),
+			MatchNumericNode(5),
+		),
+	),
+	Entry("Percent of percent", "10% + 20%",
+		MatchBinaryNode(ast.Addition,
+			MatchPostfixNode(ast.Percent, MatchNumericNode(10)), MatchPostfixNode(ast.Percent, MatchNumericNode(20)),
+		),
+	),
+	Entry("Percent followed by substraction", "10% - 1",
+		MatchBinaryNode(ast.Substraction, MatchPostfixNode(ast.Percent, MatchNumericNode(10)), MatchNumericNode(1)),
+	),
+	Entry("Factorial of percent", "a + 10%!",
+		MatchBinaryNode(ast.Addition,
+			MatchVariableNode("a"),
+			MatchPostfixNode(ast.Factorial, MatchPostfixNode(ast.Percent, MatchNumericNode(10))),
+		),
+	),
+	Entry("Percent at the end after space", "10 %",
+		MatchPostfixNode(ast.Percent, MatchNumericNode(10)),
+	),
+	Entry("Percent of factorial", "3!%",
+		MatchPostfixNode(ast.Percent, MatchPostfixNode(ast.Factorial, MatchNumericNode(3))),
+	),
+	Entry("Modulus", "10 % 3",
+		MatchBinaryNode(ast.Modulus, MatchNumericNode(10), MatchNumericNode(3)),
+	),
+	Entry("Modulus of negative number", "10 % (-3)",
+		MatchBinaryNode(ast.Modulus, MatchNumericNode(10), MatchUnaryNode(ast.Substraction, MatchNumericNode(3))),
+	),
+	Entry("Modulus followed by sign", "10 % -3 + 10 % +x",
+		MatchBinaryNode(ast.Addition,
+			MatchBinaryNode(ast.Modulus, MatchNumericNode(10), MatchUnaryNode(ast.Substraction, MatchNumericNode(3))),
+			MatchBinaryNode(ast.Modulus, MatchNumericNode(10), MatchUnaryNode(ast.Addition, MatchVariableNode("x"))),
+		),
+	),
+	Entry("Modulus followed by negation", "10 % !x",
+		MatchBinaryNode(ast.Modulus, MatchNumericNode(10), MatchUnaryNode(ast.Not, MatchVariableNode("x"))),
+	),
+)
+
+var _ = DescribeTable("Implicit multiplication",
+	func(expression string, precedence parser.TokenPrecedence, nodeMatcher types.GomegaMatcher) {
+		priorities := parser.DefaultTokenPriorities()
+		if precedence > 0 {
+			priorities[lexer.ImplicitMultiplication] = parser.TokenMeta{Precedence: precedence}
+		}
+		p, err := pratt.NewParser(priorities)
+		Expect(err).To(Succeed())
+		p.(parser.ImplicitMultiplicationParser).SetImplicitMultiplication(parser.ImplicitMultiplication{
+			Enabled:    true,
+			IsFunction: func(name string) bool { return name == "sin" },
+		})
+		input, err := lexer.NewLexer(expression).Tokenize()
+		Expect(err).To(Succeed())
+
+		rootNode, err := p.Parse(input)
+		Expect(err).To(Succeed())
+		Expect(rootNode).To(nodeMatcher)
+		Expect(rootNode).To(SurviveJSONRoundTrip())
+	},
+	Entry("Number and identifier", "2x", parser.TokenPrecedence(0),
+		MatchImplicitMultiplication(MatchNumericNode(2), MatchVariableNode("x")),
+	),
+	Entry("Number and parenthesis", "3(a + b)", parser.TokenPrecedence(0),
+		MatchImplicitMultiplication(
+			MatchNumericNode(3),
+			MatchBinaryNode(ast.Addition, MatchVariableNode("a"), MatchVariableNode("b")),
+		),
+	),
+	Entry("Parentheses", "(a)(b)", parser.TokenPrecedence(0),
+		MatchImplicitMultiplication(MatchVariableNode("a"), MatchVariableNode("b")),
+	),
+	Entry("Identifier and parenthesis", "x(y + 1)", parser.TokenPrecedence(0),
+		MatchImplicitMultiplication(
+			MatchVariableNode("x"),
+			MatchBinaryNode(ast.Addition, MatchVariableNode("y"), MatchNumericNode(1)),
+		),
+	),
+	Entry("Known function is not multiplied", "2 sin(x)", parser.TokenPrecedence(0),
+		MatchImplicitMultiplication(MatchNumericNode(2), MatchFunctionNode("sin", MatchVariableNode("x"))),
+	),
+	Entry("Same precedence as multiplication", "1 / 2x", parser.TokenPrecedence(0),
+		MatchImplicitMultiplication(
+			MatchBinaryNode(ast.Division, MatchNumericNode(1), MatchNumericNode(2)),
+			MatchVariableNode("x"),
+		),
+	),
+	Entry("Higher precedence than multiplication", "1 / 2x", parser.TokenPrecedence(50),
+		MatchBinaryNode(
+			ast.Division,
+			MatchNumericNode(1),
+			MatchImplicitMultiplication(MatchNumericNode(2), MatchVariableNode("x")),
+		),
+	),
+	Entry("Written multiplication is not marked", "2 * x", parser.TokenPrecedence(0),
+		MatchBinaryNode(ast.Multiplication, MatchNumericNode(2), MatchVariableNode("x")),
+	),
+	Entry("Function definition", "f(x) = 2x", parser.TokenPrecedence(0),
+		MatchFunctionDefNode("f", []string{"x"},
+			MatchImplicitMultiplication(MatchNumericNode(2), MatchVariableNode("x")),
+		),
+	),
+)
@@ -0,0 +1,169 @@
+package pratt
+
+import (
+	"github.com/arxeiss/go-expression-calculator/ast"
+	"github.com/arxeiss/go-expression-calculator/lexer"
+	"github.com/arxeiss/go-expression-calculator/parser"
+)
+
+var (
+	binaryOperations = map[lexer.TokenType]ast.Operation{
+		lexer.Addition:       ast.Addition,
+		lexer.Substraction:   ast.Substraction,
+		lexer.Multiplication: ast.Multiplication,
+		lexer.Division:       ast.Division,
+		lexer.FloorDiv:       ast.FloorDiv,
+		lexer.Modulus:        ast.Modulus,
+		lexer.Exponent:       ast.Exponent,
+		lexer.Less:           ast.Less,
+		lexer.LessOrEqual:    ast.LessOrEqual,
+		lexer.Greater:        ast.Greater,
+		lexer.GreaterOrEqual: ast.GreaterOrEqual,
+		lexer.IsEqual:        ast.IsEqual,
+		lexer.NotEqual:       ast.NotEqual,
+		lexer.And:            ast.And,
+		lexer.Or:             ast.Or,
+
+		// Registered by SetImplicitMultiplication only
+		lexer.ImplicitMultiplication: ast.Multiplication,
+	}
+	unaryOperations = map[lexer.TokenType]ast.Operation{
+		lexer.UnaryAddition:     ast.Addition,
+		lexer.UnarySubstraction: ast.Substraction,
+		lexer.Not:               ast.Not,
+	}
+	postfixOperations = map[lexer.TokenType]ast.Operation{
+		lexer.Factorial: ast.Factorial,
+		lexer.Percent:   ast.Percent,
+	}
+)
+
+func (p *Parser) registerDefaults() {
+	p.RegisterPrefix(lexer.Number, parseNumber)
+	p.RegisterPrefix(lexer.Identifier, parseIdentifier)
+	p.RegisterPrefix(lexer.LPar, parseParenthesis)
+	p.RegisterPrefix(lexer.Invalid, parseInvalid)
+	for _, tokenType := range []lexer.TokenType{lexer.Addition, lexer.Substraction, lexer.Not} {
+		p.RegisterPrefix(tokenType, parseUnary)
+	}
+	for tokenType := range binaryOperations {
+		if tokenType != lexer.ImplicitMultiplication {
+			p.RegisterInfix(tokenType, parseBinary)
+		}
+	}
+	p.RegisterInfix(lexer.Equal, parseAssign)
+	p.RegisterInfix(lexer.Question, parseConditional)
+	p.RegisterPostfix(lexer.Not, parsePostfix)
+	p.RegisterPostfix(lexer.Modulus, parsePostfix)
+}
+
+func parseNumber(s *State, token *lexer.Token) (ast.Node, error) {
+	return ast.NewNumericNode(token.Value(), token), nil
+}
+
+// parseInvalid returns placeholder inserted by error recovery
+func parseInvalid(s *State, token *lexer.Token) (ast.Node, error) {
+	return ast.NewErrorNode(token), nil
+}
+
+// parseIdentifier returns variable, or function call if the identifier is followed by left parenthesis
+func parseIdentifier(s *State, token *lexer.Token) (ast.Node, error) {
+	if !s.Has(lexer.LPar) {
+		return ast.NewVariableNode(token.Identifier(), token), nil
+	}
+	lPar, _ := s.Expect()
+	if err := s.openPar(lPar); err != nil {
+		return nil, err
+	}
+	args := []ast.Node{}
+	// When there is nothing to start an argument, it is most likely the end.
+	// Argument is required after the comma, so trailing comma like max(1,) is not valid
+	for s.hasPrefix() || len(args) > 0 {
+		node, err := s.ParseExpression(s.parser.priorities.MinPrecedence())
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, node)
+		if !s.Has(lexer.Comma) {
+			break
+		}
+		_, _ = s.Expect() // Pop out the comma
+	}
+	// Function call must end with right parenthesis
+	if err := s.closePar(); err != nil {
+		return nil, err
+	}
+	return ast.NewFunctionNode(token.Identifier(), args, token), nil
+}
+
+// parseParenthesis parses sub-expression with the lowest precedence
+func parseParenthesis(s *State, token *lexer.Token) (ast.Node, error) {
+	if err := s.openPar(token); err != nil {
+		return nil, err
+	}
+	node, err := s.parseExpression(s.parser.priorities.MinPrecedence())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.closePar(); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// parseUnary parses operand with the precedence of unary operator, so only operators binding stronger
+// are applied on the operand, like -2 ^ 2
+func parseUnary(s *State, token *lexer.Token) (ast.Node, error) {
+	_ = token.ChangeToUnary()
+	node, err := s.ParseExpression(s.parser.priorities.GetPrecedence(token.Type()))
+	if err != nil {
+		return nil, err
+	}
+	return ast.NewUnaryNode(unaryOperations[token.Type()], node, token), nil
+}
+
+func parseBinary(s *State, left ast.Node, token *lexer.Token) (ast.Node, error) {
+	right, err := s.ParseExpression(s.OperandPrecedence(token.Type()))
+	if err != nil {
+		return nil, err
+	}
+	return parser.NewBinaryNode(binaryOperations[token.Type()], left, right, token), nil
+}
+
+// parsePostfix changes logical negation to factorial and modulus to percent, both are applied on the left operand
+func parsePostfix(s *State, left ast.Node, token *lexer.Token) (ast.Node, error) {
+	if err := token.ChangeToPostfix(); err != nil {
+		return nil, parser.ParseError(token, err)
+	}
+	return ast.NewPostfixNode(postfixOperations[token.Type()], left, token), nil
+}
+
+// parseAssign parses the value assigned into the variable on the left side.
+// With right associativity `a = b = 3` assigns 3 into both variables
+func parseAssign(s *State, left ast.Node, token *lexer.Token) (ast.Node, error) {
+	variable, ok := left.(*ast.VariableNode)
+	if !ok {
+		return nil, parser.ParseError(token, ErrInvalidAssign)
+	}
+	value, err := s.ParseExpression(s.OperandPrecedence(token.Type()))
+	if err != nil {
+		return nil, err
+	}
+	return ast.NewAssignNode(variable, value, token), nil
+}
+
+// parseConditional parses `cond ? a : b`, the middle part is enclosed by both operators so it can be any expression
+func parseConditional(s *State, condition ast.Node, token *lexer.Token) (ast.Node, error) {
+	thenNode, err := s.ParseExpression(s.parser.priorities.MinPrecedence())
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.Expect(lexer.Colon); err != nil {
+		return nil, err
+	}
+	elseNode, err := s.ParseExpression(s.OperandPrecedence(token.Type()))
+	if err != nil {
+		return nil, err
+	}
+	return ast.NewConditionalNode(condition, thenNode, elseNode, token), nil
+}
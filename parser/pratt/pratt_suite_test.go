@@ -0,0 +1,13 @@
+package pratt_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestPratt(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Pratt Suite")
+}
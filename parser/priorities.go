@@ -32,6 +32,19 @@ func DefaultTokenPriorities() TokenPriorities {
 	return TokenPriorities{
 		lexer.Equal: TokenMeta{Precedence: 10, Associativity: RightAssociativity},
 
+		lexer.Question: TokenMeta{Precedence: 11, Associativity: RightAssociativity},
+
+		lexer.Or:  TokenMeta{Precedence: 12},
+		lexer.And: TokenMeta{Precedence: 14},
+
+		lexer.IsEqual:  TokenMeta{Precedence: 16},
+		lexer.NotEqual: TokenMeta{Precedence: 16},
+
+		lexer.Less:           TokenMeta{Precedence: 18},
+		lexer.LessOrEqual:    TokenMeta{Precedence: 18},
+		lexer.Greater:        TokenMeta{Precedence: 18},
+		lexer.GreaterOrEqual: TokenMeta{Precedence: 18},
+
 		lexer.Addition:     TokenMeta{Precedence: 20},
 		lexer.Substraction: TokenMeta{Precedence: 20},
 
@@ -39,11 +52,18 @@ func DefaultTokenPriorities() TokenPriorities {
 		lexer.Division:       TokenMeta{Precedence: 40},
 		lexer.FloorDiv:       TokenMeta{Precedence: 40},
 		lexer.Modulus:        TokenMeta{Precedence: 40},
+		// Used only when implicit multiplication is enabled, same precedence means 1/2x is (1/2)x
+		lexer.ImplicitMultiplication: TokenMeta{Precedence: 40},
 
 		lexer.UnaryAddition:     TokenMeta{Precedence: 60},
 		lexer.UnarySubstraction: TokenMeta{Precedence: 60},
+		lexer.Not:               TokenMeta{Precedence: 60},
 
 		lexer.Exponent: TokenMeta{Precedence: 80, Associativity: RightAssociativity},
+
+		// Postfix operators bind the strongest, so -3! is -(3!) and 2^3! is 2^(3!)
+		lexer.Factorial: TokenMeta{Precedence: 90},
+		lexer.Percent:   TokenMeta{Precedence: 90},
 	}
 }
 
@@ -51,7 +71,9 @@ func (tp TokenPriorities) Normalize() error {
 	for k := range tp {
 		switch k {
 		case lexer.Equal, lexer.Addition, lexer.Substraction, lexer.Multiplication, lexer.Division, lexer.FloorDiv,
-			lexer.Modulus, lexer.UnaryAddition, lexer.UnarySubstraction, lexer.Exponent:
+			lexer.Modulus, lexer.UnaryAddition, lexer.UnarySubstraction, lexer.Exponent, lexer.Factorial, lexer.Percent,
+			lexer.Less, lexer.LessOrEqual, lexer.Greater, lexer.GreaterOrEqual, lexer.IsEqual, lexer.NotEqual,
+			lexer.And, lexer.Or, lexer.Not, lexer.Question, lexer.ImplicitMultiplication:
 
 		default:
 			delete(tp, k)
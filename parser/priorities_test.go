@@ -18,28 +18,59 @@ var _ = Describe("Priorities", func() {
 		equal := p.GetPrecedence(lexer.Equal)
 		Expect(equal).To(BeNumerically(">", 0))
 
+		question := p.GetPrecedence(lexer.Question)
+		Expect(question).To(BeNumerically(">", equal))
+		Expect(p.NextPrecedence(equal)).To(Equal(question))
+
+		or := p.GetPrecedence(lexer.Or)
+		Expect(or).To(BeNumerically(">", question))
+		Expect(p.NextPrecedence(question)).To(Equal(or))
+
+		and := p.GetPrecedence(lexer.And)
+		Expect(and).To(BeNumerically(">", or))
+		Expect(p.NextPrecedence(or)).To(Equal(and))
+
+		isEqual := p.GetPrecedence(lexer.IsEqual)
+		Expect(isEqual).To(BeNumerically(">", and))
+		Expect(p.GetPrecedence(lexer.NotEqual)).To(BeNumerically("==", isEqual))
+		Expect(p.NextPrecedence(and)).To(Equal(isEqual))
+
+		less := p.GetPrecedence(lexer.Less)
+		Expect(less).To(BeNumerically(">", isEqual))
+		Expect(p.GetPrecedence(lexer.LessOrEqual)).To(BeNumerically("==", less))
+		Expect(p.GetPrecedence(lexer.Greater)).To(BeNumerically("==", less))
+		Expect(p.GetPrecedence(lexer.GreaterOrEqual)).To(BeNumerically("==", less))
+		Expect(p.NextPrecedence(isEqual)).To(Equal(less))
+
 		addition := p.GetPrecedence(lexer.Addition)
-		Expect(addition).To(BeNumerically(">", equal))
+		Expect(addition).To(BeNumerically(">", less))
 		Expect(p.GetPrecedence(lexer.Substraction)).To(BeNumerically("==", addition))
-		Expect(p.NextPrecedence(equal)).To(Equal(addition))
+		Expect(p.NextPrecedence(less)).To(Equal(addition))
 
 		multiplication := p.GetPrecedence(lexer.Multiplication)
 		Expect(multiplication).To(BeNumerically(">", addition))
 		Expect(p.GetPrecedence(lexer.Division)).To(BeNumerically("==", multiplication))
 		Expect(p.GetPrecedence(lexer.FloorDiv)).To(BeNumerically("==", multiplication))
 		Expect(p.GetPrecedence(lexer.Modulus)).To(BeNumerically("==", multiplication))
+		Expect(p.GetPrecedence(lexer.ImplicitMultiplication)).To(BeNumerically("==", multiplication))
 		Expect(p.NextPrecedence(addition)).To(Equal(multiplication))
 
 		unaryAddition := p.GetPrecedence(lexer.UnaryAddition)
 		Expect(unaryAddition).To(BeNumerically(">", multiplication))
 		Expect(p.GetPrecedence(lexer.UnarySubstraction)).To(BeNumerically("==", unaryAddition))
+		Expect(p.GetPrecedence(lexer.Not)).To(BeNumerically("==", unaryAddition))
 		Expect(p.NextPrecedence(multiplication)).To(Equal(unaryAddition))
 
 		exponent := p.GetPrecedence(lexer.Exponent)
 		Expect(exponent).To(BeNumerically(">", unaryAddition))
 		Expect(p.NextPrecedence(unaryAddition)).To(Equal(exponent))
 
-		Expect(p.MaxPrecedence()).To(Equal(exponent))
+		factorial := p.GetPrecedence(lexer.Factorial)
+		Expect(factorial).To(BeNumerically(">", exponent))
+		Expect(p.GetPrecedence(lexer.Percent)).To(BeNumerically("==", factorial))
+		Expect(p.NextPrecedence(exponent)).To(Equal(factorial))
+
+		Expect(p.MaxPrecedence()).To(Equal(factorial))
 	})
 
 	It("Returns default values when token meta are not set", func() {
@@ -69,10 +100,11 @@ var _ = Describe("Priorities", func() {
 		p[lexer.Comma] = parser.TokenMeta{Precedence: 100}
 		p[lexer.EOL] = parser.TokenMeta{Precedence: 100}
 		p[lexer.Whitespace] = parser.TokenMeta{Precedence: 100}
+		p[lexer.Colon] = parser.TokenMeta{Precedence: 100}
 
-		Expect(p).To(HaveLen(17))
+		Expect(p).To(HaveLen(31))
 		Expect(p.Normalize()).To(Succeed())
-		Expect(p).To(HaveLen(10))
+		Expect(p).To(HaveLen(23))
 	})
 })
 
@@ -88,9 +120,19 @@ var _ = DescribeTable("Check default associativity",
 	Entry("Division", lexer.Division, parser.LeftAssociativity),
 	Entry("FloorDiv", lexer.FloorDiv, parser.LeftAssociativity),
 	Entry("Modulus", lexer.Modulus, parser.LeftAssociativity),
+	Entry("ImplicitMultiplication", lexer.ImplicitMultiplication, parser.LeftAssociativity),
 	Entry("UnaryAddition", lexer.UnaryAddition, parser.LeftAssociativity),
 	Entry("UnarySubstraction", lexer.UnarySubstraction, parser.LeftAssociativity),
 	Entry("Exponent", lexer.Exponent, parser.RightAssociativity),
+	Entry("Factorial", lexer.Factorial, parser.LeftAssociativity),
+	Entry("Percent", lexer.Percent, parser.LeftAssociativity),
+	Entry("Less", lexer.Less, parser.LeftAssociativity),
+	Entry("IsEqual", lexer.IsEqual, parser.LeftAssociativity),
+	Entry("And", lexer.And, parser.LeftAssociativity),
+	Entry("Or", lexer.Or, parser.LeftAssociativity),
+	Entry("Not", lexer.Not, parser.LeftAssociativity),
+	Entry("Question", lexer.Question, parser.RightAssociativity),
+	Entry("Colon", lexer.Colon, parser.LeftAssociativity),
 	Entry("LPar", lexer.LPar, parser.LeftAssociativity),
 	Entry("RPar", lexer.RPar, parser.LeftAssociativity),
 	Entry("Identifier", lexer.Identifier, parser.LeftAssociativity),
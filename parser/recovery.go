@@ -0,0 +1,224 @@
+package parser
+
+import (
+	"errors"
+
+	"github.com/arxeiss/go-expression-calculator/ast"
+	"github.com/arxeiss/go-expression-calculator/lexer"
+)
+
+// RecoveringParser can continue parsing after errors and return partial AST with all collected errors
+type RecoveringParser interface {
+	Parser
+	ParseWithRecovery(tokenList []*lexer.Token) (ast.Node, []*Error)
+}
+
+// ParseWithRecovery parses all statements like ParseStatements, but does not stop on the first error.
+// Part of the statement with an error is replaced by ast.ErrorNode and parsing continues after the closest
+// comma, right parenthesis or statement boundary. Returned node is never nil, errors are in order of discovery.
+func ParseWithRecovery(
+	tokenList []*lexer.Token,
+	parseStatement func(tokenList []*lexer.Token) (ast.Node, error),
+) (ast.Node, []*Error) {
+	statements := lexer.SplitStatements(tokenList)
+	nodes := make([]ast.Node, 0, len(statements))
+	errs := make([]*Error, 0)
+	for _, statement := range statements {
+		node, statementErrs := recoverStatement(statement, parseStatement)
+		nodes = append(nodes, node)
+		errs = append(errs, statementErrs...)
+	}
+	if len(nodes) == 1 {
+		return nodes[0], errs
+	}
+	return ast.NewBlockNode(nodes, firstToken(statements[0])), errs
+}
+
+// recoverStatement parses the statement again and again, every time the part with an error is replaced
+// by invalid token, or missing right parenthesis is added or the extra one removed.
+// When nothing helps, the whole statement is replaced by ast.ErrorNode
+func recoverStatement(
+	tokenList []*lexer.Token,
+	parseStatement func(tokenList []*lexer.Token) (ast.Node, error),
+) (ast.Node, []*Error) {
+	errs := make([]*Error, 0)
+	// Errors at tokens added by recovery or already reported ones are just consequences of previous errors
+	known := make(map[*lexer.Token]bool)
+	for attempts := 2*len(tokenList) + 2; attempts > 0; attempts-- {
+		// Parsers can change tokens, ie into unary operators, so every attempt works with fresh copies
+		attempt := make([]*lexer.Token, len(tokenList))
+		originals := make(map[*lexer.Token]*lexer.Token, len(tokenList))
+		for i, t := range tokenList {
+			attempt[i] = t.Clone()
+			originals[attempt[i]] = t
+		}
+		node, err := parseStatement(attempt)
+		if err == nil {
+			return node, errs
+		}
+		parseErr := &Error{}
+		if !errors.As(err, &parseErr) {
+			parseErr = ParseError(nil, err)
+		}
+		original := originals[parseErr.token]
+		if original == nil || !known[original] {
+			errs = append(errs, parseErr)
+		}
+		known[original] = true
+
+		fixed, ok := synchronize(tokenList, original, known)
+		if !ok {
+			break
+		}
+		tokenList = fixed
+	}
+	return ast.NewErrorNode(statementToken(tokenList)), errs
+}
+
+// synchronize fixes the token list around the token with an error, returns false if nothing can be fixed
+func synchronize(tokenList []*lexer.Token, errToken *lexer.Token, known map[*lexer.Token]bool) ([]*lexer.Token, bool) {
+	index, depth := tokenDepth(tokenList, errToken)
+	if index < 0 {
+		return nil, false
+	}
+	switch {
+	case errToken.Type() == lexer.EOL && depth > 0, errToken.Type() == lexer.LPar && !isClosed(tokenList, index):
+		// Add missing right parenthesis at the end of the statement
+		eol := len(tokenList) - 1
+		position := tokenList[eol].StartPosition()
+		rPar := lexer.NewToken(lexer.RPar, 0, "", position, position)
+		known[rPar] = true
+		return insertTokens(tokenList, eol, eol, rPar), true
+	case errToken.Type() == lexer.RPar && depth <= 0:
+		// Remove right parenthesis without the left one
+		return insertTokens(tokenList, index, index+1), true
+	}
+	start, end := errorRegion(tokenList, index, depth)
+	return replaceRegion(tokenList, start, end, known)
+}
+
+// tokenDepth returns index of the token in the list and number of parentheses opened before it.
+// If the token is not in the list, index is -1
+func tokenDepth(tokenList []*lexer.Token, token *lexer.Token) (int, int) {
+	depth := 0
+	for i, t := range tokenList {
+		switch {
+		case t == token:
+			return i, depth
+		case t.Type() == lexer.LPar:
+			depth++
+		case t.Type() == lexer.RPar:
+			depth--
+		}
+	}
+	return -1, depth
+}
+
+// isClosed checks if left parenthesis at index has matching right parenthesis
+func isClosed(tokenList []*lexer.Token, index int) bool {
+	nested := 0
+	for _, t := range tokenList[index:] {
+		if t.Type() == lexer.LPar {
+			nested++
+		} else if t.Type() == lexer.RPar {
+			nested--
+		}
+		if nested == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// replaceRegion replaces tokens between start and end with single invalid token spanning them without whitespace.
+// Returns false if the region is already replaced
+func replaceRegion(
+	tokenList []*lexer.Token,
+	start, end int,
+	known map[*lexer.Token]bool,
+) ([]*lexer.Token, bool) {
+	first, last := start, end
+	for first < end && tokenList[first].Type() == lexer.Whitespace {
+		first++
+	}
+	for last > first && tokenList[last-1].Type() == lexer.Whitespace {
+		last--
+	}
+	if last-first == 1 && tokenList[first].Type() == lexer.Invalid {
+		return nil, false
+	}
+	invalid := lexer.InvalidToken(tokenList[end], nil)
+	if first < last {
+		invalid = lexer.InvalidToken(tokenList[first], tokenList[last-1])
+	}
+	known[invalid] = true
+	return insertTokens(tokenList, start, end, invalid), true
+}
+
+// errorRegion finds the part around the token at index, which is enclosed by the closest comma or parenthesis
+// at the same depth. Outside of parentheses the region is the whole statement.
+func errorRegion(tokenList []*lexer.Token, index, depth int) (int, int) {
+	if depth <= 0 {
+		return 0, len(tokenList) - 1
+	}
+	end := index
+	for nested := 0; end < len(tokenList)-1; end++ {
+		t := tokenList[end].Type()
+		if nested == 0 && (t == lexer.Comma || t == lexer.RPar) {
+			break
+		}
+		if t == lexer.LPar {
+			nested++
+		} else if t == lexer.RPar {
+			nested--
+		}
+	}
+	start := index
+	for nested := 0; start > 0; start-- {
+		t := tokenList[start-1].Type()
+		if nested == 0 && (t == lexer.Comma || t == lexer.LPar) {
+			break
+		}
+		if t == lexer.RPar {
+			nested++
+		} else if t == lexer.LPar {
+			nested--
+		}
+	}
+	return start, end
+}
+
+// insertTokens replaces tokens between start and end with given tokens
+func insertTokens(tokenList []*lexer.Token, start, end int, tokens ...*lexer.Token) []*lexer.Token {
+	result := make([]*lexer.Token, 0, len(tokenList)-(end-start)+len(tokens))
+	result = append(result, tokenList[:start]...)
+	result = append(result, tokens...)
+	return append(result, tokenList[end:]...)
+}
+
+// statementToken returns invalid token spanning the whole statement without surrounding whitespace
+func statementToken(tokenList []*lexer.Token) *lexer.Token {
+	var first, last *lexer.Token
+	for _, t := range tokenList {
+		if t.Type() != lexer.Whitespace && t.Type() != lexer.EOL {
+			if first == nil {
+				first = t
+			}
+			last = t
+		}
+	}
+	if first == nil {
+		return lexer.InvalidToken(tokenList[len(tokenList)-1], nil)
+	}
+	return lexer.InvalidToken(first, last)
+}
+
+// firstToken returns the first token of the statement which is not whitespace
+func firstToken(tokenList []*lexer.Token) *lexer.Token {
+	for _, t := range tokenList {
+		if t.Type() != lexer.Whitespace {
+			return t
+		}
+	}
+	return tokenList[0]
+}
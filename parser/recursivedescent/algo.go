@@ -15,24 +15,37 @@ var (
 	ErrExpectedOperand = errors.New("expected number, identifier or left parenthesis")
 	ErrExpectedEOL     = errors.New("last token is expected to be the end of input")
 	ErrUnexpectedToken = errors.New("unexpected token")
+	ErrDuplicateParam  = errors.New("duplicate parameter name in function definition")
+	ErrInvalidAssign   = errors.New("only variable can be assigned")
 
 	binaryOperators = []lexer.TokenType{
 		lexer.Addition, lexer.Substraction,
 		lexer.Multiplication, lexer.Division, lexer.FloorDiv, lexer.Modulus,
 		lexer.Exponent,
+		lexer.Less, lexer.LessOrEqual, lexer.Greater, lexer.GreaterOrEqual, lexer.IsEqual, lexer.NotEqual,
+		lexer.And, lexer.Or,
 	}
 )
 
 type Parser struct {
 	priorities parser.TokenPriorities
+	limits     parser.Limits
+	implicit   parser.ImplicitMultiplication
+	// operators are all binary operators, implicit multiplication included when it is enabled
+	operators []lexer.TokenType
 }
 
+var _ parser.RecoveringParser = &Parser{}
+var _ parser.LimitedParser = &Parser{}
+var _ parser.ImplicitMultiplicationParser = &Parser{}
+
 func NewParser(priorities parser.TokenPriorities) (parser.Parser, error) {
 	if err := priorities.Normalize(); err != nil {
 		return nil, err
 	}
 	return &Parser{
 		priorities: priorities,
+		operators:  binaryOperators,
 	}, nil
 }
 
@@ -41,10 +54,48 @@ type parserInstance struct {
 	i             int
 	parser        *Parser
 	maxPrecedence parser.TokenPrecedence
+	// depth is number of nodes above the parsed operand, it is lower than the final depth of AST,
+	// because left operands are parsed before their parent is known
+	depth int
+	// parens is number of opened parentheses
+	parens int
+}
+
+// SetLimits restricts size of parsed expressions, see parser.Limits.
+// The depth is checked also during parsing, so deeply nested input does not exhaust the stack
+func (p *Parser) SetLimits(limits parser.Limits) {
+	p.limits = limits
 }
 
-// Parse uses Recursive Descent parser.
+// SetImplicitMultiplication enables or disables multiplication between adjacent operands,
+// see parser.ImplicitMultiplication
+func (p *Parser) SetImplicitMultiplication(options parser.ImplicitMultiplication) {
+	p.implicit = options
+	p.operators = binaryOperators
+	if options.Enabled {
+		p.operators = append(append([]lexer.TokenType{}, binaryOperators...), lexer.ImplicitMultiplication)
+	}
+}
+
+// Parse parses all statements of the input, see parser.ParseStatements
 func (p *Parser) Parse(tokenList []*lexer.Token) (ast.Node, error) {
+	node, err := parser.ParseStatements(p.implicit.Insert(tokenList), p.parseStatement)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.limits.Check(node); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// ParseWithRecovery parses all statements and continues after errors, see parser.ParseWithRecovery
+func (p *Parser) ParseWithRecovery(tokenList []*lexer.Token) (ast.Node, []*parser.Error) {
+	return parser.ParseWithRecovery(p.implicit.Insert(tokenList), p.parseStatement)
+}
+
+// parseStatement uses Recursive Descent parser.
+func (p *Parser) parseStatement(tokenList []*lexer.Token) (ast.Node, error) {
 	noWhiteSpaceList := make([]*lexer.Token, 0)
 	for _, v := range tokenList {
 		if v.Type() != lexer.Whitespace {
@@ -78,18 +129,11 @@ func (p *parserInstance) getAssociativity(tokenType lexer.TokenType) parser.Toke
 }
 
 func (p *parserInstance) parseBlock() (ast.Node, error) {
-	// Parse assignment
 	var node ast.Node
 	var err error
 
-	if p.hasNth(0, lexer.Identifier) && p.hasNth(1, lexer.Equal) {
-		variable, _ := p.expect()
-		equalOp, _ := p.expect()
-
-		node, err = p.parseExpression(p.getPrecedence(equalOp.Type()))
-		if err == nil {
-			node = ast.NewAssignNode(ast.NewVariableNode(variable.Identifier(), variable), node, equalOp)
-		}
+	if p.isFunctionDefinition() {
+		node, err = p.parseFunctionDefinition()
 	} else {
 		node, err = p.parseExpression(p.parser.priorities.MinPrecedence())
 	}
@@ -102,6 +146,52 @@ func (p *parserInstance) parseBlock() (ast.Node, error) {
 	return node, nil
 }
 
+// isFunctionDefinition looks ahead if tokens match pattern `name(param1, param2, ...) =`
+func (p *parserInstance) isFunctionDefinition() bool {
+	if !p.hasNth(0, lexer.Identifier) || !p.hasNth(1, lexer.LPar) {
+		return false
+	}
+	nth := 2
+	if p.hasNth(nth, lexer.Identifier) {
+		nth++
+		for p.hasNth(nth, lexer.Comma) && p.hasNth(nth+1, lexer.Identifier) {
+			nth += 2
+		}
+	}
+	return p.hasNth(nth, lexer.RPar) && p.hasNth(nth+1, lexer.Equal)
+}
+
+func (p *parserInstance) parseFunctionDefinition() (ast.Node, error) {
+	name, _ := p.expect()
+	_, _ = p.expect() // Pop out left parenthesis, checked by isFunctionDefinition already
+
+	params := []*ast.VariableNode{}
+	paramNames := make(map[string]bool)
+	for p.has(lexer.Identifier) {
+		param, _ := p.expect()
+		lowerName := strings.ToLower(param.Identifier())
+		if paramNames[lowerName] {
+			return nil, parser.ParseError(param, ErrDuplicateParam)
+		}
+		paramNames[lowerName] = true
+		params = append(params, ast.NewVariableNode(param.Identifier(), param))
+		if p.has(lexer.Comma) {
+			_, _ = p.expect()
+		}
+	}
+	_, _ = p.expect() // Pop out right parenthesis
+	equalOp, _ := p.expect()
+
+	body, err := p.parseNested(p.getPrecedence(equalOp.Type()))
+	if err != nil {
+		return nil, err
+	}
+	if body == nil {
+		return nil, parser.ParseError(p.current(), ErrExpectedOperand)
+	}
+	return ast.NewFunctionDefNode(name.Identifier(), params, body, equalOp), nil
+}
+
 func (p *parserInstance) parseExpression(currentPrecedence parser.TokenPrecedence) (ast.Node, error) {
 	var node ast.Node
 	var err error
@@ -116,11 +206,12 @@ func (p *parserInstance) parseExpression(currentPrecedence parser.TokenPrecedenc
 	// If there is no node returned, we should expect either term or unary operators
 	if node == nil {
 		switch {
-		case p.has(lexer.LPar, lexer.Identifier, lexer.Number):
+		case p.has(lexer.LPar, lexer.Identifier, lexer.Number, lexer.Invalid):
 			node, err = p.parseTerm()
 		// Unary operators are checked only if their precedence match current one
 		case p.has(lexer.Addition) && currentPrecedence == p.getPrecedence(lexer.UnaryAddition),
-			p.has(lexer.Substraction) && currentPrecedence == p.getPrecedence(lexer.UnarySubstraction):
+			p.has(lexer.Substraction) && currentPrecedence == p.getPrecedence(lexer.UnarySubstraction),
+			p.has(lexer.Not) && currentPrecedence == p.getPrecedence(lexer.Not):
 			node, err = p.handleUnary()
 		}
 	}
@@ -129,7 +220,7 @@ func (p *parserInstance) parseExpression(currentPrecedence parser.TokenPrecedenc
 	}
 
 	// Iterate until there are operators with same precedence
-	for p.getPrecedence(p.current().Type()) == currentPrecedence {
+	for p.operatorPrecedence(node) == currentPrecedence {
 		node, err = p.handleSamePrecedenceTokens(currentPrecedence, node)
 		if err != nil {
 			return nil, err
@@ -139,13 +230,35 @@ func (p *parserInstance) parseExpression(currentPrecedence parser.TokenPrecedenc
 	return node, err
 }
 
+// operatorPrecedence returns precedence of the current token. After an operand it can be postfix operator
+func (p *parserInstance) operatorPrecedence(leftNode ast.Node) parser.TokenPrecedence {
+	if tokenType, isPostfix := p.postfixType(); isPostfix && leftNode != nil {
+		return p.getPrecedence(tokenType)
+	}
+	return p.getPrecedence(p.current().Type())
+}
+
+// postfixType returns type of the current token as postfix operator, see parser.PostfixType
+func (p *parserInstance) postfixType() (lexer.TokenType, bool) {
+	return parser.PostfixType(p.previous(), p.current(), p.nextNth(1))
+}
+
 func (p *parserInstance) handleSamePrecedenceTokens(
 	currentPrecedence parser.TokenPrecedence,
 	leftNode ast.Node,
 ) (ast.Node, error) {
+	if _, isPostfix := p.postfixType(); isPostfix && leftNode != nil {
+		return p.handlePostfix(leftNode)
+	}
+	if p.has(lexer.Question) {
+		return p.handleConditional(currentPrecedence, leftNode)
+	}
+	if p.has(lexer.Equal) {
+		return p.handleAssign(currentPrecedence, leftNode)
+	}
 	// Left part is matched, we always need to find operator now
 	current := p.current()
-	operatorToken, err := p.expect(binaryOperators...)
+	operatorToken, err := p.expect(p.parser.operators...)
 	if err != nil {
 		return nil, err
 	}
@@ -156,18 +269,15 @@ func (p *parserInstance) handleSamePrecedenceTokens(
 
 	var rightNode ast.Node
 	current = p.current()
-
-	// Has another opearator after operator, it must be unary
-	if p.has(lexer.Addition, lexer.Substraction) {
+	nextPrecedence := p.rightPrecedence(currentPrecedence, operatorToken.Type())
+	// Has another opearator after operator, it must be unary. If its precedence is not reached yet,
+	// nesting handles it and operators between both precedences are applied on the unary node, like 1 + -2 * 3
+	if p.has(lexer.Addition) && nextPrecedence > p.getPrecedence(lexer.UnaryAddition) ||
+		p.has(lexer.Substraction) && nextPrecedence > p.getPrecedence(lexer.UnarySubstraction) ||
+		p.has(lexer.Not) && nextPrecedence > p.getPrecedence(lexer.Not) {
 		rightNode, err = p.handleUnary()
 	} else {
-		// If operator is RightPrecedence, keep same precedence as right parts should be lower in AST
-		// So next iteration with same precedence will parse it first
-		nextPrecedence := currentPrecedence
-		if p.getAssociativity(operatorToken.Type()) == parser.LeftAssociativity {
-			nextPrecedence = p.parser.priorities.NextPrecedence(currentPrecedence)
-		}
-		rightNode, err = p.parseExpression(nextPrecedence)
+		rightNode, err = p.parseNested(nextPrecedence)
 	}
 	if err != nil {
 		return nil, err
@@ -177,11 +287,82 @@ func (p *parserInstance) handleSamePrecedenceTokens(
 		return nil, parser.ParseError(current, ErrExpectedOperand)
 	}
 
-	return ast.NewBinaryNode(tokenTypeToOperation(operatorToken.Type()), leftNode, rightNode, operatorToken), nil
+	return parser.NewBinaryNode(tokenTypeToOperation(operatorToken.Type()), leftNode, rightNode, operatorToken), nil
+}
+
+// rightPrecedence returns precedence used to parse the right operand of the operator
+func (p *parserInstance) rightPrecedence(
+	currentPrecedence parser.TokenPrecedence,
+	operator lexer.TokenType,
+) parser.TokenPrecedence {
+	// If operator is RightPrecedence, keep same precedence as right parts should be lower in AST
+	// So next iteration with same precedence will parse it first
+	if p.getAssociativity(operator) == parser.RightAssociativity {
+		return currentPrecedence
+	}
+	// There is no higher precedence, so only term can be on the right side
+	if currentPrecedence == p.maxPrecedence {
+		return currentPrecedence + 1
+	}
+	return p.parser.priorities.NextPrecedence(currentPrecedence)
+}
+
+// handleAssign parses the value assigned into the variable on the left side.
+// With right associativity `a = b = 3` assigns 3 into both variables
+func (p *parserInstance) handleAssign(currentPrecedence parser.TokenPrecedence, target ast.Node) (ast.Node, error) {
+	equalOp, _ := p.expect()
+	if target == nil {
+		return nil, parser.ParseError(equalOp, ErrExpectedOperand)
+	}
+	variable, ok := target.(*ast.VariableNode)
+	if !ok {
+		return nil, parser.ParseError(equalOp, ErrInvalidAssign)
+	}
+	current := p.current()
+	value, err := p.parseNested(p.rightPrecedence(currentPrecedence, equalOp.Type()))
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, parser.ParseError(current, ErrExpectedOperand)
+	}
+	return ast.NewAssignNode(variable, value, equalOp), nil
+}
+
+// handleConditional parses `cond ? a : b`, the middle part is enclosed by both operators so it can be any expression.
+// The last part keeps current precedence, as the operator is always right associative
+func (p *parserInstance) handleConditional(
+	currentPrecedence parser.TokenPrecedence,
+	condition ast.Node,
+) (ast.Node, error) {
+	questionToken, _ := p.expect()
+	if condition == nil {
+		return nil, parser.ParseError(questionToken, ErrExpectedOperand)
+	}
+	current := p.current()
+	thenNode, err := p.parseNested(p.parser.priorities.MinPrecedence())
+	if err != nil {
+		return nil, err
+	}
+	if thenNode == nil {
+		return nil, parser.ParseError(current, ErrExpectedOperand)
+	}
+	if _, err := p.expect(lexer.Colon); err != nil {
+		return nil, err
+	}
+	current = p.current()
+	elseNode, err := p.parseNested(currentPrecedence)
+	if err != nil {
+		return nil, err
+	}
+	if elseNode == nil {
+		return nil, parser.ParseError(current, ErrExpectedOperand)
+	}
+	return ast.NewConditionalNode(condition, thenNode, elseNode, questionToken), nil
 }
 
 func (p *parserInstance) handleUnary() (ast.Node, error) {
-	token, err := p.expect(lexer.Addition, lexer.Substraction)
+	token, err := p.expect(lexer.Addition, lexer.Substraction, lexer.Not)
 	if err != nil {
 		return nil, err
 	}
@@ -189,7 +370,7 @@ func (p *parserInstance) handleUnary() (ast.Node, error) {
 	current := p.current()
 
 	// Recurse again to match more unary operators or operators with higher precedence
-	node, err := p.parseExpression(p.getPrecedence(token.Type()))
+	node, err := p.parseNested(p.getPrecedence(token.Type()))
 	if err != nil {
 		return nil, err
 	}
@@ -200,14 +381,24 @@ func (p *parserInstance) handleUnary() (ast.Node, error) {
 	return ast.NewUnaryNode(tokenTypeToOperation(token.Type()), node, token), nil
 }
 
+// handlePostfix applies postfix operator on the operand, like 5! or 10%
+func (p *parserInstance) handlePostfix(operand ast.Node) (ast.Node, error) {
+	token, _ := p.expect()
+	_ = token.ChangeToPostfix()
+	return ast.NewPostfixNode(tokenTypeToOperation(token.Type()), operand, token), nil
+}
+
 func (p *parserInstance) parseTerm() (ast.Node, error) {
-	token, err := p.expect(lexer.LPar, lexer.Identifier, lexer.Number)
+	token, err := p.expect(lexer.LPar, lexer.Identifier, lexer.Number, lexer.Invalid)
 	if err != nil {
 		return nil, err
 	}
 	var node ast.Node
 	switch token.Type() {
 	case lexer.LPar:
+		if err := p.openPar(token); err != nil {
+			return nil, err
+		}
 		// If there is left parenthesis, just nest with lowest priority to handle sub-expression
 		node, err = p.parseExpression(p.parser.priorities.MinPrecedence())
 		if err != nil {
@@ -217,40 +408,76 @@ func (p *parserInstance) parseTerm() (ast.Node, error) {
 		if _, err := p.expect(lexer.RPar); err != nil {
 			return nil, err
 		}
+		p.parens--
 	case lexer.Identifier:
 		if p.has(lexer.LPar) {
-			_, _ = p.expect() // Just pop out if it is function
-			args := []ast.Node{}
-			for {
-				// Parse sub-expresion as argument
-				node, err = p.parseExpression(p.parser.priorities.MinPrecedence())
-				if err != nil {
-					return nil, err
-				}
-				if node == nil { // When there is nothing, it is most like end
-					break
-				}
-				args = append(args, node)
-				if !p.has(lexer.Comma) {
-					break
-				}
-				_, _ = p.expect() // If pop out the comma
-			}
-			// Function call must end with right parenthesis
-			if _, err := p.expect(lexer.RPar); err != nil {
-				return nil, err
-			}
-			node = ast.NewFunctionNode(token.Identifier(), args, token)
-		} else {
-			node = ast.NewVariableNode(token.Identifier(), token)
+			return p.parseFunction(token)
 		}
+		node = ast.NewVariableNode(token.Identifier(), token)
 	case lexer.Number:
+
 		node = ast.NewNumericNode(token.Value(), token)
+	case lexer.Invalid:
+		// Placeholder inserted by error recovery
+		node = ast.NewErrorNode(token)
 	}
 
 	return node, nil
 }
 
+// parseFunction parses arguments of the function call, identifier is already processed
+func (p *parserInstance) parseFunction(token *lexer.Token) (ast.Node, error) {
+	lPar, _ := p.expect() // Just pop out if it is function
+	if err := p.openPar(lPar); err != nil {
+		return nil, err
+	}
+	args := []ast.Node{}
+	for {
+		// Parse sub-expresion as argument
+		node, err := p.parseNested(p.parser.priorities.MinPrecedence())
+		if err != nil {
+			return nil, err
+		}
+		if node == nil { // When there is nothing, it is most like end
+			// Argument is required after the comma, so trailing comma like max(1,) is not valid
+			if len(args) > 0 {
+				return nil, parser.ParseError(p.current(), ErrExpectedOperand)
+			}
+			break
+		}
+		args = append(args, node)
+		if !p.has(lexer.Comma) {
+			break
+		}
+		_, _ = p.expect() // If pop out the comma
+	}
+	// Function call must end with right parenthesis
+	if _, err := p.expect(lexer.RPar); err != nil {
+		return nil, err
+	}
+	p.parens--
+	return ast.NewFunctionNode(token.Identifier(), args, token), nil
+}
+
+// parseNested parses operand of the node, which is one level deeper in AST.
+// Limit of depth is checked before the recursion
+func (p *parserInstance) parseNested(currentPrecedence parser.TokenPrecedence) (ast.Node, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if limit := p.parser.limits.Depth; limit > 0 && p.depth >= limit {
+		return nil, parser.ParseError(p.current(), parser.ErrDepthLimit)
+	}
+	return p.parseExpression(currentPrecedence)
+}
+
+// openPar counts opened parentheses and checks the limit of depth
+func (p *parserInstance) openPar(token *lexer.Token) error {
+	if p.parens++; p.parser.limits.Depth > 0 && p.parens > p.parser.limits.Depth {
+		return parser.ParseError(token, parser.ErrDepthLimit)
+	}
+	return nil
+}
+
 func (p *parserInstance) moveForward() {
 	p.i++
 }
@@ -297,6 +524,14 @@ func (p *parserInstance) current() *lexer.Token {
 	return p.nextNth(0)
 }
 
+// previous returns the token before the current one, or nil at the beginning
+func (p *parserInstance) previous() *lexer.Token {
+	if p.i > 0 {
+		return p.tokenList[p.i-1]
+	}
+	return nil
+}
+
 func (p *parserInstance) nextNth(nth int) *lexer.Token {
 	if p.i+nth < len(p.tokenList) {
 		return p.tokenList[p.i+nth]
@@ -310,7 +545,7 @@ func tokenTypeToOperation(tt lexer.TokenType) ast.Operation {
 		return ast.Addition
 	case lexer.UnarySubstraction, lexer.Substraction:
 		return ast.Substraction
-	case lexer.Multiplication:
+	case lexer.Multiplication, lexer.ImplicitMultiplication:
 		return ast.Multiplication
 	case lexer.Division:
 		return ast.Division
@@ -320,6 +555,35 @@ func tokenTypeToOperation(tt lexer.TokenType) ast.Operation {
 		return ast.FloorDiv
 	case lexer.Modulus:
 		return ast.Modulus
+	case lexer.Factorial:
+		return ast.Factorial
+	case lexer.Percent:
+		return ast.Percent
+	}
+	return comparisonOperation(tt)
+}
+
+// comparisonOperation converts comparison and logical operators
+func comparisonOperation(tt lexer.TokenType) ast.Operation {
+	switch tt {
+	case lexer.Less:
+		return ast.Less
+	case lexer.LessOrEqual:
+		return ast.LessOrEqual
+	case lexer.Greater:
+		return ast.Greater
+	case lexer.GreaterOrEqual:
+		return ast.GreaterOrEqual
+	case lexer.IsEqual:
+		return ast.IsEqual
+	case lexer.NotEqual:
+		return ast.NotEqual
+	case lexer.And:
+		return ast.And
+	case lexer.Or:
+		return ast.Or
+	case lexer.Not:
+		return ast.Not
 	}
 	return ast.Invalid
 }
@@ -25,12 +25,22 @@ var (
 	ErrMissingLPar      = errors.New("cannot find matching left parenthesis")
 	ErrMissingRPar      = errors.New("cannot find matching right parenthesis")
 	ErrUnsupportedToken = errors.New("unsupported token")
+	ErrUnexpectedComma  = errors.New("comma is allowed only to separate function arguments")
+	ErrMissingQuestion  = errors.New("cannot find matching question mark of conditional operator")
+	ErrMissingColon     = errors.New("cannot find matching colon of conditional operator")
+	ErrInvalidAssign    = errors.New("only variable can be assigned")
 )
 
 type Parser struct {
 	priorities parser.TokenPriorities
+	limits     parser.Limits
+	implicit   parser.ImplicitMultiplication
 }
 
+var _ parser.RecoveringParser = &Parser{}
+var _ parser.LimitedParser = &Parser{}
+var _ parser.ImplicitMultiplicationParser = &Parser{}
+
 func NewParser(priorities parser.TokenPriorities) (parser.Parser, error) {
 	if err := priorities.Normalize(); err != nil {
 		return nil, err
@@ -58,14 +68,45 @@ func normalizeTokenList(tokenList []*lexer.Token) ([]*lexer.Token, error) {
 	return noWhiteSpaceList, nil
 }
 
-// Parse uses Shunting Yard algorithm to parse the input.
+// SetLimits restricts size of parsed expressions, see parser.Limits
+func (p *Parser) SetLimits(limits parser.Limits) {
+	p.limits = limits
+}
+
+// SetImplicitMultiplication enables or disables multiplication between adjacent operands,
+// see parser.ImplicitMultiplication
+func (p *Parser) SetImplicitMultiplication(options parser.ImplicitMultiplication) {
+	p.implicit = options
+}
+
+// Parse parses all statements of the input, see parser.ParseStatements
+func (p *Parser) Parse(tokenList []*lexer.Token) (ast.Node, error) {
+	node, err := parser.ParseStatements(p.implicit.Insert(tokenList), p.parseStatement)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.limits.Check(node); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// ParseWithRecovery parses all statements and continues after errors, see parser.ParseWithRecovery
+func (p *Parser) ParseWithRecovery(tokenList []*lexer.Token) (ast.Node, []*parser.Error) {
+	return parser.ParseWithRecovery(p.implicit.Insert(tokenList), p.parseStatement)
+}
+
+// parseStatement uses Shunting Yard algorithm to parse the input.
 // Algorithm is based on Wiki page https://en.wikipedia.org/wiki/Shunting-yard_algorithm
 // with some improvements discussed on StackOverflow https://stackoverflow.com/a/29652095/1513087
 // and modified to produce Abstract Syntax Tree rather than RPN
-func (p *Parser) Parse(tokenList []*lexer.Token) (ast.Node, error) {
+func (p *Parser) parseStatement(tokenList []*lexer.Token) (ast.Node, error) {
 	expect := operandToken
 	output := make([]ast.Node, 0)
 	opStack := make([]*lexer.Token, 0)
+	// argsCount holds number of already parsed arguments for each opened parenthesis
+	argsCount := make([]int, 0)
+	var prevToken *lexer.Token
 	var err error
 
 	if tokenList, err = normalizeTokenList(tokenList); err != nil {
@@ -77,7 +118,7 @@ tokenLoop:
 		curToken := tokenList[i]
 
 		switch curToken.Type() {
-		case lexer.Number:
+		case lexer.Number, lexer.Invalid:
 			expect, output, err = p.handleNumber(expect, curToken, output)
 
 		case lexer.Identifier:
@@ -91,14 +132,25 @@ tokenLoop:
 			}
 			// If operator is expected, fallthrough to handle operator
 			fallthrough
-		case lexer.Exponent, lexer.Multiplication, lexer.Division, lexer.FloorDiv, lexer.Modulus:
-			expect, opStack, output, err = p.handleOperator(expect, curToken, opStack, output)
+		case lexer.Exponent, lexer.Multiplication, lexer.Division, lexer.FloorDiv, lexer.Modulus,
+			lexer.Less, lexer.LessOrEqual, lexer.Greater, lexer.GreaterOrEqual, lexer.IsEqual, lexer.NotEqual,
+			lexer.And, lexer.Or, lexer.Question, lexer.Colon, lexer.Equal, lexer.ImplicitMultiplication, lexer.Percent:
+			expect, opStack, output, err = p.handleOperator(
+				expect, prevToken, curToken, tokenList[i+1], opStack, output)
+
+		case lexer.Not, lexer.Factorial:
+			opStack, output, err = p.handleNot(expect, prevToken, curToken, tokenList[i+1], opStack, output)
 
 		case lexer.LPar:
-			expect, opStack, err = p.handleLPar(expect, curToken, opStack)
+			expect, opStack, err = p.handleLPar(expect, curToken, opStack, len(argsCount))
+			argsCount = append(argsCount, 0)
+
+		case lexer.Comma:
+			expect, opStack, output, err = p.handleComma(expect, curToken, opStack, output, argsCount)
 
 		case lexer.RPar:
-			expect, opStack, output, err = p.handleRPar(expect, curToken, opStack, output)
+			expect, opStack, output, argsCount, err = p.handleRPar(
+				expect, curToken, prevToken, opStack, output, argsCount)
 
 		case lexer.EOL:
 			if expect == operandToken {
@@ -112,6 +164,7 @@ tokenLoop:
 		if err != nil {
 			return nil, err
 		}
+		prevToken = curToken
 	}
 
 	return p.clearOpStack(opStack, output)
@@ -138,7 +191,8 @@ func (p *Parser) clearOpStack(opStack []*lexer.Token, output []ast.Node) (ast.No
 	return output[0], err
 }
 
-// handleNumber parse number if expected token in operand, otherwise error is returned
+// handleNumber parse number if expected token in operand, otherwise error is returned.
+// Invalid token inserted by error recovery is handled same way, but it produces ast.ErrorNode
 func (*Parser) handleNumber(
 	expect expectState,
 	curToken *lexer.Token,
@@ -147,7 +201,11 @@ func (*Parser) handleNumber(
 	if expect == operatorToken {
 		return expect, nil, parser.ParseError(curToken, ErrExpectedOperator)
 	}
-	output = append(output, ast.NewNumericNode(curToken.Value(), curToken))
+	var node ast.Node = ast.NewNumericNode(curToken.Value(), curToken)
+	if curToken.Type() == lexer.Invalid {
+		node = ast.NewErrorNode(curToken)
+	}
+	output = append(output, node)
 	expect = operatorToken
 
 	return expect, output, nil
@@ -191,27 +249,58 @@ func (*Parser) handleUnary(
 	return opStack, nil
 }
 
-// handleOperator parse token as binary operator or return error if operand is expected
+// handleNot parse logical negation, which is unary operator, so it cannot stand where operator is expected.
+// The only exception is factorial written after an operand
+func (p *Parser) handleNot(
+	expect expectState,
+	prevToken *lexer.Token,
+	curToken *lexer.Token,
+	nextToken *lexer.Token,
+	opStack []*lexer.Token,
+	output []ast.Node,
+) ([]*lexer.Token, []ast.Node, error) {
+	if _, isPostfix := parser.PostfixType(prevToken, curToken, nextToken); isPostfix && expect == operatorToken {
+		return p.handlePostfix(curToken, opStack, output)
+	}
+	if expect == operatorToken {
+		return nil, nil, parser.ParseError(curToken, ErrExpectedOperator)
+	}
+	opStack, err := p.handleUnary(curToken, opStack)
+	return opStack, output, err
+}
+
+// handleOperator parse token as binary operator or return error if operand is expected.
+// Colon of conditional operator is passed to handleColon and percent after an operand to handlePostfix
 func (p *Parser) handleOperator(
 	expect expectState,
+	prevToken *lexer.Token,
 	curToken *lexer.Token,
+	nextToken *lexer.Token,
 	opStack []*lexer.Token,
 	output []ast.Node,
 ) (expectState, []*lexer.Token, []ast.Node, error) {
+	if _, isPostfix := parser.PostfixType(prevToken, curToken, nextToken); isPostfix && expect == operatorToken {
+		opStack, output, err := p.handlePostfix(curToken, opStack, output)
+		return expect, opStack, output, err
+	}
 	if expect == operandToken {
 		return expect, nil, nil, parser.ParseError(curToken, ErrExpectedOperand)
 	}
+	if curToken.Type() == lexer.Colon {
+		return p.handleColon(expect, curToken, opStack, output)
+	}
 	for len(opStack) > 0 {
 		topStackEl := opStack[len(opStack)-1]
 		// Continue only, if the operator at the top of the operator stack is not a left parenthesis
-		if topStackEl.Type() == lexer.LPar {
+		// Question mark behaves same way, operators between it and the colon belong to the middle part
+		if topStackEl.Type() == lexer.LPar || topStackEl.Type() == lexer.Question {
 			break
 		}
 		// If the operator at the top of the operator stack has greater precedence
 		// OR
 		// The operator at the top of the operator stack has equal precedence and the token is left associative
-		if p.priorities.GetPrecedence(topStackEl.Type()) > p.priorities.GetPrecedence(curToken.Type()) ||
-			(p.priorities.GetPrecedence(topStackEl.Type()) == p.priorities.GetPrecedence(curToken.Type()) &&
+		if p.precedence(topStackEl.Type()) > p.precedence(curToken.Type()) ||
+			(p.precedence(topStackEl.Type()) == p.precedence(curToken.Type()) &&
 				p.priorities.GetAssociativity(curToken.Type()) == parser.LeftAssociativity) {
 			var err error
 			output, err = p.addToOutput(output, topStackEl)
@@ -230,32 +319,182 @@ func (p *Parser) handleOperator(
 	return expect, opStack, output, nil
 }
 
-// handleLPar parse left parenthesis or return error, if operator is expected
-func (*Parser) handleLPar(
+// handlePostfix applies postfix operator on the last operand, operators on the stack binding stronger are applied first
+func (p *Parser) handlePostfix(
+	curToken *lexer.Token,
+	opStack []*lexer.Token,
+	output []ast.Node,
+) ([]*lexer.Token, []ast.Node, error) {
+	_ = curToken.ChangeToPostfix()
+	for len(opStack) > 0 {
+		topStackEl := opStack[len(opStack)-1]
+		if topStackEl.Type() == lexer.LPar || topStackEl.Type() == lexer.Question ||
+			!p.appliedBeforePostfix(topStackEl.Type(), curToken.Type()) {
+			break
+		}
+		var err error
+		if output, err = p.addToOutput(output, topStackEl); err != nil {
+			return nil, nil, err
+		}
+		opStack = opStack[:len(opStack)-1]
+	}
+	output, err := p.addToOutput(output, curToken)
+	return opStack, output, err
+}
+
+// appliedBeforePostfix checks if the operator from the stack takes the operand before the postfix operator.
+// With the same precedence only left associative binary operators do, so -3! is -(3!) and 2^3! is 2^(3!)
+func (p *Parser) appliedBeforePostfix(operator, postfix lexer.TokenType) bool {
+	if p.precedence(operator) != p.precedence(postfix) {
+		return p.precedence(operator) > p.precedence(postfix)
+	}
+	switch operator {
+	case lexer.UnaryAddition, lexer.UnarySubstraction, lexer.Not:
+		return false
+	}
+	return p.priorities.GetAssociativity(operator) == parser.LeftAssociativity
+}
+
+// handleColon finish the middle part of conditional operator, operators up to the question mark are moved to output.
+// Condition and the middle part are kept in the partial conditional node, which is completed when colon is popped out
+func (p *Parser) handleColon(
+	expect expectState,
+	curToken *lexer.Token,
+	opStack []*lexer.Token,
+	output []ast.Node,
+) (expectState, []*lexer.Token, []ast.Node, error) {
+	if expect == operandToken {
+		return expect, nil, nil, parser.ParseError(curToken, ErrExpectedOperand)
+	}
+	for len(opStack) > 0 {
+		topStackEl := opStack[len(opStack)-1]
+		if topStackEl.Type() == lexer.LPar || topStackEl.Type() == lexer.Question {
+			break
+		}
+		var err error
+		if output, err = p.addToOutput(output, topStackEl); err != nil {
+			return expect, nil, nil, err
+		}
+		opStack = opStack[:len(opStack)-1]
+	}
+	if len(opStack) == 0 || opStack[len(opStack)-1].Type() != lexer.Question {
+		return expect, nil, nil, parser.ParseError(curToken, ErrMissingQuestion)
+	}
+	if len(output) < 2 {
+		return expect, nil, nil, errors.New("internal error, missing values for conditional operator")
+	}
+	questionToken := opStack[len(opStack)-1]
+	output[len(output)-2] = ast.NewConditionalNode(output[len(output)-2], output[len(output)-1], nil, questionToken)
+	output = output[:len(output)-1]
+	opStack[len(opStack)-1] = curToken
+	expect = operandToken
+
+	return expect, opStack, output, nil
+}
+
+// precedence returns precedence of the token, colon shares it with question mark as both are the same operator
+func (p *Parser) precedence(tokenType lexer.TokenType) parser.TokenPrecedence {
+	if tokenType == lexer.Colon {
+		tokenType = lexer.Question
+	}
+	return p.priorities.GetPrecedence(tokenType)
+}
+
+// handleLPar parse left parenthesis or return error, if operator is expected or nesting exceeds the limit of depth
+func (p *Parser) handleLPar(
 	expect expectState,
 	curToken *lexer.Token,
 	opStack []*lexer.Token,
+	openedPars int,
 ) (expectState, []*lexer.Token, error) {
 	if expect == operatorToken {
 		return expect, nil, parser.ParseError(curToken, ErrExpectedOperator)
 	}
+	if p.limits.Depth > 0 && openedPars >= p.limits.Depth {
+		return expect, nil, parser.ParseError(curToken, parser.ErrDepthLimit)
+	}
 	opStack = append(opStack, curToken)
 	expect = operandToken
 
 	return expect, opStack, nil
 }
 
-// handleRPar parse right parenthesis, checks all matching left parenthesis or return error if operand is expected
-func (p *Parser) handleRPar(
+// handleComma finish current function argument, all operators up to the left parenthesis are moved to output
+// Returns error if operand is expected or comma is not inside of function call
+func (p *Parser) handleComma(
 	expect expectState,
 	curToken *lexer.Token,
 	opStack []*lexer.Token,
 	output []ast.Node,
+	argsCount []int,
 ) (expectState, []*lexer.Token, []ast.Node, error) {
 	if expect == operandToken {
 		return expect, nil, nil, parser.ParseError(curToken, ErrExpectedOperand)
 	}
-	// Repeat until top of operator stack is no Left parenthesis
+	opStack, output, err := p.moveOperatorsToOutput(opStack, output)
+	if err != nil {
+		return expect, nil, nil, err
+	}
+	// Left parenthesis must be at the top of the stack and must belong to the function call
+	if !isFunctionCall(opStack) {
+		return expect, nil, nil, parser.ParseError(curToken, ErrUnexpectedComma)
+	}
+	argsCount[len(argsCount)-1]++
+	expect = operandToken
+
+	return expect, opStack, output, nil
+}
+
+// handleRPar parse right parenthesis, checks all matching left parenthesis or return error if operand is expected
+// The only exception, when operand can be expected, is function call without arguments
+func (p *Parser) handleRPar(
+	expect expectState,
+	curToken *lexer.Token,
+	prevToken *lexer.Token,
+	opStack []*lexer.Token,
+	output []ast.Node,
+	argsCount []int,
+) (expectState, []*lexer.Token, []ast.Node, []int, error) {
+	if expect == operandToken && (prevToken.Type() != lexer.LPar || !isFunctionCall(opStack)) {
+		return expect, nil, nil, nil, parser.ParseError(curToken, ErrExpectedOperand)
+	}
+	opStack, output, err := p.moveOperatorsToOutput(opStack, output)
+	if err != nil {
+		return expect, nil, nil, nil, err
+	}
+	// If operator stack is empty, there is no matching left parenthesis
+	if len(opStack) == 0 {
+		return expect, nil, nil, nil, parser.ParseError(curToken, ErrMissingLPar)
+	}
+	isFunction := isFunctionCall(opStack)
+	// If not empty, there must be left parenthesis, just remove it together with its arguments counter
+	opStack = opStack[:len(opStack)-1]
+	args := argsCount[len(argsCount)-1]
+	argsCount = argsCount[:len(argsCount)-1]
+
+	// Check if left parenthesis was there because of function call
+	if isFunction {
+		// Last argument is not followed by comma, so it was not counted yet
+		if expect == operatorToken {
+			args++
+		}
+		// Remove function name and add to output
+		output, err = p.addFunctionToOutput(output, opStack[len(opStack)-1], args)
+		if err != nil {
+			return expect, nil, nil, nil, err
+		}
+		opStack = opStack[:len(opStack)-1]
+	}
+
+	expect = operatorToken
+	return expect, opStack, output, argsCount, nil
+}
+
+// moveOperatorsToOutput pops operators from the stack into the output until left parenthesis is found
+func (p *Parser) moveOperatorsToOutput(
+	opStack []*lexer.Token,
+	output []ast.Node,
+) ([]*lexer.Token, []ast.Node, error) {
 	for len(opStack) > 0 {
 		topStackEl := opStack[len(opStack)-1]
 		if topStackEl.Type() == lexer.LPar {
@@ -265,38 +504,38 @@ func (p *Parser) handleRPar(
 		var err error
 		output, err = p.addToOutput(output, topStackEl)
 		if err != nil {
-			return expect, nil, nil, err
+			return nil, nil, err
 		}
 		opStack = opStack[:len(opStack)-1]
 	}
-	// If operator stack is empty, there is no matching left parenthesis
-	if len(opStack) == 0 {
-		return expect, nil, nil, parser.ParseError(curToken, ErrMissingLPar)
-	}
-	// If not empty, there must be left parenthesis, just remove it
-	opStack = opStack[:len(opStack)-1]
+	return opStack, output, nil
+}
 
-	// Check if left parenthesis was there because of function call
-	// If identifier is found, it must be function. Variables are never added to operator stack
-	if len(opStack) > 0 && opStack[len(opStack)-1].Type() == lexer.Identifier {
-		// Remove it and add to output
-		var err error
-		output, err = p.addToOutput(output, opStack[len(opStack)-1])
-		if err != nil {
-			return expect, nil, nil, err
-		}
-		opStack = opStack[:len(opStack)-1]
+// isFunctionCall checks if there is left parenthesis at the top of the stack preceded by function name
+// If identifier is found, it must be function. Variables are never added to operator stack
+func isFunctionCall(opStack []*lexer.Token) bool {
+	return len(opStack) > 1 &&
+		opStack[len(opStack)-1].Type() == lexer.LPar &&
+		opStack[len(opStack)-2].Type() == lexer.Identifier
+}
+
+// addFunctionToOutput takes last argsCount nodes from output and replaces them with function node
+func (*Parser) addFunctionToOutput(output []ast.Node, token *lexer.Token, argsCount int) ([]ast.Node, error) {
+	if len(output) < argsCount {
+		return nil, errors.New("internal error, missing values for function")
 	}
+	params := make([]ast.Node, argsCount)
+	copy(params, output[len(output)-argsCount:])
+	output = output[:len(output)-argsCount]
 
-	expect = operatorToken
-	return expect, opStack, output, nil
+	return append(output, ast.NewFunctionNode(token.Identifier(), params, token)), nil
 }
 
 func (p *Parser) addToOutput(output []ast.Node, token *lexer.Token) ([]ast.Node, error) {
 	var err error
 	var op ast.Operation
 	switch t := token.Type(); t {
-	case lexer.UnaryAddition, lexer.UnarySubstraction:
+	case lexer.UnaryAddition, lexer.UnarySubstraction, lexer.Not:
 		if len(output) < 1 {
 			return nil, errors.New("internal error, missing value for unary operator")
 		}
@@ -304,8 +543,17 @@ func (p *Parser) addToOutput(output []ast.Node, token *lexer.Token) ([]ast.Node,
 			return nil, err
 		}
 		output[len(output)-1] = ast.NewUnaryNode(op, output[len(output)-1], token)
+	case lexer.Factorial, lexer.Percent:
+		if len(output) < 1 {
+			return nil, errors.New("internal error, missing value for postfix operator")
+		}
+		if op, err = tokenTypeToOperation(t); err != nil {
+			return nil, err
+		}
+		output[len(output)-1] = ast.NewPostfixNode(op, output[len(output)-1], token)
 	case lexer.Addition, lexer.Substraction, lexer.Multiplication, lexer.Division, lexer.Exponent,
-		lexer.FloorDiv, lexer.Modulus:
+		lexer.FloorDiv, lexer.Modulus, lexer.Less, lexer.LessOrEqual, lexer.Greater, lexer.GreaterOrEqual,
+		lexer.IsEqual, lexer.NotEqual, lexer.And, lexer.Or, lexer.ImplicitMultiplication:
 
 		if len(output) < 2 {
 			return nil, errors.New("internal error, missing values for binary operator")
@@ -317,26 +565,53 @@ func (p *Parser) addToOutput(output []ast.Node, token *lexer.Token) ([]ast.Node,
 		if op, err = tokenTypeToOperation(t); err != nil {
 			return nil, err
 		}
-		output[len(output)-1] = ast.NewBinaryNode(op, l, r, token)
-	case lexer.Identifier:
-		// Current functions support only 1 parameter
-		if len(output) < 1 {
-			return nil, errors.New("internal error, missing value for function")
-		}
-		output[len(output)-1] = ast.NewFunctionNode(token.Identifier(), []ast.Node{output[len(output)-1]}, token)
+		output[len(output)-1] = parser.NewBinaryNode(op, l, r, token)
+	case lexer.Equal:
+		return addAssignToOutput(output, token)
+	case lexer.Colon:
+		return addConditionalToOutput(output)
+	case lexer.Question:
+		return nil, parser.ParseError(token, ErrMissingColon)
 	default:
 		return nil, fmt.Errorf("unexpected token '%s' received to add to output", t.String())
 	}
 	return output, err
 }
 
+// addAssignToOutput creates assign node, the left side must be a variable
+func addAssignToOutput(output []ast.Node, token *lexer.Token) ([]ast.Node, error) {
+	if len(output) < 2 {
+		return nil, errors.New("internal error, missing values for assignment")
+	}
+	variable, ok := output[len(output)-2].(*ast.VariableNode)
+	if !ok {
+		return nil, parser.ParseError(token, ErrInvalidAssign)
+	}
+	output[len(output)-2] = ast.NewAssignNode(variable, output[len(output)-1], token)
+	return output[:len(output)-1], nil
+}
+
+// addConditionalToOutput completes partial conditional node created by handleColon with the last part
+func addConditionalToOutput(output []ast.Node) ([]ast.Node, error) {
+	if len(output) < 2 {
+		return nil, errors.New("internal error, missing values for conditional operator")
+	}
+	partial, ok := output[len(output)-2].(*ast.ConditionalNode)
+	if !ok {
+		return nil, errors.New("internal error, missing condition of conditional operator")
+	}
+	output[len(output)-2] = ast.NewConditionalNode(
+		partial.Condition(), partial.Then(), output[len(output)-1], partial.GetToken())
+	return output[:len(output)-1], nil
+}
+
 func tokenTypeToOperation(tt lexer.TokenType) (ast.Operation, error) {
 	switch tt {
 	case lexer.UnaryAddition, lexer.Addition:
 		return ast.Addition, nil
 	case lexer.UnarySubstraction, lexer.Substraction:
 		return ast.Substraction, nil
-	case lexer.Multiplication:
+	case lexer.Multiplication, lexer.ImplicitMultiplication:
 		return ast.Multiplication, nil
 	case lexer.Division:
 		return ast.Division, nil
@@ -346,6 +621,35 @@ func tokenTypeToOperation(tt lexer.TokenType) (ast.Operation, error) {
 		return ast.FloorDiv, nil
 	case lexer.Modulus:
 		return ast.Modulus, nil
+	case lexer.Factorial:
+		return ast.Factorial, nil
+	case lexer.Percent:
+		return ast.Percent, nil
+	}
+	return comparisonOperation(tt)
+}
+
+// comparisonOperation converts comparison and logical operators
+func comparisonOperation(tt lexer.TokenType) (ast.Operation, error) {
+	switch tt {
+	case lexer.Less:
+		return ast.Less, nil
+	case lexer.LessOrEqual:
+		return ast.LessOrEqual, nil
+	case lexer.Greater:
+		return ast.Greater, nil
+	case lexer.GreaterOrEqual:
+		return ast.GreaterOrEqual, nil
+	case lexer.IsEqual:
+		return ast.IsEqual, nil
+	case lexer.NotEqual:
+		return ast.NotEqual, nil
+	case lexer.And:
+		return ast.And, nil
+	case lexer.Or:
+		return ast.Or, nil
+	case lexer.Not:
+		return ast.Not, nil
 	}
 	return ast.Invalid, fmt.Errorf("missing convertion of %s to AST operation", tt.String())
 }
@@ -1,6 +1,9 @@
 package shuntyard_test
 
 import (
+	"errors"
+	"strings"
+
 	"github.com/onsi/gomega/types"
 
 	"github.com/arxeiss/go-expression-calculator/ast"
@@ -46,6 +49,7 @@ var _ = Describe("Successful parsing", func() {
 		rootNode, err := p.Parse(input)
 		Expect(err).To(Succeed())
 		Expect(rootNode).NotTo(BeNil())
+		Expect(rootNode).To(SurviveJSONRoundTrip())
 
 		Expect(rootNode).To(MatchBinaryNode(
 			ast.Addition,
@@ -96,6 +100,7 @@ var _ = Describe("Successful parsing", func() {
 		rootNode, err := p.Parse(input)
 		Expect(err).To(Succeed())
 		Expect(rootNode).NotTo(BeNil())
+		Expect(rootNode).To(SurviveJSONRoundTrip())
 
 		Expect(rootNode).To(MatchUnaryNode(
 			ast.Substraction,
@@ -121,6 +126,7 @@ var _ = Describe("Successful parsing", func() {
 		rootNode, err := p.Parse(input)
 		Expect(err).To(Succeed())
 		Expect(rootNode).NotTo(BeNil())
+		Expect(rootNode).To(SurviveJSONRoundTrip())
 
 		Expect(rootNode).To(MatchBinaryNode(
 			ast.Addition,
@@ -160,6 +166,7 @@ var _ = Describe("Successful parsing", func() {
 		rootNode, err := p.Parse(input)
 		Expect(err).To(Succeed())
 		Expect(rootNode).NotTo(BeNil())
+		Expect(rootNode).To(SurviveJSONRoundTrip())
 
 		Expect(rootNode).To(MatchUnaryNode(
 			ast.Addition,
@@ -197,6 +204,7 @@ var _ = Describe("Successful parsing", func() {
 		rootNode, err := p.Parse(input)
 		Expect(err).To(Succeed())
 		Expect(rootNode).NotTo(BeNil())
+		Expect(rootNode).To(SurviveJSONRoundTrip())
 
 		Expect(rootNode).To(MatchBinaryNode(
 			ast.Substraction,
@@ -236,6 +244,7 @@ var _ = Describe("Successful parsing", func() {
 		rootNode, err := p.Parse(input)
 		Expect(err).To(Succeed())
 		Expect(rootNode).NotTo(BeNil())
+		Expect(rootNode).To(SurviveJSONRoundTrip())
 
 		Expect(rootNode).To(MatchBinaryNode(
 			ast.Exponent,
@@ -254,6 +263,323 @@ var _ = Describe("Successful parsing", func() {
 			),
 		))
 	})
+
+	It("Support chained assignment", func() {
+		p, err := shuntyard.NewParser(parser.DefaultTokenPriorities())
+		Expect(err).To(Succeed())
+		input := []*lexer.Token{
+			lexer.NewToken(lexer.Identifier, 0, "a", 0, 0),
+			lexer.NewToken(lexer.Equal, 0, "", 0, 0),
+			lexer.NewToken(lexer.Identifier, 0, "b", 0, 0),
+			lexer.NewToken(lexer.Equal, 0, "", 0, 0),
+			lexer.NewToken(lexer.Number, 3, "", 0, 0),
+			lexer.NewToken(lexer.EOL, 0, "", 0, 0),
+		}
+		rootNode, err := p.Parse(input)
+		Expect(err).To(Succeed())
+		Expect(rootNode).NotTo(BeNil())
+		Expect(rootNode).To(SurviveJSONRoundTrip())
+
+		Expect(rootNode).To(MatchAssignNode(
+			MatchVariableNode("a"),
+			MatchAssignNode(
+				MatchVariableNode("b"),
+				MatchNumericNode(3),
+			),
+		))
+	})
+
+	It("Support assignment inside expression", func() {
+		p, err := shuntyard.NewParser(parser.DefaultTokenPriorities())
+		Expect(err).To(Succeed())
+		input := []*lexer.Token{
+			lexer.NewToken(lexer.Identifier, 0, "y", 0, 0),
+			lexer.NewToken(lexer.Equal, 0, "", 0, 0),
+			lexer.NewToken(lexer.LPar, 0, "", 0, 0),
+			lexer.NewToken(lexer.Identifier, 0, "x", 0, 0),
+			lexer.NewToken(lexer.Equal, 0, "", 0, 0),
+			lexer.NewToken(lexer.Number, 2, "", 0, 0),
+			lexer.NewToken(lexer.RPar, 0, "", 0, 0),
+			lexer.NewToken(lexer.Multiplication, 0, "", 0, 0),
+			lexer.NewToken(lexer.Number, 3, "", 0, 0),
+			lexer.NewToken(lexer.EOL, 0, "", 0, 0),
+		}
+		rootNode, err := p.Parse(input)
+		Expect(err).To(Succeed())
+		Expect(rootNode).NotTo(BeNil())
+		Expect(rootNode).To(SurviveJSONRoundTrip())
+
+		Expect(rootNode).To(MatchAssignNode(
+			MatchVariableNode("y"),
+			MatchBinaryNode(
+				ast.Multiplication,
+				MatchAssignNode(
+					MatchVariableNode("x"),
+					MatchNumericNode(2),
+				),
+				MatchNumericNode(3),
+			),
+		))
+	})
+
+	It("Comparison and logical operators", func() {
+		p, err := shuntyard.NewParser(parser.DefaultTokenPriorities())
+		Expect(err).To(Succeed())
+		input := []*lexer.Token{
+			/* ! */ lexer.NewToken(lexer.Not, 0, "", 0, 0),
+			/* a */ lexer.NewToken(lexer.Identifier, 0, "a", 0, 0),
+			/* < */ lexer.NewToken(lexer.Less, 0, "", 0, 0),
+			/* b */ lexer.NewToken(lexer.Identifier, 0, "b", 0, 0),
+			/* + */ lexer.NewToken(lexer.Addition, 0, "", 0, 0),
+			/* 1 */ lexer.NewToken(lexer.Number, 1, "", 0, 0),
+			/* && */ lexer.NewToken(lexer.And, 0, "", 0, 0),
+			/* c */ lexer.NewToken(lexer.Identifier, 0, "c", 0, 0),
+			/* == */ lexer.NewToken(lexer.IsEqual, 0, "", 0, 0),
+			/* 2 */ lexer.NewToken(lexer.Number, 2, "", 0, 0),
+			/* || */ lexer.NewToken(lexer.Or, 0, "", 0, 0),
+			/* d */ lexer.NewToken(lexer.Identifier, 0, "d", 0, 0),
+			/* >= */ lexer.NewToken(lexer.GreaterOrEqual, 0, "", 0, 0),
+			/* - */ lexer.NewToken(lexer.Substraction, 0, "", 0, 0), // Will be changed to unary
+			/* e */ lexer.NewToken(lexer.Identifier, 0, "e", 0, 0),
+			/* != */ lexer.NewToken(lexer.NotEqual, 0, "", 0, 0),
+			/* ! */ lexer.NewToken(lexer.Not, 0, "", 0, 0),
+			/* f */ lexer.NewToken(lexer.Identifier, 0, "f", 0, 0),
+			lexer.NewToken(lexer.EOL, 0, "", 0, 0),
+		}
+		rootNode, err := p.Parse(input)
+		Expect(err).To(Succeed())
+		Expect(rootNode).NotTo(BeNil())
+		Expect(rootNode).To(SurviveJSONRoundTrip())
+
+		Expect(rootNode).To(MatchBinaryNode(
+			ast.Or,
+			MatchBinaryNode(
+				ast.And,
+				MatchBinaryNode(
+					ast.Less,
+					MatchUnaryNode(ast.Not, MatchVariableNode("a")),
+					MatchBinaryNode(ast.Addition, MatchVariableNode("b"), MatchNumericNode(1)),
+				),
+				MatchBinaryNode(ast.IsEqual, MatchVariableNode("c"), MatchNumericNode(2)),
+			),
+			MatchBinaryNode(
+				ast.NotEqual,
+				MatchBinaryNode(
+					ast.GreaterOrEqual,
+					MatchVariableNode("d"),
+					MatchUnaryNode(ast.Substraction, MatchVariableNode("e")),
+				),
+				MatchUnaryNode(ast.Not, MatchVariableNode("f")),
+			),
+		))
+	})
+
+	It("Conditional operator", func() {
+		p, err := shuntyard.NewParser(parser.DefaultTokenPriorities())
+		Expect(err).To(Succeed())
+		input := []*lexer.Token{
+			/* a */ lexer.NewToken(lexer.Identifier, 0, "a", 0, 0),
+			/* > */ lexer.NewToken(lexer.Greater, 0, "", 0, 0),
+			/* 0 */ lexer.NewToken(lexer.Number, 0, "", 0, 0),
+			/* ? */ lexer.NewToken(lexer.Question, 0, "", 0, 0),
+			/* b */ lexer.NewToken(lexer.Identifier, 0, "b", 0, 0),
+			/* ? */ lexer.NewToken(lexer.Question, 0, "", 0, 0),
+			/* 1 */ lexer.NewToken(lexer.Number, 1, "", 0, 0),
+			/* : */ lexer.NewToken(lexer.Colon, 0, "", 0, 0),
+			/* 2 */ lexer.NewToken(lexer.Number, 2, "", 0, 0),
+			/* : */ lexer.NewToken(lexer.Colon, 0, "", 0, 0),
+			/* c */ lexer.NewToken(lexer.Identifier, 0, "c", 0, 0),
+			/* || */ lexer.NewToken(lexer.Or, 0, "", 0, 0),
+			/* d */ lexer.NewToken(lexer.Identifier, 0, "d", 0, 0),
+			/* ? */ lexer.NewToken(lexer.Question, 0, "", 0, 0),
+			/* - */ lexer.NewToken(lexer.Substraction, 0, "", 0, 0), // Will be changed to unary
+			/* e */ lexer.NewToken(lexer.Identifier, 0, "e", 0, 0),
+			/* : */ lexer.NewToken(lexer.Colon, 0, "", 0, 0),
+			/* f */ lexer.NewToken(lexer.Identifier, 0, "f", 0, 0),
+			/* * */ lexer.NewToken(lexer.Multiplication, 0, "", 0, 0),
+			/* 3 */ lexer.NewToken(lexer.Number, 3, "", 0, 0),
+			lexer.NewToken(lexer.EOL, 0, "", 0, 0),
+		}
+		rootNode, err := p.Parse(input)
+		Expect(err).To(Succeed())
+		Expect(rootNode).NotTo(BeNil())
+		Expect(rootNode).To(SurviveJSONRoundTrip())
+
+		Expect(rootNode).To(MatchConditionalNode(
+			MatchBinaryNode(ast.Greater, MatchVariableNode("a"), MatchNumericNode(0)),
+			MatchConditionalNode(MatchVariableNode("b"), MatchNumericNode(1), MatchNumericNode(2)),
+			MatchConditionalNode(
+				MatchBinaryNode(ast.Or, MatchVariableNode("c"), MatchVariableNode("d")),
+				MatchUnaryNode(ast.Substraction, MatchVariableNode("e")),
+				MatchBinaryNode(ast.Multiplication, MatchVariableNode("f"), MatchNumericNode(3)),
+			),
+		))
+		Expect(rootNode.GetToken()).To(BeIdenticalTo(input[3]))
+	})
+
+	It("Multiple statements", func() {
+		p, err := shuntyard.NewParser(parser.DefaultTokenPriorities())
+		Expect(err).To(Succeed())
+		input, err := lexer.NewLexer("max(a, 3); b * 2\n\n;b ^ 2;").Tokenize()
+		Expect(err).To(Succeed())
+		rootNode, err := p.Parse(input)
+		Expect(err).To(Succeed())
+		Expect(rootNode).To(SurviveJSONRoundTrip())
+
+		Expect(rootNode).To(MatchBlockNode(
+			MatchFunctionNode("max", MatchVariableNode("a"), MatchNumericNode(3)),
+			MatchBinaryNode(ast.Multiplication, MatchVariableNode("b"), MatchNumericNode(2)),
+			MatchBinaryNode(ast.Exponent, MatchVariableNode("b"), MatchNumericNode(2)),
+		))
+		Expect(rootNode.GetToken()).To(BeIdenticalTo(input[0]))
+	})
+
+	It("Reports line and column of error in later statement", func() {
+		p, err := shuntyard.NewParser(parser.DefaultTokenPriorities())
+		Expect(err).To(Succeed())
+		input, err := lexer.NewLexer("a + 3\nb * (a *\n 2 +)").Tokenize()
+		Expect(err).To(Succeed())
+		rootNode, err := p.Parse(input)
+		Expect(rootNode).To(BeNil())
+
+		parseErr, ok := err.(*parser.Error)
+		Expect(ok).To(BeTrue())
+		Expect(parseErr.Position()).To(Equal(19))
+		Expect(parseErr.Location()).To(Equal(lexer.Location{Offset: 19, Length: 1, Line: 3, Column: 5}))
+		Expect(parseErr.Error()).To(ContainSubstring("found RPar token at line 3, column 5"))
+	})
+
+	It("Support functions without arguments", func() {
+		p, err := shuntyard.NewParser(parser.DefaultTokenPriorities())
+		Expect(err).To(Succeed())
+		input := []*lexer.Token{
+			lexer.NewToken(lexer.Identifier, 0, "rand", 0, 0),
+			lexer.NewToken(lexer.LPar, 0, "", 0, 0),
+			lexer.NewToken(lexer.RPar, 0, "", 0, 0),
+			lexer.NewToken(lexer.Multiplication, 0, "", 0, 0),
+			lexer.NewToken(lexer.Identifier, 0, "pi", 0, 0),
+			lexer.NewToken(lexer.LPar, 0, "", 0, 0),
+			lexer.NewToken(lexer.RPar, 0, "", 0, 0),
+			lexer.NewToken(lexer.EOL, 0, "", 0, 0),
+		}
+		rootNode, err := p.Parse(input)
+		Expect(err).To(Succeed())
+		Expect(rootNode).NotTo(BeNil())
+		Expect(rootNode).To(SurviveJSONRoundTrip())
+
+		Expect(rootNode).To(MatchBinaryNode(
+			ast.Multiplication,
+			MatchFunctionNode("rand"),
+			MatchFunctionNode("pi"),
+		))
+	})
+
+	It("Support functions with multiple arguments", func() {
+		p, err := shuntyard.NewParser(parser.DefaultTokenPriorities())
+		Expect(err).To(Succeed())
+		input := []*lexer.Token{
+			// max(a, 123, b, -55---31^2)
+			lexer.NewToken(lexer.Identifier, 0, "max", 0, 0),
+			lexer.NewToken(lexer.LPar, 0, "", 0, 0),
+			lexer.NewToken(lexer.Identifier, 0, "a", 0, 0),
+			lexer.NewToken(lexer.Comma, 0, "", 0, 0),
+			lexer.NewToken(lexer.Number, 123, "", 0, 0),
+			lexer.NewToken(lexer.Comma, 0, "", 0, 0),
+			lexer.NewToken(lexer.Identifier, 0, "b", 0, 0),
+			lexer.NewToken(lexer.Comma, 0, "", 0, 0),
+			lexer.NewToken(lexer.Substraction, 0, "", 0, 0),
+			lexer.NewToken(lexer.Number, 55, "", 0, 0),
+			lexer.NewToken(lexer.Substraction, 0, "", 0, 0),
+			lexer.NewToken(lexer.Substraction, 0, "", 0, 0),
+			lexer.NewToken(lexer.Substraction, 0, "", 0, 0),
+			lexer.NewToken(lexer.Number, 31, "", 0, 0),
+			lexer.NewToken(lexer.Exponent, 0, "", 0, 0),
+			lexer.NewToken(lexer.Number, 2, "", 0, 0),
+			lexer.NewToken(lexer.RPar, 0, "", 0, 0),
+			lexer.NewToken(lexer.EOL, 0, "", 0, 0),
+		}
+		rootNode, err := p.Parse(input)
+		Expect(err).To(Succeed())
+		Expect(rootNode).NotTo(BeNil())
+		Expect(rootNode).To(SurviveJSONRoundTrip())
+
+		Expect(rootNode).To(MatchFunctionNode(
+			"max",
+			MatchVariableNode("a"),
+			MatchNumericNode(123),
+			MatchVariableNode("b"),
+			MatchBinaryNode(
+				ast.Substraction,
+				MatchUnaryNode(ast.Substraction, MatchNumericNode(55)),
+				MatchUnaryNode(
+					ast.Substraction,
+					MatchUnaryNode(
+						ast.Substraction,
+						MatchBinaryNode(
+							ast.Exponent,
+							MatchNumericNode(31),
+							MatchNumericNode(2),
+						),
+					),
+				),
+			),
+		))
+	})
+
+	It("Support functions inside functions", func() {
+		p, err := shuntyard.NewParser(parser.DefaultTokenPriorities())
+		Expect(err).To(Succeed())
+		input := []*lexer.Token{
+			/* min */ lexer.NewToken(lexer.Identifier, 0, "min", 0, 0),
+			/* ( */ lexer.NewToken(lexer.LPar, 0, "", 0, 0),
+			/* sin */ lexer.NewToken(lexer.Identifier, 0, "sin", 0, 0),
+			/* ( */ lexer.NewToken(lexer.LPar, 0, "", 0, 0),
+			/* 4 */ lexer.NewToken(lexer.Number, 4, "", 0, 0),
+			/* * */ lexer.NewToken(lexer.Multiplication, 0, "", 0, 0),
+			/* 7 */ lexer.NewToken(lexer.Number, 7, "", 0, 0),
+			/* ) */ lexer.NewToken(lexer.RPar, 0, "", 0, 0),
+			/* , */ lexer.NewToken(lexer.Comma, 0, "", 0, 0),
+			/* cos */ lexer.NewToken(lexer.Identifier, 0, "cos", 0, 0),
+			/* ( */ lexer.NewToken(lexer.LPar, 0, "", 0, 0),
+			/* max */ lexer.NewToken(lexer.Identifier, 0, "max", 0, 0),
+			/* ( */ lexer.NewToken(lexer.LPar, 0, "", 0, 0),
+			/* a */ lexer.NewToken(lexer.Identifier, 0, "a", 0, 0),
+			/* , */ lexer.NewToken(lexer.Comma, 0, "", 0, 0),
+			/* sqrt */ lexer.NewToken(lexer.Identifier, 0, "sqrt", 0, 0),
+			/* ( */ lexer.NewToken(lexer.LPar, 0, "", 0, 0),
+			/* 18 */ lexer.NewToken(lexer.Number, 18, "", 0, 0),
+			/* ) */ lexer.NewToken(lexer.RPar, 0, "", 0, 0),
+			/* , */ lexer.NewToken(lexer.Comma, 0, "", 0, 0),
+			/* ( */ lexer.NewToken(lexer.LPar, 0, "", 0, 0),
+			/* 25 */ lexer.NewToken(lexer.Number, 25, "", 0, 0),
+			/* ) */ lexer.NewToken(lexer.RPar, 0, "", 0, 0),
+			/* , */ lexer.NewToken(lexer.Comma, 0, "", 0, 0),
+			/* pi */ lexer.NewToken(lexer.Identifier, 0, "pi", 0, 0),
+			/* ( */ lexer.NewToken(lexer.LPar, 0, "", 0, 0),
+			/* ) */ lexer.NewToken(lexer.RPar, 0, "", 0, 0),
+			/* ) */ lexer.NewToken(lexer.RPar, 0, "", 0, 0),
+			/* ) */ lexer.NewToken(lexer.RPar, 0, "", 0, 0),
+			/* ) */ lexer.NewToken(lexer.RPar, 0, "", 0, 0),
+			lexer.NewToken(lexer.EOL, 0, "", 0, 0),
+		}
+
+		rootNode, err := p.Parse(input)
+		Expect(err).To(Succeed())
+		Expect(rootNode).NotTo(BeNil())
+		Expect(rootNode).To(SurviveJSONRoundTrip())
+
+		Expect(rootNode).To(MatchFunctionNode(
+			"min",
+			MatchFunctionNode("sin", MatchBinaryNode(ast.Multiplication, MatchNumericNode(4), MatchNumericNode(7))),
+			MatchFunctionNode("cos", MatchFunctionNode(
+				"max",
+				MatchVariableNode("a"),
+				MatchFunctionNode("sqrt", MatchNumericNode(18)),
+				MatchNumericNode(25),
+				MatchFunctionNode("pi"),
+			)),
+		))
+	})
 })
 
 var _ = DescribeTable("Handle errors",
@@ -274,22 +600,67 @@ var _ = DescribeTable("Handle errors",
 		lexer.NewToken(lexer.Identifier, 0, "abc", 0, 3),
 	}, Equal(0), ContainSubstring(shuntyard.ErrExpectedEOL.Error())),
 
-	Entry("Assignment to variable is not supported by this algorithm", []*lexer.Token{
-		lexer.NewToken(lexer.Identifier, 0, "abc", 0, 3),
-		lexer.NewToken(lexer.Equal, 0, "", 3, 4),
-		lexer.NewToken(lexer.Number, 123, "", 4, 7),
-		lexer.NewToken(lexer.EOL, 0, "", 7, 7),
-	}, Equal(3), ContainSubstring("unsupported token; found Equal token at position 3")),
+	Entry("Assign to number is not valid", []*lexer.Token{
+		lexer.NewToken(lexer.Number, 20, "", 0, 2),
+		lexer.NewToken(lexer.Addition, 0, "", 2, 3),
+		lexer.NewToken(lexer.Number, 77, "", 3, 5),
+		lexer.NewToken(lexer.Equal, 0, "", 5, 6),
+		lexer.NewToken(lexer.Number, 99, "", 6, 8),
+		lexer.NewToken(lexer.EOL, 0, "", 8, 8),
+	}, Equal(5), ContainSubstring("only variable can be assigned; found Equal token at position 5")),
+
+	Entry("Assign to function call is not valid", []*lexer.Token{
+		lexer.NewToken(lexer.Identifier, 0, "f", 0, 1),
+		lexer.NewToken(lexer.LPar, 0, "", 1, 2),
+		lexer.NewToken(lexer.Identifier, 0, "x", 2, 3),
+		lexer.NewToken(lexer.RPar, 0, "", 3, 4),
+		lexer.NewToken(lexer.Equal, 0, "", 4, 5),
+		lexer.NewToken(lexer.Number, 1, "", 5, 6),
+		lexer.NewToken(lexer.EOL, 0, "", 6, 6),
+	}, Equal(4), ContainSubstring("only variable can be assigned; found Equal token at position 4")),
+
+	Entry("Comma outside of function call", []*lexer.Token{
+		lexer.NewToken(lexer.Number, 23, "", 0, 2),
+		lexer.NewToken(lexer.Comma, 0, "", 2, 3),
+		lexer.NewToken(lexer.Number, 46, "", 3, 5),
+		lexer.NewToken(lexer.EOL, 0, "", 5, 5),
+	}, Equal(2), ContainSubstring("comma is allowed only to separate function arguments; found Comma token at position 2")), //nolint:lll
 
-	Entry("Function with multiple arguments is not supported by this algorithm", []*lexer.Token{
+	Entry("Comma inside of parenthesis which are not function call", []*lexer.Token{
 		lexer.NewToken(lexer.Identifier, 0, "sin", 0, 3),
 		lexer.NewToken(lexer.LPar, 0, "", 3, 4),
+		lexer.NewToken(lexer.LPar, 0, "", 4, 5),
+		lexer.NewToken(lexer.Number, 23, "", 5, 7),
+		lexer.NewToken(lexer.Comma, 0, "", 7, 8),
+		lexer.NewToken(lexer.Number, 46, "", 8, 10),
+		lexer.NewToken(lexer.RPar, 0, "", 10, 11),
+		lexer.NewToken(lexer.RPar, 0, "", 11, 12),
+		lexer.NewToken(lexer.EOL, 0, "", 12, 12),
+	}, Equal(7), ContainSubstring("comma is allowed only to separate function arguments; found Comma token at position 7")), //nolint:lll
+
+	Entry("Missing function argument after comma", []*lexer.Token{
+		lexer.NewToken(lexer.Identifier, 0, "max", 0, 3),
+		lexer.NewToken(lexer.LPar, 0, "", 3, 4),
 		lexer.NewToken(lexer.Number, 23, "", 4, 6),
 		lexer.NewToken(lexer.Comma, 0, "", 6, 7),
-		lexer.NewToken(lexer.Number, 46, "", 7, 9),
-		lexer.NewToken(lexer.RPar, 0, "", 9, 10),
-		lexer.NewToken(lexer.EOL, 0, "", 10, 10),
-	}, Equal(6), ContainSubstring("unsupported token; found Comma token at position 6")),
+		lexer.NewToken(lexer.RPar, 0, "", 7, 8),
+		lexer.NewToken(lexer.EOL, 0, "", 8, 8),
+	}, Equal(7), ContainSubstring("expected number, identifier or left parenthesis; found RPar token at position 7")),
+
+	Entry("Missing function argument before comma", []*lexer.Token{
+		lexer.NewToken(lexer.Identifier, 0, "max", 0, 3),
+		lexer.NewToken(lexer.LPar, 0, "", 3, 4),
+		lexer.NewToken(lexer.Comma, 0, "", 4, 5),
+		lexer.NewToken(lexer.Number, 23, "", 5, 7),
+		lexer.NewToken(lexer.RPar, 0, "", 7, 8),
+		lexer.NewToken(lexer.EOL, 0, "", 8, 8),
+	}, Equal(4), ContainSubstring("expected number, identifier or left parenthesis; found Comma token at position 4")),
+
+	Entry("Empty parenthesis which are not function call", []*lexer.Token{
+		lexer.NewToken(lexer.LPar, 0, "", 0, 1),
+		lexer.NewToken(lexer.RPar, 0, "", 1, 2),
+		lexer.NewToken(lexer.EOL, 0, "", 2, 2),
+	}, Equal(1), ContainSubstring("expected number, identifier or left parenthesis; found RPar token at position 1")),
 
 	Entry("Two number tokens in a row", []*lexer.Token{
 		lexer.NewToken(lexer.Number, 20, "", 0, 2),
@@ -337,6 +708,104 @@ var _ = DescribeTable("Handle errors",
 		Equal(3),
 		ContainSubstring("expected number, identifier or left parenthesis; found Division token at position 3"),
 	),
+	Entry("Logical negation is not binary operator",
+		[]*lexer.Token{
+			lexer.NewToken(lexer.Identifier, 0, "a", 0, 1),
+			lexer.NewToken(lexer.Not, 0, "", 2, 3),
+			lexer.NewToken(lexer.Identifier, 0, "b", 4, 5),
+			lexer.NewToken(lexer.EOL, 0, "", 5, 5),
+		},
+		Equal(2),
+		ContainSubstring("expected operator or right parenthesis; found Not token at position 2"),
+	),
+	Entry("Factorial cannot be followed by operand",
+		[]*lexer.Token{
+			lexer.NewToken(lexer.Number, 3, "", 0, 1),
+			lexer.NewToken(lexer.Not, 0, "", 1, 2),
+			lexer.NewToken(lexer.Number, 4, "", 3, 4),
+			lexer.NewToken(lexer.EOL, 0, "", 4, 4),
+		},
+		Equal(1),
+		ContainSubstring("expected operator or right parenthesis; found Not token at position 1"),
+	),
+	Entry("Missing operand of comparison",
+		[]*lexer.Token{
+			lexer.NewToken(lexer.Identifier, 0, "a", 0, 1),
+			lexer.NewToken(lexer.LessOrEqual, 0, "", 2, 4),
+			lexer.NewToken(lexer.And, 0, "", 5, 7),
+			lexer.NewToken(lexer.Identifier, 0, "b", 8, 9),
+			lexer.NewToken(lexer.EOL, 0, "", 9, 9),
+		},
+		Equal(5),
+		ContainSubstring("expected number, identifier or left parenthesis; found And token at position 5"),
+	),
+	Entry("Conditional without condition",
+		[]*lexer.Token{
+			lexer.NewToken(lexer.Question, 0, "", 0, 1),
+			lexer.NewToken(lexer.Identifier, 0, "a", 2, 3),
+			lexer.NewToken(lexer.Colon, 0, "", 4, 5),
+			lexer.NewToken(lexer.Identifier, 0, "b", 6, 7),
+			lexer.NewToken(lexer.EOL, 0, "", 7, 7),
+		},
+		Equal(0),
+		ContainSubstring("expected number, identifier or left parenthesis; found Question token at position 0"),
+	),
+	Entry("Conditional without middle part",
+		[]*lexer.Token{
+			lexer.NewToken(lexer.Identifier, 0, "a", 0, 1),
+			lexer.NewToken(lexer.Question, 0, "", 2, 3),
+			lexer.NewToken(lexer.Colon, 0, "", 4, 5),
+			lexer.NewToken(lexer.Identifier, 0, "b", 6, 7),
+			lexer.NewToken(lexer.EOL, 0, "", 7, 7),
+		},
+		Equal(4),
+		ContainSubstring("expected number, identifier or left parenthesis; found Colon token at position 4"),
+	),
+	Entry("Conditional without last part",
+		[]*lexer.Token{
+			lexer.NewToken(lexer.Identifier, 0, "a", 0, 1),
+			lexer.NewToken(lexer.Question, 0, "", 2, 3),
+			lexer.NewToken(lexer.Identifier, 0, "b", 4, 5),
+			lexer.NewToken(lexer.Colon, 0, "", 6, 7),
+			lexer.NewToken(lexer.EOL, 0, "", 7, 7),
+		},
+		Equal(7),
+		ContainSubstring("found EOL token at position 7"),
+	),
+	Entry("Conditional without colon",
+		[]*lexer.Token{
+			lexer.NewToken(lexer.Identifier, 0, "a", 0, 1),
+			lexer.NewToken(lexer.Question, 0, "", 2, 3),
+			lexer.NewToken(lexer.Identifier, 0, "b", 4, 5),
+			lexer.NewToken(lexer.EOL, 0, "", 5, 5),
+		},
+		Equal(2),
+		ContainSubstring("cannot find matching colon of conditional operator; found Question token at position 2"),
+	),
+	Entry("Conditional closed by right parenthesis before colon",
+		[]*lexer.Token{
+			lexer.NewToken(lexer.LPar, 0, "", 0, 1),
+			lexer.NewToken(lexer.Identifier, 0, "a", 1, 2),
+			lexer.NewToken(lexer.Question, 0, "", 3, 4),
+			lexer.NewToken(lexer.Identifier, 0, "b", 5, 6),
+			lexer.NewToken(lexer.RPar, 0, "", 6, 7),
+			lexer.NewToken(lexer.Colon, 0, "", 8, 9),
+			lexer.NewToken(lexer.Identifier, 0, "c", 10, 11),
+			lexer.NewToken(lexer.EOL, 0, "", 11, 11),
+		},
+		Equal(3),
+		ContainSubstring("cannot find matching colon of conditional operator; found Question token at position 3"),
+	),
+	Entry("Colon without question mark",
+		[]*lexer.Token{
+			lexer.NewToken(lexer.Identifier, 0, "a", 0, 1),
+			lexer.NewToken(lexer.Colon, 0, "", 2, 3),
+			lexer.NewToken(lexer.Identifier, 0, "b", 4, 5),
+			lexer.NewToken(lexer.EOL, 0, "", 5, 5),
+		},
+		Equal(2),
+		ContainSubstring("cannot find matching question mark of conditional operator; found Colon token at position 2"),
+	),
 	Entry("Found left parenthesis, expecting operator",
 		[]*lexer.Token{
 			lexer.NewToken(lexer.Number, 20, "", 0, 2),
@@ -391,3 +860,265 @@ var _ = DescribeTable("Handle errors",
 		ContainSubstring("cannot find matching left parenthesis; found RPar token at position 8"),
 	),
 )
+
+var _ = DescribeTable("Recover from errors",
+	func(expression string, nodeMatcher types.GomegaMatcher, diagnostics ...string) {
+		p, err := shuntyard.NewParser(parser.DefaultTokenPriorities())
+		Expect(err).To(Succeed())
+		input, err := lexer.NewLexer(expression).Tokenize()
+		Expect(err).To(Succeed())
+
+		rootNode, errs := p.(parser.RecoveringParser).ParseWithRecovery(input)
+		Expect(rootNode).To(nodeMatcher)
+		Expect(rootNode).To(SurviveJSONRoundTrip())
+
+		Expect(errs).To(HaveLen(len(diagnostics)))
+		for i, d := range diagnostics {
+			Expect(errs[i].Error()).To(ContainSubstring(d))
+		}
+	},
+	Entry("Valid input has no diagnostics",
+		"max(1, 2)",
+		MatchFunctionNode("max", MatchNumericNode(1), MatchNumericNode(2)),
+	),
+	Entry("Missing function argument",
+		"max(1, , 3)",
+		MatchFunctionNode("max", MatchNumericNode(1), MatchErrorNode(), MatchNumericNode(3)),
+		"expected number, identifier or left parenthesis; found Comma token at position 7",
+	),
+	Entry("Invalid function argument",
+		"max(1 2, 3)",
+		MatchFunctionNode("max", MatchErrorNode(), MatchNumericNode(3)),
+		"expected operator or right parenthesis; found Number token at position 6",
+	),
+	Entry("Invalid expression in parenthesis",
+		"-(1 +) * 2",
+		MatchBinaryNode(ast.Multiplication, MatchUnaryNode(ast.Substraction, MatchErrorNode()), MatchNumericNode(2)),
+		"expected number, identifier or left parenthesis; found RPar token at position 5",
+	),
+	Entry("Missing right parenthesis",
+		"max(1, 2",
+		MatchFunctionNode("max", MatchNumericNode(1), MatchNumericNode(2)),
+		"cannot find matching right parenthesis; found LPar token at position 3",
+	),
+	Entry("Extra right parenthesis",
+		"1 + 2)",
+		MatchBinaryNode(ast.Addition, MatchNumericNode(1), MatchNumericNode(2)),
+		"cannot find matching left parenthesis; found RPar token at position 5",
+	),
+	Entry("Errors in nested functions",
+		"min(sin(1 +), 2 * )",
+		MatchFunctionNode("min", MatchFunctionNode("sin", MatchErrorNode()), MatchErrorNode()),
+		"expected number, identifier or left parenthesis; found RPar token at position 11",
+		"expected number, identifier or left parenthesis; found RPar token at position 18",
+	),
+	Entry("Invalid statement outside of parenthesis",
+		"1, 2",
+		MatchErrorNode(),
+		"comma is allowed only to separate function arguments; found Comma token at position 1",
+	),
+	Entry("Empty input",
+		"",
+		MatchErrorNode(),
+		"unexpected end of input; found EOL token at position 0",
+	),
+	Entry("Errors in multiple statements",
+		"1 + * 2; max(2, 3 +)\n(4 - 1",
+		MatchBlockNode(
+			MatchErrorNode(),
+			MatchFunctionNode("max", MatchNumericNode(2), MatchErrorNode()),
+			MatchBinaryNode(ast.Substraction, MatchNumericNode(4), MatchNumericNode(1)),
+		),
+		"expected number, identifier or left parenthesis; found Multiplication token at position 4",
+		"expected number, identifier or left parenthesis; found RPar token at position 19",
+		"cannot find matching right parenthesis; found LPar token at line 2, column 1",
+	),
+)
+
+var _ = DescribeTable("Limits",
+	func(expression string, limits parser.Limits, expectedErr error, message string) {
+		p, err := shuntyard.NewParser(parser.DefaultTokenPriorities())
+		Expect(err).To(Succeed())
+		p.(parser.LimitedParser).SetLimits(limits)
+		input, err := lexer.NewLexer(expression).Tokenize()
+		Expect(err).To(Succeed())
+
+		rootNode, err := p.Parse(input)
+		if expectedErr == nil {
+			Expect(err).To(Succeed())
+			Expect(rootNode).ToNot(BeNil())
+			return
+		}
+		Expect(rootNode).To(BeNil())
+		Expect(errors.Is(err, expectedErr)).To(BeTrue())
+		Expect(err).To(MatchError(message))
+	},
+	Entry("Within limits", "max(1, 2 * (3 + 4))", parser.Limits{Depth: 4, Nodes: 7}, nil, ""),
+	Entry("Left associative operators", "1 - 2 - 3 - 4", parser.Limits{Depth: 3}, parser.ErrDepthLimit,
+		"expression exceeded the limit of depth; found Number token at position 0"),
+	Entry("Nested parentheses", "((((1))))", parser.Limits{Depth: 3}, parser.ErrDepthLimit,
+		"expression exceeded the limit of depth; found LPar token at position 3"),
+	Entry("Deeply nested parentheses",
+		strings.Repeat("(", 100000)+"1"+strings.Repeat(")", 100000), parser.Limits{Depth: 100},
+		parser.ErrDepthLimit, "expression exceeded the limit of depth; found LPar token at position 100"),
+	Entry("Unary operators", "- - - 1", parser.Limits{Depth: 3}, parser.ErrDepthLimit,
+		"expression exceeded the limit of depth; found Number token at position 6"),
+	Entry("Right associative operators", "2 ^ 2 ^ 2 ^ 2", parser.Limits{Depth: 3}, parser.ErrDepthLimit,
+		"expression exceeded the limit of depth; found Number token at position 8"),
+	Entry("Function arguments", "max(1, max(2, 3))", parser.Limits{Depth: 2}, parser.ErrDepthLimit,
+		"expression exceeded the limit of depth; found Number token at position 11"),
+	Entry("Statements are checked separately", "1 - 2; -(-3)", parser.Limits{Depth: 3}, nil, ""),
+	Entry("Number of nodes", "1 + 2 * 3", parser.Limits{Nodes: 4}, parser.ErrNodeLimit,
+		"expression exceeded the limit of nodes; found Number token at position 8"),
+	Entry("Number of nodes in all statements", "1 + 2; 3 * 4", parser.Limits{Nodes: 4}, parser.ErrNodeLimit,
+		"expression exceeded the limit of nodes; found Number token at position 7"),
+)
+
+var _ = DescribeTable("Postfix operators",
+	func(expression string, nodeMatcher types.GomegaMatcher) {
+		p, err := shuntyard.NewParser(parser.DefaultTokenPriorities())
+		Expect(err).To(Succeed())
+		input, err := lexer.NewLexer(expression).Tokenize()
+		Expect(err).To(Succeed())
+
+		rootNode, err := p.Parse(input)
+		Expect(err).To(Succeed())
+		Expect(rootNode).To(nodeMatcher)
+		Expect(rootNode).To(SurviveJSONRoundTrip())
+	},
+	Entry("Factorial", "3!", MatchPostfixNode(ast.Factorial, MatchNumericNode(3))),
+	Entry("Factorial of parenthesis", "(a + 1)!",
+		MatchPostfixNode(ast.Factorial, MatchBinaryNode(ast.Addition, MatchVariableNode("a"), MatchNumericNode(1))),
+	),
+	Entry("Factorial binds stronger than unary operator", "-3!",
+		MatchUnaryNode(ast.Substraction, MatchPostfixNode(ast.Factorial, MatchNumericNode(3))),
+	),
+	Entry("Logical negation of factorial", "!3!",
+		MatchUnaryNode(ast.Not, MatchPostfixNode(ast.Factorial, MatchNumericNode(3))),
+	),
+	Entry("Factorial in exponent", "2 ^ 3!",
+		MatchBinaryNode(ast.Exponent, MatchNumericNode(2), MatchPostfixNode(ast.Factorial, MatchNumericNode(3))),
+	),
+	Entry("Factorial before multiplication", "3! * 2",
+		MatchBinaryNode(ast.Multiplication, MatchPostfixNode(ast.Factorial, MatchNumericNode(3)), MatchNumericNode(2)),
+	),
+	Entry("Repeated factorial", "3!!",
+		MatchPostfixNode(ast.Factorial, MatchPostfixNode(ast.Factorial, MatchNumericNode(3))),
+	),
+	Entry("Factorial compared", "a! != 1",
+		MatchBinaryNode(ast.NotEqual, MatchPostfixNode(ast.Factorial, MatchVariableNode("a")), MatchNumericNode(1)),
+	),
+	Entry("Factorial in function argument", "max(3!, 2)",
+		MatchFunctionNode("max", MatchPostfixNode(ast.Factorial, MatchNumericNode(3)), MatchNumericNode(2)),
+	),
+	Entry("Percent", "50%", MatchPostfixNode(ast.Percent, MatchNumericNode(50))),
+	Entry("Percent added to the left operand", "100 + 10%",
+		MatchBinaryNode(ast.Addition, MatchNumericNode(100), MatchPostfixNode(ast.Percent, MatchNumericNode(10))),
+	),
+	Entry("Percent followed by operator", "10% * 5",
+		MatchBinaryNode(ast.Multiplication, MatchPostfixNode(ast.Percent, MatchNumericNode(10)), MatchNumericNode(5)),
+	),
+	Entry("Percent followed by sign in parentheses", "(10%) - 5",
+		MatchBinaryNode(ast.Substraction, MatchPostfixNode(ast.Percent, MatchNumericNode(10)), MatchNumericNode(5)),
+	),
+	Entry("Percent followed by sign", "100 + 10% - 5",
+		MatchBinaryNode(ast.Substraction,
+			MatchBinaryNode(ast.Addition, MatchNumericNode(100), MatchPostfixNode(ast.Percent, MatchNumericNode(10))),
+			MatchNumericNode(5),
+		),
+	),
+	Entry("Percent of percent", "10% + 20%",
+		MatchBinaryNode(ast.Addition,
+			MatchPostfixNode(ast.Percent, MatchNumericNode(10)), MatchPostfixNode(ast.Percent, MatchNumericNode(20)),
+		),
+	),
+	Entry("Percent followed by substraction", "10% - 1",
+		MatchBinaryNode(ast.Substraction, MatchPostfixNode(ast.Percent, MatchNumericNode(10)), MatchNumericNode(1)),
+	),
+	Entry("Factorial of percent", "a + 10%!",
+		MatchBinaryNode(ast.Addition,
+			MatchVariableNode("a"),
+			MatchPostfixNode(ast.Factorial, MatchPostfixNode(ast.Percent, MatchNumericNode(10))),
+		),
+	),
+	Entry("Percent at the end after space", "10 %",
+		MatchPostfixNode(ast.Percent, MatchNumericNode(10)),
+	),
+	Entry("Percent of factorial", "3!%",
+		MatchPostfixNode(ast.Percent, MatchPostfixNode(ast.Factorial, MatchNumericNode(3))),
+	),
+	Entry("Modulus", "10 % 3",
+		MatchBinaryNode(ast.Modulus, MatchNumericNode(10), MatchNumericNode(3)),
+	),
+	Entry("Modulus of negative number", "10 % (-3)",
+		MatchBinaryNode(ast.Modulus, MatchNumericNode(10), MatchUnaryNode(ast.Substraction, MatchNumericNode(3))),
+	),
+	Entry("Modulus followed by sign", "10 % -3 + 10 % +x",
+		MatchBinaryNode(ast.Addition,
+			MatchBinaryNode(ast.Modulus, MatchNumericNode(10), MatchUnaryNode(ast.Substraction, MatchNumericNode(3))),
+			MatchBinaryNode(ast.Modulus, MatchNumericNode(10), MatchUnaryNode(ast.Addition, MatchVariableNode("x"))),
+		),
+	),
+	Entry("Modulus followed by negation", "10 % !x",
+		MatchBinaryNode(ast.Modulus, MatchNumericNode(10), MatchUnaryNode(ast.Not, MatchVariableNode("x"))),
+	),
+)
+
+var _ = DescribeTable("Implicit multiplication",
+	func(expression string, precedence parser.TokenPrecedence, nodeMatcher types.GomegaMatcher) {
+		priorities := parser.DefaultTokenPriorities()
+		if precedence > 0 {
+			priorities[lexer.ImplicitMultiplication] = parser.TokenMeta{Precedence: precedence}
+		}
+		p, err := shuntyard.NewParser(priorities)
+		Expect(err).To(Succeed())
+		p.(parser.ImplicitMultiplicationParser).SetImplicitMultiplication(parser.ImplicitMultiplication{
+			Enabled:    true,
+			IsFunction: func(name string) bool { return name == "sin" },
+		})
+		input, err := lexer.NewLexer(expression).Tokenize()
+		Expect(err).To(Succeed())
+
+		rootNode, err := p.Parse(input)
+		Expect(err).To(Succeed())
+		Expect(rootNode).To(nodeMatcher)
+		Expect(rootNode).To(SurviveJSONRoundTrip())
+	},
+	Entry("Number and identifier", "2x", parser.TokenPrecedence(0),
+		MatchImplicitMultiplication(MatchNumericNode(2), MatchVariableNode("x")),
+	),
+	Entry("Number and parenthesis", "3(a + b)", parser.TokenPrecedence(0),
+		MatchImplicitMultiplication(
+			MatchNumericNode(3),
+			MatchBinaryNode(ast.Addition, MatchVariableNode("a"), MatchVariableNode("b")),
+		),
+	),
+	Entry("Parentheses", "(a)(b)", parser.TokenPrecedence(0),
+		MatchImplicitMultiplication(MatchVariableNode("a"), MatchVariableNode("b")),
+	),
+	Entry("Identifier and parenthesis", "x(y + 1)", parser.TokenPrecedence(0),
+		MatchImplicitMultiplication(
+			MatchVariableNode("x"),
+			MatchBinaryNode(ast.Addition, MatchVariableNode("y"), MatchNumericNode(1)),
+		),
+	),
+	Entry("Known function is not multiplied", "2 sin(x)", parser.TokenPrecedence(0),
+		MatchImplicitMultiplication(MatchNumericNode(2), MatchFunctionNode("sin", MatchVariableNode("x"))),
+	),
+	Entry("Same precedence as multiplication", "1 / 2x", parser.TokenPrecedence(0),
+		MatchImplicitMultiplication(
+			MatchBinaryNode(ast.Division, MatchNumericNode(1), MatchNumericNode(2)),
+			MatchVariableNode("x"),
+		),
+	),
+	Entry("Higher precedence than multiplication", "1 / 2x", parser.TokenPrecedence(50),
+		MatchBinaryNode(
+			ast.Division,
+			MatchNumericNode(1),
+			MatchImplicitMultiplication(MatchNumericNode(2), MatchVariableNode("x")),
+		),
+	),
+	Entry("Written multiplication is not marked", "2 * x", parser.TokenPrecedence(0),
+		MatchBinaryNode(ast.Multiplication, MatchNumericNode(2), MatchVariableNode("x")),
+	),
+)